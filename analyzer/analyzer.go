@@ -3,6 +3,7 @@ package analyzer
 import (
 	"math"
 	"sort"
+	"stock/calendar"
 	"stock/common/types"
 	"time"
 )
@@ -10,25 +11,43 @@ import (
 type Analyzer struct {
 	trades      []types.Trade
 	initialCash float64
+	calendar    *calendar.Calendar
 }
 
 func NewAnalyzer(trades []types.Trade, initialCash float64) *Analyzer {
 	return &Analyzer{
 		trades:      trades,
 		initialCash: initialCash,
+		calendar:    calendar.Default(),
 	}
 }
 
+// SetCalendar 设置交易日历，年化和回撤持续时间按交易日计算，默认使用calendar.Default()
+func (a *Analyzer) SetCalendar(cal *calendar.Calendar) {
+	if cal == nil {
+		cal = calendar.Default()
+	}
+	a.calendar = cal
+}
+
+// periodsPerYear 每年交易日数
+func (a *Analyzer) periodsPerYear() float64 {
+	return a.calendar.TradingDaysPerYear()
+}
+
 // 计算总收益率
 func (a *Analyzer) TotalReturn(finalValue float64) float64 {
 	return (finalValue - a.initialCash) / a.initialCash
 }
 
-// 计算年化收益率
-func (a *Analyzer) AnnualizedReturn(finalValue float64, duration time.Duration) float64 {
-	totalReturn := a.TotalReturn(finalValue)
-	years := duration.Hours() / (24 * 365)
-	return math.Pow(1+totalReturn, 1/years) - 1
+// 按区间内交易日数计算年化收益率
+func (a *Analyzer) AnnualizedReturn(finalValue float64, start, end time.Time) float64 {
+	days := a.calendar.TradingDaysBetween(start, end)
+	if days == 0 {
+		return 0
+	}
+	years := float64(days) / a.periodsPerYear()
+	return math.Pow(1+a.TotalReturn(finalValue), 1/years) - 1
 }
 
 // 计算最大回撤
@@ -139,7 +158,7 @@ func (a *Analyzer) ProfitLossRatio() float64 {
 // 计算年波动率
 func (a *Analyzer) AnnualVolatility(returns []float64) float64 {
 	stdDev := a.StandardDeviation(returns)
-	return stdDev * math.Sqrt(252) // 252个交易日
+	return stdDev * math.Sqrt(a.periodsPerYear())
 }
 
 // 计算索提诺比率
@@ -157,38 +176,40 @@ func (a *Analyzer) SortinoRatio(returns []float64, targetReturn float64) float64
 	return (a.Mean(returns) - targetReturn) / downsideDev
 }
 
-// 计算卡尔玛比率
-func (a *Analyzer) CalmarRatio(finalValue float64, maxDrawdown float64, duration time.Duration) float64 {
+// 按交易日区间计算卡尔玛比率
+func (a *Analyzer) CalmarRatio(finalValue float64, maxDrawdown float64, start, end time.Time) float64 {
 	if maxDrawdown == 0 {
 		return 0
 	}
-	annualizedReturn := a.AnnualizedReturn(finalValue, duration)
-	return annualizedReturn / maxDrawdown
+	return a.AnnualizedReturn(finalValue, start, end) / maxDrawdown
 }
 
-// 计算回撤持续时间
-func (a *Analyzer) DrawdownDuration(values []float64) time.Duration {
-	if len(values) == 0 {
-		return 0
+// 计算最长回撤区间，返回回撤开始（前高）时间、恢复或结束时间及持续交易日数
+func (a *Analyzer) DrawdownPeriod(values []float64, timestamps []time.Time) (time.Time, time.Time, int) {
+	if len(values) == 0 || len(values) != len(timestamps) {
+		return time.Time{}, time.Time{}, 0
 	}
 
-	var maxDuration time.Duration
-	var currentDuration time.Duration
+	var maxStart, maxEnd time.Time
+	maxDays := 0
 	peak := values[0]
+	peakIndex := 0
 
 	for i := 1; i < len(values); i++ {
-		if values[i] > peak {
+		if values[i] >= peak {
 			peak = values[i]
-			currentDuration = 0
-		} else {
-			currentDuration += time.Duration(1) * time.Hour * 24 // 假设每天一个数据点
+			peakIndex = i
+			continue
 		}
-		if currentDuration > maxDuration {
-			maxDuration = currentDuration
+		days := a.calendar.TradingDaysBetween(timestamps[peakIndex], timestamps[i])
+		if days > maxDays {
+			maxDays = days
+			maxStart = timestamps[peakIndex]
+			maxEnd = timestamps[i]
 		}
 	}
 
-	return maxDuration
+	return maxStart, maxEnd, maxDays
 }
 
 // 计算风险价值 (VaR)
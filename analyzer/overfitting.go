@@ -0,0 +1,266 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// eulerGamma 欧拉-马歇罗尼常数
+const eulerGamma = 0.5772156649015329
+
+// normCDF 标准正态分布函数
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// normInv 标准正态分布的分位数函数
+func normInv(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+// 计算偏度
+func (a *Analyzer) Skewness(returns []float64) float64 {
+	std := a.StandardDeviation(returns)
+	if std == 0 {
+		return 0
+	}
+	mean := a.Mean(returns)
+	sum := 0.0
+	for _, r := range returns {
+		sum += math.Pow((r-mean)/std, 3)
+	}
+	return sum / float64(len(returns))
+}
+
+// 计算峰度（正态分布为3）
+func (a *Analyzer) Kurtosis(returns []float64) float64 {
+	std := a.StandardDeviation(returns)
+	if std == 0 {
+		return 3
+	}
+	mean := a.Mean(returns)
+	sum := 0.0
+	for _, r := range returns {
+		sum += math.Pow((r-mean)/std, 4)
+	}
+	return sum / float64(len(returns))
+}
+
+// 计算概率夏普比率(PSR)：考虑偏度和峰度后，真实夏普比率超过benchmark的概率
+// benchmark与收益率同周期（不年化），收益率左偏或厚尾时PSR降低
+func (a *Analyzer) ProbabilisticSharpeRatio(returns []float64, benchmark float64) float64 {
+	n := float64(len(returns))
+	if n < 2 {
+		return math.NaN()
+	}
+	sr := a.SharpeRatio(returns, 0)
+	if math.IsNaN(sr) || math.IsInf(sr, 0) {
+		return math.NaN()
+	}
+	return probabilisticSharpe(sr, benchmark, a.Skewness(returns), a.Kurtosis(returns), n)
+}
+
+// probabilisticSharpe 由夏普比率、偏度、峰度和样本数n计算PSR
+func probabilisticSharpe(sr, benchmark, skewness, kurtosis, n float64) float64 {
+	denominator := 1 - skewness*sr + (kurtosis-1)/4*sr*sr
+	if denominator <= 0 {
+		return math.NaN()
+	}
+	return normCDF((sr - benchmark) * math.Sqrt(n-1) / math.Sqrt(denominator))
+}
+
+// 计算N次独立试验中最大夏普比率的期望值，sharpeVariance为各次试验夏普比率的方差
+func (a *Analyzer) ExpectedMaxSharpe(trials int, sharpeVariance float64) float64 {
+	if trials < 2 || sharpeVariance <= 0 {
+		return 0
+	}
+	n := float64(trials)
+	return math.Sqrt(sharpeVariance) * ((1-eulerGamma)*normInv(1-1/n) + eulerGamma*normInv(1-1/(n*math.E)))
+}
+
+// 计算紧缩夏普比率(DSR)：以trials次试验中最大夏普比率的期望值为基准的PSR，
+// 用于评估参数寻优后选出的最优策略，sharpeVariance为各次试验（不年化）夏普比率的方差
+func (a *Analyzer) DeflatedSharpeRatio(returns []float64, trials int, sharpeVariance float64) float64 {
+	return a.ProbabilisticSharpeRatio(returns, a.ExpectedMaxSharpe(trials, sharpeVariance))
+}
+
+// 从多组试验的收益率中选出夏普比率最高的一组，返回其序号、夏普比率和紧缩夏普比率
+func (a *Analyzer) DeflatedSharpeOfBest(returns [][]float64) (best int, sharpe, deflated float64) {
+	if len(returns) == 0 {
+		return -1, math.NaN(), math.NaN()
+	}
+	// 收益率恒定（如从未交易）的试验夏普比率记为0
+	sharpes := make([]float64, len(returns))
+	for i, series := range returns {
+		if sr := a.SharpeRatio(series, 0); !math.IsNaN(sr) && !math.IsInf(sr, 0) {
+			sharpes[i] = sr
+		}
+		if sharpes[i] > sharpes[best] {
+			best = i
+		}
+	}
+	variance := 0.0
+	mean := a.Mean(sharpes)
+	for _, s := range sharpes {
+		variance += (s - mean) * (s - mean)
+	}
+	if len(sharpes) > 1 {
+		variance /= float64(len(sharpes) - 1)
+	}
+	return best, sharpes[best], a.DeflatedSharpeRatio(returns[best], len(returns), variance)
+}
+
+// PBOResult 组合对称交叉验证(CSCV)的结果
+type PBOResult struct {
+	PBO          float64   // 回测过拟合概率：样本内最优策略在样本外排名低于中位数的比例
+	Logits       []float64 // 每种划分下样本内最优策略样本外相对排名的logit
+	ISSharpe     []float64 // 每种划分下样本内最优策略的样本内夏普比率
+	OOSSharpe    []float64 // 对应的样本外夏普比率
+	Slope        float64   // 样本外对样本内夏普比率的回归斜率，衡量绩效衰减
+	Intercept    float64
+	ProbOOSLoss  float64 // 样本内最优策略样本外夏普比率为负的比例
+	Combinations int
+}
+
+// 计算回测过拟合概率(PBO)：returns[i]为第i组参数的收益率序列，按时间等分为partitions段（偶数），
+// 遍历所有一半分段作为样本内、其余作为样本外的组合，统计样本内最优参数在样本外的相对排名
+func (a *Analyzer) ProbabilityOfBacktestOverfitting(returns [][]float64, partitions int) (*PBOResult, error) {
+	trials := len(returns)
+	if trials < 2 {
+		return nil, fmt.Errorf("至少需要2组试验，实际%d组", trials)
+	}
+	if partitions < 2 || partitions%2 != 0 {
+		return nil, fmt.Errorf("分段数必须为不小于2的偶数，实际%d", partitions)
+	}
+	length := len(returns[0])
+	for _, series := range returns {
+		if len(series) < length {
+			length = len(series)
+		}
+	}
+	size := length / partitions
+	if size < 2 {
+		return nil, fmt.Errorf("收益率长度%d不足以分为%d段", length, partitions)
+	}
+
+	// 预先计算每段的和与平方和，任意分段组合的夏普比率可以由这些汇总量得到
+	sums := make([][]float64, trials)
+	squares := make([][]float64, trials)
+	for i, series := range returns {
+		sums[i] = make([]float64, partitions)
+		squares[i] = make([]float64, partitions)
+		for s := 0; s < partitions; s++ {
+			for _, r := range series[s*size : (s+1)*size] {
+				sums[i][s] += r
+				squares[i][s] += r * r
+			}
+		}
+	}
+	sharpe := func(trial int, blocks []bool, inSample bool) float64 {
+		sum, square, n := 0.0, 0.0, 0.0
+		for s, in := range blocks {
+			if in == inSample {
+				sum += sums[trial][s]
+				square += squares[trial][s]
+				n += float64(size)
+			}
+		}
+		mean := sum / n
+		variance := square/n - mean*mean
+		if variance <= 0 {
+			return 0
+		}
+		return mean / math.Sqrt(variance)
+	}
+
+	result := &PBOResult{}
+	blocks := make([]bool, partitions)
+	is := make([]float64, trials)
+	oos := make([]float64, trials)
+	var visit func(start, chosen int)
+	visit = func(start, chosen int) {
+		if chosen == partitions/2 {
+			best := 0
+			for i := 0; i < trials; i++ {
+				is[i] = sharpe(i, blocks, true)
+				oos[i] = sharpe(i, blocks, false)
+				if is[i] > is[best] {
+					best = i
+				}
+			}
+			// 样本外相对排名，并列取平均名次
+			below, equal := 0, 0
+			for i := 0; i < trials; i++ {
+				switch {
+				case oos[i] < oos[best]:
+					below++
+				case oos[i] == oos[best]:
+					equal++
+				}
+			}
+			rank := float64(below) + float64(equal+1)/2
+			omega := rank / float64(trials+1)
+			result.Logits = append(result.Logits, math.Log(omega/(1-omega)))
+			result.ISSharpe = append(result.ISSharpe, is[best])
+			result.OOSSharpe = append(result.OOSSharpe, oos[best])
+			return
+		}
+		for s := start; s <= partitions-(partitions/2-chosen); s++ {
+			blocks[s] = true
+			visit(s+1, chosen+1)
+			blocks[s] = false
+		}
+	}
+	visit(0, 0)
+
+	result.Combinations = len(result.Logits)
+	overfit, loss := 0, 0
+	for i, logit := range result.Logits {
+		if logit <= 0 {
+			overfit++
+		}
+		if result.OOSSharpe[i] < 0 {
+			loss++
+		}
+	}
+	result.PBO = float64(overfit) / float64(result.Combinations)
+	result.ProbOOSLoss = float64(loss) / float64(result.Combinations)
+	result.Slope, result.Intercept = linearFit(result.ISSharpe, result.OOSSharpe)
+	return result, nil
+}
+
+// 中位数logit，大于0说明样本内最优参数在样本外通常仍优于中位数
+func (r *PBOResult) MedianLogit() float64 {
+	if len(r.Logits) == 0 {
+		return math.NaN()
+	}
+	sorted := append([]float64(nil), r.Logits...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// linearFit 最小二乘拟合 y = slope*x + intercept
+func linearFit(x, y []float64) (slope, intercept float64) {
+	n := float64(len(x))
+	if n == 0 {
+		return 0, 0
+	}
+	var sx, sy, sxx, sxy float64
+	for i := range x {
+		sx += x[i]
+		sy += y[i]
+		sxx += x[i] * x[i]
+		sxy += x[i] * y[i]
+	}
+	denominator := n*sxx - sx*sx
+	if denominator == 0 {
+		return 0, sy / n
+	}
+	slope = (n*sxy - sx*sy) / denominator
+	return slope, (sy - slope*sx) / n
+}
@@ -0,0 +1,131 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDeflatedSharpeReference(t *testing.T) {
+	// Bailey & López de Prado (2014) 数值例子：1250个日收益率，年化夏普比率2.5，
+	// 100次试验的年化夏普比率方差0.5，偏度-3、峰度10，基准SR0≈0.1132，DSR≈0.9004
+	a := NewAnalyzer(nil, 100000)
+	sr0 := a.ExpectedMaxSharpe(100, 0.5/250)
+	if math.Abs(sr0-0.1132) > 1e-4 {
+		t.Errorf("最大夏普比率期望%v，期望0.1132", sr0)
+	}
+	if dsr := probabilisticSharpe(2.5/math.Sqrt(250), sr0, -3, 10, 1250); math.Abs(dsr-0.9004) > 1e-3 {
+		t.Errorf("DSR=%v，期望0.9004", dsr)
+	}
+	if got := a.ExpectedMaxSharpe(1, 0.002); got != 0 {
+		t.Errorf("单次试验的基准%v，期望0", got)
+	}
+}
+
+func TestProbabilisticSharpe(t *testing.T) {
+	a := NewAnalyzer(nil, 100000)
+	// 对称序列：均值0.001，偏度0
+	returns := make([]float64, 250)
+	for i := range returns {
+		returns[i] = 0.001 + 0.01*float64(i%2*2-1)
+	}
+	sr := a.SharpeRatio(returns, 0)
+	if got := a.ProbabilisticSharpeRatio(returns, sr); math.Abs(got-0.5) > 1e-12 {
+		t.Errorf("基准等于夏普比率时PSR=%v，期望0.5", got)
+	}
+	// 偏度0、峰度1时分母为 1 + 0 * SR²
+	want := normCDF(sr * math.Sqrt(249))
+	if got := a.ProbabilisticSharpeRatio(returns, 0); math.Abs(got-want) > 1e-12 {
+		t.Errorf("PSR=%v，期望%v", got, want)
+	}
+
+	// 夏普比率相同时，左偏和厚尾都降低PSR
+	normal := probabilisticSharpe(0.1, 0, 0, 3, 250)
+	if skewed := probabilisticSharpe(0.1, 0, -3, 3, 250); skewed >= normal {
+		t.Errorf("左偏PSR=%v，应低于%v", skewed, normal)
+	}
+	if fat := probabilisticSharpe(0.1, 0, 0, 10, 250); fat >= normal {
+		t.Errorf("厚尾PSR=%v，应低于%v", fat, normal)
+	}
+	if !math.IsNaN(a.ProbabilisticSharpeRatio(returns[:1], 0)) {
+		t.Error("样本不足时PSR应为NaN")
+	}
+	if got := a.DeflatedSharpeRatio(returns, 1, 0); got != a.ProbabilisticSharpeRatio(returns, 0) {
+		t.Errorf("单次试验的DSR=%v，应等于以0为基准的PSR", got)
+	}
+}
+
+func TestPBOReversal(t *testing.T) {
+	// 两组参数在前后两段表现完全相反：样本内最优在样本外总是最差
+	a := NewAnalyzer(nil, 100000)
+	returns := [][]float64{
+		{0.02, 0.01, -0.01, -0.02},
+		{-0.01, -0.02, 0.02, 0.01},
+	}
+	r, err := a.ProbabilityOfBacktestOverfitting(returns, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每段夏普比率为0.015/0.005=3，样本外排名1/3，logit=ln(1/2)
+	if r.Combinations != 2 || r.PBO != 1 || r.ProbOOSLoss != 1 {
+		t.Errorf("PBO结果%+v，期望2种划分、PBO=1", r)
+	}
+	for i := range r.Logits {
+		if math.Abs(r.Logits[i]-math.Log(0.5)) > 1e-12 || math.Abs(r.ISSharpe[i]-3) > 1e-9 || math.Abs(r.OOSSharpe[i]+3) > 1e-9 {
+			t.Errorf("第%d种划分logit=%v 样本内%v 样本外%v", i, r.Logits[i], r.ISSharpe[i], r.OOSSharpe[i])
+		}
+	}
+	if math.Abs(r.MedianLogit()-math.Log(0.5)) > 1e-12 || r.Slope != 0 || math.Abs(r.Intercept+3) > 1e-9 {
+		t.Errorf("中位数logit=%v 斜率%v 截距%v", r.MedianLogit(), r.Slope, r.Intercept)
+	}
+}
+
+func TestPBODominant(t *testing.T) {
+	// 第3组参数每段都最好：样本外排名N/(N+1)，logit=ln N，PBO为0
+	a := NewAnalyzer(nil, 100000)
+	base := []float64{0.01, -0.005, 0.008, -0.002, 0.012, -0.006, 0.004, 0.001}
+	returns := make([][]float64, 4)
+	for i := range returns {
+		returns[i] = make([]float64, 16)
+		for j := range returns[i] {
+			returns[i][j] = base[j%len(base)] + float64(i)*0.001
+		}
+	}
+	returns[2], returns[3] = returns[3], returns[2]
+	r, err := a.ProbabilityOfBacktestOverfitting(returns, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// C(4,2)=6种划分
+	if r.Combinations != 6 || r.PBO != 0 || r.ProbOOSLoss != 0 {
+		t.Errorf("PBO结果%+v，期望6种划分、PBO=0", r)
+	}
+	for i, logit := range r.Logits {
+		if math.Abs(logit-math.Log(4)) > 1e-12 {
+			t.Errorf("第%d种划分logit=%v，期望ln4", i, logit)
+		}
+	}
+
+	best, sharpe, _ := a.DeflatedSharpeOfBest(returns)
+	if best != 2 || math.Abs(sharpe-a.SharpeRatio(returns[2], 0)) > 1e-12 {
+		t.Errorf("最优试验%d 夏普比率%v，期望序号2", best, sharpe)
+	}
+}
+
+func TestPBOErrors(t *testing.T) {
+	a := NewAnalyzer(nil, 100000)
+	series := make([]float64, 16)
+	tests := []struct {
+		name       string
+		returns    [][]float64
+		partitions int
+	}{
+		{"试验不足", [][]float64{series}, 4},
+		{"分段数为奇数", [][]float64{series, series}, 3},
+		{"收益率过短", [][]float64{series, series[:6]}, 4},
+	}
+	for _, tt := range tests {
+		if _, err := a.ProbabilityOfBacktestOverfitting(tt.returns, tt.partitions); err == nil {
+			t.Errorf("%s: 应返回错误", tt.name)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package analyzer
+
+import (
+	"math"
+	"stock/common/types"
+	"time"
+)
+
+// RoundTrip 一笔完整的开平仓交易，盈亏已扣除双边费用
+type RoundTrip struct {
+	Symbol   string
+	Opened   time.Time
+	Closed   time.Time
+	Quantity float64 // 正数为多头，负数为空头
+	Cost     float64 // 开仓金额（含开仓费用）
+	PnL      float64
+}
+
+// Return 交易收益率，盈亏除以开仓金额
+func (t RoundTrip) Return() float64 {
+	if t.Cost == 0 {
+		return 0
+	}
+	return t.PnL / t.Cost
+}
+
+// lot 未平仓的一笔开仓，cash为开仓现金流（含费用和持有期间的分红）
+type lot struct {
+	quantity float64
+	cash     float64
+	cost     float64 // 开仓金额（含费用），不随分红变化
+	opened   time.Time
+}
+
+// RoundTrips 按先进先出将成交配对为开平仓交易，分红和送转股计入对应的持仓，
+// 一笔平仓对应多笔开仓时拆分为多笔交易，未平仓的部分不计入
+func RoundTrips(trades []types.Trade) []RoundTrip {
+	open := make(map[string][]lot)
+	var trips []RoundTrip
+	for _, trade := range trades {
+		lots := open[trade.Symbol]
+		switch trade.Type {
+		case types.ActionDividend:
+			// 分红按数量分摊到各笔持仓
+			total := 0.0
+			for _, l := range lots {
+				total += l.quantity
+			}
+			net := trade.Price*trade.Quantity - trade.Fee
+			for i := range lots {
+				if total != 0 {
+					lots[i].cash += net * lots[i].quantity / total
+				}
+			}
+			continue
+		case types.ActionBonus:
+			total := 0.0
+			for _, l := range lots {
+				total += l.quantity
+			}
+			for i := range lots {
+				if total != 0 {
+					lots[i].quantity *= (total + trade.Quantity) / total
+				}
+			}
+			continue
+		}
+
+		signed, cash := trade.Quantity, -(trade.Price*trade.Quantity + trade.Fee)
+		if trade.Type == types.ActionSell {
+			signed, cash = -trade.Quantity, trade.Price*trade.Quantity-trade.Fee
+		}
+		if signed == 0 {
+			continue
+		}
+		remaining := signed
+		for len(lots) > 0 && remaining != 0 && lots[0].quantity*remaining < 0 {
+			head := &lots[0]
+			closed := math.Min(math.Abs(head.quantity), math.Abs(remaining))
+			fraction := closed / math.Abs(head.quantity)
+			share := closed / math.Abs(signed)
+			quantity := closed
+			if head.quantity < 0 {
+				quantity = -closed
+			}
+			trips = append(trips, RoundTrip{
+				Symbol:   trade.Symbol,
+				Opened:   head.opened,
+				Closed:   trade.Timestamp,
+				Quantity: quantity,
+				Cost:     head.cost * fraction,
+				PnL:      head.cash*fraction + cash*share,
+			})
+			head.cash -= head.cash * fraction
+			head.cost -= head.cost * fraction
+			head.quantity -= quantity
+			if math.Abs(head.quantity) < 1e-9 {
+				lots = lots[1:]
+			}
+			if remaining > 0 {
+				remaining -= closed
+			} else {
+				remaining += closed
+			}
+		}
+		if math.Abs(remaining) > 1e-9 {
+			lots = append(lots, lot{
+				quantity: remaining,
+				cash:     cash * math.Abs(remaining) / math.Abs(signed),
+				cost:     (trade.Price*trade.Quantity + trade.Fee) * math.Abs(remaining) / math.Abs(signed),
+				opened:   trade.Timestamp,
+			})
+		}
+		open[trade.Symbol] = lots
+	}
+	return trips
+}
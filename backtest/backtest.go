@@ -1,26 +1,44 @@
 package backtest
 
 import (
+	"context"
 	"sort"
 	"stock/broker"
+	"stock/calendar"
+	"stock/clock"
 	"stock/common/types"
 	"stock/datasource"
 	"stock/orders"
 	"stock/portfolio"
+	"stock/risk"
+	"stock/schedule"
 	"stock/strategy"
+	"stock/universe"
 	"time"
 )
 
 type Backtest struct {
-	startDate   time.Time
-	endDate     time.Time
-	initialCash float64
-	dataSource  datasource.DataSource
-	strategies  []strategy.Strategy
-	portfolios  []*portfolio.Portfolio
-	broker      broker.Broker
-	logger      types.Logger
-	symbols     []string
+	startDate     time.Time
+	endDate       time.Time
+	initialCash   float64
+	dataSource    datasource.DataSource
+	strategies    []strategy.Strategy
+	portfolios    []*portfolio.Portfolio
+	orderManagers []*orders.OrderManager
+	broker        broker.Broker
+	logger        types.Logger
+	symbols       []string
+	actions       []types.CorporateAction // 按除权日排序的公司行为
+	calendar      *calendar.Calendar
+	riskConfig    *risk.Config
+	clock         *clock.SimulationClock // 按K线时间推进的模拟时钟
+	orderIDs      *clock.Sequence        // 所有策略共用的订单编号
+	tradeIDs      *clock.Sequence        // 所有策略共用的成交编号
+	timers        []*Timer
+	observers     []EventHandler
+	universe      *universe.Universe  // 为nil时所有股票始终在池内
+	fill          universe.FillPolicy // 缺失K线的填充方式
+	onProgress    func(Progress)
 }
 
 type BacktestResult struct {
@@ -37,11 +55,20 @@ type StrategyResult struct {
 	Trades      []types.Trade
 	EquityCurve []float64
 	MaxDrawdown float64
-	Returns     []float64 // 每日收益率序列
-	Values      []float64 // 每日净值序列
+	Returns     []float64    // 每日收益率序列
+	Values      []float64    // 每日净值序列
+	Timestamps  []time.Time  // 净值序列对应的交易日
+	RiskEvents  []risk.Event // 风控拒单、暂停开仓、熔断、追保和强制平仓事件
+	Halted      bool         // 是否因最大回撤熔断而停止
+	Leverage    []float64    // 每日杠杆率（多空持仓市值之和/权益）
 }
 
 func NewBacktest(startDate time.Time, endDate time.Time, initialCash float64, dataSource datasource.DataSource, broker broker.Broker, logger types.Logger, symbols []string) *Backtest {
+	simClock := clock.NewSimulationClock(startDate)
+	// 经纪商的订单和成交时间戳同样取自模拟时钟
+	if b, ok := broker.(interface{ SetClock(clock.Clock) }); ok {
+		b.SetClock(simClock)
+	}
 	return &Backtest{
 		startDate:   startDate,
 		endDate:     endDate,
@@ -50,21 +77,120 @@ func NewBacktest(startDate time.Time, endDate time.Time, initialCash float64, da
 		broker:      broker,
 		logger:      logger,
 		symbols:     symbols,
+		calendar:    calendar.Default(),
+		clock:       simClock,
+		orderIDs:    clock.NewSequence(clock.OrderIDPrefix),
+		tradeIDs:    clock.NewSequence(clock.TradeIDPrefix),
+		fill:        universe.ForwardFill(),
 	}
 }
 
+// Clock 返回回测使用的模拟时钟
+func (b *Backtest) Clock() *clock.SimulationClock {
+	return b.clock
+}
+
+// SetCalendar 设置交易日历，非交易日的数据将被忽略
+func (b *Backtest) SetCalendar(cal *calendar.Calendar) {
+	b.calendar = cal
+}
+
+// SetUniverse 设置随时间变化的股票池：不在池内的K线被忽略，退市后第一个交易日按最后收盘价清算持仓。
+// 池内登记但不在回测股票列表中的股票同样会读取数据
+func (b *Backtest) SetUniverse(u *universe.Universe) {
+	b.universe = u
+}
+
+// SetFillPolicy 设置停牌等缺失K线的填充方式，默认不限天数前值填充。
+// 组合始终按最后可得的收盘价估值，填充方式决定策略在截面中看到的数据
+func (b *Backtest) SetFillPolicy(fill universe.FillPolicy) {
+	b.fill = fill
+}
+
+// universeSymbols 回测股票列表加上股票池中登记的其他股票
+func (b *Backtest) universeSymbols() []string {
+	symbols := append([]string(nil), b.symbols...)
+	seen := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		seen[symbol] = true
+	}
+	for _, symbol := range b.universe.Symbols() {
+		if !seen[symbol] {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+// SetProgressHandler 设置进度回调，每个策略处理完一个交易日后调用
+func (b *Backtest) SetProgressHandler(handler func(Progress)) {
+	b.onProgress = handler
+}
+
+// SetRiskConfig 为每个策略的组合启用风控检查
+func (b *Backtest) SetRiskConfig(config risk.Config) {
+	b.riskConfig = &config
+}
+
 func (b *Backtest) AddStrategy(strategy strategy.Strategy) {
 	b.strategies = append(b.strategies, strategy)
-	portfolio := portfolio.NewPortfolio(b.initialCash, b.broker, orders.NewOrderManager(b.broker))
+	orderManager := orders.NewOrderManager(b.broker)
+	orderManager.SetClock(b.clock)
+	orderManager.SetIDSequence(b.orderIDs)
+	portfolio := portfolio.NewPortfolio(b.initialCash, b.broker, orderManager)
+	portfolio.SetTradeIDSequence(b.tradeIDs)
 	b.portfolios = append(b.portfolios, portfolio)
+	b.orderManagers = append(b.orderManagers, orderManager)
+}
+
+// AddTimer 添加定时任务，每个交易日when到期时在行情之后对每个策略的组合调用fn，when为nil时每日触发
+func (b *Backtest) AddTimer(name string, when schedule.Schedule, fn func(t time.Time, portfolio *portfolio.Portfolio) error) {
+	b.timers = append(b.timers, &Timer{Name: name, Schedule: when, Func: fn})
+}
+
+// AddObserver 添加事件观察者，按注册顺序在策略之后收到全部事件
+func (b *Backtest) AddObserver(observer EventHandler) {
+	b.observers = append(b.observers, observer)
+}
+
+// SetCorporateActions 设置公司行为事件，回测时在除权除息日计入持仓
+func (b *Backtest) SetCorporateActions(actions []types.CorporateAction) {
+	b.actions = make([]types.CorporateAction, len(actions))
+	copy(b.actions, actions)
+	sort.SliceStable(b.actions, func(i, j int) bool {
+		return b.actions[i].ExDate.Before(b.actions[j].ExDate)
+	})
 }
 
 func (b *Backtest) Run() (*BacktestResult, error) {
+	return b.RunContext(context.Background())
+}
+
+// RunContext 运行回测，处理每个事件前检查ctx，取消或超时后返回包装了ctx.Err()的错误
+func (b *Backtest) RunContext(ctx context.Context) (*BacktestResult, error) {
 	if len(b.strategies) == 0 {
 		return nil, types.ErrNoStrategy
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	logRiskEvent, _ := b.logger.(interface{ LogRiskEvent(risk.Event) })
+
+	// 每个组合使用独立的风控管理器
+	managers := make([]*risk.Manager, len(b.strategies))
+	if b.riskConfig != nil {
+		for index := range b.strategies {
+			managers[index] = risk.NewManager(*b.riskConfig)
+			if logRiskEvent != nil {
+				managers[index].SetEventHandler(logRiskEvent.LogRiskEvent)
+			}
+			b.portfolios[index].SetRiskChecker(managers[index])
+		}
+	}
 
 	// Initialize strategies
+	b.clock.Set(b.startDate)
 	for index, strategy := range b.strategies {
 		err := strategy.OnStart(b.portfolios[index])
 		if err != nil {
@@ -72,62 +198,97 @@ func (b *Backtest) Run() (*BacktestResult, error) {
 		}
 	}
 
-	// Main backtest loop
-	equityCurves := make([][]float64, len(b.strategies))
-
 	// Get data for all symbols
-	allData := make([]*types.DataPoint, 0)
-	for _, symbol := range b.symbols {
-		data, err := b.dataSource.GetData(symbol, datasource.PeriodTypeDay, b.startDate, b.endDate)
+	symbols := b.universeSymbols()
+	data := make(map[string][]*types.DataPoint, len(symbols))
+	tradingDays := make(map[time.Time]bool)
+	for _, symbol := range symbols {
+		points, err := b.dataSource.GetData(symbol, datasource.PeriodTypeDay, b.startDate, b.endDate)
 		if err != nil {
 			return nil, err
 		}
-		allData = append(allData, data...)
+		data[symbol] = points
+		for _, d := range points {
+			if b.universe.Contains(symbol, d.Timestamp) {
+				tradingDays[d.Timestamp] = true
+			}
+		}
 	}
 
-	for index, strategy := range b.strategies {
-		// Group data points by timestamp
-		dataByTimestamp := make(map[time.Time][]*types.DataPoint)
-		for _, d := range allData {
-			dataByTimestamp[d.Timestamp] = append(dataByTimestamp[d.Timestamp], d)
-		}
-
-		// Sort timestamps and process data points in order
-		sortedTimestamps := make([]time.Time, 0, len(dataByTimestamp))
-		for timestamp := range dataByTimestamp {
-			sortedTimestamps = append(sortedTimestamps, timestamp)
-		}
-		sort.Slice(sortedTimestamps, func(i, j int) bool {
-			return sortedTimestamps[i].Before(sortedTimestamps[j])
-		})
-
-		for _, timestamp := range sortedTimestamps {
-			dataPoints := dataByTimestamp[timestamp]
-			err := strategy.OnData(dataPoints, b.portfolios[index])
-			if err != nil {
-				return nil, err
+	// 需要完整序列的策略在回测开始前一次性计算
+	for _, s := range b.strategies {
+		if prepared, ok := s.(strategy.SeriesStrategy); ok {
+			for _, symbol := range symbols {
+				if err := prepared.Prepare(symbol, data[symbol]); err != nil {
+					return nil, err
+				}
 			}
-			// Record daily portfolio value
-			equityCurves[index] = append(equityCurves[index], b.portfolios[index].GetValue())
 		}
 	}
 
-	// Finalize strategies
+	// Sort timestamps and process data points in order
+	sortedTimestamps := make([]time.Time, 0, len(tradingDays))
+	for timestamp := range tradingDays {
+		if b.calendar != nil && !b.calendar.IsTradingDay(timestamp) {
+			continue
+		}
+		sortedTimestamps = append(sortedTimestamps, timestamp)
+	}
+	sort.Slice(sortedTimestamps, func(i, j int) bool {
+		return sortedTimestamps[i].Before(sortedTimestamps[j])
+	})
+	// 按交易日对齐为截面，区分停牌缺失和不在股票池内
+	panels := universe.Align(symbols, data, sortedTimestamps, b.universe, b.fill)
+
+	// 各策略依次运行自己的事件队列
+	timers := b.dueTimers(panels)
+	runs := make([]*strategyRun, len(b.strategies))
+	for index := range b.strategies {
+		var handler func(risk.Event)
+		if logRiskEvent != nil {
+			handler = logRiskEvent.LogRiskEvent
+		}
+		runs[index] = b.newRun(ctx, index, managers[index], handler)
+		runs[index].progress = Progress{
+			Strategy: index,
+			Name:     b.strategies[index].Name(),
+			Bars:     len(panels),
+			Done:     index * len(panels),
+			Total:    len(b.strategies) * len(panels),
+		}
+		runs[index].schedule(panels, timers)
+		if err := runs[index].drain(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Finalize strategies，只通过SetUniverse指定股票时b.symbols为空，取股票池中的第一只股票
+	endSymbol := ""
+	if len(symbols) > 0 {
+		endSymbol = symbols[0]
+	}
 	for index, strategy := range b.strategies {
+		if timestamps := runs[index].timestamps; len(timestamps) > 0 {
+			b.clock.Set(timestamps[len(timestamps)-1])
+		}
 		b.logger.LogEnd(b.portfolios[index])
-		err := strategy.OnEnd(b.portfolios[index], b.symbols[0])
+		err := strategy.OnEnd(b.portfolios[index], endSymbol)
 		if err != nil {
 			return nil, err
 		}
+		// 分发结束时平仓产生的订单事件
+		if err := runs[index].drain(); err != nil {
+			return nil, err
+		}
 	}
 
 	// Calculate results
 	results := make([]StrategyResult, len(b.strategies))
-	for i := range b.strategies {
+	for i, run := range runs {
 		// 计算每日收益率
-		returns := make([]float64, len(equityCurves[i]))
-		for j := 1; j < len(equityCurves[i]); j++ {
-			returns[j] = (equityCurves[i][j] - equityCurves[i][j-1]) / equityCurves[i][j-1]
+		returns := make([]float64, len(run.equity))
+		for j := 1; j < len(run.equity); j++ {
+			returns[j] = (run.equity[j] - run.equity[j-1]) / run.equity[j-1]
 		}
 
 		results[i] = StrategyResult{
@@ -135,11 +296,18 @@ func (b *Backtest) Run() (*BacktestResult, error) {
 			Portfolio:   b.portfolios[i],
 			FinalValue:  b.portfolios[i].GetValue(),
 			Trades:      b.portfolios[i].Transactions(),
-			EquityCurve: equityCurves[i],
-			MaxDrawdown: calculateMaxDrawdown(equityCurves[i]),
+			EquityCurve: run.equity,
+			MaxDrawdown: calculateMaxDrawdown(run.equity),
 			Returns:     returns,
-			Values:      equityCurves[i],
+			Values:      run.equity,
+			Timestamps:  run.timestamps,
+			Leverage:    run.leverage,
+		}
+		if managers[i] != nil {
+			results[i].RiskEvents = managers[i].Events()
+			results[i].Halted = managers[i].Killed()
 		}
+		results[i].RiskEvents = append(results[i].RiskEvents, run.marginEvents...)
 	}
 
 	return &BacktestResult{
@@ -25,16 +25,10 @@ func PreprocessData(symbol string, data []types.Bar) []*types.DataPoint {
 		return nil
 	}
 
-	// 对齐指标长度
-	start := len(data) - len(ma5)
-	if len(macdValues) < len(ma5) {
-		start = len(data) - len(macdValues)
-	}
-
-	// 创建数据点
-	points := make([]*types.DataPoint, len(data)-start)
-	for i := start; i < len(data); i++ {
-		points[i-start] = &types.DataPoint{
+	// 指标与输入等长，预热期为NaN
+	points := make([]*types.DataPoint, len(data))
+	for i := range data {
+		points[i] = &types.DataPoint{
 			Symbol:    symbol,
 			Timestamp: time.Unix(data[i].Time, 0),
 			Open:      data[i].Open,
@@ -43,10 +37,10 @@ func PreprocessData(symbol string, data []types.Bar) []*types.DataPoint {
 			Close:     data[i].Close,
 			Volume:    data[i].Volume,
 			Indicators: map[string]float64{
-				"MA5":           ma5[i-start],
-				"MACD":          macdValues[i-start].MACD,
-				"Signal":        macdValues[i-start].Signal,
-				"MACDHistogram": macdValues[i-start].Histogram,
+				"MA5":           ma5[i],
+				"MACD":          macdValues[i].MACD,
+				"Signal":        macdValues[i].Signal,
+				"MACDHistogram": macdValues[i].Histogram,
 			},
 		}
 	}
@@ -0,0 +1,34 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+
+	"stock/common/types"
+)
+
+func TestPreprocessDataAligned(t *testing.T) {
+	bars := make([]types.Bar, 40)
+	for i := range bars {
+		c := 10 + float64(i%7)*0.1
+		bars[i] = types.Bar{Time: int64(i) * 86400, Open: c, High: c, Low: c, Close: c}
+	}
+	points := PreprocessData("600000.SH", bars)
+	if len(points) != len(bars) {
+		t.Fatalf("数据点%d个，期望与输入等长%d个", len(points), len(bars))
+	}
+	for i, p := range points {
+		if p.Close != bars[i].Close {
+			t.Fatalf("第%d个数据点收盘价%v，期望%v", i, p.Close, bars[i].Close)
+		}
+	}
+	if !math.IsNaN(points[3].Indicators["MA5"]) || math.IsNaN(points[4].Indicators["MA5"]) {
+		t.Errorf("MA5预热期应为前4个数据点: %v %v", points[3].Indicators["MA5"], points[4].Indicators["MA5"])
+	}
+	if want := (bars[0].Close + bars[1].Close + bars[2].Close + bars[3].Close + bars[4].Close) / 5; math.Abs(points[4].Indicators["MA5"]-want) > 1e-9 {
+		t.Errorf("MA5=%v，期望%v", points[4].Indicators["MA5"], want)
+	}
+	if len(PreprocessData("600000.SH", bars[:25])) != 0 {
+		t.Error("不足26个数据点时应返回空")
+	}
+}
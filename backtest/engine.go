@@ -0,0 +1,221 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"stock/common/types"
+	"stock/portfolio"
+	"stock/risk"
+	"stock/strategy"
+	"stock/universe"
+)
+
+// strategyRun 单个策略的事件循环，每个策略使用独立的组合和事件队列
+type strategyRun struct {
+	ctx          context.Context
+	backtest     *Backtest
+	index        int
+	strategy     strategy.Strategy
+	portfolio    *portfolio.Portfolio
+	manager      *risk.Manager
+	logRiskEvent func(risk.Event)
+	queue        *EventQueue
+	halted       bool // 触发最大回撤熔断后不再调用策略
+	flattening   bool // 熔断后仍有因涨跌停或T+1未能平掉的持仓，每日收盘重试
+	progress     Progress
+
+	equity       []float64
+	timestamps   []time.Time
+	leverage     []float64
+	marginEvents []risk.Event
+}
+
+func (b *Backtest) newRun(ctx context.Context, index int, manager *risk.Manager, logRiskEvent func(risk.Event)) *strategyRun {
+	run := &strategyRun{
+		ctx:          ctx,
+		backtest:     b,
+		index:        index,
+		strategy:     b.strategies[index],
+		portfolio:    b.portfolios[index],
+		manager:      manager,
+		logRiskEvent: logRiskEvent,
+		queue:        NewEventQueue(),
+	}
+	// 组合下单是同步成交的，订单状态变化作为事件入队，在触发它的事件之后分发
+	b.orderManagers[index].SetEventHandler(func(order *types.Order) {
+		snapshot := *order
+		run.queue.Push(&Event{
+			Type:     orderEventType(order.Status),
+			Time:     b.clock.Now(),
+			Strategy: index,
+			Order:    &snapshot,
+		})
+	})
+	return run
+}
+
+// hasPositions 组合是否还有未平的持仓
+func hasPositions(p *portfolio.Portfolio) bool {
+	for _, qty := range p.Positions() {
+		if qty != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func orderEventType(status types.OrderStatus) EventType {
+	switch status {
+	case types.OrderStatusFilled:
+		return EventOrderFilled
+	case types.OrderStatusCanceled, types.OrderStatusRejected:
+		return EventOrderCancelled
+	default:
+		return EventOrderSubmitted
+	}
+}
+
+// dueTimers 计算每个交易日到期的定时任务，有状态的时间表（如EveryNDays）只按交易日推进一次，
+// 结果由所有策略共用
+func (b *Backtest) dueTimers(panels []*universe.Panel) [][]*Timer {
+	due := make([][]*Timer, len(panels))
+	for i, panel := range panels {
+		for _, timer := range b.timers {
+			if timer.Schedule == nil || timer.Schedule.Due(panel.Time) {
+				due[i] = append(due[i], timer)
+			}
+		}
+	}
+	return due
+}
+
+// schedule 为每个交易日生成开盘、公司行为、退市、行情、定时任务和收盘事件，timers为每个交易日到期的定时任务
+func (r *strategyRun) schedule(panels []*universe.Panel, timers [][]*Timer) {
+	actions := r.backtest.actions
+	actionIndex := 0
+	delistings := r.backtest.universe.Delistings()
+	delistIndex := 0
+	for i, panel := range panels {
+		timestamp := panel.Time
+		r.push(&Event{Type: EventSessionOpen, Time: timestamp})
+		// 截至当前交易日的除权除息事件
+		for actionIndex < len(actions) && !actions[actionIndex].ExDate.After(timestamp) {
+			r.push(&Event{Type: EventCorporateAction, Time: timestamp, Action: &actions[actionIndex]})
+			actionIndex++
+		}
+		// 最后交易日之后的第一个交易日清算退市股票的持仓
+		for delistIndex < len(delistings) && delistings[delistIndex].Delisted(timestamp) {
+			r.push(&Event{Type: EventDelisting, Time: timestamp, Symbol: delistings[delistIndex].Symbol})
+			delistIndex++
+		}
+		r.push(&Event{Type: EventMarketData, Time: timestamp, Data: panel.Traded(), Panel: panel})
+		for _, timer := range timers[i] {
+			r.push(&Event{Type: EventTimer, Time: timestamp, Timer: timer})
+		}
+		r.push(&Event{Type: EventSessionClose, Time: timestamp})
+	}
+}
+
+func (r *strategyRun) push(event *Event) {
+	event.Strategy = r.index
+	r.queue.Push(event)
+}
+
+// drain 依次处理队列中的全部事件，每个事件之前检查ctx，取消后立即中止
+func (r *strategyRun) drain() error {
+	for event := r.queue.Pop(); event != nil; event = r.queue.Pop() {
+		if err := r.ctx.Err(); err != nil {
+			return fmt.Errorf("回测在%s中止: %w", event.Time.Format("2006-01-02"), err)
+		}
+		if err := r.dispatch(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dispatch 先由引擎更新经纪商和组合，再通知策略和观察者
+func (r *strategyRun) dispatch(event *Event) error {
+	if err := r.handle(event); err != nil {
+		return err
+	}
+	if handler, ok := r.strategy.(EventHandler); ok && !r.halted {
+		if err := handler.OnEvent(event, r.portfolio); err != nil {
+			return err
+		}
+	}
+	for _, observer := range r.backtest.observers {
+		if err := observer.OnEvent(event, r.portfolio); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *strategyRun) handle(event *Event) error {
+	switch event.Type {
+	case EventSessionOpen:
+		// 当前交易日内的所有订单、成交和事件都使用K线时间
+		r.backtest.clock.Set(event.Time)
+	case EventCorporateAction:
+		return r.portfolio.ApplyCorporateAction(*event.Action)
+	case EventDelisting:
+		return r.portfolio.Delist(event.Symbol, event.Time)
+	case EventMarketData:
+		// 缺失K线按填充方式估值，未填充时沿用最后收盘价
+		for _, dp := range event.Panel.Bars {
+			if dp != nil {
+				r.portfolio.UpdatePrice(dp.Symbol, dp.Close)
+			}
+		}
+		if r.halted {
+			return nil
+		}
+		if s, ok := r.strategy.(strategy.PanelStrategy); ok {
+			return s.OnPanel(event.Panel, r.portfolio)
+		}
+		return r.strategy.OnData(event.Data, r.portfolio)
+	case EventTimer:
+		if !r.halted && event.Timer.Func != nil {
+			return event.Timer.Func(event.Time, r.portfolio)
+		}
+	case EventSessionClose:
+		return r.close(event)
+	}
+	return nil
+}
+
+// close 收盘处理：最大回撤熔断时清仓，期货逐日盯市，融资融券计息、追保和强制平仓，记录当日净值
+func (r *strategyRun) close(event *Event) error {
+	if r.manager != nil && r.manager.OnBar(event.Time, r.portfolio) {
+		r.halted = true
+		r.flattening = true
+	}
+	if r.flattening {
+		if err := r.portfolio.CloseAll(event.Time); err != nil {
+			return err
+		}
+		r.flattening = hasPositions(r.portfolio)
+	}
+	event.Risk = r.portfolio.Settle(event.Time)
+	for _, e := range event.Risk {
+		r.marginEvents = append(r.marginEvents, e)
+		if r.logRiskEvent != nil {
+			r.logRiskEvent(e)
+		}
+	}
+	r.equity = append(r.equity, r.portfolio.GetValue())
+	r.timestamps = append(r.timestamps, event.Time)
+	r.leverage = append(r.leverage, r.portfolio.Leverage())
+
+	if r.backtest.onProgress != nil {
+		r.progress.Bar++
+		r.progress.Done++
+		r.progress.Time = event.Time
+		r.progress.Equity = r.equity[len(r.equity)-1]
+		r.backtest.onProgress(r.progress)
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"stock/broker"
+	"stock/common/types"
+	"stock/datasource"
+	"stock/portfolio"
+	"stock/risk"
+	"stock/schedule"
+	"stock/universe"
+)
+
+// priceSource 每个交易日一个收盘价的内存数据源
+type priceSource struct {
+	days   []time.Time
+	prices []float64
+}
+
+func (s *priceSource) GetData(symbol string, period datasource.PeriodType, start, end time.Time) ([]*types.DataPoint, error) {
+	points := make([]*types.DataPoint, len(s.prices))
+	for i, price := range s.prices {
+		points[i] = &types.DataPoint{
+			Symbol:     symbol,
+			Timestamp:  s.days[i],
+			Open:       price,
+			High:       price,
+			Low:        price,
+			Close:      price,
+			Volume:     1e6,
+			Indicators: map[string]float64{},
+		}
+	}
+	return points, nil
+}
+
+func (s *priceSource) GetSupportedPeriods() []datasource.PeriodType {
+	return []datasource.PeriodType{datasource.PeriodTypeDay}
+}
+
+func (s *priceSource) ConvertPeriod(data []*types.DataPoint, targetPeriod datasource.PeriodType) ([]*types.DataPoint, error) {
+	return data, nil
+}
+
+type nopLogger struct{}
+
+func (nopLogger) LogData(*types.DataPoint) {}
+func (nopLogger) LogTrade(types.Trade)     {}
+func (nopLogger) LogEnd(types.Portfolio)   {}
+
+// buyOnceStrategy 第一根K线买入固定数量后不再交易
+type buyOnceStrategy struct {
+	quantity float64
+	bought   bool
+}
+
+func (s *buyOnceStrategy) OnStart(*portfolio.Portfolio) error { return nil }
+
+func (s *buyOnceStrategy) OnData(data []*types.DataPoint, p *portfolio.Portfolio) error {
+	if s.bought {
+		return nil
+	}
+	s.bought = true
+	return p.Buy(data[0].Symbol, data[0].Timestamp, data[0].Close, s.quantity)
+}
+
+func (s *buyOnceStrategy) OnEnd(*portfolio.Portfolio, string) error      { return nil }
+func (s *buyOnceStrategy) Calculate([]types.Candle) map[string][]float64 { return nil }
+func (s *buyOnceStrategy) Name() string                                  { return "buy-once" }
+
+func TestHaltRetriesFlattenAfterLimitDown(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	source := &priceSource{
+		days: []time.Time{day(2), day(3), day(4), day(5), day(8), day(9)},
+		// 1月4日跌停触发熔断，1月5日继续跌停，1月8日打开跌停
+		prices: []float64{10, 10, 9, 8.1, 8.5, 8.6},
+	}
+	symbol := "600000.SH"
+	simBroker := broker.NewSimulatedBroker(broker.NewFixedFeeCalculator(0), nopLogger{}, 100000)
+	bt := NewBacktest(day(1), day(31), 100000, source, simBroker, nopLogger{}, []string{symbol})
+	bt.SetRiskConfig(risk.Config{MaxDrawdown: 0.005})
+	bt.AddStrategy(&buyOnceStrategy{quantity: 1000})
+
+	result, err := bt.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := result.Results[0]
+	if !r.Halted {
+		t.Fatal("回撤超过上限应触发熔断")
+	}
+	if qty := r.Portfolio.PositionSize(symbol); qty != 0 {
+		t.Fatalf("熔断后剩余持仓%v，期望在跌停打开后清仓", qty)
+	}
+	if len(r.Trades) != 2 {
+		t.Fatalf("成交%d笔，期望买入和清仓各1笔", len(r.Trades))
+	}
+	sell := r.Trades[1]
+	if !sell.Timestamp.Equal(day(8)) || sell.Price != 8.5 || sell.Quantity != 1000 {
+		t.Errorf("清仓成交%s %.2f×%.0f，期望01-08 8.50×1000", sell.Timestamp.Format("01-02"), sell.Price, sell.Quantity)
+	}
+	if last := r.Values[len(r.Values)-1]; last != 98500 {
+		t.Errorf("清仓后净值%.2f，期望98500", last)
+	}
+}
+
+// cancelStrategy 收到第一根K线时取消回测，并记录取消之后处理的事件
+type cancelStrategy struct {
+	buyOnceStrategy
+	cancel   context.CancelFunc
+	canceled bool
+	after    []EventType
+}
+
+func (s *cancelStrategy) OnData(data []*types.DataPoint, p *portfolio.Portfolio) error {
+	s.cancel()
+	s.canceled = true
+	return nil
+}
+
+func (s *cancelStrategy) OnEvent(event *Event, p *portfolio.Portfolio) error {
+	if s.canceled && event.Type != EventMarketData {
+		s.after = append(s.after, event.Type)
+	}
+	return nil
+}
+
+func TestCancelStopsBetweenEvents(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	source := &priceSource{days: []time.Time{day(2), day(3)}, prices: []float64{10, 10}}
+	simBroker := broker.NewSimulatedBroker(broker.NewFixedFeeCalculator(0), nopLogger{}, 100000)
+	bt := NewBacktest(day(1), day(31), 100000, source, simBroker, nopLogger{}, []string{"600000.SH"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := &cancelStrategy{cancel: cancel}
+	bt.AddStrategy(s)
+
+	_, err := bt.RunContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("取消后应返回context.Canceled，得到%v", err)
+	}
+	if len(s.after) != 0 {
+		t.Errorf("取消后仍处理了事件%v，期望在下一个事件之前中止", s.after)
+	}
+}
+
+func TestTimerSharedAcrossStrategies(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	days := []time.Time{day(2), day(3), day(4), day(5), day(8), day(9), day(10)}
+	source := &priceSource{days: days, prices: []float64{10, 10, 10, 10, 10, 10, 10}}
+	simBroker := broker.NewSimulatedBroker(broker.NewFixedFeeCalculator(0), nopLogger{}, 100000)
+	bt := NewBacktest(day(1), day(31), 100000, source, simBroker, nopLogger{}, []string{"600000.SH"})
+	bt.AddStrategy(&buyOnceStrategy{quantity: 100})
+	bt.AddStrategy(&buyOnceStrategy{quantity: 100})
+
+	fired := make(map[*portfolio.Portfolio][]string)
+	bt.AddTimer("every3", schedule.NewEveryNDays(3), func(t time.Time, p *portfolio.Portfolio) error {
+		fired[p] = append(fired[p], t.Format("01-02"))
+		return nil
+	})
+	result, err := bt.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "01-02 01-05 01-10"
+	for i, r := range result.Results {
+		if got := strings.Join(fired[r.Portfolio], " "); got != want {
+			t.Errorf("策略%d定时任务触发于%s，期望%s", i, got, want)
+		}
+	}
+}
+
+// flipStrategy 每根K线交替买入和卖出
+type flipStrategy struct{}
+
+func (flipStrategy) OnStart(*portfolio.Portfolio) error { return nil }
+
+func (flipStrategy) OnData(data []*types.DataPoint, p *portfolio.Portfolio) error {
+	dp := data[0]
+	if qty, _ := p.GetSymbolPosition(dp.Symbol); qty > 0 {
+		return p.Sell(dp.Symbol, dp.Timestamp, dp.Close, qty)
+	}
+	return p.Buy(dp.Symbol, dp.Timestamp, dp.Close, 100)
+}
+
+func (flipStrategy) OnEnd(*portfolio.Portfolio, string) error      { return nil }
+func (flipStrategy) Calculate([]types.Candle) map[string][]float64 { return nil }
+func (flipStrategy) Name() string                                  { return "flip" }
+
+// orderRecorder 记录所有策略的订单事件
+type orderRecorder struct {
+	lines []string
+}
+
+func (r *orderRecorder) OnEvent(event *Event, p *portfolio.Portfolio) error {
+	if event.Order != nil {
+		r.lines = append(r.lines, fmt.Sprintf("%d %s %s %v %s", event.Strategy, event.Type,
+			event.Order.ID, event.Order.Status, event.Order.CreatedAt.Format(time.RFC3339)))
+	}
+	return nil
+}
+
+func TestRunUniverseOnly(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	source := &priceSource{days: []time.Time{day(2), day(3)}, prices: []float64{10, 10.5}}
+	u := universe.NewUniverse()
+	u.Add("600000.SH", day(1), time.Time{})
+	simBroker := broker.NewSimulatedBroker(broker.NewFixedFeeCalculator(0), nopLogger{}, 100000)
+	// 股票只通过SetUniverse指定
+	bt := NewBacktest(day(1), day(31), 100000, source, simBroker, nopLogger{}, nil)
+	bt.SetUniverse(u)
+	bt.AddStrategy(&buyOnceStrategy{quantity: 100})
+	result, err := bt.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(result.Results[0].Trades); n != 1 {
+		t.Errorf("成交%d笔，期望1笔", n)
+	}
+}
+
+func TestRunReproducible(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	run := func() (string, []string) {
+		source := &priceSource{
+			days:   []time.Time{day(2), day(3), day(4), day(5), day(8)},
+			prices: []float64{10, 10.2, 10.1, 10.4, 10.3},
+		}
+		simBroker := broker.NewSimulatedBroker(broker.NewFixedFeeCalculator(0.001), nopLogger{}, 100000)
+		bt := NewBacktest(day(1), day(31), 100000, source, simBroker, nopLogger{}, []string{"600000.SH"})
+		bt.AddStrategy(flipStrategy{})
+		bt.AddStrategy(flipStrategy{})
+		recorder := &orderRecorder{}
+		bt.AddObserver(recorder)
+		result, err := bt.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var trades strings.Builder
+		for i, r := range result.Results {
+			for _, trade := range r.Trades {
+				fmt.Fprintf(&trades, "%d %+v\n", i, trade)
+			}
+		}
+		return trades.String(), recorder.lines
+	}
+
+	trades1, orders1 := run()
+	trades2, orders2 := run()
+	if trades1 != trades2 {
+		t.Errorf("两次回测的成交不一致:\n%s\n%s", trades1, trades2)
+	}
+	if strings.Join(orders1, "\n") != strings.Join(orders2, "\n") {
+		t.Errorf("两次回测的订单事件不一致:\n%s\n%s", strings.Join(orders1, "\n"), strings.Join(orders2, "\n"))
+	}
+
+	// 订单编号在所有策略间唯一
+	owner := make(map[string]string)
+	for _, line := range orders1 {
+		fields := strings.Fields(line)
+		strategy, id := fields[0], fields[2]
+		if prev, ok := owner[id]; ok && prev != strategy {
+			t.Errorf("订单编号%s同时属于策略%s和策略%s", id, prev, strategy)
+		}
+		owner[id] = strategy
+	}
+	if len(owner) != 10 {
+		t.Errorf("共%d个订单编号，期望两个策略各5个", len(owner))
+	}
+	if !strings.Contains(trades1, "ID:TRD-000010") {
+		t.Errorf("成交编号应在所有策略间连续编号:\n%s", trades1)
+	}
+}
@@ -0,0 +1,141 @@
+package backtest
+
+import (
+	"container/heap"
+	"time"
+
+	"stock/common/types"
+	"stock/portfolio"
+	"stock/risk"
+	"stock/schedule"
+	"stock/universe"
+)
+
+// EventType 回测事件类型，同一时刻的事件按类型的先后顺序处理
+type EventType int
+
+const (
+	EventSessionOpen     EventType = iota // 开盘：推进模拟时钟
+	EventCorporateAction                  // 除权除息
+	EventDelisting                        // 退市：按最后收盘价了结持仓
+	EventMarketData                       // 行情：更新市价后调用策略OnData或OnPanel
+	EventOrderSubmitted                   // 订单提交
+	EventOrderFilled                      // 订单成交
+	EventOrderCancelled                   // 订单撤销或被拒绝
+	EventTimer                            // 定时任务
+	EventSessionClose                     // 收盘：风控检查、结算并记录净值
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSessionOpen:
+		return "开盘"
+	case EventCorporateAction:
+		return "公司行为"
+	case EventDelisting:
+		return "退市"
+	case EventMarketData:
+		return "行情"
+	case EventOrderSubmitted:
+		return "订单提交"
+	case EventOrderFilled:
+		return "订单成交"
+	case EventOrderCancelled:
+		return "订单撤销"
+	case EventTimer:
+		return "定时任务"
+	case EventSessionClose:
+		return "收盘"
+	default:
+		return "未知事件"
+	}
+}
+
+// Event 回测事件，按Type只填写对应的字段
+type Event struct {
+	Type     EventType
+	Time     time.Time
+	Strategy int                    // 事件所属策略的序号
+	Data     []*types.DataPoint     // EventMarketData：当日有K线的数据
+	Panel    *universe.Panel        // EventMarketData：按股票池对齐的截面数据
+	Symbol   string                 // EventDelisting
+	Action   *types.CorporateAction // EventCorporateAction
+	Order    *types.Order           // 订单事件，为事件发生时的订单快照
+	Timer    *Timer                 // EventTimer
+	Risk     []risk.Event           // EventSessionClose：当日结算产生的追保和强平事件
+	seq      uint64
+}
+
+// EventHandler 事件观察者，策略实现该接口时同样会收到事件
+//
+// 同一事件依次交给经纪商和组合（引擎内部处理）、策略、观察者，观察者按注册顺序调用
+type EventHandler interface {
+	OnEvent(event *Event, portfolio *portfolio.Portfolio) error
+}
+
+// Timer 定时任务，每个交易日Due返回true时在行情之后触发
+type Timer struct {
+	Name     string
+	Schedule schedule.Schedule
+	Func     func(t time.Time, portfolio *portfolio.Portfolio) error
+}
+
+// EventQueue 按时间、事件类型和入队顺序排列的事件优先队列
+type EventQueue struct {
+	events eventHeap
+	seq    uint64
+}
+
+// NewEventQueue 创建空的事件队列
+func NewEventQueue() *EventQueue {
+	return &EventQueue{}
+}
+
+// Push 将事件加入队列
+func (q *EventQueue) Push(event *Event) {
+	q.seq++
+	event.seq = q.seq
+	heap.Push(&q.events, event)
+}
+
+// Pop 取出最早的事件，队列为空时返回nil
+func (q *EventQueue) Pop() *Event {
+	if len(q.events) == 0 {
+		return nil
+	}
+	return heap.Pop(&q.events).(*Event)
+}
+
+// Len 返回队列中的事件数
+func (q *EventQueue) Len() int {
+	return len(q.events)
+}
+
+type eventHeap []*Event
+
+func (h eventHeap) Len() int { return len(h) }
+
+func (h eventHeap) Less(i, j int) bool {
+	if !h[i].Time.Equal(h[j].Time) {
+		return h[i].Time.Before(h[j].Time)
+	}
+	if h[i].Type != h[j].Type {
+		return h[i].Type < h[j].Type
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h eventHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *eventHeap) Push(x interface{}) {
+	*h = append(*h, x.(*Event))
+}
+
+func (h *eventHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	event := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return event
+}
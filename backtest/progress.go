@@ -0,0 +1,23 @@
+package backtest
+
+import "time"
+
+// Progress 回测进度，每个策略处理完一个交易日后报告
+type Progress struct {
+	Strategy int       // 策略序号
+	Name     string    // 策略名称
+	Bar      int       // 该策略已处理的交易日数
+	Bars     int       // 每个策略的交易日总数
+	Done     int       // 全部策略已处理的交易日数
+	Total    int       // 全部策略的交易日总数
+	Time     time.Time // 当前交易日
+	Equity   float64   // 收盘后权益
+}
+
+// Fraction 全部策略的完成比例
+func (p Progress) Fraction() float64 {
+	if p.Total == 0 {
+		return 1
+	}
+	return float64(p.Done) / float64(p.Total)
+}
@@ -1,10 +1,11 @@
 package broker
 
 import (
-	"fmt"
-	"time"
+	"sort"
 
+	"stock/clock"
 	"stock/common/types"
+	"stock/instrument"
 )
 
 // Observer 观测器接口
@@ -42,6 +43,8 @@ type Broker interface {
 	UpdatePosition(symbol string, price float64, quantity float64, action types.Action) error
 	// 获取观测器
 	GetObserver() Observer
+	// 公司行为入账（现金分红、送转股）
+	ApplyCorporateAction(symbol string, cash float64, shares float64) error
 }
 
 // FeeCalculator 定义交易费用计算接口
@@ -63,6 +66,37 @@ func (f *FixedFeeCalculator) Calculate(action types.Action, price float64, quant
 	return price * quantity * f.feeRate
 }
 
+// SymbolFeeCalculator 按品种计算费用的费用计算器
+type SymbolFeeCalculator interface {
+	CalculateSymbol(inst *instrument.Instrument, action types.Action, price float64, quantity float64) float64
+}
+
+// InstrumentFeeCalculator 按品种费率计算费用：股票含最低佣金、卖出印花税和过户费，期货按合约价值计
+type InstrumentFeeCalculator struct{}
+
+// NewInstrumentFeeCalculator 创建按品种费率计算的费用计算器
+func NewInstrumentFeeCalculator() *InstrumentFeeCalculator {
+	return &InstrumentFeeCalculator{}
+}
+
+func (c *InstrumentFeeCalculator) Calculate(action types.Action, price float64, quantity float64) float64 {
+	return c.CalculateSymbol(instrument.Stock(""), action, price, quantity)
+}
+
+func (c *InstrumentFeeCalculator) CalculateSymbol(inst *instrument.Instrument, action types.Action, price float64, quantity float64) float64 {
+	notional := inst.Notional(price, quantity)
+	fees := inst.Fees
+	commission := notional * fees.Commission
+	if commission < fees.MinFee {
+		commission = fees.MinFee
+	}
+	fee := commission + notional*fees.TransferFee
+	if action == types.ActionSell {
+		fee += notional * fees.StampDuty
+	}
+	return fee
+}
+
 // CustomFeeCalculator 自定义费用计算器
 type CustomFeeCalculator struct {
 	calcFunc func(action types.Action, price float64, quantity float64) float64
@@ -84,6 +118,12 @@ type SimulatedBroker struct {
 	orders        map[string]*types.Order
 	positions     map[string]*types.Position
 	observer      Observer
+	allowShort    bool
+	margin        *MarginConfig
+	instruments   *instrument.Registry
+	clock         clock.Clock
+	orderIDs      *clock.Sequence
+	tradeIDs      *clock.Sequence
 }
 
 func NewSimulatedBroker(feeCalculator FeeCalculator, logger types.Logger, initialCash float64) *SimulatedBroker {
@@ -99,12 +139,44 @@ func NewSimulatedBroker(feeCalculator FeeCalculator, logger types.Logger, initia
 			Balance:   initialCash,
 			Positions: make(map[string]*types.Position),
 		},
-		orders:    make(map[string]*types.Order),
-		positions: make(map[string]*types.Position),
-		observer:  NewDefaultObserver(),
+		orders:      make(map[string]*types.Order),
+		positions:   make(map[string]*types.Position),
+		observer:    NewDefaultObserver(),
+		instruments: instrument.Default(),
+		clock:       clock.WallClock{},
+		orderIDs:    clock.NewSequence(clock.OrderIDPrefix),
+		tradeIDs:    clock.NewSequence(clock.TradeIDPrefix),
 	}
 }
 
+// SetClock 设置时钟，回测时注入模拟时钟使订单和成交时间戳取自K线时间
+func (b *SimulatedBroker) SetClock(c clock.Clock) {
+	b.clock = c
+}
+
+// SetInstruments 设置品种注册表，默认使用instrument.Default()
+func (b *SimulatedBroker) SetInstruments(registry *instrument.Registry) {
+	b.instruments = registry
+}
+
+// Instruments 返回品种注册表
+func (b *SimulatedBroker) Instruments() *instrument.Registry {
+	return b.instruments
+}
+
+// SetAllowShort 设置是否允许卖空，允许后卖出数量可以超过持仓，仓位为负
+//
+// 只允许卖空而不开通融资融券（SetMargin）时，融券卖出没有保证金约束，空头规模不受资金限制，
+// 适合研究多空信号；需要模拟保证金、融券费用和强制平仓时应使用SetMargin
+func (b *SimulatedBroker) SetAllowShort(allow bool) {
+	b.allowShort = allow
+}
+
+// AllowShort 是否允许卖空，开通融资融券后允许融券卖出
+func (b *SimulatedBroker) AllowShort() bool {
+	return b.allowShort || b.margin != nil
+}
+
 func (b *SimulatedBroker) GetObserver() Observer {
 	return b.observer
 }
@@ -139,19 +211,38 @@ func (b *SimulatedBroker) UpdatePosition(symbol string, price float64, quantity
 	return nil
 }
 
+// ApplyCorporateAction 将分红现金和送转股计入账户与仓位
+func (b *SimulatedBroker) ApplyCorporateAction(symbol string, cash float64, shares float64) error {
+	b.account.Cash += cash
+	b.account.Balance += cash
+
+	if pos, exists := b.positions[symbol]; exists && shares != 0 {
+		newQuantity := pos.Quantity + shares
+		pos.AvgPrice = pos.AvgPrice * pos.Quantity / newQuantity
+		pos.Quantity = newQuantity
+		b.account.Positions[symbol] = pos
+	}
+
+	b.account.Equity = b.account.Cash
+	for _, p := range b.positions {
+		b.account.Equity += p.MarketValue
+	}
+	return nil
+}
+
 func (b *SimulatedBroker) Logger() types.Logger {
 	return b.logger
 }
 
 func (b *SimulatedBroker) CreateOrder(strategyID string, symbol string, quantity float64, orderType types.OrderType) (*types.Order, error) {
 	order := &types.Order{
-		ID:         generateOrderID(),
+		ID:         b.orderIDs.Next(),
 		StrategyID: strategyID,
 		Symbol:     symbol,
 		Quantity:   quantity,
 		Type:       orderType,
 		Status:     types.OrderStatusNew,
-		CreatedAt:  time.Now(),
+		CreatedAt:  b.clock.Now(),
 	}
 
 	b.orders[order.ID] = order
@@ -192,15 +283,16 @@ func (b *SimulatedBroker) ExecuteOrder(order *types.Order) error {
 
 	// 更新订单状态
 	order.Status = types.OrderStatusFilled
-	order.UpdatedAt = time.Now()
+	order.UpdatedAt = b.clock.Now()
 
 	// 通知观测器交易完成
 	b.observer.OnTrade(&types.Trade{
+		ID:        b.tradeIDs.Next(),
 		OrderID:   order.ID,
 		Symbol:    order.Symbol,
 		Price:     order.Price,
 		Quantity:  order.Quantity,
-		Timestamp: time.Now(),
+		Timestamp: order.UpdatedAt,
 	})
 
 	return nil
@@ -217,7 +309,7 @@ func (b *SimulatedBroker) CancelOrder(orderID string) error {
 	}
 
 	order.Status = types.OrderStatusCanceled
-	order.UpdatedAt = time.Now()
+	order.UpdatedAt = b.clock.Now()
 	return nil
 }
 
@@ -233,6 +325,14 @@ func (b *SimulatedBroker) GetOrders() ([]*types.Order, error) {
 	for _, order := range b.orders {
 		orders = append(orders, order)
 	}
+	// 订单编号的序号按创建顺序递增，按序号排序后输出顺序与下单顺序一致
+	sort.Slice(orders, func(i, j int) bool {
+		a, b := clock.SequenceNumber(orders[i].ID), clock.SequenceNumber(orders[j].ID)
+		if a != b {
+			return a < b
+		}
+		return orders[i].ID < orders[j].ID
+	})
 	return orders, nil
 }
 
@@ -244,6 +344,11 @@ func (b *SimulatedBroker) CalculateTradeCost(action types.Action, price float64,
 	return b.feeCalculator.Calculate(action, price, quantity)
 }
 
-func generateOrderID() string {
-	return fmt.Sprintf("ORD-%d", time.Now().UnixNano())
+// CalculateSymbolTradeCost 按品种计算交易成本，费用计算器不区分品种时按合约价值折算数量
+func (b *SimulatedBroker) CalculateSymbolTradeCost(symbol string, action types.Action, price float64, quantity float64) float64 {
+	inst := b.instruments.Lookup(symbol)
+	if c, ok := b.feeCalculator.(SymbolFeeCalculator); ok {
+		return c.CalculateSymbol(inst, action, price, quantity)
+	}
+	return b.feeCalculator.Calculate(action, price, quantity*inst.Multiplier)
 }
@@ -0,0 +1,54 @@
+package broker
+
+// MarginConfig 融资融券账户参数
+type MarginConfig struct {
+	DefaultHaircut   float64            // 担保证券默认折算率
+	Haircuts         map[string]float64 // 个股折算率，未列出的使用默认值
+	FinancingMargin  float64            // 融资保证金比例
+	ShortMargin      float64            // 融券保证金比例
+	FinancingRate    float64            // 融资年利率
+	LendingRate      float64            // 融券年费率，按融券市值计
+	MaintenanceRatio float64            // 维持担保比例下限，低于该值追加担保物
+	RestoreRatio     float64            // 强制平仓后需恢复到的担保比例
+	GraceDays        int                // 追保期限（交易日），到期仍低于下限则强制平仓，0表示立即平仓
+	DaysPerYear      float64            // 计息天数，按自然日计息
+}
+
+// DefaultMarginConfig 常见的融资融券参数：折算率70%，保证金比例100%，
+// 融资利率8%，融券费率10%，维持担保比例130%，强平恢复到150%，追保期限2个交易日
+func DefaultMarginConfig() MarginConfig {
+	return MarginConfig{
+		DefaultHaircut:   0.7,
+		FinancingMargin:  1,
+		ShortMargin:      1,
+		FinancingRate:    0.08,
+		LendingRate:      0.10,
+		MaintenanceRatio: 1.3,
+		RestoreRatio:     1.5,
+		GraceDays:        2,
+		DaysPerYear:      360,
+	}
+}
+
+// Haircut 返回股票的折算率
+func (c *MarginConfig) Haircut(symbol string) float64 {
+	if h, ok := c.Haircuts[symbol]; ok {
+		return h
+	}
+	return c.DefaultHaircut
+}
+
+// SetMargin 开通融资融券，同时允许卖空
+func (b *SimulatedBroker) SetMargin(config MarginConfig) {
+	b.margin = &config
+}
+
+// Margin 返回融资融券参数，未开通时返回nil
+func (b *SimulatedBroker) Margin() *MarginConfig {
+	return b.margin
+}
+
+// UpdateMargin 记录账户的融资融券负债（融资负债、融券市值和未付利息之和）
+func (b *SimulatedBroker) UpdateMargin(liabilities float64) {
+	b.account.Margin = liabilities
+}
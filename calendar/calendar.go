@@ -0,0 +1,293 @@
+package calendar
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed data/cn_holidays.txt
+var bundledHolidays string
+
+// Exchange 交易所
+type Exchange string
+
+const (
+	ExchangeSSE  Exchange = "SSE"  // 上海证券交易所
+	ExchangeSZSE Exchange = "SZSE" // 深圳证券交易所
+)
+
+// DefaultTradingDaysPerYear 日历未覆盖时使用的年化交易日数
+const DefaultTradingDaysPerYear = 252
+
+// Session 交易时段，起止时间为距当日零点的偏移
+type Session struct {
+	Name  string
+	Start time.Duration
+	End   time.Duration
+}
+
+// Contains 判断时间点是否处于该时段内
+func (s Session) Contains(t time.Time) bool {
+	offset := t.Sub(startOfDay(t))
+	return offset >= s.Start && offset < s.End
+}
+
+// 沪深交易所A股交易时段
+var cnSessions = []Session{
+	{Name: "开盘集合竞价", Start: 9*time.Hour + 15*time.Minute, End: 9*time.Hour + 25*time.Minute},
+	{Name: "上午连续竞价", Start: 9*time.Hour + 30*time.Minute, End: 11*time.Hour + 30*time.Minute},
+	{Name: "下午连续竞价", Start: 13 * time.Hour, End: 14*time.Hour + 57*time.Minute},
+	{Name: "收盘集合竞价", Start: 14*time.Hour + 57*time.Minute, End: 15 * time.Hour},
+}
+
+// Calendar 交易日历
+type Calendar struct {
+	exchange  Exchange
+	holidays  map[int]bool // 以yyyymmdd为键的休市日
+	firstYear int
+	lastYear  int
+	sessions  []Session
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultCalendar *Calendar
+)
+
+// Default 返回基于内置休市日数据的上交所日历
+func Default() *Calendar {
+	defaultOnce.Do(func() {
+		cal, err := Load(ExchangeSSE, strings.NewReader(bundledHolidays))
+		if err != nil {
+			panic(fmt.Sprintf("内置交易日历数据错误: %v", err))
+		}
+		defaultCalendar = cal
+	})
+	return defaultCalendar
+}
+
+// New 使用内置休市日数据创建指定交易所的日历，沪深交易所休市安排一致
+func New(exchange Exchange) *Calendar {
+	cal := *Default()
+	cal.exchange = exchange
+	return &cal
+}
+
+// LoadFile 从文件加载休市日数据
+func LoadFile(exchange Exchange, path string) (*Calendar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开交易日历文件失败: %v", err)
+	}
+	defer file.Close()
+	return Load(exchange, file)
+}
+
+// Load 解析休市日数据，每行格式为"年份: 月-日 月-日 ..."，#开头为注释
+func Load(exchange Exchange, r io.Reader) (*Calendar, error) {
+	cal := &Calendar{
+		exchange: exchange,
+		holidays: make(map[int]bool),
+		sessions: cnSessions,
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		yearPart, days, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("第%d行格式错误: %s", lineNo, line)
+		}
+		year, err := strconv.Atoi(strings.TrimSpace(yearPart))
+		if err != nil {
+			return nil, fmt.Errorf("第%d行年份错误: %v", lineNo, err)
+		}
+
+		for _, field := range strings.Fields(days) {
+			day, err := time.Parse("2006-01-02", fmt.Sprintf("%d-%s", year, field))
+			if err != nil {
+				return nil, fmt.Errorf("第%d行日期错误: %v", lineNo, err)
+			}
+			cal.holidays[dateKey(day)] = true
+		}
+
+		if cal.firstYear == 0 || year < cal.firstYear {
+			cal.firstYear = year
+		}
+		if year > cal.lastYear {
+			cal.lastYear = year
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cal, nil
+}
+
+// Exchange 返回日历所属交易所
+func (c *Calendar) Exchange() Exchange {
+	return c.exchange
+}
+
+// Covers 判断日期所在年份是否有休市日数据
+func (c *Calendar) Covers(t time.Time) bool {
+	return t.Year() >= c.firstYear && t.Year() <= c.lastYear
+}
+
+// IsTradingDay 判断是否为交易日
+func (c *Calendar) IsTradingDay(t time.Time) bool {
+	weekday := t.Weekday()
+	if weekday == time.Saturday || weekday == time.Sunday {
+		return false
+	}
+	return !c.holidays[dateKey(t)]
+}
+
+// Next 返回t之后的下一个交易日（不含t当天）
+func (c *Calendar) Next(t time.Time) time.Time {
+	day := startOfDay(t).AddDate(0, 0, 1)
+	for !c.IsTradingDay(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// Prev 返回t之前的上一个交易日（不含t当天）
+func (c *Calendar) Prev(t time.Time) time.Time {
+	day := startOfDay(t).AddDate(0, 0, -1)
+	for !c.IsTradingDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// Align 若t为交易日返回当天，否则返回下一个交易日
+func (c *Calendar) Align(t time.Time) time.Time {
+	day := startOfDay(t)
+	if c.IsTradingDay(day) {
+		return day
+	}
+	return c.Next(day)
+}
+
+// TradingDays 返回[start, end]区间内的所有交易日
+func (c *Calendar) TradingDays(start, end time.Time) []time.Time {
+	var days []time.Time
+	for day := startOfDay(start); !day.After(end); day = day.AddDate(0, 0, 1) {
+		if c.IsTradingDay(day) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// TradingDaysBetween 统计(start, end]区间内的交易日数量
+func (c *Calendar) TradingDaysBetween(start, end time.Time) int {
+	if !end.After(start) {
+		return 0
+	}
+	count := 0
+	for day := startOfDay(start).AddDate(0, 0, 1); !day.After(end); day = day.AddDate(0, 0, 1) {
+		if c.IsTradingDay(day) {
+			count++
+		}
+	}
+	return count
+}
+
+// TradingDaysInYear 返回某年的交易日数量
+func (c *Calendar) TradingDaysInYear(year int) int {
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	return len(c.TradingDays(start, start.AddDate(1, 0, -1)))
+}
+
+// TradingDaysPerYear 返回覆盖年份的平均年交易日数，用于年化计算
+func (c *Calendar) TradingDaysPerYear() float64 {
+	if c.firstYear == 0 {
+		return DefaultTradingDaysPerYear
+	}
+	total := 0
+	for year := c.firstYear; year <= c.lastYear; year++ {
+		total += c.TradingDaysInYear(year)
+	}
+	return float64(total) / float64(c.lastYear-c.firstYear+1)
+}
+
+// IsFirstOfWeek 判断是否为所在周的第一个交易日
+func (c *Calendar) IsFirstOfWeek(t time.Time) bool {
+	if !c.IsTradingDay(t) {
+		return false
+	}
+	_, week := t.ISOWeek()
+	_, prevWeek := c.Prev(t).ISOWeek()
+	return week != prevWeek
+}
+
+// IsLastOfWeek 判断是否为所在周的最后一个交易日
+func (c *Calendar) IsLastOfWeek(t time.Time) bool {
+	if !c.IsTradingDay(t) {
+		return false
+	}
+	_, week := t.ISOWeek()
+	_, nextWeek := c.Next(t).ISOWeek()
+	return week != nextWeek
+}
+
+// IsFirstOfMonth 判断是否为所在月的第一个交易日
+func (c *Calendar) IsFirstOfMonth(t time.Time) bool {
+	return c.IsTradingDay(t) && c.Prev(t).Month() != t.Month()
+}
+
+// IsLastOfMonth 判断是否为所在月的最后一个交易日
+func (c *Calendar) IsLastOfMonth(t time.Time) bool {
+	return c.IsTradingDay(t) && c.Next(t).Month() != t.Month()
+}
+
+// Sessions 返回交易时段，非交易日返回空
+func (c *Calendar) Sessions(t time.Time) []Session {
+	if !c.IsTradingDay(t) {
+		return nil
+	}
+	return c.sessions
+}
+
+// SessionAt 返回时间点所处的交易时段
+func (c *Calendar) SessionAt(t time.Time) (Session, bool) {
+	for _, s := range c.Sessions(t) {
+		if s.Contains(t) {
+			return s, true
+		}
+	}
+	return Session{}, false
+}
+
+// MarketOpen 返回交易日连续竞价开始时间
+func (c *Calendar) MarketOpen(t time.Time) time.Time {
+	return startOfDay(t).Add(c.sessions[1].Start)
+}
+
+// MarketClose 返回交易日收盘时间
+func (c *Calendar) MarketClose(t time.Time) time.Time {
+	return startOfDay(t).Add(c.sessions[len(c.sessions)-1].End)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func dateKey(t time.Time) int {
+	return t.Year()*10000 + int(t.Month())*100 + t.Day()
+}
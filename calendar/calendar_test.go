@@ -0,0 +1,87 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestTradingDaysInYear(t *testing.T) {
+	// 上交所公布的全年交易日数
+	want := map[int]int{2020: 243, 2021: 243, 2022: 242, 2023: 242, 2024: 242}
+	cal := Default()
+	for year, days := range want {
+		if got := cal.TradingDaysInYear(year); got != days {
+			t.Errorf("%d年交易日%d天，期望%d天", year, got, days)
+		}
+	}
+}
+
+func TestHolidays(t *testing.T) {
+	cal := Default()
+	// 2024年春节休市2月9日至2月16日
+	if cal.IsTradingDay(date("2024-02-09")) || cal.IsTradingDay(date("2024-02-16")) {
+		t.Error("春节休市日不应为交易日")
+	}
+	if got := cal.Next(date("2024-02-08")); !got.Equal(date("2024-02-19")) {
+		t.Errorf("2024-02-08的下一个交易日为%s，期望2024-02-19", got.Format("2006-01-02"))
+	}
+	if got := cal.Prev(date("2024-02-19")); !got.Equal(date("2024-02-08")) {
+		t.Errorf("2024-02-19的上一个交易日为%s，期望2024-02-08", got.Format("2006-01-02"))
+	}
+	if got := cal.Align(date("2024-02-10")); !got.Equal(date("2024-02-19")) {
+		t.Errorf("2024-02-10对齐到%s，期望2024-02-19", got.Format("2006-01-02"))
+	}
+	if got := cal.TradingDaysBetween(date("2024-02-08"), date("2024-02-19")); got != 1 {
+		t.Errorf("(02-08, 02-19]区间%d个交易日，期望1个", got)
+	}
+}
+
+func TestPeriodBoundaries(t *testing.T) {
+	cal := Default()
+	tests := []struct {
+		day   string
+		check func(*Calendar, time.Time) bool
+		name  string
+		want  bool
+	}{
+		{"2024-02-08", (*Calendar).IsLastOfWeek, "周末", true}, // 周五2月9日休市
+		{"2024-02-19", (*Calendar).IsFirstOfWeek, "周初", true},
+		{"2024-02-20", (*Calendar).IsFirstOfWeek, "周初", false},
+		{"2024-04-30", (*Calendar).IsLastOfMonth, "月末", true},
+		{"2024-05-06", (*Calendar).IsFirstOfMonth, "月初", true}, // 5月1日至3日休市
+		{"2024-05-07", (*Calendar).IsFirstOfMonth, "月初", false},
+		{"2024-02-10", (*Calendar).IsFirstOfWeek, "周初", false}, // 非交易日
+	}
+	for _, tt := range tests {
+		if got := tt.check(cal, date(tt.day)); got != tt.want {
+			t.Errorf("%s是否%s: %v，期望%v", tt.day, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	cal, err := Load(ExchangeSZSE, strings.NewReader("# 注释\n2030: 01-01 01-02\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cal.IsTradingDay(date("2030-01-02")) || !cal.IsTradingDay(date("2030-01-03")) {
+		t.Error("休市日解析错误")
+	}
+	if !cal.Covers(date("2030-06-01")) || cal.Covers(date("2031-01-01")) {
+		t.Error("覆盖年份错误")
+	}
+	for _, src := range []string{"2030 01-01", "abc: 01-01", "2030: 13-01"} {
+		if _, err := Load(ExchangeSSE, strings.NewReader(src)); err == nil {
+			t.Errorf("%q 应解析失败", src)
+		}
+	}
+}
@@ -0,0 +1,951 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock/analyzer"
+	"stock/backtest"
+	"stock/broker"
+	"stock/calendar"
+	"stock/common"
+	"stock/common/types"
+	"stock/datasource"
+	"stock/factor"
+	"stock/instrument"
+	"stock/pairs"
+	"stock/risk"
+	"stock/robustness"
+	"stock/schedule"
+	"stock/sizing"
+	"stock/strategy"
+	"stock/universe"
+	"stock/visualization"
+)
+
+// runCommand 处理命令行子命令
+func runCommand(args []string) error {
+	if len(args) >= 2 && args[0] == "data" {
+		switch args[1] {
+		case "check":
+			return runDataCheck(args[2:])
+		case "import":
+			return runDataImport(args[2:])
+		case "synth":
+			return runDataSynth(args[2:])
+		}
+	}
+	if len(args) >= 1 {
+		switch args[0] {
+		case "backtest":
+			return runRuleBacktest(args[1:])
+		case "rotate":
+			return runRotation(args[1:])
+		case "factor":
+			return runFactorReport(args[1:])
+		case "pairs":
+			return runPairs(args[1:])
+		case "sweep":
+			return runSweep(args[1:])
+		}
+	}
+	return fmt.Errorf("未知命令: %s\n用法: stock data check|import|synth [选项] | stock backtest -rules 配置文件 [选项] | stock sweep [选项] | stock rotate|factor|pairs -dir 数据目录 [选项]", strings.Join(args, " "))
+}
+
+// openDataSource 根据文件扩展名创建数据源
+func openDataSource(file string) datasource.DataSource {
+	if strings.HasSuffix(strings.ToLower(file), ".day") {
+		return datasource.NewTDXDataSource(file)
+	}
+	return datasource.NewCSVDataSource(file)
+}
+
+// runDataCheck 检查数据质量，可选按修复策略输出修复后的数据
+func runDataCheck(args []string) error {
+	fs := flag.NewFlagSet("data check", flag.ContinueOnError)
+	file := fs.String("file", "data/cmb.csv", "数据文件路径（.csv或通达信.day）")
+	symbol := fs.String("symbol", "600036.SH", "股票代码")
+	start := fs.String("start", "2000-01-01", "开始日期")
+	end := fs.String("end", time.Now().Format("2006-01-02"), "结束日期")
+	maxReturn := fs.Float64("max-return", 0.22, "单日收益率异常阈值")
+	repair := fs.String("repair", "", "修复策略: drop, ffill, flag")
+	output := fs.String("out", "", "修复后数据输出路径（CSV）")
+	verbose := fs.Bool("v", false, "输出每条异常明细")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	startDate, err := time.Parse("2006-01-02", *start)
+	if err != nil {
+		return fmt.Errorf("开始日期格式错误: %v", err)
+	}
+	endDate, err := time.Parse("2006-01-02", *end)
+	if err != nil {
+		return fmt.Errorf("结束日期格式错误: %v", err)
+	}
+
+	points, err := openDataSource(*file).GetData(*symbol, datasource.PeriodTypeDay, startDate, endDate.AddDate(0, 0, 1))
+	if err != nil {
+		return fmt.Errorf("读取数据失败: %v", err)
+	}
+
+	config := datasource.DefaultQualityConfig()
+	config.MaxAbsReturn = *maxReturn
+	report := datasource.CheckQuality(*symbol, points, config)
+
+	fmt.Printf("数据检查: %s %s (%d条, %s ~ %s)\n", *symbol, *file, report.Total,
+		report.Start.Format("2006-01-02"), report.End.Format("2006-01-02"))
+	for _, anomalyType := range []datasource.AnomalyType{
+		datasource.AnomalyMissingDay,
+		datasource.AnomalyDuplicate,
+		datasource.AnomalyNonPositivePrice,
+		datasource.AnomalyOHLCViolation,
+		datasource.AnomalyOutlierReturn,
+		datasource.AnomalyZeroVolume,
+	} {
+		fmt.Printf("  %-20s %d\n", anomalyType, report.Count(anomalyType))
+	}
+	if *verbose {
+		for _, a := range report.Anomalies {
+			fmt.Printf("  %s %-20s %s\n", a.Timestamp.Format("2006-01-02"), a.Type, a.Detail)
+		}
+	}
+
+	if *repair == "" {
+		return nil
+	}
+	policy, err := datasource.ParseRepairPolicy(*repair)
+	if err != nil {
+		return err
+	}
+	repaired := datasource.RepairData(points, report, policy)
+	fmt.Printf("修复后数据: %d条\n", len(repaired))
+
+	if *output != "" {
+		if err := datasource.WriteCSV(*output, repaired); err != nil {
+			return fmt.Errorf("写入修复数据失败: %v", err)
+		}
+		fmt.Printf("已保存到 %s\n", *output)
+	}
+	return nil
+}
+
+// runDataImport 从东方财富接口导入K线数据到CSV或本地缓存
+func runDataImport(args []string) error {
+	fs := flag.NewFlagSet("data import", flag.ContinueOnError)
+	baseURL := fs.String("base-url", datasource.DefaultEastmoneyBaseURL, "K线接口地址")
+	symbol := fs.String("symbol", "600036.SH", "股票代码")
+	start := fs.String("start", "2010-01-01", "开始日期")
+	end := fs.String("end", time.Now().Format("2006-01-02"), "结束日期")
+	fqt := fs.Int("fqt", 1, "复权类型: 0不复权, 1前复权, 2后复权")
+	output := fs.String("out", "", "CSV输出路径")
+	cacheDir := fs.String("cache", "", "本地缓存目录")
+	retries := fs.Int("retries", 3, "失败重试次数")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" && *cacheDir == "" {
+		return fmt.Errorf("需要指定 -out 或 -cache")
+	}
+
+	startDate, err := time.Parse("2006-01-02", *start)
+	if err != nil {
+		return fmt.Errorf("开始日期格式错误: %v", err)
+	}
+	endDate, err := time.Parse("2006-01-02", *end)
+	if err != nil {
+		return fmt.Errorf("结束日期格式错误: %v", err)
+	}
+	// 数据源区间不含端点，前后各扩展一天
+	startDate, endDate = startDate.AddDate(0, 0, -1), endDate.AddDate(0, 0, 1)
+
+	ds := datasource.NewEastmoneyDataSource(*baseURL, datasource.AdjustType(*fqt))
+	ds.SetRetry(*retries, 2*time.Second)
+
+	if *output != "" {
+		n, err := ds.ImportCSV(*symbol, datasource.PeriodTypeDay, startDate, endDate, *output)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已导入 %s %d条数据到 %s\n", *symbol, n, *output)
+	}
+	if *cacheDir != "" {
+		cache := datasource.NewCachedDataSource(ds, *cacheDir, datasource.AdjustType(*fqt))
+		n, err := ds.ImportCache(*symbol, datasource.PeriodTypeDay, startDate, endDate, cache)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已导入 %s %d条数据到缓存 %s\n", *symbol, n, *cacheDir)
+	}
+	return nil
+}
+
+// runDataSynth 按收益率模型生成合成行情并保存为CSV
+func runDataSynth(args []string) error {
+	fs := flag.NewFlagSet("data synth", flag.ContinueOnError)
+	model := fs.String("model", "gbm", "收益率模型: gbm, garch, jump, regime, bootstrap")
+	symbol := fs.String("symbol", "600036.SH", "股票代码，决定涨跌停规则和随机序列")
+	start := fs.String("start", "2020-01-01", "开始日期")
+	end := fs.String("end", "2022-12-31", "结束日期")
+	seed := fs.Int64("seed", 1, "随机种子")
+	mu := fs.Float64("mu", 0.08, "年化漂移率（gbm、jump）")
+	sigma := fs.Float64("sigma", 0.3, "年化波动率（gbm、garch、jump）")
+	price := fs.Float64("price", 10, "起始价格")
+	limit := fs.Bool("limit", true, "按涨跌停规则截断价格")
+	suspend := fs.Float64("suspend", 0, "每个交易日开始停牌的概率")
+	suspendDays := fs.Float64("suspend-days", 5, "平均停牌天数")
+	source := fs.String("source", "data/cmb.csv", "块自助法使用的真实行情文件")
+	block := fs.Int("block", 20, "块自助法的块长度")
+	output := fs.String("out", "", "CSV输出路径")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" {
+		return fmt.Errorf("需要指定 -out")
+	}
+
+	startDate, err := time.Parse("2006-01-02", *start)
+	if err != nil {
+		return fmt.Errorf("开始日期格式错误: %v", err)
+	}
+	endDate, err := time.Parse("2006-01-02", *end)
+	if err != nil {
+		return fmt.Errorf("结束日期格式错误: %v", err)
+	}
+
+	var returnModel datasource.ReturnModel
+	switch *model {
+	case "gbm":
+		returnModel = datasource.GBM{Mu: *mu, Sigma: *sigma}
+	case "garch":
+		returnModel = datasource.NewGARCH(*sigma, 0.1, 0.85)
+	case "jump":
+		returnModel = datasource.JumpDiffusion{Mu: *mu, Sigma: *sigma, Lambda: 5, JumpMean: -0.03, JumpStd: 0.06}
+	case "regime":
+		returnModel = datasource.BullBear()
+	case "bootstrap":
+		real, err := openDataSource(*source).GetData(*symbol, datasource.PeriodTypeDay, time.Time{}, time.Now())
+		if err != nil {
+			return err
+		}
+		returnModel = datasource.NewBlockBootstrap(real, *block)
+	default:
+		return fmt.Errorf("未知的收益率模型: %s", *model)
+	}
+
+	ds := datasource.NewSyntheticDataSource(returnModel, *seed)
+	ds.SetInitialPrice(*price)
+	ds.SetPriceLimit(*limit)
+	ds.SetSuspension(*suspend, *suspendDays)
+	data, err := ds.GetData(*symbol, datasource.PeriodTypeDay, startDate, endDate)
+	if err != nil {
+		return err
+	}
+	if err := datasource.WriteCSV(*output, data); err != nil {
+		return err
+	}
+	fmt.Printf("已生成 %s %s模型 %d条数据（停牌%d天）到 %s\n",
+		*symbol, returnModel.Name(), len(data), len(ds.Suspensions(*symbol)), *output)
+	return nil
+}
+
+// runRuleBacktest 按JSON规则配置运行回测，无需重新编译
+func runRuleBacktest(args []string) error {
+	fs := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	rules := fs.String("rules", "", "规则策略配置文件（JSON）")
+	file := fs.String("file", "data/sh600036.day", "数据文件路径（.csv或通达信.day）")
+	symbol := fs.String("symbol", "600036.SH", "股票代码")
+	start := fs.String("start", "2020-01-01", "开始日期")
+	end := fs.String("end", "2022-12-31", "结束日期")
+	cash := fs.Float64("cash", 100000, "初始资金")
+	actionsFile := fs.String("actions", "", "公司行为数据文件（CSV）")
+	maxWeight := fs.Float64("max-weight", 0, "单股持仓占权益上限，0不限制")
+	dailyLoss := fs.Float64("daily-loss", 0, "当日亏损限制，0不限制")
+	maxDrawdown := fs.Float64("max-drawdown", 0, "最大回撤熔断阈值，0不限制")
+	simulations := fs.Int("robust", 0, "稳健性分析的模拟次数，0不分析")
+	methods := fs.String("robust-methods", "trades,returns", "重抽样方法: shuffle交易重排, trades交易自助法, returns收益率块自助法, prices价格路径扰动")
+	block := fs.Float64("block", 20, "收益率块自助法的平均块长度（交易日）")
+	noise := fs.Float64("noise", 0.5, "价格路径扰动的噪声，按日波动率的倍数")
+	ruin := fs.Float64("ruin", 0.5, "亏损超过初始资金的该比例视为破产")
+	seed := fs.Int64("seed", 1, "随机种子")
+	robustOut := fs.String("robust-out", "robustness.html", "稳健性分析图表输出文件")
+	timeout := fs.Duration("timeout", 0, "价格路径扰动每次回测的时间上限（如2s），超时的模拟被跳过，0不限")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *rules == "" {
+		return fmt.Errorf("需要指定 -rules")
+	}
+
+	startDate, err := time.Parse("2006-01-02", *start)
+	if err != nil {
+		return fmt.Errorf("开始日期格式错误: %v", err)
+	}
+	endDate, err := time.Parse("2006-01-02", *end)
+	if err != nil {
+		return fmt.Errorf("结束日期格式错误: %v", err)
+	}
+	endDate = endDate.Add(24*time.Hour - time.Second)
+
+	config, err := strategy.LoadRuleConfig(*rules)
+	if err != nil {
+		return err
+	}
+	var actions []types.CorporateAction
+	if *actionsFile != "" {
+		actions, err = datasource.LoadCorporateActions(*actionsFile)
+		if err != nil {
+			return fmt.Errorf("加载公司行为数据失败: %v", err)
+		}
+	}
+
+	// run 每次创建新的策略和经纪商，稳健性分析在扰动后的数据上重复运行
+	run := func(ctx context.Context, ds datasource.DataSource, logger types.Logger) (*strategy.RuleStrategy, *backtest.StrategyResult, error) {
+		ruleStrategy, err := strategy.NewRuleStrategy(*config)
+		if err != nil {
+			return nil, nil, err
+		}
+		simBroker := broker.NewSimulatedBroker(
+			broker.NewFixedFeeCalculator(backtest.DefaultFeeConfig.Commission),
+			logger,
+			*cash,
+		)
+		bt := backtest.NewBacktest(startDate, endDate, *cash, ds, simBroker, logger, []string{*symbol})
+		bt.AddStrategy(ruleStrategy)
+		if actions != nil {
+			bt.SetCorporateActions(actions)
+		}
+		if *maxWeight > 0 || *dailyLoss > 0 || *maxDrawdown > 0 {
+			bt.SetRiskConfig(risk.Config{
+				MaxPositionWeight: *maxWeight,
+				DailyLossLimit:    *dailyLoss,
+				MaxDrawdown:       *maxDrawdown,
+			})
+		}
+		results, err := bt.RunContext(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ruleStrategy, &results.Results[0], nil
+	}
+
+	ds := openDataSource(*file)
+	ruleStrategy, result, err := run(context.Background(), ds, common.NewConsoleLogger())
+	if err != nil {
+		return err
+	}
+	a := analyzer.NewAnalyzer(result.Trades, *cash)
+	fmt.Printf("\n规则策略 %s 回测结果:\n", ruleStrategy.Name())
+	fmt.Printf("最终资产: %.2f\n", result.FinalValue)
+	fmt.Printf("总收益率: %.2f%%\n", a.TotalReturn(result.FinalValue)*100)
+	fmt.Printf("年化收益率: %.2f%%\n", a.AnnualizedReturn(result.FinalValue, startDate, endDate)*100)
+	fmt.Printf("最大回撤: %.2f%%\n", result.MaxDrawdown*100)
+	fmt.Printf("交易次数: %d\n", len(result.Trades))
+	fmt.Printf("胜率: %.2f%%\n", a.WinRate()*100)
+	fmt.Printf("风控事件: %d\n", len(result.RiskEvents))
+
+	if *simulations <= 0 {
+		return nil
+	}
+	robust := robustness.DefaultConfig()
+	robust.Simulations = *simulations
+	robust.Seed = *seed
+	robust.BlockSize = *block
+	robust.RuinThreshold = *ruin
+	robust.PeriodsPerYear = calendar.Default().TradingDaysPerYear()
+	robust.Timeout = *timeout
+
+	var reports []*robustness.Result
+	for _, method := range strings.Split(*methods, ",") {
+		var report *robustness.Result
+		switch strings.TrimSpace(method) {
+		case "shuffle":
+			report, err = robustness.Trades(analyzer.RoundTrips(result.Trades), *cash, false, robust)
+		case "trades":
+			report, err = robustness.Trades(analyzer.RoundTrips(result.Trades), *cash, true, robust)
+		case "returns":
+			report, err = robustness.Returns(robustness.DailyReturns(*cash, result.Values), *cash, robust)
+		case "prices":
+			report, err = robustness.PricePaths(ds, *noise, *cash, func(ctx context.Context, ds datasource.DataSource) ([]float64, error) {
+				_, r, err := run(ctx, ds, common.NewNopLogger())
+				if err != nil {
+					return nil, err
+				}
+				return r.Values, nil
+			}, robust)
+		default:
+			return fmt.Errorf("未知的重抽样方法: %s", method)
+		}
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+	printRobustness(reports)
+	if err := visualization.PlotRobustness(reports, *robustOut); err != nil {
+		return fmt.Errorf("生成稳健性分析图表失败: %v", err)
+	}
+	fmt.Printf("稳健性分析图表已保存到 %s\n", *robustOut)
+	return nil
+}
+
+// printRobustness 输出各重抽样方法的95%置信区间和破产概率
+func printRobustness(reports []*robustness.Result) {
+	fmt.Printf("\n%-16s %12s %26s %22s %20s %8s\n", "方法", "原始资产", "最终资产95%区间", "最大回撤95%区间", "夏普95%区间", "破产概率")
+	for _, r := range reports {
+		finalLo, finalHi := r.FinalValue.Interval(0.95)
+		ddLo, ddHi := r.MaxDrawdown.Interval(0.95)
+		sharpeLo, sharpeHi := r.Sharpe.Interval(0.95)
+		fmt.Printf("%-16s %12.2f [%11.2f, %11.2f] [%8.2f%%, %8.2f%%] [%7.2f, %7.2f] %7.2f%%\n",
+			r.Method, r.Observed.FinalValue, finalLo, finalHi, ddLo*100, ddHi*100, sharpeLo, sharpeHi, r.RiskOfRuin*100)
+		if r.Aborted > 0 {
+			fmt.Printf("%-16s %d次模拟超时中止，未计入统计\n", "", r.Aborted)
+		}
+	}
+}
+
+// parseRange 解析整数区间 "起始:结束:步长"，也可以是单个整数
+func parseRange(spec string) ([]int, error) {
+	parts := strings.Split(spec, ":")
+	bounds := make([]int, len(parts))
+	for i, part := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("参数区间格式错误: %s", spec)
+		}
+		bounds[i] = v
+	}
+	switch len(bounds) {
+	case 1:
+		return bounds, nil
+	case 2:
+		bounds = append(bounds, 1)
+	case 3:
+	default:
+		return nil, fmt.Errorf("参数区间格式错误: %s", spec)
+	}
+	if bounds[2] <= 0 {
+		return nil, fmt.Errorf("参数区间步长必须为正: %s", spec)
+	}
+	var values []int
+	for v := bounds[0]; v <= bounds[1]; v += bounds[2] {
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// runSweep 遍历MACD参数组合，评估最优参数的概率夏普比率、紧缩夏普比率和回测过拟合概率
+func runSweep(args []string) error {
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	file := fs.String("file", "data/sh600036.day", "数据文件路径（.csv或通达信.day）")
+	symbol := fs.String("symbol", "600036.SH", "股票代码")
+	fastSpec := fs.String("fast", "5:15:2", "快线周期区间（起始:结束:步长）")
+	slowSpec := fs.String("slow", "20:40:5", "慢线周期区间")
+	signalSpec := fs.String("signal", "5:13:2", "信号线周期区间")
+	fraction := fs.Float64("fraction", 0.5, "每次开仓占权益的比例")
+	partitions := fs.Int("partitions", 16, "组合对称交叉验证的分段数（偶数）")
+	start := fs.String("start", "2015-01-01", "开始日期")
+	end := fs.String("end", "2022-12-31", "结束日期")
+	cash := fs.Float64("cash", 100000, "初始资金")
+	timeout := fs.Duration("timeout", 0, "参数寻优的总时间上限（如5m），超时后中止，0不限")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	startDate, err := time.Parse("2006-01-02", *start)
+	if err != nil {
+		return fmt.Errorf("开始日期格式错误: %v", err)
+	}
+	endDate, err := time.Parse("2006-01-02", *end)
+	if err != nil {
+		return fmt.Errorf("结束日期格式错误: %v", err)
+	}
+	endDate = endDate.Add(24*time.Hour - time.Second)
+
+	var ranges [3][]int
+	for i, spec := range []string{*fastSpec, *slowSpec, *signalSpec} {
+		if ranges[i], err = parseRange(spec); err != nil {
+			return err
+		}
+	}
+
+	logger := common.NewNopLogger()
+	simBroker := broker.NewSimulatedBroker(
+		broker.NewFixedFeeCalculator(backtest.DefaultFeeConfig.Commission),
+		logger,
+		*cash,
+	)
+	bt := backtest.NewBacktest(startDate, endDate, *cash, openDataSource(*file), simBroker, logger, []string{*symbol})
+	var params []string
+	for _, fast := range ranges[0] {
+		for _, slow := range ranges[1] {
+			if fast >= slow {
+				continue
+			}
+			for _, signal := range ranges[2] {
+				macd := strategy.NewMACDStrategy(fast, slow, signal, nil)
+				macd.SetSizer(sizing.NewPercentEquity(*fraction))
+				bt.AddStrategy(macd)
+				params = append(params, fmt.Sprintf("MACD(%d,%d,%d)", fast, slow, signal))
+			}
+		}
+	}
+	if len(params) < 2 {
+		return fmt.Errorf("至少需要2组参数，实际%d组", len(params))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	if *timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	bar := common.NewProgressBar(os.Stderr, 40)
+	bt.SetProgressHandler(func(p backtest.Progress) {
+		bar.Update(p.Fraction(), fmt.Sprintf("%d/%d %s", p.Strategy+1, len(params), params[p.Strategy]))
+	})
+	results, err := bt.RunContext(ctx)
+	bar.Finish()
+	if err != nil {
+		return err
+	}
+	returns := make([][]float64, len(results.Results))
+	for i, result := range results.Results {
+		returns[i] = result.Returns
+	}
+
+	a := analyzer.NewAnalyzer(nil, *cash)
+	annualize := math.Sqrt(calendar.Default().TradingDaysPerYear())
+	best, sharpe, deflated := a.DeflatedSharpeOfBest(returns)
+	pbo, err := a.ProbabilityOfBacktestOverfitting(returns, *partitions)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nMACD参数寻优: %d组参数\n", len(params))
+	fmt.Printf("最优参数: %s, 最终资产: %.2f\n", params[best], results.Results[best].FinalValue)
+	fmt.Printf("年化夏普比率: %.3f, 偏度: %.3f, 峰度: %.3f\n",
+		sharpe*annualize, a.Skewness(returns[best]), a.Kurtosis(returns[best]))
+	fmt.Printf("概率夏普比率PSR(SR>0): %.2f%%\n", a.ProbabilisticSharpeRatio(returns[best], 0)*100)
+	fmt.Printf("紧缩夏普比率DSR: %.2f%%\n", deflated*100)
+	fmt.Printf("回测过拟合概率PBO: %.2f%% (%d种划分, logit中位数%.3f)\n", pbo.PBO*100, pbo.Combinations, pbo.MedianLogit())
+	fmt.Printf("样本外亏损概率: %.2f%%, 样本外/样本内夏普回归斜率: %.3f\n", pbo.ProbOOSLoss*100, pbo.Slope)
+	return nil
+}
+
+// parseFactorWeights 解析因子及权重，例如 "momentum(120,20)=1,volatility(20)=-1"
+func parseFactorWeights(spec string) (*factor.Pipeline, error) {
+	pipeline := factor.NewPipeline()
+	// 因子参数中也有逗号，按右括号后的逗号拆分
+	var items []string
+	depth, start := 0, 0
+	for i, r := range spec {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, spec[start:i])
+				start = i + 1
+			}
+		}
+	}
+	items = append(items, spec[start:])
+
+	for _, item := range items {
+		name, weightText := item, "1"
+		if idx := strings.LastIndex(item, "="); idx >= 0 {
+			name, weightText = item[:idx], item[idx+1:]
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(weightText), 64)
+		if err != nil {
+			return nil, fmt.Errorf("因子权重错误: %s", item)
+		}
+		f, err := factor.Parse(name)
+		if err != nil {
+			return nil, err
+		}
+		pipeline.Add(f, weight)
+	}
+	return pipeline, nil
+}
+
+// newPipeline 按命令行参数创建因子流水线
+func newPipeline(factors, fundamentalsFile, sectorsFile, sizeField string) (*factor.Pipeline, error) {
+	pipeline, err := parseFactorWeights(factors)
+	if err != nil {
+		return nil, err
+	}
+	if fundamentalsFile != "" {
+		fundamentals, err := datasource.LoadFundamentals(fundamentalsFile)
+		if err != nil {
+			return nil, err
+		}
+		pipeline.SetFundamentals(fundamentals)
+		pipeline.SetSizeField(sizeField)
+	}
+	if sectorsFile != "" {
+		sectors, err := risk.LoadSectors(sectorsFile)
+		if err != nil {
+			return nil, err
+		}
+		pipeline.SetGroups(sectors)
+	}
+	return pipeline, nil
+}
+
+// runRotation 对目录中的全部股票运行因子轮动回测
+func runRotation(args []string) error {
+	fs := flag.NewFlagSet("rotate", flag.ContinueOnError)
+	dir := fs.String("dir", "", "数据目录，每只股票一个文件（600036.SH.csv或sh600036.day）")
+	factors := fs.String("factors", "momentum(120,20)=1,volatility(20)=-1", "因子及权重，负权重表示越小越好")
+	top := fs.Int("top", 10, "持有得分最高的股票数")
+	quantiles := fs.Int("quantiles", 0, "按分位数分组，大于0时忽略-top")
+	quantile := fs.Int("quantile", 1, "持有第几组，1为得分最高")
+	spec := fs.String("schedule", "L * *", "调仓规则（日 月 星期），默认每月最后一个交易日")
+	start := fs.String("start", "2020-01-01", "开始日期")
+	end := fs.String("end", "2022-12-31", "结束日期")
+	cash := fs.Float64("cash", 1000000, "初始资金")
+	fundamentalsFile := fs.String("fundamentals", "", "基本面数据文件（CSV: Date,Symbol,字段...）")
+	sectorsFile := fs.String("sectors", "", "行业分类文件，用于行业中性化")
+	sizeField := fs.String("size-field", "", "市值字段，用于市值中性化")
+	gross := fs.Float64("gross", 1, "总仓位，大于1时需要融资")
+	margin := fs.Bool("margin", false, "开通融资融券（默认折算率、利率和维持担保比例）")
+	fees := fs.String("fees", "fixed", "费用模型: fixed固定费率，instrument按品种费率（股票、ETF、可转债、期货）")
+	instrumentsFile := fs.String("instruments", "", "品种文件（CSV: symbol,class,lot,limit,t0,commission...），覆盖按代码识别的品种规则")
+	universeFile := fs.String("universe", "", "股票池文件（CSV: symbol,start,end），退市后按最后收盘价清算")
+	fillLimit := fs.Int("fill-limit", 0, "停牌缺失K线最多前值填充的交易日数，0为不限，-1为不填充")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return fmt.Errorf("需要指定 -dir")
+	}
+
+	startDate, err := time.Parse("2006-01-02", *start)
+	if err != nil {
+		return fmt.Errorf("开始日期格式错误: %v", err)
+	}
+	endDate, err := time.Parse("2006-01-02", *end)
+	if err != nil {
+		return fmt.Errorf("结束日期格式错误: %v", err)
+	}
+	endDate = endDate.Add(24*time.Hour - time.Second)
+
+	pipeline, err := newPipeline(*factors, *fundamentalsFile, *sectorsFile, *sizeField)
+	if err != nil {
+		return err
+	}
+
+	sched, err := schedule.ParseCron(*spec, nil)
+	if err != nil {
+		return err
+	}
+
+	ds := datasource.NewDirectoryDataSource(*dir)
+	symbols, err := ds.Symbols()
+	if err != nil {
+		return err
+	}
+	if len(symbols) == 0 {
+		return fmt.Errorf("目录%s中没有数据文件", *dir)
+	}
+
+	rotation := strategy.NewRotationStrategy(sched, pipeline, *top)
+	rotation.SetGross(*gross)
+	if *quantiles > 0 {
+		rotation.SetQuantile(*quantiles, *quantile)
+	}
+
+	logger := common.NewConsoleLogger()
+	feeCalculator, err := newFeeCalculator(*fees)
+	if err != nil {
+		return err
+	}
+	simBroker := broker.NewSimulatedBroker(feeCalculator, types.Logger(logger), *cash)
+	if *instrumentsFile != "" {
+		registry := instrument.NewRegistry()
+		if err := registry.LoadFile(*instrumentsFile); err != nil {
+			return err
+		}
+		simBroker.SetInstruments(registry)
+	}
+	if *margin {
+		simBroker.SetMargin(broker.DefaultMarginConfig())
+	}
+	bt := backtest.NewBacktest(startDate, endDate, *cash, ds, simBroker, logger, symbols)
+	bt.AddStrategy(rotation)
+	if *universeFile != "" {
+		u, err := universe.LoadFile(*universeFile)
+		if err != nil {
+			return err
+		}
+		bt.SetUniverse(u)
+	}
+	bt.SetFillPolicy(universe.FillPolicy{Forward: *fillLimit >= 0, Limit: *fillLimit})
+
+	results, err := bt.Run()
+	if err != nil {
+		return err
+	}
+	result := results.Results[0]
+	a := analyzer.NewAnalyzer(result.Trades, *cash)
+	fmt.Printf("\n因子轮动 %s (%d只股票, %d次调仓) 回测结果:\n",
+		strings.Join(pipeline.Factors(), "+"), len(symbols), len(rotation.Scores()))
+	fmt.Printf("最终资产: %.2f\n", result.FinalValue)
+	fmt.Printf("总收益率: %.2f%%\n", a.TotalReturn(result.FinalValue)*100)
+	fmt.Printf("年化收益率: %.2f%%\n", a.AnnualizedReturn(result.FinalValue, startDate, endDate)*100)
+	fmt.Printf("最大回撤: %.2f%%\n", result.MaxDrawdown*100)
+	fmt.Printf("交易次数: %d\n", len(result.Trades))
+	printMarginSummary(result)
+	printOrderErrors("调仓", rotation.Errors())
+	return nil
+}
+
+// printOrderErrors 输出策略下单失败的错误，action为调仓、开平仓等
+func printOrderErrors(action string, errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+	fmt.Printf("%s下单失败: %d次\n", action, len(errs))
+	for _, err := range errs {
+		// errors.Join合并的多条错误逐行缩进
+		fmt.Printf("  %s\n", strings.ReplaceAll(err.Error(), "\n", "\n  "))
+	}
+}
+
+// newFeeCalculator 按名称创建费用模型
+func newFeeCalculator(name string) (broker.FeeCalculator, error) {
+	switch name {
+	case "fixed":
+		return broker.NewFixedFeeCalculator(backtest.DefaultFeeConfig.Commission), nil
+	case "instrument":
+		return broker.NewInstrumentFeeCalculator(), nil
+	}
+	return nil, fmt.Errorf("未知的费用模型: %s", name)
+}
+
+// printMarginSummary 输出杠杆率和融资融券汇总
+func printMarginSummary(result backtest.StrategyResult) {
+	maxLeverage, sum := 0.0, 0.0
+	for _, l := range result.Leverage {
+		maxLeverage = math.Max(maxLeverage, l)
+		sum += l
+	}
+	if len(result.Leverage) > 0 {
+		fmt.Printf("平均杠杆率: %.2f, 最高杠杆率: %.2f\n", sum/float64(len(result.Leverage)), maxLeverage)
+	}
+	if result.Portfolio.Margin() == nil {
+		return
+	}
+	calls, liquidations := 0, 0
+	for _, event := range result.RiskEvents {
+		switch event.Type {
+		case risk.EventMarginCall:
+			calls++
+		case risk.EventLiquidation:
+			liquidations++
+		}
+	}
+	fmt.Printf("融资负债: %.2f, 已付利息: %.2f, 未付利息: %.2f\n",
+		result.Portfolio.Debt(), result.Portfolio.InterestPaid(), result.Portfolio.AccruedInterest())
+	fmt.Printf("追保通知: %d次, 强制平仓: %d笔\n", calls, liquidations)
+}
+
+// runFactorReport 计算因子的IC、分位组收益和换手率，并输出图表
+func runFactorReport(args []string) error {
+	fs := flag.NewFlagSet("factor", flag.ContinueOnError)
+	dir := fs.String("dir", "", "数据目录，每只股票一个文件（600036.SH.csv或sh600036.day）")
+	factors := fs.String("factors", "momentum(120,20)", "因子及权重，多个因子时分析合成得分")
+	horizonsText := fs.String("horizons", "1,5,20", "未来收益的持有期（交易日），逗号分隔")
+	quantiles := fs.Int("quantiles", 5, "分位组数")
+	start := fs.String("start", "2020-01-01", "开始日期")
+	end := fs.String("end", "2022-12-31", "结束日期")
+	fundamentalsFile := fs.String("fundamentals", "", "基本面数据文件（CSV: Date,Symbol,字段...）")
+	sectorsFile := fs.String("sectors", "", "行业分类文件，用于行业中性化")
+	sizeField := fs.String("size-field", "", "市值字段，用于市值中性化")
+	output := fs.String("out", "factor_report.html", "图表输出文件")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		return fmt.Errorf("需要指定 -dir")
+	}
+
+	startDate, err := time.Parse("2006-01-02", *start)
+	if err != nil {
+		return fmt.Errorf("开始日期格式错误: %v", err)
+	}
+	endDate, err := time.Parse("2006-01-02", *end)
+	if err != nil {
+		return fmt.Errorf("结束日期格式错误: %v", err)
+	}
+	endDate = endDate.Add(24*time.Hour - time.Second)
+
+	var horizons []int
+	for _, text := range strings.Split(*horizonsText, ",") {
+		h, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil || h < 1 {
+			return fmt.Errorf("持有期错误: %s", text)
+		}
+		horizons = append(horizons, h)
+	}
+
+	pipeline, err := newPipeline(*factors, *fundamentalsFile, *sectorsFile, *sizeField)
+	if err != nil {
+		return err
+	}
+
+	ds := datasource.NewDirectoryDataSource(*dir)
+	symbols, err := ds.Symbols()
+	if err != nil {
+		return err
+	}
+	histories := make(map[string][]*types.DataPoint, len(symbols))
+	for _, symbol := range symbols {
+		data, err := ds.GetData(symbol, datasource.PeriodTypeDay, startDate, endDate)
+		if err != nil {
+			return err
+		}
+		histories[symbol] = data
+	}
+
+	name := strings.Join(pipeline.Factors(), "+")
+	report := factor.Analyze(name, pipeline.Panel(histories), factor.ForwardReturns(histories, horizons), *quantiles)
+
+	fmt.Printf("因子 %s (%d只股票, %d个交易日)\n", name, len(symbols), len(report.Dates))
+	fmt.Printf("%6s %8s %8s %8s %8s %8s %8s %10s\n", "持有期", "IC均值", "RankIC", "IR", "t值", "IC>0", "期数", "多空收益")
+	for _, h := range report.Horizons {
+		fmt.Printf("%6d %8.4f %8.4f %8.2f %8.2f %7.1f%% %8d %9.2f%%\n",
+			h.Horizon, h.ICStats.Mean, h.RankICStats.Mean, h.RankICStats.IR, h.RankICStats.TStat,
+			h.RankICStats.Positive*100, h.RankICStats.Count, h.SpreadMean*100)
+		for q, r := range h.QuantileMean {
+			fmt.Printf("       Q%d 平均收益: %.3f%%\n", q+1, r*100)
+		}
+	}
+	fmt.Printf("平均自相关: %.3f\n", report.MeanAutocorrelation())
+	fmt.Printf("Q1平均换手率: %.1f%%\n", report.MeanTurnover(0)*100)
+
+	if err := visualization.PlotFactorReport(report, *output); err != nil {
+		return fmt.Errorf("生成图表失败: %v", err)
+	}
+	fmt.Printf("图表已保存到 %s\n", *output)
+	return nil
+}
+
+// runPairs 对两只股票做协整检验并回测配对交易策略
+func runPairs(args []string) error {
+	fs := flag.NewFlagSet("pairs", flag.ContinueOnError)
+	dir := fs.String("dir", "", "数据目录，每只股票一个文件（600036.SH.csv或sh600036.day）")
+	y := fs.String("y", "", "第一条腿的股票代码")
+	x := fs.String("x", "", "第二条腿的股票代码（对冲腿）")
+	hedge := fs.String("hedge", "ols", "对冲比例估计方法: ols或kalman")
+	window := fs.Int("window", 60, "滚动回归和Z值的窗口")
+	entry := fs.Float64("entry", 2, "开仓Z值阈值")
+	exit := fs.Float64("exit", 0.5, "平仓Z值阈值")
+	stop := fs.Float64("stop", 0, "止损Z值阈值，0不止损")
+	fraction := fs.Float64("fraction", 0.5, "两条腿合计占用的权益比例")
+	start := fs.String("start", "2020-01-01", "开始日期")
+	end := fs.String("end", "2022-12-31", "结束日期")
+	cash := fs.Float64("cash", 1000000, "初始资金")
+	margin := fs.Bool("margin", false, "通过融资融券账户卖空，否则不限制卖空")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" || *y == "" || *x == "" {
+		return fmt.Errorf("需要指定 -dir、-y 和 -x")
+	}
+
+	startDate, err := time.Parse("2006-01-02", *start)
+	if err != nil {
+		return fmt.Errorf("开始日期格式错误: %v", err)
+	}
+	endDate, err := time.Parse("2006-01-02", *end)
+	if err != nil {
+		return fmt.Errorf("结束日期格式错误: %v", err)
+	}
+	endDate = endDate.Add(24*time.Hour - time.Second)
+
+	var estimator pairs.HedgeEstimator
+	switch *hedge {
+	case "ols":
+		estimator = pairs.NewRollingOLS(*window)
+	case "kalman":
+		estimator = pairs.NewKalman(1e-4, 1e-3, *window)
+	default:
+		return fmt.Errorf("未知的对冲比例估计方法: %s", *hedge)
+	}
+
+	ds := datasource.NewDirectoryDataSource(*dir)
+	ySeries, err := ds.GetData(*y, datasource.PeriodTypeDay, startDate, endDate)
+	if err != nil {
+		return err
+	}
+	xSeries, err := ds.GetData(*x, datasource.PeriodTypeDay, startDate, endDate)
+	if err != nil {
+		return err
+	}
+	xClose := make(map[time.Time]float64, len(xSeries))
+	for _, dp := range xSeries {
+		xClose[dp.Timestamp] = dp.Close
+	}
+	var ys, xs []float64
+	for _, dp := range ySeries {
+		if c, ok := xClose[dp.Timestamp]; ok {
+			ys = append(ys, dp.Close)
+			xs = append(xs, c)
+		}
+	}
+	coint, err := pairs.EngleGranger(ys, xs, 1)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("协整检验 %s ~ %s (%d个样本):\n", *y, *x, len(ys))
+	fmt.Printf("对冲比例: %.4f, 截距: %.4f\n", coint.Beta, coint.Alpha)
+	fmt.Printf("ADF统计量: %.3f (1%%: %.3f, 5%%: %.3f, 10%%: %.3f)\n",
+		coint.ADF.Stat, coint.Critical[0.01], coint.Critical[0.05], coint.Critical[0.10])
+	fmt.Printf("半衰期: %.1f, 5%%水平协整: %v\n", coint.HalfLife, coint.Cointegrated())
+
+	pairsStrategy := strategy.NewPairsStrategy(*y, *x, estimator, *window)
+	pairsStrategy.SetThresholds(*entry, *exit, *stop)
+	pairsStrategy.SetFraction(*fraction)
+
+	logger := common.NewConsoleLogger()
+	simBroker := broker.NewSimulatedBroker(
+		broker.NewFixedFeeCalculator(backtest.DefaultFeeConfig.Commission),
+		types.Logger(logger),
+		*cash,
+	)
+	if *margin {
+		simBroker.SetMargin(broker.DefaultMarginConfig())
+	} else {
+		simBroker.SetAllowShort(true)
+	}
+	bt := backtest.NewBacktest(startDate, endDate, *cash, ds, simBroker, logger, []string{*y, *x})
+	bt.AddStrategy(pairsStrategy)
+
+	results, err := bt.Run()
+	if err != nil {
+		return err
+	}
+	result := results.Results[0]
+	a := analyzer.NewAnalyzer(result.Trades, *cash)
+	fmt.Printf("\n配对交易 %s/%s (%s) 回测结果:\n", *y, *x, estimator.Name())
+	fmt.Printf("最终资产: %.2f\n", result.FinalValue)
+	fmt.Printf("总收益率: %.2f%%\n", a.TotalReturn(result.FinalValue)*100)
+	fmt.Printf("年化收益率: %.2f%%\n", a.AnnualizedReturn(result.FinalValue, startDate, endDate)*100)
+	fmt.Printf("最大回撤: %.2f%%\n", result.MaxDrawdown*100)
+	fmt.Printf("交易次数: %d\n", len(result.Trades))
+	printMarginSummary(result)
+	printOrderErrors("开平仓", pairsStrategy.Errors())
+	return nil
+}
@@ -0,0 +1,84 @@
+package clock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Clock 时钟接口，订单和成交的时间戳都从时钟获取
+type Clock interface {
+	Now() time.Time
+}
+
+// WallClock 系统时钟，用于实盘和未注入模拟时钟的场景
+type WallClock struct{}
+
+func (WallClock) Now() time.Time {
+	return time.Now()
+}
+
+// SimulationClock 模拟时钟，由回测引擎按K线时间推进，相同输入的两次回测得到完全相同的时间戳
+type SimulationClock struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+// NewSimulationClock 创建从start开始的模拟时钟
+func NewSimulationClock(start time.Time) *SimulationClock {
+	return &SimulationClock{now: start}
+}
+
+func (c *SimulationClock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.now
+}
+
+// Set 将时钟设置到指定时间
+func (c *SimulationClock) Set(t time.Time) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+}
+
+// 订单和成交编号前缀，经纪商、订单管理器和组合统一使用
+const (
+	OrderIDPrefix = "ORD"
+	TradeIDPrefix = "TRD"
+)
+
+// Sequence 顺序编号生成器，生成 prefix-000001、prefix-000002…
+type Sequence struct {
+	mu     sync.Mutex
+	prefix string
+	next   int
+}
+
+// NewSequence 创建以prefix为前缀的编号生成器
+func NewSequence(prefix string) *Sequence {
+	return &Sequence{prefix: prefix}
+}
+
+// Next 返回下一个编号
+func (s *Sequence) Next() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	return fmt.Sprintf("%s-%06d", s.prefix, s.next)
+}
+
+// SequenceNumber 返回Sequence生成的编号中的序号，格式不符时返回-1
+func SequenceNumber(id string) int {
+	i := strings.LastIndexByte(id, '-')
+	if i < 0 {
+		return -1
+	}
+	n, err := strconv.Atoi(id[i+1:])
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -3,6 +3,7 @@ package common
 import (
 	"fmt"
 	"stock/common/types"
+	"stock/risk"
 )
 
 type ConsoleLogger struct{}
@@ -15,13 +16,35 @@ func (l *ConsoleLogger) LogData(data *types.DataPoint) {
 	// 禁用数据日志
 }
 
+// LogRiskEvent 输出风控事件
+func (l *ConsoleLogger) LogRiskEvent(event risk.Event) {
+	fmt.Printf("[风控] %s\n", event)
+}
+
 func (l *ConsoleLogger) LogTrade(trade types.Trade) {
-	// 交易日志格式
-	action := "买入"
-	if trade.Type == types.ActionSell {
-		action = "卖出"
+	switch trade.Type {
+	case types.ActionDividend:
+		gross := trade.Quantity * trade.Price
+		fmt.Printf("[分红] %s %s 持股%.2f股 每股%.4f元, 税前: %.2f元, 红利税: %.2f元, 实收: %.2f元\n",
+			trade.Timestamp.Format("2006-01-02"),
+			trade.Symbol,
+			trade.Quantity,
+			trade.Price,
+			gross,
+			trade.Fee,
+			gross-trade.Fee)
+		return
+	case types.ActionBonus:
+		fmt.Printf("[送股] %s %s 新增%.2f股\n",
+			trade.Timestamp.Format("2006-01-02"),
+			trade.Symbol,
+			trade.Quantity)
+		return
 	}
 
+	// 交易日志格式
+	action := trade.Type.String()
+
 	totalAmount := trade.Quantity * trade.Price
 	netAmount := totalAmount - trade.Fee
 
@@ -42,3 +65,16 @@ func (l *ConsoleLogger) LogEnd(portfolio types.Portfolio) {
 		portfolio.GetCash(),
 		portfolio.GetPositions())
 }
+
+// NopLogger 不输出任何日志，用于批量模拟
+type NopLogger struct{}
+
+func NewNopLogger() *NopLogger {
+	return &NopLogger{}
+}
+
+func (l *NopLogger) LogData(data *types.DataPoint) {}
+
+func (l *NopLogger) LogTrade(trade types.Trade) {}
+
+func (l *NopLogger) LogEnd(portfolio types.Portfolio) {}
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ProgressBar 终端进度条，用回车符原地刷新，通常输出到stderr以免混入回测日志
+type ProgressBar struct {
+	w     io.Writer
+	width int
+	last  int // 上次刷新时的千分比，避免每个交易日都刷新
+}
+
+// NewProgressBar 创建宽度为width个字符的进度条
+func NewProgressBar(w io.Writer, width int) *ProgressBar {
+	if width <= 0 {
+		width = 40
+	}
+	return &ProgressBar{w: w, width: width, last: -1}
+}
+
+// Update 按完成比例刷新进度条，label显示在进度条之后
+func (b *ProgressBar) Update(fraction float64, label string) {
+	if fraction < 0 {
+		fraction = 0
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	permille := int(fraction * 1000)
+	if permille == b.last {
+		return
+	}
+	b.last = permille
+	filled := int(fraction * float64(b.width))
+	fmt.Fprintf(b.w, "\r[%s%s] %5.1f%% %s\033[K",
+		strings.Repeat("#", filled), strings.Repeat(".", b.width-filled), fraction*100, label)
+}
+
+// Finish 结束进度条并换行
+func (b *ProgressBar) Finish() {
+	if b.last >= 0 {
+		fmt.Fprintln(b.w)
+	}
+	b.last = -1
+}
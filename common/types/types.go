@@ -2,6 +2,7 @@ package types
 
 import (
 	"errors"
+	"math"
 	"time"
 )
 
@@ -34,8 +35,28 @@ const (
 	ActionBuy Action = iota
 	ActionSell
 	ActionHold
+	ActionDividend // 现金分红入账
+	ActionBonus    // 送转股入账
 )
 
+// String 返回交易动作的中文名称
+func (a Action) String() string {
+	switch a {
+	case ActionBuy:
+		return "买入"
+	case ActionSell:
+		return "卖出"
+	case ActionHold:
+		return "持有"
+	case ActionDividend:
+		return "分红"
+	case ActionBonus:
+		return "送股"
+	default:
+		return "未知"
+	}
+}
+
 // Broker 定义经纪人接口
 type Broker interface {
 	ExecuteOrder(order *Order) error
@@ -65,7 +86,8 @@ func (o *Order) CanCancel() bool {
 	return o.Status == OrderStatusNew
 }
 
-func (o *Order) SetStatus(status OrderStatus) error {
+// SetStatus updates the order status; timestamp is recorded as UpdatedAt
+func (o *Order) SetStatus(status OrderStatus, timestamp time.Time) error {
 	// 验证状态转换
 	switch status {
 	case OrderStatusFilled:
@@ -83,7 +105,7 @@ func (o *Order) SetStatus(status OrderStatus) error {
 	}
 
 	o.Status = status
-	o.UpdatedAt = time.Now()
+	o.UpdatedAt = timestamp
 	return nil
 }
 
@@ -118,15 +140,32 @@ func NewPosition(symbol string) *Position {
 	}
 }
 
-// 更新仓位信息
+// 更新仓位信息，数量为负表示空头仓位
 func (p *Position) Update(price float64, quantity float64, action Action) {
-	if action == ActionBuy {
-		totalCost := p.AvgPrice*p.Quantity + price*quantity
-		p.Quantity += quantity
-		p.AvgPrice = totalCost / p.Quantity
-	} else if action == ActionSell {
-		p.Quantity -= quantity
-		p.RealizedPL += (price - p.AvgPrice) * quantity
+	signed := quantity
+	if action == ActionSell {
+		signed = -quantity
+	}
+	if action == ActionBuy || action == ActionSell {
+		switch {
+		case p.Quantity == 0 || (p.Quantity > 0) == (signed > 0):
+			// 开仓或加仓
+			totalCost := p.AvgPrice*math.Abs(p.Quantity) + price*quantity
+			p.Quantity += signed
+			p.AvgPrice = totalCost / math.Abs(p.Quantity)
+		default:
+			// 减仓或平仓，超出部分反向开仓
+			closed := math.Min(quantity, math.Abs(p.Quantity))
+			if p.Quantity > 0 {
+				p.RealizedPL += (price - p.AvgPrice) * closed
+			} else {
+				p.RealizedPL += (p.AvgPrice - price) * closed
+			}
+			p.Quantity += signed
+			if quantity > closed {
+				p.AvgPrice = price
+			}
+		}
 	}
 	p.MarketValue = p.Quantity * price
 	p.UnrealizedPL = (price - p.AvgPrice) * p.Quantity
@@ -178,6 +217,24 @@ type Trade struct {
 	Symbol    string
 }
 
+// CorporateAction 公司行为（除权除息）事件
+type CorporateAction struct {
+	Symbol       string
+	ExDate       time.Time // 除权除息日
+	CashDividend float64   // 每股现金分红（税前）
+	BonusRatio   float64   // 每股送转股数，如10送3为0.3
+	SplitRatio   float64   // 拆股比例，如1拆2为2，0表示无拆股
+}
+
+// ShareMultiplier 返回除权后每股对应的股数
+func (c CorporateAction) ShareMultiplier() float64 {
+	multiplier := 1 + c.BonusRatio
+	if c.SplitRatio > 0 {
+		multiplier *= c.SplitRatio
+	}
+	return multiplier
+}
+
 // MACDValue MACD指标值
 type MACDValue struct {
 	MACD      float64
@@ -263,4 +320,6 @@ var (
 	ErrInvalidDateRange      = errors.New("invalid date range")
 	ErrInvalidInitialCash    = errors.New("invalid initial cash")
 	ErrNoStrategy            = errors.New("no strategy configured")
+	ErrPriceLimit            = errors.New("price limit reached")
+	ErrNotSellable           = errors.New("position not sellable until next trading day")
 )
@@ -0,0 +1,316 @@
+package datasource
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"stock/common/types"
+)
+
+// AdjustType 复权类型
+type AdjustType int
+
+const (
+	AdjustNone     AdjustType = iota // 不复权
+	AdjustForward                    // 前复权
+	AdjustBackward                   // 后复权
+)
+
+// SourceFile 基于本地文件的数据源，缓存依据源文件修改时间判断是否失效
+type SourceFile interface {
+	SourcePath() string
+}
+
+// VersionedSource 非文件数据源提供数据版本，版本变化时缓存失效
+type VersionedSource interface {
+	DataVersion() string
+}
+
+// AdjustedSource 自行复权的数据源（如东方财富接口），缓存不再重复复权
+type AdjustedSource interface {
+	Adjust() AdjustType
+}
+
+// DefaultCacheTTL 既没有源文件也没有数据版本的数据源的默认缓存有效期
+const DefaultCacheTTL = 24 * time.Hour
+
+// 缓存文件格式（小端序）:
+//
+//	magic[4] version(uint32) key(uint64) writtenAt(int64) from(int64) to(int64) count(uint64)
+//	Time[count](int64) Open[count] High[count] Low[count] Close[count] Volume[count](float64)
+const (
+	cacheMagic      = "SBTC"
+	cacheVersion    = 3
+	cacheHeaderSize = 48
+	cacheColumns    = 6
+)
+
+// cacheFullEnd 加载全部历史时使用的结束时间
+var cacheFullEnd = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// CachedDataSource 为任意数据源增加本地列式二进制缓存
+type CachedDataSource struct {
+	source  DataSource
+	dir     string
+	adjust  AdjustType
+	actions []types.CorporateAction
+	ttl     time.Duration
+}
+
+func NewCachedDataSource(source DataSource, dir string, adjust AdjustType) *CachedDataSource {
+	return &CachedDataSource{
+		source: source,
+		dir:    dir,
+		adjust: adjust,
+		ttl:    DefaultCacheTTL,
+	}
+}
+
+// SetCorporateActions 设置公司行为事件，不复权数据源按缓存的复权类型复权后写入缓存
+func (c *CachedDataSource) SetCorporateActions(actions []types.CorporateAction) {
+	c.actions = make([]types.CorporateAction, len(actions))
+	copy(c.actions, actions)
+	sort.SliceStable(c.actions, func(i, j int) bool {
+		return c.actions[i].ExDate.Before(c.actions[j].ExDate)
+	})
+}
+
+// SetTTL 设置缓存有效期，只对既没有源文件也没有数据版本的数据源生效，0表示永不过期
+func (c *CachedDataSource) SetTTL(ttl time.Duration) {
+	c.ttl = ttl
+}
+
+func (c *CachedDataSource) GetData(symbol string, period PeriodType, start, end time.Time) ([]*types.DataPoint, error) {
+	path := c.cachePath(symbol, period)
+	key, notBefore := c.cacheKey(symbol)
+
+	if points, ok := readCacheRange(path, key, notBefore, symbol, start, end); ok {
+		return points, nil
+	}
+
+	// 缓存缺失或已失效，从源数据加载全部历史后写入缓存
+	all, err := c.source.GetData(symbol, period, time.Time{}, cacheFullEnd)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Put(symbol, period, all); err != nil {
+		return nil, err
+	}
+
+	points, ok := readCacheRange(path, key, notBefore, symbol, start, end)
+	if !ok {
+		return nil, fmt.Errorf("读取缓存文件失败: %s", path)
+	}
+	return points, nil
+}
+
+// Put 将外部获取的全部历史数据按缓存的复权类型处理后写入缓存
+func (c *CachedDataSource) Put(symbol string, period PeriodType, points []*types.DataPoint) error {
+	return c.PutRange(symbol, period, time.Time{}, cacheFullEnd, points)
+}
+
+// PutRange 写入[start, end]区间的数据，之后超出该区间的请求会重新从数据源加载全部历史
+func (c *CachedDataSource) PutRange(symbol string, period PeriodType, start, end time.Time, points []*types.DataPoint) error {
+	adjusted, err := c.adjusted(symbol, points)
+	if err != nil {
+		return err
+	}
+	key, _ := c.cacheKey(symbol)
+	return writeCache(c.cachePath(symbol, period), key, start, end, adjusted)
+}
+
+// Invalidate 删除指定股票和周期的缓存
+func (c *CachedDataSource) Invalidate(symbol string, period PeriodType) error {
+	err := os.Remove(c.cachePath(symbol, period))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *CachedDataSource) GetSupportedPeriods() []PeriodType {
+	return c.source.GetSupportedPeriods()
+}
+
+func (c *CachedDataSource) ConvertPeriod(data []*types.DataPoint, targetPeriod PeriodType) ([]*types.DataPoint, error) {
+	return c.source.ConvertPeriod(data, targetPeriod)
+}
+
+func (c *CachedDataSource) cachePath(symbol string, period PeriodType) string {
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(symbol)
+	return filepath.Join(c.dir, fmt.Sprintf("%s_p%d_a%d.col", name, period, c.adjust))
+}
+
+// adjusted 自行复权的数据源必须与缓存复权类型一致，不复权数据源按公司行为复权
+func (c *CachedDataSource) adjusted(symbol string, points []*types.DataPoint) ([]*types.DataPoint, error) {
+	if src, ok := c.source.(AdjustedSource); ok {
+		if src.Adjust() != c.adjust {
+			return nil, fmt.Errorf("数据源复权类型%d与缓存复权类型%d不一致", src.Adjust(), c.adjust)
+		}
+		return points, nil
+	}
+	if c.adjust == AdjustNone {
+		return points, nil
+	}
+	sorted := make([]*types.DataPoint, len(points))
+	copy(sorted, points)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+	return AdjustPrices(sorted, c.symbolActions(symbol), c.adjust), nil
+}
+
+func (c *CachedDataSource) symbolActions(symbol string) []types.CorporateAction {
+	var actions []types.CorporateAction
+	for _, action := range c.actions {
+		if action.Symbol == symbol {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// cacheKey 计算缓存有效性标识：源文件修改时间或数据版本、复权类型和该股票的公司行为，
+// 都没有时按有效期判断，返回写入时间的下限（纳秒），0表示不检查
+func (c *CachedDataSource) cacheKey(symbol string) (key uint64, notBefore int64) {
+	h := fnv.New64a()
+	switch src := c.source.(type) {
+	case SourceFile:
+		if info, err := os.Stat(src.SourcePath()); err == nil {
+			fmt.Fprintf(h, "file:%d", info.ModTime().UnixNano())
+		}
+	case VersionedSource:
+		fmt.Fprintf(h, "version:%s", src.DataVersion())
+	default:
+		if c.ttl > 0 {
+			notBefore = time.Now().Add(-c.ttl).UnixNano()
+		}
+	}
+	fmt.Fprintf(h, "|adjust:%d", c.adjust)
+	if _, ok := c.source.(AdjustedSource); !ok && c.adjust != AdjustNone {
+		for _, action := range c.symbolActions(symbol) {
+			fmt.Fprintf(h, "|%d,%g,%g,%g", action.ExDate.Unix(), action.CashDividend, action.BonusRatio, action.SplitRatio)
+		}
+	}
+	return h.Sum64(), notBefore
+}
+
+func writeCache(path string, key uint64, from, to time.Time, points []*types.DataPoint) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("创建缓存目录失败: %v", err)
+	}
+
+	sorted := make([]*types.DataPoint, len(points))
+	copy(sorted, points)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	n := len(sorted)
+	buf := make([]byte, cacheHeaderSize+n*8*cacheColumns)
+	copy(buf[0:4], cacheMagic)
+	binary.LittleEndian.PutUint32(buf[4:8], cacheVersion)
+	binary.LittleEndian.PutUint64(buf[8:16], key)
+	binary.LittleEndian.PutUint64(buf[16:24], uint64(time.Now().UnixNano()))
+	binary.LittleEndian.PutUint64(buf[24:32], uint64(from.Unix()))
+	binary.LittleEndian.PutUint64(buf[32:40], uint64(to.Unix()))
+	binary.LittleEndian.PutUint64(buf[40:48], uint64(n))
+
+	for i, p := range sorted {
+		values := [cacheColumns]uint64{
+			uint64(p.Timestamp.Unix()),
+			math.Float64bits(p.Open),
+			math.Float64bits(p.High),
+			math.Float64bits(p.Low),
+			math.Float64bits(p.Close),
+			math.Float64bits(p.Volume),
+		}
+		for col, v := range values {
+			offset := cacheHeaderSize + (col*n+i)*8
+			binary.LittleEndian.PutUint64(buf[offset:offset+8], v)
+		}
+	}
+
+	// 先写临时文件再重命名，避免读到写了一半的缓存
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o644); err != nil {
+		return fmt.Errorf("写入缓存文件失败: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// readCacheRange 读取缓存中(start, end)区间内的数据，缓存不存在、标识不一致、写入时间早于notBefore
+// 或请求区间超出缓存覆盖的区间时返回false
+func readCacheRange(path string, key uint64, notBefore int64, symbol string, start, end time.Time) ([]*types.DataPoint, bool) {
+	buf, release, err := mapFile(path)
+	if err != nil {
+		return nil, false
+	}
+	defer release()
+
+	if len(buf) < cacheHeaderSize ||
+		string(buf[0:4]) != cacheMagic ||
+		binary.LittleEndian.Uint32(buf[4:8]) != cacheVersion ||
+		binary.LittleEndian.Uint64(buf[8:16]) != key ||
+		int64(binary.LittleEndian.Uint64(buf[16:24])) < notBefore ||
+		start.Unix() < int64(binary.LittleEndian.Uint64(buf[24:32])) ||
+		end.Unix() > int64(binary.LittleEndian.Uint64(buf[32:40])) {
+		return nil, false
+	}
+	n := int(binary.LittleEndian.Uint64(buf[40:48]))
+	if len(buf) != cacheHeaderSize+n*8*cacheColumns {
+		return nil, false
+	}
+
+	column := func(col, i int) uint64 {
+		offset := cacheHeaderSize + (col*n+i)*8
+		return binary.LittleEndian.Uint64(buf[offset : offset+8])
+	}
+
+	// 时间列有序，二分查找区间边界，只解码需要的行
+	from := sort.Search(n, func(i int) bool {
+		return int64(column(0, i)) > start.Unix()
+	})
+	to := sort.Search(n, func(i int) bool {
+		return int64(column(0, i)) >= end.Unix()
+	})
+	if to < from {
+		to = from
+	}
+
+	// MA5按全部历史计算，区间前4根K线的收盘价也要读入
+	points := make([]*types.DataPoint, 0, to-from)
+	closePrices := make([]float64, 0, to-from+4)
+	first := from - 4
+	if first < 0 {
+		first = 0
+	}
+	for i := first; i < to; i++ {
+		closePrice := math.Float64frombits(column(4, i))
+		closePrices = append(closePrices, closePrice)
+		if i < from {
+			continue
+		}
+		points = append(points, &types.DataPoint{
+			Symbol:    symbol,
+			Timestamp: time.Unix(int64(column(0, i)), 0).UTC(),
+			Open:      math.Float64frombits(column(1, i)),
+			High:      math.Float64frombits(column(2, i)),
+			Low:       math.Float64frombits(column(3, i)),
+			Close:     closePrice,
+			Volume:    math.Float64frombits(column(5, i)),
+			Indicators: map[string]float64{
+				"MA5": calculateMA(closePrices, 5),
+			},
+		})
+	}
+
+	return points, true
+}
@@ -0,0 +1,217 @@
+package datasource
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stock/common/types"
+)
+
+// memSource 内存数据源，记录GetData调用次数
+type memSource struct {
+	closes []float64
+	start  time.Time
+	calls  int
+}
+
+func (s *memSource) GetData(symbol string, period PeriodType, start, end time.Time) ([]*types.DataPoint, error) {
+	s.calls++
+	var points []*types.DataPoint
+	for i, c := range s.closes {
+		ts := s.start.AddDate(0, 0, i)
+		if ts.Before(start) || ts.After(end) {
+			continue
+		}
+		points = append(points, &types.DataPoint{
+			Symbol: symbol, Timestamp: ts, Open: c, High: c, Low: c, Close: c, Volume: 100,
+		})
+	}
+	return points, nil
+}
+
+func (s *memSource) GetSupportedPeriods() []PeriodType {
+	return []PeriodType{PeriodTypeDay}
+}
+
+func (s *memSource) ConvertPeriod(data []*types.DataPoint, targetPeriod PeriodType) ([]*types.DataPoint, error) {
+	return data, nil
+}
+
+// versionedSource 提供数据版本的内存数据源
+type versionedSource struct {
+	memSource
+	version string
+}
+
+func (s *versionedSource) DataVersion() string {
+	return s.version
+}
+
+var cacheStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func closesOf(points []*types.DataPoint) []float64 {
+	closes := make([]float64, len(points))
+	for i, p := range points {
+		closes[i] = p.Close
+	}
+	return closes
+}
+
+func TestCacheAdjust(t *testing.T) {
+	// 1月3日除权：每股分红1元并10送10，除权因子(10−1)/(10×2)=0.45
+	actions := []types.CorporateAction{
+		{Symbol: "600000.SH", ExDate: cacheStart.AddDate(0, 0, 2), CashDividend: 1, BonusRatio: 1},
+		{Symbol: "600036.SH", ExDate: cacheStart.AddDate(0, 0, 1), SplitRatio: 10},
+	}
+	tests := []struct {
+		adjust AdjustType
+		want   []float64
+	}{
+		{AdjustNone, []float64{10, 10, 4.5, 4.6}},
+		{AdjustForward, []float64{4.5, 4.5, 4.5, 4.6}},
+		{AdjustBackward, []float64{10, 10, 10, 4.6 / 0.45}},
+	}
+	for _, tt := range tests {
+		source := &memSource{closes: []float64{10, 10, 4.5, 4.6}, start: cacheStart}
+		cache := NewCachedDataSource(source, t.TempDir(), tt.adjust)
+		cache.SetCorporateActions(actions)
+		points, err := cache.GetData("600000.SH", PeriodTypeDay, time.Time{}, cacheFullEnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := closesOf(points)
+		if len(got) != len(tt.want) {
+			t.Fatalf("复权类型%d读取%d条，期望%d条", tt.adjust, len(got), len(tt.want))
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+				t.Errorf("复权类型%d收盘价%v，期望%v", tt.adjust, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCacheAdjustedSourceMismatch(t *testing.T) {
+	cache := NewCachedDataSource(NewEastmoneyDataSource("", AdjustForward), t.TempDir(), AdjustBackward)
+	if err := cache.Put("600036.SH", PeriodTypeDay, nil); err == nil {
+		t.Fatal("数据源与缓存复权类型不一致时应返回错误")
+	}
+}
+
+func TestCacheMAUsesFullHistory(t *testing.T) {
+	source := &memSource{closes: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, start: cacheStart}
+	cache := NewCachedDataSource(source, t.TempDir(), AdjustNone)
+	// 区间从第6根K线（收盘价6）开始，MA5应包含之前4根
+	points, err := cache.GetData("600000.SH", PeriodTypeDay, cacheStart.AddDate(0, 0, 4), cacheFullEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if points[0].Close != 6 || points[0].Indicators["MA5"] != 4 {
+		t.Errorf("首根K线收盘价%v MA5=%v，期望6和4", points[0].Close, points[0].Indicators["MA5"])
+	}
+}
+
+func TestCacheMAMatchesFileSources(t *testing.T) {
+	dir := t.TempDir()
+	closes := []float64{10, 10.2, 10.1, 10.5, 10.4, 10.8, 11, 10.9, 11.3, 11.2}
+	points := make([]*types.DataPoint, len(closes))
+	records := make([]TDXDayRecord, len(closes))
+	for i, c := range closes {
+		ts := cacheStart.AddDate(0, 0, i)
+		points[i] = &types.DataPoint{Timestamp: ts, Open: c, High: c, Low: c, Close: c, Volume: 100}
+		cents := uint32(math.Round(c * 100))
+		records[i] = TDXDayRecord{
+			Date: uint32(ts.Year()*10000 + int(ts.Month())*100 + ts.Day()),
+			Open: cents, High: cents, Low: cents, Close: cents, Volume: 100,
+		}
+	}
+	csvPath := filepath.Join(dir, "600000.SH.csv")
+	if err := WriteCSV(csvPath, points); err != nil {
+		t.Fatal(err)
+	}
+	tdxPath := filepath.Join(dir, "sh600000.day")
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, records); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tdxPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 区间从第6根K线开始，有无缓存时MA5都应包含区间之前的K线
+	start, end := cacheStart.AddDate(0, 0, 4), cacheStart.AddDate(0, 0, 20)
+	for _, source := range []DataSource{NewCSVDataSource(csvPath), NewTDXDataSource(tdxPath)} {
+		direct, err := source.GetData("600000.SH", PeriodTypeDay, start, end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cached, err := NewCachedDataSource(source, t.TempDir(), AdjustNone).GetData("600000.SH", PeriodTypeDay, start, end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(direct) != 5 || len(cached) != len(direct) {
+			t.Fatalf("%T读取%d条，缓存读取%d条，期望5条", source, len(direct), len(cached))
+		}
+		for i := range direct {
+			if math.Abs(direct[i].Indicators["MA5"]-cached[i].Indicators["MA5"]) > 1e-9 {
+				t.Errorf("%T第%d根K线MA5=%v，缓存MA5=%v", source, i, direct[i].Indicators["MA5"], cached[i].Indicators["MA5"])
+			}
+		}
+		if want := (10.2 + 10.1 + 10.5 + 10.4 + 10.8) / 5; math.Abs(direct[0].Indicators["MA5"]-want) > 1e-9 {
+			t.Errorf("%T首根K线MA5=%v，期望%v", source, direct[0].Indicators["MA5"], want)
+		}
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	source := &versionedSource{memSource: memSource{closes: []float64{1, 2, 3}, start: cacheStart}, version: "v1"}
+	cache := NewCachedDataSource(source, t.TempDir(), AdjustNone)
+	for i := 0; i < 2; i++ {
+		if _, err := cache.GetData("600000.SH", PeriodTypeDay, time.Time{}, cacheFullEnd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if source.calls != 1 {
+		t.Fatalf("版本不变时加载%d次，期望只加载1次", source.calls)
+	}
+	source.version = "v2"
+	if _, err := cache.GetData("600000.SH", PeriodTypeDay, time.Time{}, cacheFullEnd); err != nil {
+		t.Fatal(err)
+	}
+	if source.calls != 2 {
+		t.Errorf("版本变化后加载%d次，期望重新加载", source.calls)
+	}
+
+	// 没有版本的数据源按有效期失效
+	plain := &memSource{closes: []float64{1, 2, 3}, start: cacheStart}
+	cache = NewCachedDataSource(plain, t.TempDir(), AdjustNone)
+	cache.SetTTL(time.Nanosecond)
+	for i := 0; i < 2; i++ {
+		if _, err := cache.GetData("600000.SH", PeriodTypeDay, time.Time{}, cacheFullEnd); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if plain.calls != 2 {
+		t.Errorf("缓存过期后加载%d次，期望每次都重新加载", plain.calls)
+	}
+}
+
+func TestCacheLoadsBeyond2038(t *testing.T) {
+	start := time.Date(2038, 1, 18, 0, 0, 0, 0, time.UTC)
+	source := &memSource{closes: []float64{1, 2, 3, 4}, start: start}
+	cache := NewCachedDataSource(source, t.TempDir(), AdjustNone)
+	points, err := cache.GetData("600000.SH", PeriodTypeDay, time.Time{}, time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 4 {
+		t.Errorf("读取%d条，期望2038年1月19日之后的K线也写入缓存", len(points))
+	}
+}
@@ -0,0 +1,120 @@
+package datasource
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"stock/common/types"
+)
+
+// LoadCorporateActions 从CSV文件加载公司行为事件
+// 文件格式: Symbol,ExDate,CashDividend,BonusRatio,SplitRatio
+func LoadCorporateActions(path string) ([]types.CorporateAction, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开公司行为文件失败: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("读取公司行为文件失败: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	actions := make([]types.CorporateAction, 0, len(records)-1)
+	for i, record := range records[1:] { // 跳过表头
+		if len(record) < 5 {
+			return nil, fmt.Errorf("第%d行字段数量不足", i+2)
+		}
+
+		exDate, err := time.Parse("2006-01-02", record[1])
+		if err != nil {
+			return nil, fmt.Errorf("第%d行除权日期格式错误: %v", i+2, err)
+		}
+
+		values := make([]float64, 3)
+		for j := range values {
+			if record[j+2] == "" {
+				continue
+			}
+			values[j], err = strconv.ParseFloat(record[j+2], 64)
+			if err != nil {
+				return nil, fmt.Errorf("第%d行数值格式错误: %v", i+2, err)
+			}
+		}
+
+		actions = append(actions, types.CorporateAction{
+			Symbol:       record[0],
+			ExDate:       exDate,
+			CashDividend: values[0],
+			BonusRatio:   values[1],
+			SplitRatio:   values[2],
+		})
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].ExDate.Before(actions[j].ExDate)
+	})
+	return actions, nil
+}
+
+// AdjustPrices 按公司行为对按时间排序的不复权K线复权，返回新的数据点，成交量不变
+//
+// 除权因子 = (前收盘价 − 每股分红) / (前收盘价 × 每股送转后股数)，
+// 前复权将除权日之前的价格乘以其后所有除权因子，后复权将除权日及之后的价格除以此前所有除权因子
+func AdjustPrices(points []*types.DataPoint, actions []types.CorporateAction, adjust AdjustType) []*types.DataPoint {
+	factors := make([]float64, len(points))
+	for i := range factors {
+		factors[i] = 1
+	}
+	for _, action := range actions {
+		// 除权日当天或之后的第一根K线
+		i := sort.Search(len(points), func(k int) bool {
+			return !points[k].Timestamp.Before(action.ExDate)
+		})
+		if i == 0 || i == len(points) {
+			continue
+		}
+		prevClose := points[i-1].Close
+		if factor := (prevClose - action.CashDividend) / (prevClose * action.ShareMultiplier()); factor > 0 {
+			factors[i] *= factor
+		}
+	}
+
+	adjusted := make([]*types.DataPoint, len(points))
+	scale := 1.0
+	switch adjust {
+	case AdjustForward:
+		for i := len(points) - 1; i >= 0; i-- {
+			adjusted[i] = scalePrices(points[i], scale)
+			scale *= factors[i]
+		}
+	case AdjustBackward:
+		for i := range points {
+			scale /= factors[i]
+			adjusted[i] = scalePrices(points[i], scale)
+		}
+	default:
+		for i := range points {
+			adjusted[i] = scalePrices(points[i], 1)
+		}
+	}
+	return adjusted
+}
+
+func scalePrices(p *types.DataPoint, scale float64) *types.DataPoint {
+	point := *p
+	point.Open *= scale
+	point.High *= scale
+	point.Low *= scale
+	point.Close *= scale
+	return &point
+}
@@ -63,11 +63,16 @@ func (ds *CSVDataSource) GetData(symbol string, period PeriodType, start, end ti
 		low, _ := strconv.ParseFloat(record[4], 64)
 		volume, _ := strconv.ParseFloat(record[5], 64)
 
-		if timestamp.After(start) && timestamp.Before(end) {
-			closePrices = append(closePrices, close)
+		// MA5按文件中的全部历史计算，与缓存数据源一致
+		if !timestamp.Before(end) {
+			continue
+		}
+		closePrices = append(closePrices, close)
+		if timestamp.After(start) {
 			ma5 := calculateMA(closePrices, 5)
 
 			points = append(points, &types.DataPoint{
+				Symbol:    symbol,
 				Timestamp: timestamp,
 				Open:      open,
 				High:      high,
@@ -84,21 +89,46 @@ func (ds *CSVDataSource) GetData(symbol string, period PeriodType, start, end ti
 	return points, nil
 }
 
+// WriteCSV 将数据按CSVDataSource可读取的格式写入文件
+func WriteCSV(path string, points []*types.DataPoint) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"Date", "Open", "Close", "High", "Low", "Volume"}); err != nil {
+		return err
+	}
+	for _, p := range points {
+		record := []string{
+			p.Timestamp.Format("2006-01-02"),
+			strconv.FormatFloat(p.Open, 'f', -1, 64),
+			strconv.FormatFloat(p.Close, 'f', -1, 64),
+			strconv.FormatFloat(p.High, 'f', -1, 64),
+			strconv.FormatFloat(p.Low, 'f', -1, 64),
+			strconv.FormatFloat(p.Volume, 'f', -1, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// SourcePath 返回数据文件路径
+func (ds *CSVDataSource) SourcePath() string {
+	return ds.path
+}
+
 func (ds *CSVDataSource) GetSupportedPeriods() []PeriodType {
 	return []PeriodType{PeriodTypeDay}
 }
 
 func (ds *CSVDataSource) ConvertPeriod(data []*types.DataPoint, targetPeriod PeriodType) ([]*types.DataPoint, error) {
-	if targetPeriod == PeriodTypeDay {
-		return data, nil
-	}
-
-	// 按周转换
-	if targetPeriod == PeriodTypeWeek {
-		return convertToWeekly(data)
-	}
-
-	return nil, fmt.Errorf("unsupported period conversion: %v", targetPeriod)
+	return Resample(data, targetPeriod)
 }
 
 // TDXDataSource 通达信数据源
@@ -159,11 +189,16 @@ func (ds *TDXDataSource) GetData(symbol string, period PeriodType, start, end ti
 		day := int(record.Date % 100)
 		timestamp := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
 
-		if timestamp.After(start) && timestamp.Before(end) {
-			closePrices = append(closePrices, float64(record.Close))
+		// MA5按文件中的全部历史计算，与缓存数据源一致；价格单位为分
+		if !timestamp.Before(end) {
+			continue
+		}
+		closePrices = append(closePrices, float64(record.Close)/100)
+		if timestamp.After(start) {
 			ma5 := calculateMA(closePrices, 5)
 
 			points = append(points, &types.DataPoint{
+				Symbol:    symbol,
 				Timestamp: timestamp,
 				Open:      float64(record.Open) / 100,
 				High:      float64(record.High) / 100,
@@ -193,58 +228,70 @@ func calculateMA(prices []float64, period int) float64 {
 	return sum / float64(period)
 }
 
+// SourcePath 返回数据文件路径
+func (ds *TDXDataSource) SourcePath() string {
+	return ds.path
+}
+
 func (ds *TDXDataSource) GetSupportedPeriods() []PeriodType {
 	return []PeriodType{PeriodTypeDay}
 }
 
 func (ds *TDXDataSource) ConvertPeriod(data []*types.DataPoint, targetPeriod PeriodType) ([]*types.DataPoint, error) {
-	if targetPeriod == PeriodTypeDay {
-		return data, nil
-	}
-
-	// 按周转换
-	if targetPeriod == PeriodTypeWeek {
-		return convertToWeekly(data)
-	}
-
-	return nil, fmt.Errorf("unsupported period conversion: %v", targetPeriod)
+	return Resample(data, targetPeriod)
 }
 
-func convertToWeekly(data []*types.DataPoint) ([]*types.DataPoint, error) {
-	if len(data) == 0 {
-		return nil, nil
+// Resample 将日线数据合成周线（ISO周）或月线，K线时间为该周期内最后一根日线的日期
+//
+// 相邻两根日线属于不同自然周期时开始新的K线，周期最后一个交易日停牌或缺失时不会与下一周期合并，
+// 最后一个未完结周期同样以实际最后一根日线为时间
+func Resample(data []*types.DataPoint, targetPeriod PeriodType) ([]*types.DataPoint, error) {
+	var periodKey func(t time.Time) int
+	switch targetPeriod {
+	case PeriodTypeDay:
+		return data, nil
+	case PeriodTypeWeek:
+		periodKey = func(t time.Time) int {
+			year, week := t.ISOWeek()
+			return year*100 + week
+		}
+	case PeriodTypeMonth:
+		periodKey = func(t time.Time) int {
+			return t.Year()*100 + int(t.Month())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported period conversion: %v", targetPeriod)
 	}
 
-	var weeklyData []*types.DataPoint
-	var currentWeek *types.DataPoint
+	var resampled []*types.DataPoint
+	var current *types.DataPoint
+	key := 0
 
 	for _, dp := range data {
-		year, week := dp.Timestamp.ISOWeek()
-		currentYear, currentWeekNum := currentWeek.Timestamp.ISOWeek()
-		if currentWeek == nil || currentYear != year || currentWeekNum != week {
-			if currentWeek != nil {
-				weeklyData = append(weeklyData, currentWeek)
-			}
-			currentWeek = &types.DataPoint{
-				Timestamp:  dp.Timestamp,
+		if current != nil && periodKey(dp.Timestamp) != key {
+			resampled = append(resampled, current)
+			current = nil
+		}
+		if current == nil {
+			key = periodKey(dp.Timestamp)
+			current = &types.DataPoint{
+				Symbol:     dp.Symbol,
 				Open:       dp.Open,
 				High:       dp.High,
 				Low:        dp.Low,
-				Close:      dp.Close,
-				Volume:     dp.Volume,
+				Volume:     0,
 				Indicators: make(map[string]float64),
 			}
-		} else {
-			currentWeek.High = math.Max(currentWeek.High, dp.High)
-			currentWeek.Low = math.Min(currentWeek.Low, dp.Low)
-			currentWeek.Close = dp.Close
-			currentWeek.Volume += dp.Volume
 		}
+		current.Timestamp = dp.Timestamp
+		current.High = math.Max(current.High, dp.High)
+		current.Low = math.Min(current.Low, dp.Low)
+		current.Close = dp.Close
+		current.Volume += dp.Volume
 	}
-
-	if currentWeek != nil {
-		weeklyData = append(weeklyData, currentWeek)
+	if current != nil {
+		resampled = append(resampled, current)
 	}
 
-	return weeklyData, nil
+	return resampled, nil
 }
@@ -0,0 +1,100 @@
+package datasource
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"stock/common/types"
+)
+
+// DirectoryDataSource 目录数据源，每只股票一个文件，用于全市场回测
+//
+// 支持的文件名：
+//
+//	600036.SH.csv   CSV格式，与CSVDataSource相同
+//	sh600036.day    通达信日线
+type DirectoryDataSource struct {
+	dir string
+}
+
+func NewDirectoryDataSource(dir string) *DirectoryDataSource {
+	return &DirectoryDataSource{dir: dir}
+}
+
+// tdxFileName 将 600036.SH 转换为通达信文件名 sh600036.day
+func tdxFileName(symbol string) string {
+	parts := strings.SplitN(symbol, ".", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return strings.ToLower(parts[1]) + parts[0] + ".day"
+}
+
+// symbolFromFile 由文件名还原股票代码，无法识别时返回空字符串
+func symbolFromFile(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".csv"):
+		return strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+	case strings.HasSuffix(lower, ".day") && len(name) > 6:
+		code := strings.TrimSuffix(name, filepath.Ext(name))
+		return code[2:] + "." + strings.ToUpper(code[:2])
+	}
+	return ""
+}
+
+// source 查找股票对应的文件
+func (ds *DirectoryDataSource) source(symbol string) (DataSource, error) {
+	csvPath := filepath.Join(ds.dir, symbol+".csv")
+	if _, err := os.Stat(csvPath); err == nil {
+		return NewCSVDataSource(csvPath), nil
+	}
+	if name := tdxFileName(symbol); name != "" {
+		dayPath := filepath.Join(ds.dir, name)
+		if _, err := os.Stat(dayPath); err == nil {
+			return NewTDXDataSource(dayPath), nil
+		}
+	}
+	return nil, fmt.Errorf("目录%s中没有%s的数据文件", ds.dir, symbol)
+}
+
+// Symbols 返回目录中所有股票代码
+func (ds *DirectoryDataSource) Symbols() ([]string, error) {
+	entries, err := os.ReadDir(ds.dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取数据目录失败: %v", err)
+	}
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if symbol := symbolFromFile(entry.Name()); symbol != "" && !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols, nil
+}
+
+func (ds *DirectoryDataSource) GetData(symbol string, period PeriodType, start, end time.Time) ([]*types.DataPoint, error) {
+	source, err := ds.source(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return source.GetData(symbol, period, start, end)
+}
+
+func (ds *DirectoryDataSource) GetSupportedPeriods() []PeriodType {
+	return []PeriodType{PeriodTypeDay}
+}
+
+func (ds *DirectoryDataSource) ConvertPeriod(data []*types.DataPoint, targetPeriod PeriodType) ([]*types.DataPoint, error) {
+	return Resample(data, targetPeriod)
+}
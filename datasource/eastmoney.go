@@ -0,0 +1,319 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock/common/types"
+)
+
+// DefaultEastmoneyBaseURL 东方财富历史K线接口地址
+const DefaultEastmoneyBaseURL = "http://push2his.eastmoney.com"
+
+// eastmoneyKlinePath K线接口路径
+const eastmoneyKlinePath = "/api/qt/stock/kline/get"
+
+// EastmoneyKlineResponse 东方财富K线接口响应
+type EastmoneyKlineResponse struct {
+	RC   int `json:"rc"`
+	Data *struct {
+		Code    string   `json:"code"`
+		Market  int      `json:"market"`
+		Name    string   `json:"name"`
+		Klines  []string `json:"klines"`
+		DKTotal int      `json:"dktotal"`
+	} `json:"data"`
+}
+
+// EastmoneyDataSource 东方财富K线数据源
+type EastmoneyDataSource struct {
+	baseURL   string
+	adjust    AdjustType
+	client    *http.Client
+	pageSize  int
+	retries   int
+	retryWait time.Duration
+	version   string
+}
+
+func NewEastmoneyDataSource(baseURL string, adjust AdjustType) *EastmoneyDataSource {
+	if baseURL == "" {
+		baseURL = DefaultEastmoneyBaseURL
+	}
+	return &EastmoneyDataSource{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		adjust:    adjust,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		pageSize:  1000,
+		retries:   3,
+		retryWait: 2 * time.Second,
+	}
+}
+
+// SetHTTPClient 设置HTTP客户端
+func (ds *EastmoneyDataSource) SetHTTPClient(client *http.Client) {
+	ds.client = client
+}
+
+// SetPageSize 设置每次请求的K线数量
+func (ds *EastmoneyDataSource) SetPageSize(size int) {
+	if size > 0 {
+		ds.pageSize = size
+	}
+}
+
+// SetRetry 设置失败重试次数和间隔
+func (ds *EastmoneyDataSource) SetRetry(retries int, wait time.Duration) {
+	ds.retries = retries
+	ds.retryWait = wait
+}
+
+// Adjust 返回请求接口时使用的复权类型
+func (ds *EastmoneyDataSource) Adjust() AdjustType {
+	return ds.adjust
+}
+
+func (ds *EastmoneyDataSource) GetData(symbol string, period PeriodType, start, end time.Time) ([]*types.DataPoint, error) {
+	secid, err := EastmoneySecID(symbol)
+	if err != nil {
+		return nil, err
+	}
+	klt, err := eastmoneyKlineType(period)
+	if err != nil {
+		return nil, err
+	}
+
+	// 接口按结束日期向前返回lmt条数据，从区间末尾向前分页。结束日期只精确到日，
+	// 下一页以本页最早的日期为结束日期，分钟和小时K线当天剩余的数据才不会丢失，重复的K线按时间去重
+	var points []*types.DataPoint
+	seen := make(map[time.Time]bool)
+	pageEnd := end
+	for {
+		klines, err := ds.fetchWithRetry(secid, klt, start, pageEnd)
+		if err != nil {
+			return nil, err
+		}
+
+		var earliest time.Time
+		added := 0
+		for _, line := range klines {
+			point, err := parseEastmoneyKline(symbol, line)
+			if err != nil {
+				return nil, err
+			}
+			if !point.Timestamp.After(start) || !point.Timestamp.Before(end) {
+				continue
+			}
+			if earliest.IsZero() || point.Timestamp.Before(earliest) {
+				earliest = point.Timestamp
+			}
+			if !seen[point.Timestamp] {
+				seen[point.Timestamp] = true
+				points = append(points, point)
+				added++
+			}
+		}
+
+		if len(klines) < ds.pageSize || earliest.IsZero() {
+			break
+		}
+		if added == 0 {
+			return nil, fmt.Errorf("每页%d条K线不足以覆盖%s一天的数据，请增大每页条数", ds.pageSize, earliest.Format("2006-01-02"))
+		}
+		pageEnd = earliest
+		if !pageEnd.After(start) {
+			break
+		}
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Timestamp.Before(points[j].Timestamp)
+	})
+
+	// 接口只返回请求区间的数据，MA5从区间第一根K线开始计算；需要全部历史时通过缓存数据源读取
+	closePrices := make([]float64, 0, len(points))
+	for _, p := range points {
+		closePrices = append(closePrices, p.Close)
+		p.Indicators["MA5"] = calculateMA(closePrices, 5)
+	}
+	return points, nil
+}
+
+func (ds *EastmoneyDataSource) GetSupportedPeriods() []PeriodType {
+	return []PeriodType{PeriodTypeMinute, PeriodTypeHour, PeriodTypeDay, PeriodTypeWeek, PeriodTypeMonth}
+}
+
+func (ds *EastmoneyDataSource) ConvertPeriod(data []*types.DataPoint, targetPeriod PeriodType) ([]*types.DataPoint, error) {
+	return Resample(data, targetPeriod)
+}
+
+// ImportCSV 拉取数据并保存为CSV文件，返回数据条数
+func (ds *EastmoneyDataSource) ImportCSV(symbol string, period PeriodType, start, end time.Time, path string) (int, error) {
+	points, err := ds.GetData(symbol, period, start, end)
+	if err != nil {
+		return 0, err
+	}
+	return len(points), WriteCSV(path, points)
+}
+
+// ImportCache 拉取数据并写入本地缓存，返回数据条数；缓存记录导入的区间，超出区间的请求会重新拉取
+func (ds *EastmoneyDataSource) ImportCache(symbol string, period PeriodType, start, end time.Time, cache *CachedDataSource) (int, error) {
+	points, err := ds.GetData(symbol, period, start, end)
+	if err != nil {
+		return 0, err
+	}
+	return len(points), cache.PutRange(symbol, period, start, end, points)
+}
+
+// DataVersion 缓存使用的数据版本，由接口地址和复权类型决定，导入的数据不会按有效期过期；
+// 前复权价格在每次除权后都会变化，需要调用SetDataVersion或Invalidate更新缓存
+func (ds *EastmoneyDataSource) DataVersion() string {
+	if ds.version != "" {
+		return ds.version
+	}
+	return fmt.Sprintf("%s|fqt%d", ds.baseURL, ds.adjust)
+}
+
+// SetDataVersion 设置数据版本，版本变化后已有缓存失效
+func (ds *EastmoneyDataSource) SetDataVersion(version string) {
+	ds.version = version
+}
+
+func (ds *EastmoneyDataSource) fetchWithRetry(secid string, klt int, start, end time.Time) ([]string, error) {
+	var lastErr error
+	for attempt := 0; attempt <= ds.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(ds.retryWait)
+		}
+		klines, err := ds.fetch(secid, klt, start, end)
+		if err == nil {
+			return klines, nil
+		}
+		lastErr = err
+	}
+	return nil, fmt.Errorf("获取东方财富K线数据失败(重试%d次): %v", ds.retries, lastErr)
+}
+
+func (ds *EastmoneyDataSource) fetch(secid string, klt int, start, end time.Time) ([]string, error) {
+	params := url.Values{}
+	params.Set("secid", secid)
+	params.Set("fields1", "f1,f2,f3,f4,f5")
+	params.Set("fields2", "f51,f52,f53,f54,f55,f56,f57")
+	params.Set("klt", strconv.Itoa(klt))
+	params.Set("fqt", strconv.Itoa(int(ds.adjust)))
+	params.Set("beg", start.Format("20060102"))
+	params.Set("end", end.Format("20060102"))
+	params.Set("lmt", strconv.Itoa(ds.pageSize))
+
+	resp, err := ds.client.Get(ds.baseURL + eastmoneyKlinePath + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	var result EastmoneyKlineResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	if result.RC != 0 {
+		return nil, fmt.Errorf("接口返回错误码: %d", result.RC)
+	}
+	if result.Data == nil {
+		return nil, nil
+	}
+	return result.Data.Klines, nil
+}
+
+// EastmoneySecID 将股票代码转换为东方财富secid，如600036.SH转换为1.600036
+func EastmoneySecID(symbol string) (string, error) {
+	code := strings.ToUpper(symbol)
+	market := ""
+	switch {
+	case strings.HasSuffix(code, ".SH"):
+		code, market = strings.TrimSuffix(code, ".SH"), "1"
+	case strings.HasSuffix(code, ".SZ"):
+		code, market = strings.TrimSuffix(code, ".SZ"), "0"
+	case strings.HasPrefix(code, "SH"):
+		code, market = strings.TrimPrefix(code, "SH"), "1"
+	case strings.HasPrefix(code, "SZ"):
+		code, market = strings.TrimPrefix(code, "SZ"), "0"
+	}
+
+	if len(code) != 6 {
+		return "", types.ErrInvalidSymbol
+	}
+	if _, err := strconv.Atoi(code); err != nil {
+		return "", types.ErrInvalidSymbol
+	}
+	if market == "" {
+		// 无后缀时按代码首位判断：沪市主板、科创板、基金、债券以5/6/9/11开头
+		market = "0"
+		if code[0] == '5' || code[0] == '6' || code[0] == '9' || strings.HasPrefix(code, "11") {
+			market = "1"
+		}
+	}
+	return market + "." + code, nil
+}
+
+func eastmoneyKlineType(period PeriodType) (int, error) {
+	switch period {
+	case PeriodTypeMinute:
+		return 1, nil
+	case PeriodTypeHour:
+		return 60, nil
+	case PeriodTypeDay:
+		return 101, nil
+	case PeriodTypeWeek:
+		return 102, nil
+	case PeriodTypeMonth:
+		return 103, nil
+	default:
+		return 0, fmt.Errorf("unsupported period: %v", period)
+	}
+}
+
+// parseEastmoneyKline 解析K线字符串: 日期,开盘,收盘,最高,最低,成交量,成交额,...
+func parseEastmoneyKline(symbol, line string) (*types.DataPoint, error) {
+	fields := strings.Split(line, ",")
+	if len(fields) < 6 {
+		return nil, fmt.Errorf("K线数据格式错误: %s", line)
+	}
+
+	layout := "2006-01-02"
+	if len(fields[0]) > len(layout) {
+		layout = "2006-01-02 15:04"
+	}
+	timestamp, err := time.Parse(layout, fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("K线日期格式错误: %v", err)
+	}
+
+	values := make([]float64, 5)
+	for i := range values {
+		values[i], err = strconv.ParseFloat(fields[i+1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("K线数值格式错误: %v", err)
+		}
+	}
+
+	return &types.DataPoint{
+		Symbol:     symbol,
+		Timestamp:  timestamp,
+		Open:       values[0],
+		Close:      values[1],
+		High:       values[2],
+		Low:        values[3],
+		Volume:     values[4],
+		Indicators: make(map[string]float64),
+	}, nil
+}
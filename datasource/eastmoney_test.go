@@ -0,0 +1,257 @@
+package datasource
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stock/common/types"
+	"stock/datasource/emtest"
+)
+
+// 录制数据为600036.SH 2020年全年243根前复权日K线
+var (
+	emStart = time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)
+	emEnd   = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func newTestEastmoney(server *emtest.Server, adjust AdjustType) *EastmoneyDataSource {
+	ds := NewEastmoneyDataSource(server.URL, adjust)
+	ds.SetRetry(3, 0)
+	return ds
+}
+
+// checkKlines 检查数据条数、首尾日期和时间严格递增
+func checkKlines(t *testing.T, points []*types.DataPoint) {
+	t.Helper()
+	if len(points) != 243 {
+		t.Fatalf("获取%d条K线，期望243条", len(points))
+	}
+	if first := points[0].Timestamp.Format("2006-01-02"); first != "2020-01-02" {
+		t.Errorf("首根K线日期%s，期望2020-01-02", first)
+	}
+	if last := points[len(points)-1].Timestamp.Format("2006-01-02"); last != "2020-12-31" {
+		t.Errorf("末根K线日期%s，期望2020-12-31", last)
+	}
+	for i := 1; i < len(points); i++ {
+		if !points[i].Timestamp.After(points[i-1].Timestamp) {
+			t.Fatalf("第%d根K线时间%v未晚于前一根%v", i, points[i].Timestamp, points[i-1].Timestamp)
+		}
+	}
+}
+
+func samePrices(a, b *types.DataPoint) bool {
+	return a.Timestamp.Equal(b.Timestamp) && a.Open == b.Open && a.High == b.High &&
+		a.Low == b.Low && a.Close == b.Close && a.Volume == b.Volume
+}
+
+func TestEastmoneyPaging(t *testing.T) {
+	server := emtest.NewServer()
+	defer server.Close()
+
+	ds := newTestEastmoney(server, AdjustForward)
+	ds.SetPageSize(50)
+	points, err := ds.GetData("600036.SH", PeriodTypeDay, emStart, emEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkKlines(t, points)
+	// 4页满页加1页不足50条
+	if got := server.Requests(); got != 5 {
+		t.Errorf("请求%d次，期望5次", got)
+	}
+
+	whole, err := newTestEastmoney(server, AdjustForward).GetData("600036.SH", PeriodTypeDay, emStart, emEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range whole {
+		if !samePrices(points[i], whole[i]) {
+			t.Fatalf("第%d根K线分页结果%+v与单页结果%+v不一致", i, points[i], whole[i])
+		}
+	}
+	first := points[0]
+	if first.Open != 30.35 || first.Close != 31.20 || first.High != 31.44 || first.Low != 30.34 || first.Volume != 826245 {
+		t.Errorf("首根K线字段解析错误: %+v", first)
+	}
+}
+
+func TestEastmoneyAdjustMapping(t *testing.T) {
+	server := emtest.NewServer()
+	defer server.Close()
+
+	tests := []struct {
+		adjust AdjustType
+		fqt    string
+		count  int
+	}{
+		{AdjustNone, "0", 0},
+		{AdjustForward, "1", 243},
+		{AdjustBackward, "2", 0},
+	}
+	for _, tt := range tests {
+		points, err := newTestEastmoney(server, tt.adjust).GetData("600036.SH", PeriodTypeDay, emStart, emEnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query := server.LastQuery()
+		if got := query.Get("fqt"); got != tt.fqt {
+			t.Errorf("复权类型%d请求fqt=%s，期望%s", tt.adjust, got, tt.fqt)
+		}
+		if got := query.Get("secid"); got != "1.600036" {
+			t.Errorf("secid=%s，期望1.600036", got)
+		}
+		// 替身只录制了前复权数据，其他复权类型与真实接口的未知代码一样返回空
+		if len(points) != tt.count {
+			t.Errorf("复权类型%d获取%d条K线，期望%d条", tt.adjust, len(points), tt.count)
+		}
+	}
+}
+
+func TestEastmoneyRetry(t *testing.T) {
+	server := emtest.NewServer()
+	defer server.Close()
+
+	server.FailNext(2)
+	points, err := newTestEastmoney(server, AdjustForward).GetData("600036.SH", PeriodTypeDay, emStart, emEnd)
+	if err != nil {
+		t.Fatalf("重试后仍失败: %v", err)
+	}
+	checkKlines(t, points)
+	if got := server.Requests(); got != 3 {
+		t.Errorf("请求%d次，期望失败2次后第3次成功", got)
+	}
+
+	server.FailNext(10)
+	ds := newTestEastmoney(server, AdjustForward)
+	ds.SetRetry(2, 0)
+	if _, err := ds.GetData("600036.SH", PeriodTypeDay, emStart, emEnd); err == nil {
+		t.Fatal("超过重试次数应返回错误")
+	}
+	if got := server.Requests() - 3; got != 3 {
+		t.Errorf("请求%d次，期望首次请求加重试2次共3次", got)
+	}
+}
+
+func TestEastmoneyImportCSV(t *testing.T) {
+	server := emtest.NewServer()
+	defer server.Close()
+
+	ds := newTestEastmoney(server, AdjustForward)
+	path := filepath.Join(t.TempDir(), "600036.csv")
+	n, err := ds.ImportCSV("600036.SH", PeriodTypeDay, emStart, emEnd, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 243 {
+		t.Fatalf("导入%d条，期望243条", n)
+	}
+
+	want, err := ds.GetData("600036.SH", PeriodTypeDay, emStart, emEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewCSVDataSource(path).GetData("600036.SH", PeriodTypeDay, emStart, emEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkKlines(t, got)
+	for i := range want {
+		if !samePrices(got[i], want[i]) {
+			t.Fatalf("第%d根K线CSV读回%+v，期望%+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestEastmoneyImportCache(t *testing.T) {
+	server := emtest.NewServer()
+	ds := newTestEastmoney(server, AdjustForward)
+	cache := NewCachedDataSource(ds, t.TempDir(), AdjustForward)
+	n, err := ds.ImportCache("600036.SH", PeriodTypeDay, emStart, emEnd, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 243 {
+		t.Fatalf("导入%d条，期望243条", n)
+	}
+	want, err := ds.GetData("600036.SH", PeriodTypeDay, emStart, emEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 关闭替身服务器，之后的数据只能来自缓存
+	server.Close()
+
+	got, err := cache.GetData("600036.SH", PeriodTypeDay, emStart, emEnd)
+	if err != nil {
+		t.Fatalf("读取缓存失败: %v", err)
+	}
+	checkKlines(t, got)
+	for i := range want {
+		if !samePrices(got[i], want[i]) {
+			t.Fatalf("第%d根K线缓存读回%+v，期望%+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestEastmoneyIntradayPaging(t *testing.T) {
+	server := emtest.NewServer()
+	defer server.Close()
+
+	// 5个交易日每天4根小时K线，每页6条时每页都从某一天的中间截断
+	ds := newTestEastmoney(server, AdjustForward)
+	ds.SetPageSize(6)
+	points, err := ds.GetData("600036.SH", PeriodTypeHour, emStart, emEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 20 {
+		t.Fatalf("获取%d根小时K线，期望20根", len(points))
+	}
+	for i := 1; i < len(points); i++ {
+		if !points[i].Timestamp.After(points[i-1].Timestamp) {
+			t.Fatalf("第%d根K线时间%v未晚于前一根%v", i, points[i].Timestamp, points[i-1].Timestamp)
+		}
+	}
+
+	// 每页条数少于一天的K线数时无法向前翻页
+	ds.SetPageSize(3)
+	if _, err := ds.GetData("600036.SH", PeriodTypeHour, emStart, emEnd); err == nil {
+		t.Error("每页条数不足一天时应返回错误")
+	}
+}
+
+func TestEastmoneyImportCacheRange(t *testing.T) {
+	server := emtest.NewServer()
+	defer server.Close()
+
+	ds := newTestEastmoney(server, AdjustForward)
+	cache := NewCachedDataSource(ds, t.TempDir(), AdjustForward)
+	// 导入的数据按数据版本判断有效，不受有效期影响
+	cache.SetTTL(time.Nanosecond)
+	from := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)
+	n, err := ds.ImportCache("600036.SH", PeriodTypeDay, from, to, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	time.Sleep(time.Millisecond)
+
+	got, err := cache.GetData("600036.SH", PeriodTypeDay, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != n || server.Requests() != requests {
+		t.Errorf("导入区间内读取%d条、请求%d次，期望从缓存读取%d条", len(got), server.Requests()-requests, n)
+	}
+
+	// 超出导入区间时重新拉取全部历史，而不是返回空数据
+	got, err = cache.GetData("600036.SH", PeriodTypeDay, emStart, emEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkKlines(t, got)
+	if server.Requests() == requests {
+		t.Error("超出导入区间时应重新请求接口")
+	}
+}
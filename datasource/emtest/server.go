@@ -0,0 +1,136 @@
+// Package emtest 提供东方财富K线接口的离线替身服务器，使用录制的响应数据
+package emtest
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed testdata/*.json
+var fixtures embed.FS
+
+// klineResponse 录制的接口响应
+type klineResponse struct {
+	RC   int                    `json:"rc"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// Server 东方财富K线接口替身
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	failures int // 剩余需要模拟失败的请求数
+	requests int
+	query    url.Values
+}
+
+// NewServer 启动替身服务器，按secid、fqt和klt加载testdata/kline_<secid>_fqt<fqt>_klt<klt>.json，
+// 没有该文件时加载日线数据testdata/kline_<secid>_fqt<fqt>.json
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handleKline))
+	return s
+}
+
+// FailNext 使接下来的n次请求返回500，用于验证重试逻辑
+func (s *Server) FailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+}
+
+// LastQuery 返回最近一次请求的查询参数
+func (s *Server) LastQuery() url.Values {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.query
+}
+
+// Requests 返回已收到的请求数
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) handleKline(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	s.query = r.URL.Query()
+	fail := s.failures > 0
+	if fail {
+		s.failures--
+	}
+	s.mu.Unlock()
+
+	if fail {
+		http.Error(w, "simulated failure", http.StatusInternalServerError)
+		return
+	}
+	if r.URL.Path != "/api/qt/stock/kline/get" {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	// 日线以外的周期优先加载带klt后缀的录制数据
+	name := fmt.Sprintf("testdata/kline_%s_fqt%s_klt%s.json", query.Get("secid"), query.Get("fqt"), query.Get("klt"))
+	raw, err := fixtures.ReadFile(name)
+	if err != nil {
+		name = fmt.Sprintf("testdata/kline_%s_fqt%s.json", query.Get("secid"), query.Get("fqt"))
+		raw, err = fixtures.ReadFile(name)
+	}
+	if err != nil {
+		// 与真实接口一致，未知代码返回data为null
+		writeJSON(w, klineResponse{RC: 0})
+		return
+	}
+
+	var resp klineResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	klines := filterKlines(resp.Data["klines"], query.Get("beg"), query.Get("end"))
+	if limit, err := strconv.Atoi(query.Get("lmt")); err == nil && limit > 0 && len(klines) > limit {
+		// 接口从结束日期向前截取lmt条
+		klines = klines[len(klines)-limit:]
+	}
+	resp.Data["klines"] = klines
+	writeJSON(w, resp)
+}
+
+// filterKlines 按yyyymmdd格式的起止日期筛选K线
+func filterKlines(raw interface{}, beg, end string) []string {
+	items, _ := raw.([]interface{})
+	klines := make([]string, 0, len(items))
+	for _, item := range items {
+		line, ok := item.(string)
+		if !ok || len(line) < 10 {
+			continue
+		}
+		date := strings.ReplaceAll(line[:10], "-", "")
+		if beg != "" && date < beg {
+			continue
+		}
+		if end != "" && date > end {
+			continue
+		}
+		klines = append(klines, line)
+	}
+	return klines
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
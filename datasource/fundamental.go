@@ -0,0 +1,103 @@
+package datasource
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fundamentalRecord 某只股票在某个公告日的基本面数据
+type fundamentalRecord struct {
+	date   time.Time
+	values map[string]float64
+}
+
+// Fundamentals 按公告日排列的基本面数据，查询时只使用当日及之前公布的数据，避免未来函数
+type Fundamentals struct {
+	fields  []string
+	records map[string][]fundamentalRecord
+}
+
+// LoadFundamentals 读取基本面CSV，表头为 Date,Symbol,字段1,字段2...，Date为公告日
+func LoadFundamentals(path string) (*Fundamentals, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开基本面数据文件失败: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("读取基本面数据表头失败: %v", err)
+	}
+	if len(header) < 3 {
+		return nil, fmt.Errorf("基本面数据至少需要Date,Symbol和一个字段")
+	}
+
+	f := &Fundamentals{records: make(map[string][]fundamentalRecord)}
+	for _, name := range header[2:] {
+		f.fields = append(f.fields, strings.TrimSpace(name))
+	}
+
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("第%d行格式错误: %v", line, err)
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("第%d行日期格式错误: %v", line, err)
+		}
+		symbol := strings.TrimSpace(record[1])
+		values := make(map[string]float64, len(f.fields))
+		for i, name := range f.fields {
+			text := strings.TrimSpace(record[i+2])
+			if text == "" {
+				values[name] = math.NaN()
+				continue
+			}
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("第%d行%s格式错误: %v", line, name, err)
+			}
+			values[name] = value
+		}
+		f.records[symbol] = append(f.records[symbol], fundamentalRecord{date: date, values: values})
+	}
+
+	for _, records := range f.records {
+		sort.SliceStable(records, func(i, j int) bool {
+			return records[i].date.Before(records[j].date)
+		})
+	}
+	return f, nil
+}
+
+// Fields 返回字段名
+func (f *Fundamentals) Fields() []string {
+	return f.fields
+}
+
+// Lookup 返回截至date最新公布的基本面数据，没有数据时返回nil
+func (f *Fundamentals) Lookup(symbol string, date time.Time) map[string]float64 {
+	records := f.records[symbol]
+	idx := sort.Search(len(records), func(i int) bool {
+		return records[i].date.After(date)
+	})
+	if idx == 0 {
+		return nil
+	}
+	return records[idx-1].values
+}
@@ -0,0 +1,257 @@
+package datasource
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"stock/calendar"
+	"stock/common/types"
+	"stock/instrument"
+)
+
+// Roll 连续合约的一次换月：Date当天收盘后由From换到To，Gap为当天两个合约的收盘价差
+type Roll struct {
+	Date time.Time
+	From string
+	To   string
+	Gap  float64
+}
+
+// ContinuousFuturesDataSource 股指期货连续合约数据源，目录中每个月份合约一个文件（如 IF2303.CFE.csv）
+//
+// 支持的连续合约代码：
+//
+//	IF888、IF.CFE   主力连续，直接拼接各合约行情
+//	IF889           主力连续，按换月价差向前复权（差值法），价格变动与实际持仓盈亏一致
+//
+// 主力合约按成交量确定，只向更远月份切换；距交割日不足rollDays个交易日时强制换月。
+// 换月由当天收盘后的成交量决定，次一交易日起使用新合约，不使用未来数据。
+// 具体月份合约代码直接从目录读取。
+type ContinuousFuturesDataSource struct {
+	dir      *DirectoryDataSource
+	calendar *calendar.Calendar
+	rollDays int
+	byVolume bool
+
+	mu    sync.Mutex
+	rolls map[string][]Roll
+}
+
+func NewContinuousFuturesDataSource(dir string) *ContinuousFuturesDataSource {
+	return &ContinuousFuturesDataSource{
+		dir:      NewDirectoryDataSource(dir),
+		calendar: calendar.Default(),
+		rollDays: 2,
+		byVolume: true,
+		rolls:    make(map[string][]Roll),
+	}
+}
+
+// SetRollDays 设置距交割日多少个交易日前强制换月
+func (ds *ContinuousFuturesDataSource) SetRollDays(days int) {
+	ds.rollDays = days
+}
+
+// SetRollByVolume 设置是否在远月合约成交量超过当前合约时提前换月，关闭后只在临近交割时换月
+func (ds *ContinuousFuturesDataSource) SetRollByVolume(enabled bool) {
+	ds.byVolume = enabled
+}
+
+// SetCalendar 设置计算交割日使用的交易日历
+func (ds *ContinuousFuturesDataSource) SetCalendar(cal *calendar.Calendar) {
+	ds.calendar = cal
+}
+
+// Rolls 返回最近一次GetData生成的连续合约换月记录
+func (ds *ContinuousFuturesDataSource) Rolls(symbol string) []Roll {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.rolls[symbol]
+}
+
+// Expiry 股指期货最后交易日：合约月份第三个周五，遇节假日顺延
+func (ds *ContinuousFuturesDataSource) Expiry(contract string) (time.Time, bool) {
+	year, month, ok := instrument.ContractMonth(contract)
+	if !ok {
+		return time.Time{}, false
+	}
+	day := time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
+	for day.Weekday() != time.Friday {
+		day = day.AddDate(0, 0, 1)
+	}
+	return ds.calendar.Align(day.AddDate(0, 0, 14)), true
+}
+
+// Contracts 返回目录中某个品种的所有月份合约，按交割日排序
+func (ds *ContinuousFuturesDataSource) Contracts(root string) ([]string, error) {
+	symbols, err := ds.dir.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	var contracts []string
+	for _, symbol := range symbols {
+		if _, _, ok := instrument.ContractMonth(symbol); ok && instrument.FutureRoot(symbol) == root {
+			contracts = append(contracts, symbol)
+		}
+	}
+	sort.Slice(contracts, func(i, j int) bool {
+		ei, _ := ds.Expiry(contracts[i])
+		ej, _ := ds.Expiry(contracts[j])
+		return ei.Before(ej)
+	})
+	return contracts, nil
+}
+
+func (ds *ContinuousFuturesDataSource) GetData(symbol string, period PeriodType, start, end time.Time) ([]*types.DataPoint, error) {
+	if !instrument.IsContinuous(symbol) {
+		return ds.dir.GetData(symbol, period, start, end)
+	}
+	if period != PeriodTypeDay {
+		return nil, fmt.Errorf("连续合约只支持日线数据")
+	}
+	root := instrument.FutureRoot(symbol)
+	contracts, err := ds.Contracts(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(contracts) == 0 {
+		return nil, fmt.Errorf("目录中没有%s的月份合约数据", root)
+	}
+
+	bars := make(map[string]map[time.Time]*types.DataPoint)
+	expiry := make(map[string]time.Time)
+	dateSet := make(map[time.Time]bool)
+	for _, contract := range contracts {
+		data, err := ds.dir.GetData(contract, PeriodTypeDay, start, end)
+		if err != nil {
+			return nil, fmt.Errorf("读取%s失败: %v", contract, err)
+		}
+		bars[contract] = make(map[time.Time]*types.DataPoint, len(data))
+		for _, dp := range data {
+			bars[contract][dp.Timestamp] = dp
+			dateSet[dp.Timestamp] = true
+		}
+		expiry[contract], _ = ds.Expiry(contract)
+	}
+	dates := make([]time.Time, 0, len(dateSet))
+	for date := range dateSet {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	// expiring 距交割日不足rollDays个交易日或已经交割
+	expiring := func(contract string, date time.Time) bool {
+		return ds.calendar.TradingDaysBetween(date, expiry[contract]) < ds.rollDays
+	}
+	// main 在date有行情、未临近交割、交割日不早于after的合约中成交量最大的合约
+	main := func(date time.Time, after string) string {
+		best, volume := "", -1.0
+		for _, contract := range contracts {
+			bar, ok := bars[contract][date]
+			if !ok || expiring(contract, date) || (after != "" && expiry[contract].Before(expiry[after])) {
+				continue
+			}
+			if bar.Volume > volume {
+				best, volume = contract, bar.Volume
+			}
+		}
+		return best
+	}
+
+	var series []*types.DataPoint
+	var rolls []Roll
+	current := ""
+	for i, date := range dates {
+		if current == "" {
+			current = main(date, "")
+		}
+		bar, ok := bars[current][date]
+		if !ok {
+			// 当前合约缺少行情时直接换到主力合约，视为上一交易日收盘后换月，
+			// 价差取两个合约最近一个都有行情的交易日的收盘价差
+			next := main(date, current)
+			if next == "" || next == current {
+				continue
+			}
+			roll := Roll{Date: date, From: current, To: next}
+			if len(series) > 0 {
+				roll.Date = series[len(series)-1].Timestamp
+			}
+			for j := i - 1; j >= 0; j-- {
+				from, ok1 := bars[current][dates[j]]
+				to, ok2 := bars[next][dates[j]]
+				if ok1 && ok2 {
+					roll.Gap = to.Close - from.Close
+					break
+				}
+			}
+			rolls = append(rolls, roll)
+			current = next
+			bar = bars[current][date]
+		}
+		series = append(series, &types.DataPoint{
+			Symbol:    symbol,
+			Timestamp: date,
+			Open:      bar.Open,
+			High:      bar.High,
+			Low:       bar.Low,
+			Close:     bar.Close,
+			Volume:    bar.Volume,
+		})
+
+		// 收盘后决定次日使用的合约
+		next := current
+		if expiring(current, date) {
+			next = main(date, current)
+		} else if ds.byVolume {
+			if candidate := main(date, current); candidate != "" && bars[candidate][date].Volume > bar.Volume {
+				next = candidate
+			}
+		}
+		if next != "" && next != current {
+			rolls = append(rolls, Roll{
+				Date: date,
+				From: current,
+				To:   next,
+				Gap:  bars[next][date].Close - bar.Close,
+			})
+			current = next
+		}
+	}
+
+	if strings.HasSuffix(strings.ToUpper(symbol), "889") {
+		backAdjust(series, rolls)
+	}
+
+	ds.mu.Lock()
+	ds.rolls[symbol] = rolls
+	ds.mu.Unlock()
+	return series, nil
+}
+
+// backAdjust 差值法向前复权：换月日及之前的价格加上之后所有换月价差之和
+func backAdjust(series []*types.DataPoint, rolls []Roll) {
+	adjust := 0.0
+	r := len(rolls) - 1
+	for i := len(series) - 1; i >= 0; i-- {
+		for r >= 0 && !rolls[r].Date.Before(series[i].Timestamp) {
+			adjust += rolls[r].Gap
+			r--
+		}
+		series[i].Open += adjust
+		series[i].High += adjust
+		series[i].Low += adjust
+		series[i].Close += adjust
+	}
+}
+
+func (ds *ContinuousFuturesDataSource) GetSupportedPeriods() []PeriodType {
+	return []PeriodType{PeriodTypeDay}
+}
+
+func (ds *ContinuousFuturesDataSource) ConvertPeriod(data []*types.DataPoint, targetPeriod PeriodType) ([]*types.DataPoint, error) {
+	return Resample(data, targetPeriod)
+}
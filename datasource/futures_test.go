@@ -0,0 +1,72 @@
+package datasource
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stock/common/types"
+)
+
+func writeContract(t *testing.T, dir, contract string, dates []string, closes []float64) {
+	t.Helper()
+	points := make([]*types.DataPoint, len(dates))
+	for i, d := range dates {
+		ts, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := closes[i]
+		points[i] = &types.DataPoint{Timestamp: ts, Open: c, High: c, Low: c, Close: c, Volume: 1000}
+	}
+	if err := WriteCSV(filepath.Join(dir, contract+".csv"), points); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContinuousForcedRollGap(t *testing.T) {
+	dir := t.TempDir()
+	// IF2403在3月5日之后缺少行情，3月6日被迫换到IF2406
+	writeContract(t, dir, "IF2403.CFE",
+		[]string{"2024-03-01", "2024-03-04", "2024-03-05"},
+		[]float64{3500, 3510, 3520})
+	writeContract(t, dir, "IF2406.CFE",
+		[]string{"2024-03-01", "2024-03-04", "2024-03-05", "2024-03-06", "2024-03-07"},
+		[]float64{3550, 3560, 3570, 3580, 3590})
+
+	ds := NewContinuousFuturesDataSource(dir)
+	ds.SetRollByVolume(false)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	series, err := ds.GetData("IF889", PeriodTypeDay, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rolls := ds.Rolls("IF889")
+	if len(rolls) != 1 {
+		t.Fatalf("换月%d次，期望1次", len(rolls))
+	}
+	roll := rolls[0]
+	if roll.Date.Format("2006-01-02") != "2024-03-05" || roll.From != "IF2403.CFE" || roll.To != "IF2406.CFE" || roll.Gap != 50 {
+		t.Errorf("换月记录%+v，期望3月5日收盘后由IF2403换到IF2406，价差50", roll)
+	}
+
+	// 复权后的连续合约与IF2406走势一致，换月处没有跳空
+	want := []float64{3550, 3560, 3570, 3580, 3590}
+	if got := closesOf(series); !sameCloses(got, want) {
+		t.Errorf("IF889收盘价%v，期望%v", got, want)
+	}
+}
+
+func sameCloses(got, want []float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
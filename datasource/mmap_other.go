@@ -0,0 +1,14 @@
+//go:build !unix
+
+package datasource
+
+import "os"
+
+// mapFile 不支持内存映射的平台直接读取整个文件
+func mapFile(path string) ([]byte, func(), error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() {}, nil
+}
@@ -0,0 +1,268 @@
+package datasource
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"stock/calendar"
+	"stock/common/types"
+)
+
+// TradingCalendar 交易日历接口
+type TradingCalendar interface {
+	IsTradingDay(t time.Time) bool
+}
+
+// AnomalyType 数据异常类型
+type AnomalyType string
+
+const (
+	AnomalyMissingDay       AnomalyType = "missing_day"        // 缺失交易日
+	AnomalyDuplicate        AnomalyType = "duplicate"          // 重复日期
+	AnomalyNonPositivePrice AnomalyType = "non_positive_price" // 价格非正
+	AnomalyOHLCViolation    AnomalyType = "ohlc_violation"     // 高开低收关系错误
+	AnomalyOutlierReturn    AnomalyType = "outlier_return"     // 收益率异常
+	AnomalyZeroVolume       AnomalyType = "zero_volume"        // 零成交量
+)
+
+// Anomaly 数据异常记录
+type Anomaly struct {
+	Type      AnomalyType
+	Symbol    string
+	Timestamp time.Time
+	Index     int // 异常数据在原序列中的下标，缺失交易日为-1
+	Detail    string
+}
+
+// QualityConfig 数据质量检查配置
+type QualityConfig struct {
+	Calendar     TradingCalendar // 为空时不检查缺失交易日
+	MaxAbsReturn float64         // 单日收益率绝对值上限，超过视为异常
+	ZeroVolume   bool            // 是否将零成交量视为异常
+}
+
+// DefaultQualityConfig 默认检查配置，按沪深交易日历检查缺失，收益率阈值覆盖创业板/科创板20%涨跌幅
+func DefaultQualityConfig() QualityConfig {
+	return QualityConfig{
+		Calendar:     calendar.Default(),
+		MaxAbsReturn: 0.22,
+		ZeroVolume:   true,
+	}
+}
+
+// QualityReport 数据质量报告
+type QualityReport struct {
+	Symbol    string
+	Total     int
+	Start     time.Time
+	End       time.Time
+	Anomalies []Anomaly
+}
+
+// Count 统计指定类型的异常数量
+func (r *QualityReport) Count(anomalyType AnomalyType) int {
+	count := 0
+	for _, a := range r.Anomalies {
+		if a.Type == anomalyType {
+			count++
+		}
+	}
+	return count
+}
+
+// HasAnomalies 是否存在异常
+func (r *QualityReport) HasAnomalies() bool {
+	return len(r.Anomalies) > 0
+}
+
+// flaggedIndexes 返回存在异常的数据下标
+func (r *QualityReport) flaggedIndexes() map[int]AnomalyType {
+	flagged := make(map[int]AnomalyType)
+	for _, a := range r.Anomalies {
+		if a.Index >= 0 {
+			if _, exists := flagged[a.Index]; !exists {
+				flagged[a.Index] = a.Type
+			}
+		}
+	}
+	return flagged
+}
+
+// CheckQuality 检查数据源输出的数据质量，数据需按时间升序排列
+func CheckQuality(symbol string, points []*types.DataPoint, config QualityConfig) *QualityReport {
+	report := &QualityReport{
+		Symbol: symbol,
+		Total:  len(points),
+	}
+	if len(points) == 0 {
+		return report
+	}
+	report.Start = points[0].Timestamp
+	report.End = points[len(points)-1].Timestamp
+
+	add := func(anomalyType AnomalyType, index int, timestamp time.Time, format string, args ...interface{}) {
+		report.Anomalies = append(report.Anomalies, Anomaly{
+			Type:      anomalyType,
+			Symbol:    symbol,
+			Timestamp: timestamp,
+			Index:     index,
+			Detail:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	seen := make(map[string]int)
+	var prev *types.DataPoint // 上一条收益率正常的数据，用于计算收益率
+	var last *types.DataPoint // 上一条价格有效的数据
+	var prevDay *time.Time    // 上一条非重复数据日期，用于检查缺失交易日
+	for i, p := range points {
+		day := p.Timestamp.Format("2006-01-02")
+		if first, exists := seen[day]; exists {
+			add(AnomalyDuplicate, i, p.Timestamp, "与第%d条数据日期重复", first)
+			continue
+		}
+		seen[day] = i
+
+		if config.Calendar != nil && prevDay != nil {
+			for _, missing := range missingDays(*prevDay, p.Timestamp, config.Calendar) {
+				add(AnomalyMissingDay, -1, missing, "交易日无数据")
+			}
+		}
+		prevDay = &points[i].Timestamp
+
+		if p.Open <= 0 || p.High <= 0 || p.Low <= 0 || p.Close <= 0 {
+			add(AnomalyNonPositivePrice, i, p.Timestamp, "O=%.4f H=%.4f L=%.4f C=%.4f", p.Open, p.High, p.Low, p.Close)
+			continue
+		}
+
+		if p.High < math.Max(p.Open, p.Close) || p.Low > math.Min(p.Open, p.Close) || p.High < p.Low {
+			add(AnomalyOHLCViolation, i, p.Timestamp, "O=%.4f H=%.4f L=%.4f C=%.4f", p.Open, p.High, p.Low, p.Close)
+		}
+
+		if config.ZeroVolume && p.Volume <= 0 {
+			add(AnomalyZeroVolume, i, p.Timestamp, "成交量为%.0f", p.Volume)
+		}
+
+		// 收益率相对上一条正常数据计算，单根K线的尖峰回落后，回落的K线不会被标记。
+		// 上一条数据异常但当前价格与它接近时，价格已停留在新水平（如未复权的除权），不再标记
+		outlier := false
+		if prev != nil && config.MaxAbsReturn > 0 {
+			ret := p.Close/prev.Close - 1
+			outlier = math.Abs(ret) > config.MaxAbsReturn
+			if outlier && last != prev && math.Abs(p.Close/last.Close-1) <= config.MaxAbsReturn {
+				outlier = false
+			}
+			if outlier {
+				add(AnomalyOutlierReturn, i, p.Timestamp, "收益率%.2f%%超过阈值%.2f%%", ret*100, config.MaxAbsReturn*100)
+			}
+		}
+		if !outlier {
+			prev = p
+		}
+		last = p
+	}
+
+	sort.SliceStable(report.Anomalies, func(i, j int) bool {
+		return report.Anomalies[i].Timestamp.Before(report.Anomalies[j].Timestamp)
+	})
+	return report
+}
+
+// missingDays 返回两个日期之间（不含两端）日历中的交易日
+func missingDays(from, to time.Time, calendar TradingCalendar) []time.Time {
+	var days []time.Time
+	for day := from.AddDate(0, 0, 1); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if calendar.IsTradingDay(day) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// RepairPolicy 数据修复策略
+type RepairPolicy int
+
+const (
+	RepairDrop        RepairPolicy = iota // 删除异常数据
+	RepairForwardFill                     // 用前一交易日收盘价填充异常数据和缺失交易日
+	RepairFlag                            // 保留数据，在指标中标记异常
+)
+
+// QualityFlagIndicator 标记异常数据的指标名
+const QualityFlagIndicator = "QualityFlag"
+
+// ParseRepairPolicy 解析修复策略名称
+func ParseRepairPolicy(name string) (RepairPolicy, error) {
+	switch name {
+	case "drop":
+		return RepairDrop, nil
+	case "ffill", "forward-fill":
+		return RepairForwardFill, nil
+	case "flag":
+		return RepairFlag, nil
+	default:
+		return 0, fmt.Errorf("unknown repair policy: %s", name)
+	}
+}
+
+// RepairData 按修复策略处理检查出的异常，返回新的数据序列
+func RepairData(points []*types.DataPoint, report *QualityReport, policy RepairPolicy) []*types.DataPoint {
+	flagged := report.flaggedIndexes()
+	repaired := make([]*types.DataPoint, 0, len(points))
+
+	missing := make(map[int][]time.Time) // 缺失交易日插入到其后第一条数据之前
+	for _, a := range report.Anomalies {
+		if a.Type != AnomalyMissingDay {
+			continue
+		}
+		next := sort.Search(len(points), func(i int) bool {
+			return points[i].Timestamp.After(a.Timestamp)
+		})
+		missing[next] = append(missing[next], a.Timestamp)
+	}
+
+	var last *types.DataPoint
+	for i, p := range points {
+		anomalyType, bad := flagged[i]
+
+		if policy == RepairForwardFill && last != nil {
+			for _, day := range missing[i] {
+				repaired = append(repaired, fillFrom(last, day))
+			}
+		}
+
+		switch {
+		case !bad:
+			repaired = append(repaired, p)
+			last = p
+		case policy == RepairFlag:
+			flaggedPoint := *p
+			flaggedPoint.Indicators = make(map[string]float64, len(p.Indicators)+1)
+			for k, v := range p.Indicators {
+				flaggedPoint.Indicators[k] = v
+			}
+			flaggedPoint.Indicators[QualityFlagIndicator] = 1
+			repaired = append(repaired, &flaggedPoint)
+		case policy == RepairForwardFill && anomalyType != AnomalyDuplicate && last != nil:
+			repaired = append(repaired, fillFrom(last, p.Timestamp))
+		}
+		// 其余情况（删除策略、重复数据、序列开头的异常数据）直接丢弃
+	}
+
+	return repaired
+}
+
+// fillFrom 以前一条数据的收盘价生成填充数据
+func fillFrom(prev *types.DataPoint, timestamp time.Time) *types.DataPoint {
+	return &types.DataPoint{
+		Symbol:     prev.Symbol,
+		Timestamp:  timestamp,
+		Open:       prev.Close,
+		High:       prev.Close,
+		Low:        prev.Close,
+		Close:      prev.Close,
+		Volume:     0,
+		Indicators: map[string]float64{QualityFlagIndicator: 1},
+	}
+}
@@ -0,0 +1,67 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"stock/common/types"
+)
+
+func qualityBars(closes ...float64) []*types.DataPoint {
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	points := make([]*types.DataPoint, len(closes))
+	for i, c := range closes {
+		points[i] = &types.DataPoint{
+			Symbol: "600000.SH", Timestamp: start.AddDate(0, 0, i),
+			Open: c, High: c, Low: c, Close: c, Volume: 100,
+		}
+	}
+	return points
+}
+
+func outlierIndexes(report *QualityReport) []int {
+	var indexes []int
+	for _, a := range report.Anomalies {
+		if a.Type == AnomalyOutlierReturn {
+			indexes = append(indexes, a.Index)
+		}
+	}
+	return indexes
+}
+
+func TestQualityOutliers(t *testing.T) {
+	config := QualityConfig{MaxAbsReturn: 0.2}
+	tests := []struct {
+		name   string
+		closes []float64
+		want   []int
+	}{
+		{"尖峰回落只标记尖峰", []float64{10, 10.1, 15, 10.2, 10.3}, []int{2}},
+		{"尖峰后连续回落", []float64{10, 10.1, 5, 10, 10.1}, []int{2}},
+		{"价格停留在新水平只标记跳变", []float64{10, 10.1, 15, 15.1, 15.2}, []int{2}},
+		{"正常波动", []float64{10, 11, 12, 11, 10}, nil},
+	}
+	for _, tt := range tests {
+		got := outlierIndexes(CheckQuality("600000.SH", qualityBars(tt.closes...), config))
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: 异常下标%v，期望%v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: 异常下标%v，期望%v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestRepairDropSpike(t *testing.T) {
+	points := qualityBars(10, 10.1, 15, 10.2, 10.3)
+	report := CheckQuality("600000.SH", points, QualityConfig{MaxAbsReturn: 0.2})
+	repaired := RepairData(points, report, RepairDrop)
+	want := []float64{10, 10.1, 10.2, 10.3}
+	if got := closesOf(repaired); !sameCloses(got, want) {
+		t.Errorf("删除异常后收盘价%v，期望%v", got, want)
+	}
+}
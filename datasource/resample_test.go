@@ -0,0 +1,94 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"stock/common/types"
+)
+
+// dailyBars 按日期生成日线，第i根的开盘价为i+1，最高价+1，最低价-1，收盘价+0.5，成交量100
+func dailyBars(days ...string) []*types.DataPoint {
+	bars := make([]*types.DataPoint, len(days))
+	for i, day := range days {
+		ts, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			panic(err)
+		}
+		price := float64(i + 1)
+		bars[i] = &types.DataPoint{
+			Symbol: "600036.SH", Timestamp: ts,
+			Open: price, High: price + 1, Low: price - 1, Close: price + 0.5, Volume: 100,
+		}
+	}
+	return bars
+}
+
+func TestResample(t *testing.T) {
+	type bar struct {
+		date                   string
+		open, high, low, close float64
+		volume                 float64
+	}
+	tests := []struct {
+		name   string
+		period PeriodType
+		days   []string
+		want   []bar
+	}{
+		{
+			// 周五3月8日缺失，两周不能合并
+			name:   "周线缺失周五",
+			period: PeriodTypeWeek,
+			days:   []string{"2024-03-04", "2024-03-05", "2024-03-06", "2024-03-07", "2024-03-11", "2024-03-12", "2024-03-13", "2024-03-14", "2024-03-15"},
+			want: []bar{
+				{"2024-03-07", 1, 5, 0, 4.5, 400},
+				{"2024-03-15", 5, 10, 4, 9.5, 500},
+			},
+		},
+		{
+			// 跨年的ISO周属于同一周
+			name:   "周线跨年",
+			period: PeriodTypeWeek,
+			days:   []string{"2024-12-27", "2024-12-30", "2024-12-31", "2025-01-02", "2025-01-03", "2025-01-06"},
+			want: []bar{
+				{"2024-12-27", 1, 2, 0, 1.5, 100},
+				{"2025-01-03", 2, 6, 1, 5.5, 400},
+				{"2025-01-06", 6, 7, 5, 6.5, 100},
+			},
+		},
+		{
+			// 2月最后一个交易日缺失，最后一个未完结月份以实际最后一根日线为时间
+			name:   "月线",
+			period: PeriodTypeMonth,
+			days:   []string{"2024-01-30", "2024-01-31", "2024-02-27", "2024-02-28", "2024-03-01", "2024-03-04"},
+			want: []bar{
+				{"2024-01-31", 1, 3, 0, 2.5, 200},
+				{"2024-02-28", 3, 5, 2, 4.5, 200},
+				{"2024-03-04", 5, 7, 4, 6.5, 200},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got, err := Resample(dailyBars(tt.days...), tt.period)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: 合成%d根K线，期望%d根", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, w := range tt.want {
+			g := got[i]
+			if g.Timestamp.Format("2006-01-02") != w.date || g.Open != w.open || g.High != w.high ||
+				g.Low != w.low || g.Close != w.close || g.Volume != w.volume {
+				t.Errorf("%s: 第%d根K线%s %v/%v/%v/%v %v，期望%+v", tt.name, i, g.Timestamp.Format("2006-01-02"),
+					g.Open, g.High, g.Low, g.Close, g.Volume, w)
+			}
+		}
+	}
+
+	if _, err := Resample(dailyBars("2024-03-04"), PeriodTypeMinute); err == nil {
+		t.Error("不支持的周期应返回错误")
+	}
+}
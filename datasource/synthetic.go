@@ -0,0 +1,201 @@
+package datasource
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"stock/calendar"
+	"stock/common/types"
+	"stock/instrument"
+	"stock/stats"
+)
+
+// SyntheticDataSource 合成行情数据源，按收益率模型在交易日历上生成日线OHLCV，用于在已知行情下测试策略
+//
+// 同一种子、股票代码和日期区间生成的行情完全相同，不同股票使用不同的随机序列。
+// 开盘价、最高最低价和成交量使用独立的随机序列，开启涨跌停或停牌不会改变收益率序列本身。
+type SyntheticDataSource struct {
+	model        ReturnModel
+	seed         int64
+	calendar     *calendar.Calendar
+	initialPrice float64
+	volume       float64
+	priceLimit   bool
+	suspendProb  float64
+	suspendDays  float64
+
+	mu          sync.Mutex
+	regimes     map[string]map[time.Time]int
+	suspensions map[string][]time.Time
+}
+
+func NewSyntheticDataSource(model ReturnModel, seed int64) *SyntheticDataSource {
+	return &SyntheticDataSource{
+		model:        model,
+		seed:         seed,
+		calendar:     calendar.Default(),
+		initialPrice: 10,
+		volume:       1000000,
+		regimes:      make(map[string]map[time.Time]int),
+		suspensions:  make(map[string][]time.Time),
+	}
+}
+
+// SetInitialPrice 设置起始价格，默认10元
+func (ds *SyntheticDataSource) SetInitialPrice(price float64) {
+	ds.initialPrice = price
+}
+
+// SetVolume 设置平均日成交量，默认100万股
+func (ds *SyntheticDataSource) SetVolume(volume float64) {
+	ds.volume = volume
+}
+
+// SetPriceLimit 按品种的涨跌停规则截断价格，未实现的涨跌幅顺延到下一交易日
+func (ds *SyntheticDataSource) SetPriceLimit(enabled bool) {
+	ds.priceLimit = enabled
+}
+
+// SetSuspension 设置停牌：每个交易日以prob的概率开始停牌，停牌天数服从均值为meanDays的几何分布。
+// 停牌期间没有K线，期间的涨跌在复牌日体现
+func (ds *SyntheticDataSource) SetSuspension(prob, meanDays float64) {
+	ds.suspendProb = prob
+	ds.suspendDays = meanDays
+}
+
+// SetCalendar 设置生成行情使用的交易日历
+func (ds *SyntheticDataSource) SetCalendar(cal *calendar.Calendar) {
+	ds.calendar = cal
+}
+
+// Regimes 返回最近一次生成的行情每个交易日所处的状态，模型不区分状态时返回nil
+func (ds *SyntheticDataSource) Regimes(symbol string) map[time.Time]int {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.regimes[symbol]
+}
+
+// Suspensions 返回最近一次生成的行情中的停牌日
+func (ds *SyntheticDataSource) Suspensions(symbol string) []time.Time {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.suspensions[symbol]
+}
+
+func (ds *SyntheticDataSource) GetData(symbol string, period PeriodType, start, end time.Time) ([]*types.DataPoint, error) {
+	if period != PeriodTypeDay {
+		return nil, fmt.Errorf("合成行情只支持日线数据")
+	}
+	if ds.model == nil {
+		return nil, fmt.Errorf("没有设置收益率模型")
+	}
+	days := ds.calendar.TradingDays(start, end)
+	if len(days) == 0 {
+		return nil, fmt.Errorf("%s至%s没有交易日", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(symbol))
+	seed := ds.seed ^ int64(h.Sum64())
+	rng := rand.New(rand.NewSource(seed))
+	bars := rand.New(rand.NewSource(seed + 1))
+
+	var returns []float64
+	var states []int
+	if m, ok := ds.model.(regimeModel); ok {
+		returns, states = m.GenerateRegimes(rng, len(days))
+	} else {
+		returns = ds.model.Generate(rng, len(days))
+	}
+	sigma := stats.StdDev(returns)
+	if math.IsNaN(sigma) {
+		sigma = 0
+	}
+
+	inst := instrument.Default().Lookup(symbol)
+	var data []*types.DataPoint
+	var suspended []time.Time
+	var regimes map[time.Time]int
+	if states != nil {
+		regimes = make(map[time.Time]int, len(days))
+	}
+	prevClose, carry, halt := ds.initialPrice, 0.0, 0
+	for i, day := range days {
+		day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+		if regimes != nil {
+			regimes[day] = states[i]
+		}
+		r := returns[i] + carry
+		carry = 0
+
+		if halt == 0 && ds.suspendProb > 0 && bars.Float64() < ds.suspendProb {
+			halt = 1
+			if ds.suspendDays > 1 {
+				// 几何分布的停牌天数
+				for bars.Float64() > 1/ds.suspendDays {
+					halt++
+				}
+			}
+		}
+		if halt > 0 {
+			halt--
+			carry = r
+			suspended = append(suspended, day)
+			continue
+		}
+
+		close := prevClose * math.Exp(r)
+		open := prevClose * math.Exp(0.3*sigma*bars.NormFloat64())
+		high := math.Max(open, close) * math.Exp(0.5*sigma*math.Abs(bars.NormFloat64()))
+		low := math.Min(open, close) * math.Exp(-0.5*sigma*math.Abs(bars.NormFloat64()))
+		volume := ds.volume * math.Exp(0.3*bars.NormFloat64()) * (1 + 20*math.Abs(r))
+
+		if ds.priceLimit {
+			up, down := inst.LimitPrices(prevClose)
+			switch {
+			case close >= up:
+				carry = math.Log(close / up)
+				volume *= 0.3 // 封板后成交清淡
+			case close <= down:
+				carry = math.Log(close / down)
+				volume *= 0.3
+			}
+			clip := func(price float64) float64 {
+				return math.Min(math.Max(price, down), up)
+			}
+			open, high, low, close = clip(open), clip(high), clip(low), clip(close)
+		}
+		open, high, low, close = inst.RoundPrice(open), inst.RoundPrice(high), inst.RoundPrice(low), inst.RoundPrice(close)
+		high = math.Max(high, math.Max(open, close))
+		low = math.Min(low, math.Min(open, close))
+
+		data = append(data, &types.DataPoint{
+			Symbol:    symbol,
+			Timestamp: day,
+			Open:      open,
+			High:      high,
+			Low:       low,
+			Close:     close,
+			Volume:    math.Round(volume),
+		})
+		prevClose = close
+	}
+
+	ds.mu.Lock()
+	ds.regimes[symbol] = regimes
+	ds.suspensions[symbol] = suspended
+	ds.mu.Unlock()
+	return data, nil
+}
+
+func (ds *SyntheticDataSource) GetSupportedPeriods() []PeriodType {
+	return []PeriodType{PeriodTypeDay}
+}
+
+func (ds *SyntheticDataSource) ConvertPeriod(data []*types.DataPoint, targetPeriod PeriodType) ([]*types.DataPoint, error) {
+	return Resample(data, targetPeriod)
+}
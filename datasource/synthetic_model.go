@@ -0,0 +1,207 @@
+package datasource
+
+import (
+	"math"
+	"math/rand"
+
+	"stock/common/types"
+)
+
+// ReturnModel 合成行情的收益率模型，生成n个交易日的对数收益率
+type ReturnModel interface {
+	Generate(rng *rand.Rand, n int) []float64
+	Name() string
+}
+
+// regimeModel 可以给出每个交易日所处状态的模型
+type regimeModel interface {
+	GenerateRegimes(rng *rand.Rand, n int) ([]float64, []int)
+}
+
+// tradingDaysPerYear 年化参数换算为日参数使用的交易日数
+const tradingDaysPerYear = 252
+
+// GBM 几何布朗运动，Mu和Sigma为年化漂移率和波动率
+type GBM struct {
+	Mu    float64
+	Sigma float64
+}
+
+func (m GBM) Name() string { return "GBM" }
+
+func (m GBM) Generate(rng *rand.Rand, n int) []float64 {
+	dt := 1.0 / tradingDaysPerYear
+	drift := (m.Mu - m.Sigma*m.Sigma/2) * dt
+	vol := m.Sigma * math.Sqrt(dt)
+	returns := make([]float64, n)
+	for i := range returns {
+		returns[i] = drift + vol*rng.NormFloat64()
+	}
+	return returns
+}
+
+// GARCH GARCH(1,1)波动率聚集模型，参数均为日频：
+// r_t = Mu + σ_t·ε_t，σ²_t = Omega + Alpha·(r_{t-1}−Mu)² + Beta·σ²_{t-1}，Alpha+Beta<1
+type GARCH struct {
+	Mu    float64
+	Omega float64
+	Alpha float64
+	Beta  float64
+}
+
+// NewGARCH 按年化波动率和持续性参数创建GARCH模型，Omega由长期方差反推
+func NewGARCH(annualVol, alpha, beta float64) GARCH {
+	variance := annualVol * annualVol / tradingDaysPerYear
+	return GARCH{Omega: variance * (1 - alpha - beta), Alpha: alpha, Beta: beta}
+}
+
+func (m GARCH) Name() string { return "GARCH" }
+
+func (m GARCH) Generate(rng *rand.Rand, n int) []float64 {
+	variance := m.Omega
+	if persistence := m.Alpha + m.Beta; persistence < 1 {
+		variance = m.Omega / (1 - persistence)
+	}
+	returns := make([]float64, n)
+	for i := range returns {
+		shock := math.Sqrt(variance) * rng.NormFloat64()
+		returns[i] = m.Mu + shock
+		variance = m.Omega + m.Alpha*shock*shock + m.Beta*variance
+	}
+	return returns
+}
+
+// JumpDiffusion Merton跳跃扩散：几何布朗运动叠加泊松跳跃，
+// Lambda为每年平均跳跃次数，跳跃幅度（对数）服从N(JumpMean, JumpStd²)
+type JumpDiffusion struct {
+	Mu       float64
+	Sigma    float64
+	Lambda   float64
+	JumpMean float64
+	JumpStd  float64
+}
+
+func (m JumpDiffusion) Name() string { return "JumpDiffusion" }
+
+func (m JumpDiffusion) Generate(rng *rand.Rand, n int) []float64 {
+	returns := GBM{Mu: m.Mu, Sigma: m.Sigma}.Generate(rng, n)
+	p := m.Lambda / tradingDaysPerYear
+	for i := range returns {
+		// 日内跳跃次数按泊松分布抽样
+		for k := poisson(rng, p); k > 0; k-- {
+			returns[i] += m.JumpMean + m.JumpStd*rng.NormFloat64()
+		}
+	}
+	return returns
+}
+
+func poisson(rng *rand.Rand, lambda float64) int {
+	limit, k, product := math.Exp(-lambda), 0, rng.Float64()
+	for product > limit {
+		k++
+		product *= rng.Float64()
+	}
+	return k
+}
+
+// Regime 状态切换模型中的一个状态，Mu和Sigma为年化参数
+type Regime struct {
+	Name  string
+	Mu    float64
+	Sigma float64
+}
+
+// RegimeSwitching 马尔可夫状态切换模型，Transition[i][j]为由状态i切换到状态j的日概率
+type RegimeSwitching struct {
+	Regimes    []Regime
+	Transition [][]float64
+	Initial    int // 初始状态
+}
+
+// BullBear 牛熊两状态模型：牛市年化收益25%波动18%，熊市年化收益-30%波动35%，平均持续约一年和半年
+func BullBear() RegimeSwitching {
+	return RegimeSwitching{
+		Regimes: []Regime{
+			{Name: "bull", Mu: 0.25, Sigma: 0.18},
+			{Name: "bear", Mu: -0.30, Sigma: 0.35},
+		},
+		Transition: [][]float64{
+			{1 - 1.0/250, 1.0 / 250},
+			{1.0 / 120, 1 - 1.0/120},
+		},
+	}
+}
+
+func (m RegimeSwitching) Name() string { return "RegimeSwitching" }
+
+func (m RegimeSwitching) Generate(rng *rand.Rand, n int) []float64 {
+	returns, _ := m.GenerateRegimes(rng, n)
+	return returns
+}
+
+// GenerateRegimes 生成收益率和每个交易日所处的状态
+func (m RegimeSwitching) GenerateRegimes(rng *rand.Rand, n int) ([]float64, []int) {
+	returns := make([]float64, n)
+	states := make([]int, n)
+	if len(m.Regimes) == 0 {
+		return returns, states
+	}
+	dt := 1.0 / tradingDaysPerYear
+	state := m.Initial
+	for i := range returns {
+		if i > 0 && state < len(m.Transition) {
+			u, cumulative := rng.Float64(), 0.0
+			for next, p := range m.Transition[state] {
+				cumulative += p
+				if u < cumulative {
+					state = next
+					break
+				}
+			}
+		}
+		regime := m.Regimes[state]
+		states[i] = state
+		returns[i] = (regime.Mu-regime.Sigma*regime.Sigma/2)*dt + regime.Sigma*math.Sqrt(dt)*rng.NormFloat64()
+	}
+	return returns, states
+}
+
+// BlockBootstrap 对真实行情的对数收益率做块自助法重抽样，保留短期相关性和波动聚集
+type BlockBootstrap struct {
+	Returns   []float64
+	BlockSize int
+}
+
+// NewBlockBootstrap 由真实行情创建块自助法模型，blockSize为每块交易日数
+func NewBlockBootstrap(data []*types.DataPoint, blockSize int) BlockBootstrap {
+	var returns []float64
+	for i := 1; i < len(data); i++ {
+		if data[i-1].Close > 0 && data[i].Close > 0 {
+			returns = append(returns, math.Log(data[i].Close/data[i-1].Close))
+		}
+	}
+	return BlockBootstrap{Returns: returns, BlockSize: blockSize}
+}
+
+func (m BlockBootstrap) Name() string { return "BlockBootstrap" }
+
+func (m BlockBootstrap) Generate(rng *rand.Rand, n int) []float64 {
+	returns := make([]float64, 0, n)
+	if len(m.Returns) == 0 {
+		return make([]float64, n)
+	}
+	block := m.BlockSize
+	if block <= 0 || block > len(m.Returns) {
+		block = len(m.Returns)
+	}
+	for len(returns) < n {
+		start := rng.Intn(len(m.Returns) - block + 1)
+		for _, r := range m.Returns[start : start+block] {
+			if len(returns) == n {
+				break
+			}
+			returns = append(returns, r)
+		}
+	}
+	return returns
+}
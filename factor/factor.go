@@ -0,0 +1,211 @@
+// Package factor 截面因子计算、预处理、合成与选股
+package factor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"stock/common/types"
+	"stock/stats"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Input 单只股票在调仓日的因子输入
+type Input struct {
+	Symbol       string
+	Date         time.Time
+	History      []*types.DataPoint // 截至调仓日（含）的历史行情，按时间升序
+	Fundamentals map[string]float64 // 截至调仓日最新公布的基本面，可为nil
+}
+
+// Factor 因子，数据不足时返回NaN
+type Factor interface {
+	Name() string
+	// Lookback 计算所需的最少K线数
+	Lookback() int
+	Compute(in Input) float64
+}
+
+// closeAt 返回倒数第offset根K线的收盘价，offset为0表示最新一根
+func closeAt(history []*types.DataPoint, offset int) float64 {
+	idx := len(history) - 1 - offset
+	if idx < 0 {
+		return math.NaN()
+	}
+	return history[idx].Close
+}
+
+// Momentum 动量：跳过最近skip根K线后，过去period根K线的收益率
+type Momentum struct {
+	Period int
+	Skip   int
+}
+
+func (f Momentum) Name() string  { return fmt.Sprintf("MOM(%d,%d)", f.Period, f.Skip) }
+func (f Momentum) Lookback() int { return f.Period + f.Skip + 1 }
+
+func (f Momentum) Compute(in Input) float64 {
+	if len(in.History) < f.Lookback() {
+		return math.NaN()
+	}
+	end, start := closeAt(in.History, f.Skip), closeAt(in.History, f.Skip+f.Period)
+	if start <= 0 {
+		return math.NaN()
+	}
+	return end/start - 1
+}
+
+// Reversal 短期反转：过去period根K线收益率的相反数
+type Reversal struct {
+	Period int
+}
+
+func (f Reversal) Name() string  { return fmt.Sprintf("REV(%d)", f.Period) }
+func (f Reversal) Lookback() int { return f.Period + 1 }
+
+func (f Reversal) Compute(in Input) float64 {
+	return -Momentum{Period: f.Period}.Compute(in)
+}
+
+// Volatility 过去period根K线日对数收益率的标准差，低波动选股时取负权重
+type Volatility struct {
+	Period int
+}
+
+func (f Volatility) Name() string  { return fmt.Sprintf("VOL(%d)", f.Period) }
+func (f Volatility) Lookback() int { return f.Period + 1 }
+
+func (f Volatility) Compute(in Input) float64 {
+	if len(in.History) < f.Lookback() || f.Period < 2 {
+		return math.NaN()
+	}
+	returns := make([]float64, 0, f.Period)
+	for offset := f.Period - 1; offset >= 0; offset-- {
+		prev, cur := closeAt(in.History, offset+1), closeAt(in.History, offset)
+		if prev <= 0 || cur <= 0 {
+			return math.NaN()
+		}
+		returns = append(returns, math.Log(cur/prev))
+	}
+	return stats.StdDev(returns)
+}
+
+// Liquidity 过去period根K线平均成交额（收盘价乘成交量）的对数
+type Liquidity struct {
+	Period int
+}
+
+func (f Liquidity) Name() string  { return fmt.Sprintf("LIQ(%d)", f.Period) }
+func (f Liquidity) Lookback() int { return f.Period }
+
+func (f Liquidity) Compute(in Input) float64 {
+	if len(in.History) < f.Period || f.Period < 1 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, dp := range in.History[len(in.History)-f.Period:] {
+		sum += dp.Close * dp.Volume
+	}
+	if sum <= 0 {
+		return math.NaN()
+	}
+	return math.Log(sum / float64(f.Period))
+}
+
+// Fundamental 基本面字段，Inverse为true时取倒数（例如由PE得到EP），非正值视为缺失
+type Fundamental struct {
+	Field   string
+	Inverse bool
+}
+
+func (f Fundamental) Name() string {
+	if f.Inverse {
+		return "1/" + f.Field
+	}
+	return f.Field
+}
+
+func (f Fundamental) Lookback() int { return 0 }
+
+func (f Fundamental) Compute(in Input) float64 {
+	value, ok := in.Fundamentals[f.Field]
+	if !ok || math.IsNaN(value) {
+		return math.NaN()
+	}
+	if f.Inverse {
+		if value <= 0 {
+			return math.NaN()
+		}
+		return 1 / value
+	}
+	return value
+}
+
+// builders 按名称创建因子，参数未给出时使用默认值
+var builders = map[string]func(p []int) Factor{
+	"MOMENTUM": func(p []int) Factor { return Momentum{Period: param(p, 0, 120), Skip: param(p, 1, 20)} },
+	"REVERSAL": func(p []int) Factor { return Reversal{Period: param(p, 0, 5)} },
+	"VOLATILITY": func(p []int) Factor {
+		return Volatility{Period: param(p, 0, 20)}
+	},
+	"LIQUIDITY": func(p []int) Factor { return Liquidity{Period: param(p, 0, 20)} },
+}
+
+func param(p []int, i, def int) int {
+	if i < len(p) {
+		return p[i]
+	}
+	return def
+}
+
+// Parse 解析因子描述，例如 momentum(120,20)、volatility(20)、PE（基本面字段）、1/PB（取倒数）
+func Parse(spec string) (Factor, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("因子描述为空")
+	}
+
+	name, args := spec, ""
+	if open := strings.Index(spec, "("); open >= 0 {
+		if !strings.HasSuffix(spec, ")") {
+			return nil, fmt.Errorf("因子描述格式错误: %s", spec)
+		}
+		name, args = spec[:open], spec[open+1:len(spec)-1]
+	}
+
+	build, ok := builders[strings.ToUpper(name)]
+	if !ok {
+		if args != "" {
+			return nil, fmt.Errorf("未知的因子: %s", name)
+		}
+		if strings.HasPrefix(name, "1/") {
+			return Fundamental{Field: name[2:], Inverse: true}, nil
+		}
+		return Fundamental{Field: name}, nil
+	}
+
+	var params []int
+	for _, arg := range strings.Split(args, ",") {
+		if arg = strings.TrimSpace(arg); arg == "" {
+			continue
+		}
+		value, err := strconv.Atoi(arg)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("因子参数错误: %s", spec)
+		}
+		params = append(params, value)
+	}
+	return build(params), nil
+}
+
+// Names 返回内置行情因子名
+func Names() []string {
+	names := make([]string, 0, len(builders))
+	for name := range builders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,318 @@
+package factor
+
+import (
+	"math"
+	"sort"
+	"stock/common/types"
+	"stock/datasource"
+	"stock/stats"
+	"time"
+)
+
+// Winsorize 中位数去极值：把超出 中位数±n*1.4826*MAD 的值截断到边界
+func Winsorize(values map[string]float64, n float64) map[string]float64 {
+	out := make(map[string]float64, len(values))
+	if len(values) == 0 || n <= 0 {
+		for k, v := range values {
+			out[k] = v
+		}
+		return out
+	}
+
+	xs := make([]float64, 0, len(values))
+	for _, v := range values {
+		xs = append(xs, v)
+	}
+	med := median(xs)
+	deviations := make([]float64, len(xs))
+	for i, v := range xs {
+		deviations[i] = math.Abs(v - med)
+	}
+	bound := n * 1.4826 * median(deviations)
+
+	for k, v := range values {
+		if bound == 0 {
+			out[k] = v
+			continue
+		}
+		out[k] = math.Max(med-bound, math.Min(med+bound, v))
+	}
+	return out
+}
+
+// Standardize 截面标准化为均值0、标准差1，标准差为0时全部为0
+func Standardize(values map[string]float64) map[string]float64 {
+	xs := make([]float64, 0, len(values))
+	for _, symbol := range sortedSymbols(values) {
+		xs = append(xs, values[symbol])
+	}
+	mean := stats.Mean(xs)
+	std := stats.StdDev(xs)
+
+	out := make(map[string]float64, len(values))
+	for k, v := range values {
+		if std == 0 || math.IsNaN(std) {
+			out[k] = 0
+		} else {
+			out[k] = (v - mean) / std
+		}
+	}
+	return out
+}
+
+// Neutralize 行业与市值中性化：先减去行业均值，再对市值暴露做一元回归取残差
+// groups或exposure为nil时跳过对应步骤，没有行业或暴露的股票保持原值
+func Neutralize(values map[string]float64, groups map[string]string, exposure map[string]float64) map[string]float64 {
+	out := make(map[string]float64, len(values))
+	for k, v := range values {
+		out[k] = v
+	}
+	// 按代码顺序求和，避免map遍历顺序不同导致浮点结果不可复现
+	symbols := sortedSymbols(values)
+
+	if groups != nil {
+		sums := make(map[string]float64)
+		counts := make(map[string]int)
+		for _, k := range symbols {
+			if g, ok := groups[k]; ok {
+				sums[g] += out[k]
+				counts[g]++
+			}
+		}
+		for k, v := range out {
+			if g, ok := groups[k]; ok {
+				out[k] = v - sums[g]/float64(counts[g])
+			}
+		}
+	}
+
+	if exposure != nil {
+		var xs, ys []float64
+		for _, k := range symbols {
+			if x, ok := exposure[k]; ok && !math.IsNaN(x) {
+				xs = append(xs, x)
+				ys = append(ys, out[k])
+			}
+		}
+		if len(xs) > 2 {
+			mx, my := stats.Mean(xs), stats.Mean(ys)
+			cov, varX := 0.0, 0.0
+			for i := range xs {
+				cov += (xs[i] - mx) * (ys[i] - my)
+				varX += (xs[i] - mx) * (xs[i] - mx)
+			}
+			if varX > 0 {
+				beta := cov / varX
+				alpha := my - beta*mx
+				for k, v := range out {
+					if x, ok := exposure[k]; ok && !math.IsNaN(x) {
+						out[k] = v - alpha - beta*x
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+// weighted 带权重的因子
+type weighted struct {
+	factor Factor
+	weight float64
+}
+
+// Pipeline 因子流水线：计算 -> 去极值 -> 标准化 -> 中性化 -> 再标准化 -> 加权合成
+type Pipeline struct {
+	factors      []weighted
+	winsorize    float64
+	groups       map[string]string
+	sizeField    string
+	fundamentals *datasource.Fundamentals
+}
+
+// NewPipeline 创建因子流水线，默认3倍MAD去极值
+func NewPipeline() *Pipeline {
+	return &Pipeline{winsorize: 3}
+}
+
+// Add 添加因子，权重为负表示因子值越小越好（例如低波动）
+func (p *Pipeline) Add(f Factor, weight float64) *Pipeline {
+	p.factors = append(p.factors, weighted{factor: f, weight: weight})
+	return p
+}
+
+// SetWinsorize 设置去极值的MAD倍数，0表示不去极值
+func (p *Pipeline) SetWinsorize(n float64) {
+	p.winsorize = n
+}
+
+// SetGroups 设置行业分类，用于行业中性化
+func (p *Pipeline) SetGroups(groups map[string]string) {
+	p.groups = groups
+}
+
+// SetSizeField 设置市值字段，以其对数做市值中性化，需要先设置基本面数据
+func (p *Pipeline) SetSizeField(field string) {
+	p.sizeField = field
+}
+
+// SetFundamentals 设置基本面数据
+func (p *Pipeline) SetFundamentals(f *datasource.Fundamentals) {
+	p.fundamentals = f
+}
+
+// Factors 返回因子名
+func (p *Pipeline) Factors() []string {
+	names := make([]string, len(p.factors))
+	for i, w := range p.factors {
+		names[i] = w.factor.Name()
+	}
+	return names
+}
+
+// Lookback 所有因子所需的最多K线数
+func (p *Pipeline) Lookback() int {
+	lookback := 1
+	for _, w := range p.factors {
+		if n := w.factor.Lookback(); n > lookback {
+			lookback = n
+		}
+	}
+	return lookback
+}
+
+// Scores 一次截面计算的结果
+type Scores struct {
+	Date      time.Time
+	Raw       map[string]map[string]float64 // 因子名 -> 股票 -> 原始值
+	Processed map[string]map[string]float64 // 因子名 -> 股票 -> 预处理后的值
+	Combined  map[string]float64            // 股票 -> 合成得分，只包含所有因子都有值的股票
+}
+
+// Run 计算调仓日的截面得分，histories为各股票截至date的历史行情
+func (p *Pipeline) Run(date time.Time, histories map[string][]*types.DataPoint) *Scores {
+	scores := &Scores{
+		Date:      date,
+		Raw:       make(map[string]map[string]float64),
+		Processed: make(map[string]map[string]float64),
+		Combined:  make(map[string]float64),
+	}
+
+	inputs := make(map[string]Input, len(histories))
+	var exposure map[string]float64
+	if p.sizeField != "" {
+		exposure = make(map[string]float64)
+	}
+	for symbol, history := range histories {
+		in := Input{Symbol: symbol, Date: date, History: history}
+		if p.fundamentals != nil {
+			in.Fundamentals = p.fundamentals.Lookup(symbol, date)
+		}
+		inputs[symbol] = in
+		if exposure != nil {
+			if size, ok := in.Fundamentals[p.sizeField]; ok && size > 0 {
+				exposure[symbol] = math.Log(size)
+			}
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, w := range p.factors {
+		name := w.factor.Name()
+		raw := make(map[string]float64)
+		for symbol, in := range inputs {
+			if v := w.factor.Compute(in); !math.IsNaN(v) && !math.IsInf(v, 0) {
+				raw[symbol] = v
+			}
+		}
+		scores.Raw[name] = raw
+
+		processed := Standardize(Winsorize(raw, p.winsorize))
+		if p.groups != nil || exposure != nil {
+			processed = Standardize(Neutralize(processed, p.groups, exposure))
+		}
+		scores.Processed[name] = processed
+
+		for symbol, v := range processed {
+			scores.Combined[symbol] += w.weight * v
+			counts[symbol]++
+		}
+	}
+
+	for symbol, n := range counts {
+		if n < len(p.factors) {
+			delete(scores.Combined, symbol)
+		}
+	}
+	return scores
+}
+
+// ranked 按得分从高到低排序，得分相同按代码排序
+func ranked(scores map[string]float64) []string {
+	symbols := make([]string, 0, len(scores))
+	for symbol := range scores {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if scores[symbols[i]] != scores[symbols[j]] {
+			return scores[symbols[i]] > scores[symbols[j]]
+		}
+		return symbols[i] < symbols[j]
+	})
+	return symbols
+}
+
+// TopN 返回得分最高的n只股票
+func TopN(scores map[string]float64, n int) []string {
+	symbols := ranked(scores)
+	if n < len(symbols) {
+		symbols = symbols[:n]
+	}
+	return symbols
+}
+
+// Quantile 按得分从高到低分为q组，返回第k组（1为得分最高的一组）
+func Quantile(scores map[string]float64, q, k int) []string {
+	groups := QuantileGroups(scores, q)
+	if k < 1 || k > len(groups) {
+		return nil
+	}
+	return groups[k-1]
+}
+
+// QuantileGroups 按得分从高到低分为q组，各组数量相差不超过1
+func QuantileGroups(scores map[string]float64, q int) [][]string {
+	if q < 1 {
+		return nil
+	}
+	symbols := ranked(scores)
+	groups := make([][]string, q)
+	for i, symbol := range symbols {
+		g := i * q / len(symbols)
+		groups[g] = append(groups[g], symbol)
+	}
+	return groups
+}
+
+// sortedSymbols 按代码排序的股票列表
+func sortedSymbols(values map[string]float64) []string {
+	symbols := make([]string, 0, len(values))
+	for symbol := range values {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+func median(xs []float64) float64 {
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n == 0 {
+		return math.NaN()
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,188 @@
+package factor
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"stock/common/types"
+)
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestWinsorize(t *testing.T) {
+	values := map[string]float64{"a": 1, "b": 2, "c": 3, "d": 4, "e": 100}
+	// 中位数3，MAD为1，2倍MAD边界为3±2*1.4826
+	got := Winsorize(values, 2)
+	want := map[string]float64{"a": 1, "b": 2, "c": 3, "d": 4, "e": 3 + 2*1.4826}
+	for k, v := range want {
+		if !closeTo(got[k], v) {
+			t.Errorf("%s: 去极值后%v，期望%v", k, got[k], v)
+		}
+	}
+	if values["e"] != 100 {
+		t.Error("去极值不应修改输入")
+	}
+
+	low := Winsorize(map[string]float64{"a": -100, "b": 2, "c": 3, "d": 4, "e": 5}, 2)
+	if !closeTo(low["a"], 3-2*1.4826) {
+		t.Errorf("下界截断后%v，期望%v", low["a"], 3-2*1.4826)
+	}
+	if got := Winsorize(values, 0); !reflect.DeepEqual(got, values) {
+		t.Errorf("n为0时不应去极值，得到%v", got)
+	}
+	// 超过一半的值相同时MAD为0，不截断
+	flat := map[string]float64{"a": 1, "b": 1, "c": 1, "d": 50}
+	if got := Winsorize(flat, 3); !reflect.DeepEqual(got, flat) {
+		t.Errorf("MAD为0时不应去极值，得到%v", got)
+	}
+}
+
+func TestStandardize(t *testing.T) {
+	got := Standardize(map[string]float64{"a": 1, "b": 2, "c": 3})
+	want := map[string]float64{"a": -1, "b": 0, "c": 1}
+	for k, v := range want {
+		if !closeTo(got[k], v) {
+			t.Errorf("%s: 标准化后%v，期望%v", k, got[k], v)
+		}
+	}
+	for _, values := range []map[string]float64{{"a": 5, "b": 5}, {"a": 5}} {
+		for k, v := range Standardize(values) {
+			if v != 0 {
+				t.Errorf("%v: 标准差为0或无法计算时%s应为0，得到%v", values, k, v)
+			}
+		}
+	}
+}
+
+func TestNeutralize(t *testing.T) {
+	values := map[string]float64{"a": 1, "b": 3, "c": 10, "d": 20, "e": 5}
+	groups := map[string]string{"a": "银行", "b": "银行", "c": "电子", "d": "电子"}
+	got := Neutralize(values, groups, nil)
+	want := map[string]float64{"a": -1, "b": 1, "c": -5, "d": 5, "e": 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("行业中性化后%v，期望%v", got, want)
+	}
+
+	// 因子值与对数市值线性相关时残差全为0，没有市值的股票保持原值
+	exposure := map[string]float64{"a": 1, "b": 2, "c": 3, "d": 4, "f": math.NaN()}
+	values = map[string]float64{"a": 3, "b": 5, "c": 7, "d": 9, "e": 42, "f": 8}
+	got = Neutralize(values, nil, exposure)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		if !closeTo(got[k], 0) {
+			t.Errorf("%s: 市值中性化后%v，期望0", k, got[k])
+		}
+	}
+	if got["e"] != 42 || got["f"] != 8 {
+		t.Errorf("没有市值暴露的股票应保持原值，得到e=%v f=%v", got["e"], got["f"])
+	}
+
+	// 残差与市值正交且均值为0
+	values = map[string]float64{"a": 3, "b": 6, "c": 4, "d": 10}
+	got = Neutralize(values, nil, exposure)
+	sum, cross := 0.0, 0.0
+	for _, k := range []string{"a", "b", "c", "d"} {
+		sum += got[k]
+		cross += got[k] * exposure[k]
+	}
+	if !closeTo(sum, 0) || !closeTo(cross, 0) {
+		t.Errorf("残差之和%v、与市值的内积%v，期望均为0", sum, cross)
+	}
+
+	// 样本不足3只时跳过回归
+	few := map[string]float64{"a": 3, "b": 6}
+	if got := Neutralize(few, nil, exposure); !reflect.DeepEqual(got, few) {
+		t.Errorf("样本不足时不应回归，得到%v", got)
+	}
+}
+
+func TestNeutralizeDeterministic(t *testing.T) {
+	// 量级相差悬殊时浮点求和与顺序有关，结果应与map遍历顺序无关
+	values := make(map[string]float64)
+	groups := make(map[string]string)
+	exposure := make(map[string]float64)
+	for i := 0; i < 40; i++ {
+		symbol := fmt.Sprintf("%06d.SH", 600000+i)
+		values[symbol] = math.Pow(10, float64(i%17)) * (1 + float64(i)/7)
+		if i%2 == 1 {
+			values[symbol] = -values[symbol]
+		}
+		groups[symbol] = fmt.Sprint(i % 3)
+		exposure[symbol] = float64(i%5) + float64(i)/11
+	}
+	first := Standardize(Neutralize(values, groups, exposure))
+	for i := 0; i < 50; i++ {
+		if got := Standardize(Neutralize(values, groups, exposure)); !reflect.DeepEqual(got, first) {
+			t.Fatal("相同输入的中性化结果不一致")
+		}
+	}
+}
+
+// fixedFactor 按股票返回固定值的因子
+type fixedFactor struct {
+	name   string
+	values map[string]float64
+}
+
+func (f fixedFactor) Name() string  { return f.name }
+func (f fixedFactor) Lookback() int { return 0 }
+
+func (f fixedFactor) Compute(in Input) float64 {
+	if v, ok := f.values[in.Symbol]; ok {
+		return v
+	}
+	return math.NaN()
+}
+
+func TestPipelineRun(t *testing.T) {
+	histories := map[string][]*types.DataPoint{"a": nil, "b": nil, "c": nil, "d": nil}
+	p := NewPipeline().
+		Add(fixedFactor{"f1", map[string]float64{"a": 1, "b": 2, "c": 3, "d": 4}}, 1).
+		Add(fixedFactor{"f2", map[string]float64{"a": 4, "b": 3, "c": 2, "d": math.Inf(1)}}, -1)
+	p.SetWinsorize(0)
+	scores := p.Run(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), histories)
+
+	if _, ok := scores.Raw["f2"]["d"]; ok {
+		t.Error("无穷大的因子值应剔除")
+	}
+	// f1标准化后a..c为-1.16,-0.39,0.39；f2为1,0,-1，取负权重
+	f1 := Standardize(map[string]float64{"a": 1, "b": 2, "c": 3, "d": 4})
+	want := map[string]float64{"a": f1["a"] - 1, "b": f1["b"], "c": f1["c"] + 1}
+	if len(scores.Combined) != len(want) {
+		t.Fatalf("合成得分%v，缺少因子值的d不应参与合成", scores.Combined)
+	}
+	for k, v := range want {
+		if !closeTo(scores.Combined[k], v) {
+			t.Errorf("%s: 合成得分%v，期望%v", k, scores.Combined[k], v)
+		}
+	}
+	if got := TopN(scores.Combined, 1); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Errorf("得分最高的股票%v，期望c", got)
+	}
+}
+
+func TestRanking(t *testing.T) {
+	scores := map[string]float64{"a": 1, "c": 2, "b": 2, "d": 0, "e": -1}
+	if got := TopN(scores, 2); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("TopN=%v，得分相同应按代码排序", got)
+	}
+	if got := TopN(scores, 10); len(got) != 5 {
+		t.Errorf("n超过股票数时返回%v", got)
+	}
+
+	// 5只股票分2组：3只和2只
+	want := [][]string{{"b", "c", "a"}, {"d", "e"}}
+	if got := QuantileGroups(scores, 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("分组%v，期望%v", got, want)
+	}
+	if got := Quantile(scores, 2, 2); !reflect.DeepEqual(got, want[1]) {
+		t.Errorf("第2组%v，期望%v", got, want[1])
+	}
+	if Quantile(scores, 2, 0) != nil || Quantile(scores, 2, 3) != nil || QuantileGroups(scores, 0) != nil {
+		t.Error("组号越界应返回nil")
+	}
+}
@@ -0,0 +1,383 @@
+package factor
+
+import (
+	"math"
+	"sort"
+	"stock/common/types"
+	"stock/datasource"
+	"stock/stats"
+	"time"
+)
+
+// Panel 面板数据：日期 -> 股票 -> 值
+type Panel map[time.Time]map[string]float64
+
+// Dates 按时间升序返回面板中的日期
+func (p Panel) Dates() []time.Time {
+	dates := make([]time.Time, 0, len(p))
+	for date := range p {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+// eachCrossSection 按日期升序遍历所有股票的历史，回调当日有K线的股票截至当日的历史
+func eachCrossSection(histories map[string][]*types.DataPoint, fn func(date time.Time, section map[string][]*types.DataPoint)) {
+	seen := make(map[time.Time]bool)
+	for _, history := range histories {
+		for _, dp := range history {
+			seen[dp.Timestamp] = true
+		}
+	}
+	dates := make([]time.Time, 0, len(seen))
+	for date := range seen {
+		dates = append(dates, date)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	next := make(map[string]int, len(histories))
+	for _, date := range dates {
+		section := make(map[string][]*types.DataPoint)
+		for symbol, history := range histories {
+			i := next[symbol]
+			if i < len(history) && history[i].Timestamp.Equal(date) {
+				section[symbol] = history[:i+1]
+				next[symbol] = i + 1
+			}
+		}
+		fn(date, section)
+	}
+}
+
+// ComputePanel 计算单个因子在每个交易日的原始截面值，当日停牌的股票不计算
+func ComputePanel(f Factor, histories map[string][]*types.DataPoint, fundamentals *datasource.Fundamentals) Panel {
+	panel := make(Panel)
+	eachCrossSection(histories, func(date time.Time, section map[string][]*types.DataPoint) {
+		values := make(map[string]float64)
+		for symbol, history := range section {
+			in := Input{Symbol: symbol, Date: date, History: history}
+			if fundamentals != nil {
+				in.Fundamentals = fundamentals.Lookup(symbol, date)
+			}
+			if v := f.Compute(in); !math.IsNaN(v) && !math.IsInf(v, 0) {
+				values[symbol] = v
+			}
+		}
+		if len(values) > 0 {
+			panel[date] = values
+		}
+	})
+	return panel
+}
+
+// Panel 计算每个交易日的合成得分
+func (p *Pipeline) Panel(histories map[string][]*types.DataPoint) Panel {
+	panel := make(Panel)
+	eachCrossSection(histories, func(date time.Time, section map[string][]*types.DataPoint) {
+		if scores := p.Run(date, section); len(scores.Combined) > 0 {
+			panel[date] = scores.Combined
+		}
+	})
+	return panel
+}
+
+// ForwardReturns 计算各持有期的未来收益率：第t根K线收盘买入，第t+h根K线收盘卖出
+func ForwardReturns(histories map[string][]*types.DataPoint, horizons []int) map[int]Panel {
+	result := make(map[int]Panel, len(horizons))
+	for _, h := range horizons {
+		panel := make(Panel)
+		for symbol, history := range histories {
+			for i := 0; i+h < len(history); i++ {
+				start, end := history[i].Close, history[i+h].Close
+				if start <= 0 {
+					continue
+				}
+				date := history[i].Timestamp
+				if panel[date] == nil {
+					panel[date] = make(map[string]float64)
+				}
+				panel[date][symbol] = end/start - 1
+			}
+		}
+		result[h] = panel
+	}
+	return result
+}
+
+// ICStats IC序列的统计量
+type ICStats struct {
+	Mean     float64
+	Std      float64
+	IR       float64 // Mean/Std
+	TStat    float64
+	Positive float64 // IC为正的比例
+	Count    int
+}
+
+// HorizonReport 单个持有期的分析结果，序列与Report.Dates对齐，缺失为NaN
+type HorizonReport struct {
+	Horizon         int
+	IC              []float64
+	RankIC          []float64
+	ICStats         ICStats
+	RankICStats     ICStats
+	QuantileReturns [][]float64 // [分位组][日期]，组0为因子值最高的一组
+	QuantileMean    []float64   // 各分位组平均每期收益
+	Spread          []float64   // 最高组减最低组
+	SpreadMean      float64
+}
+
+// Report 因子分析报告
+type Report struct {
+	Name            string
+	Dates           []time.Time
+	Quantiles       int
+	Horizons        []*HorizonReport
+	Autocorrelation []float64   // 相邻两期因子值的秩相关系数
+	Turnover        [][]float64 // [分位组][日期]，新进入该组的股票占比
+}
+
+// Analyze 根据因子面板和未来收益率面板计算IC、分位组收益、自相关和换手率
+func Analyze(name string, factorValues Panel, forward map[int]Panel, quantiles int) *Report {
+	if quantiles < 1 {
+		quantiles = 5
+	}
+	report := &Report{
+		Name:      name,
+		Dates:     factorValues.Dates(),
+		Quantiles: quantiles,
+	}
+
+	horizons := make([]int, 0, len(forward))
+	for h := range forward {
+		horizons = append(horizons, h)
+	}
+	sort.Ints(horizons)
+
+	for _, h := range horizons {
+		report.Horizons = append(report.Horizons, analyzeHorizon(h, report.Dates, factorValues, forward[h], quantiles))
+	}
+
+	report.Autocorrelation = make([]float64, len(report.Dates))
+	report.Turnover = make([][]float64, quantiles)
+	for q := range report.Turnover {
+		report.Turnover[q] = make([]float64, len(report.Dates))
+	}
+	var prevGroups [][]string
+	for i, date := range report.Dates {
+		values := factorValues[date]
+		report.Autocorrelation[i] = math.NaN()
+		if i > 0 {
+			prev := factorValues[report.Dates[i-1]]
+			var xs, ys []float64
+			for symbol, v := range values {
+				if pv, ok := prev[symbol]; ok {
+					xs = append(xs, pv)
+					ys = append(ys, v)
+				}
+			}
+			report.Autocorrelation[i] = spearman(xs, ys)
+		}
+
+		groups := QuantileGroups(values, quantiles)
+		for q := range groups {
+			report.Turnover[q][i] = math.NaN()
+			if prevGroups == nil || len(groups[q]) == 0 {
+				continue
+			}
+			before := make(map[string]bool, len(prevGroups[q]))
+			for _, symbol := range prevGroups[q] {
+				before[symbol] = true
+			}
+			entered := 0
+			for _, symbol := range groups[q] {
+				if !before[symbol] {
+					entered++
+				}
+			}
+			report.Turnover[q][i] = float64(entered) / float64(len(groups[q]))
+		}
+		prevGroups = groups
+	}
+	return report
+}
+
+// MeanAutocorrelation 平均因子自相关
+func (r *Report) MeanAutocorrelation() float64 {
+	return nanMean(r.Autocorrelation)
+}
+
+// MeanTurnover 第q个分位组（0为最高组）的平均换手率
+func (r *Report) MeanTurnover(q int) float64 {
+	if q < 0 || q >= len(r.Turnover) {
+		return math.NaN()
+	}
+	return nanMean(r.Turnover[q])
+}
+
+func analyzeHorizon(h int, dates []time.Time, factorValues, returns Panel, quantiles int) *HorizonReport {
+	r := &HorizonReport{
+		Horizon:         h,
+		IC:              make([]float64, len(dates)),
+		RankIC:          make([]float64, len(dates)),
+		QuantileReturns: make([][]float64, quantiles),
+		QuantileMean:    make([]float64, quantiles),
+		Spread:          make([]float64, len(dates)),
+	}
+	for q := range r.QuantileReturns {
+		r.QuantileReturns[q] = make([]float64, len(dates))
+	}
+
+	for i, date := range dates {
+		r.IC[i], r.RankIC[i], r.Spread[i] = math.NaN(), math.NaN(), math.NaN()
+		for q := range r.QuantileReturns {
+			r.QuantileReturns[q][i] = math.NaN()
+		}
+
+		// 只使用当日同时有因子值和未来收益的股票
+		values := make(map[string]float64)
+		var xs, ys []float64
+		for symbol, v := range factorValues[date] {
+			if ret, ok := returns[date][symbol]; ok {
+				values[symbol] = v
+				xs = append(xs, v)
+				ys = append(ys, ret)
+			}
+		}
+		if len(xs) < 2 {
+			continue
+		}
+		r.IC[i] = pearson(xs, ys)
+		r.RankIC[i] = spearman(xs, ys)
+
+		for q, group := range QuantileGroups(values, quantiles) {
+			if len(group) == 0 {
+				continue
+			}
+			sum := 0.0
+			for _, symbol := range group {
+				sum += returns[date][symbol]
+			}
+			r.QuantileReturns[q][i] = sum / float64(len(group))
+		}
+		r.Spread[i] = r.QuantileReturns[0][i] - r.QuantileReturns[quantiles-1][i]
+	}
+
+	r.ICStats = icStats(r.IC)
+	r.RankICStats = icStats(r.RankIC)
+	for q := range r.QuantileReturns {
+		r.QuantileMean[q] = nanMean(r.QuantileReturns[q])
+	}
+	r.SpreadMean = nanMean(r.Spread)
+	return r
+}
+
+// Cumulative 将持有期为h的每期收益折算为日收益后累计净值，避免重叠持有期重复计算
+func Cumulative(returns []float64, h int) []float64 {
+	if h < 1 {
+		h = 1
+	}
+	out := make([]float64, len(returns))
+	value := 1.0
+	for i, r := range returns {
+		if !math.IsNaN(r) && r > -1 {
+			value *= math.Pow(1+r, 1/float64(h))
+		}
+		out[i] = value
+	}
+	return out
+}
+
+// CumulativeSum 累计求和，NaN视为0，用于绘制累计IC
+func CumulativeSum(values []float64) []float64 {
+	out := make([]float64, len(values))
+	sum := 0.0
+	for i, v := range values {
+		if !math.IsNaN(v) {
+			sum += v
+		}
+		out[i] = sum
+	}
+	return out
+}
+
+func icStats(ic []float64) ICStats {
+	var xs []float64
+	positive := 0
+	for _, v := range ic {
+		if math.IsNaN(v) {
+			continue
+		}
+		xs = append(xs, v)
+		if v > 0 {
+			positive++
+		}
+	}
+	result := ICStats{Mean: stats.Mean(xs), Std: stats.StdDev(xs), Count: len(xs)}
+	if len(xs) > 0 {
+		result.Positive = float64(positive) / float64(len(xs))
+	}
+	if result.Std > 0 {
+		result.IR = result.Mean / result.Std
+		result.TStat = result.IR * math.Sqrt(float64(len(xs)))
+	}
+	return result
+}
+
+// nanMean 忽略NaN的均值
+func nanMean(values []float64) float64 {
+	var xs []float64
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			xs = append(xs, v)
+		}
+	}
+	return stats.Mean(xs)
+}
+
+// pearson 皮尔逊相关系数
+func pearson(xs, ys []float64) float64 {
+	if len(xs) < 2 {
+		return math.NaN()
+	}
+	mx, my := stats.Mean(xs), stats.Mean(ys)
+	cov, vx, vy := 0.0, 0.0, 0.0
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+// spearman 秩相关系数，并列取平均秩
+func spearman(xs, ys []float64) float64 {
+	return pearson(ranks(xs), ranks(ys))
+}
+
+func ranks(xs []float64) []float64 {
+	idx := make([]int, len(xs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return xs[idx[a]] < xs[idx[b]] })
+
+	out := make([]float64, len(xs))
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && xs[idx[j+1]] == xs[idx[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			out[idx[k]] = rank
+		}
+		i = j + 1
+	}
+	return out
+}
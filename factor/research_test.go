@@ -0,0 +1,102 @@
+package factor
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"stock/common/types"
+)
+
+func TestForwardReturns(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	histories := map[string][]*types.DataPoint{
+		"a": {{Timestamp: day(2), Close: 10}, {Timestamp: day(3), Close: 11}, {Timestamp: day(4), Close: 12.1}},
+	}
+	forward := ForwardReturns(histories, []int{1, 2})
+	if got := forward[1][day(3)]["a"]; !closeTo(got, 0.1) {
+		t.Errorf("1日收益%v，期望0.1", got)
+	}
+	if got := forward[2][day(2)]["a"]; !closeTo(got, 0.21) {
+		t.Errorf("2日收益%v，期望0.21", got)
+	}
+	if _, ok := forward[1][day(4)]; ok {
+		t.Error("最后一根K线没有未来收益")
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	// e只有因子值没有未来收益，不参与IC和分组收益，但参与换手率分组
+	factorValues := Panel{
+		day(2): {"a": 4, "b": 3, "c": 2, "d": 1, "e": 0},
+		day(3): {"a": 1, "b": 2, "c": 3, "d": 4},
+		day(4): {"a": 1, "b": 2, "c": 3, "d": 4},
+	}
+	returns := Panel{
+		day(2): {"a": 0.04, "b": 0.03, "c": 0.02, "d": 0.01},
+		day(3): {"a": 0.04, "b": 0.03, "c": 0.02, "d": 0.01},
+		day(4): {"a": 0, "b": 0.01, "c": 0.02, "d": 0.1},
+	}
+	report := Analyze("test", factorValues, map[int]Panel{5: {}, 1: returns}, 2)
+
+	if len(report.Horizons) != 2 || report.Horizons[0].Horizon != 1 || report.Horizons[1].Horizon != 5 {
+		t.Fatalf("持有期应按升序排列")
+	}
+	h := report.Horizons[0]
+
+	// 第3天收益与因子值秩完全一致，但d的收益偏大，IC小于1
+	ic3 := 15.5 / math.Sqrt(5*62.75)
+	wantIC := []float64{1, -1, ic3}
+	wantRankIC := []float64{1, -1, 1}
+	for i := range wantIC {
+		if !closeTo(h.IC[i], wantIC[i]) || !closeTo(h.RankIC[i], wantRankIC[i]) {
+			t.Errorf("第%d期IC=%v RankIC=%v，期望%v %v", i, h.IC[i], h.RankIC[i], wantIC[i], wantRankIC[i])
+		}
+	}
+	if s := h.ICStats; !closeTo(s.Mean, ic3/3) || s.Count != 3 || !closeTo(s.Positive, 2.0/3) {
+		t.Errorf("IC统计%+v", s)
+	}
+	// RankIC为1,-1,1：均值1/3，样本标准差2/√3，t值0.5
+	if s := h.RankICStats; !closeTo(s.Mean, 1.0/3) || !closeTo(s.Std, 2/math.Sqrt(3)) || !closeTo(s.TStat, 0.5) {
+		t.Errorf("RankIC统计%+v", s)
+	}
+
+	wantTop := []float64{0.035, 0.015, 0.06}
+	wantBottom := []float64{0.015, 0.035, 0.005}
+	for i := range wantTop {
+		if !closeTo(h.QuantileReturns[0][i], wantTop[i]) || !closeTo(h.QuantileReturns[1][i], wantBottom[i]) {
+			t.Errorf("第%d期分组收益%v %v，期望%v %v", i,
+				h.QuantileReturns[0][i], h.QuantileReturns[1][i], wantTop[i], wantBottom[i])
+		}
+		if !closeTo(h.Spread[i], wantTop[i]-wantBottom[i]) {
+			t.Errorf("第%d期多空收益%v，期望%v", i, h.Spread[i], wantTop[i]-wantBottom[i])
+		}
+	}
+	if !closeTo(h.QuantileMean[0], 0.11/3) || !closeTo(h.SpreadMean, 0.055/3) {
+		t.Errorf("最高组平均收益%v、多空平均收益%v", h.QuantileMean[0], h.SpreadMean)
+	}
+
+	if empty := report.Horizons[1]; empty.ICStats.Count != 0 || !math.IsNaN(empty.IC[0]) || !math.IsNaN(empty.Spread[0]) {
+		t.Errorf("没有收益数据的持有期应为NaN，得到%+v", empty.ICStats)
+	}
+
+	// 第1天分组[a b c][d e]，第2、3天[d c][b a]
+	if !math.IsNaN(report.Turnover[0][0]) || !math.IsNaN(report.Autocorrelation[0]) {
+		t.Error("第1期没有换手率和自相关")
+	}
+	wantTurnover := [][]float64{{0.5, 0}, {1, 0}}
+	for q := range wantTurnover {
+		for i, want := range wantTurnover[q] {
+			if got := report.Turnover[q][i+1]; !closeTo(got, want) {
+				t.Errorf("第%d组第%d期换手率%v，期望%v", q, i+1, got, want)
+			}
+		}
+	}
+	if !closeTo(report.MeanTurnover(0), 0.25) || !closeTo(report.MeanTurnover(1), 0.5) || !math.IsNaN(report.MeanTurnover(2)) {
+		t.Errorf("平均换手率%v %v", report.MeanTurnover(0), report.MeanTurnover(1))
+	}
+	if !closeTo(report.Autocorrelation[1], -1) || !closeTo(report.Autocorrelation[2], 1) || !closeTo(report.MeanAutocorrelation(), 0) {
+		t.Errorf("因子自相关%v", report.Autocorrelation)
+	}
+}
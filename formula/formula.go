@@ -0,0 +1,305 @@
+// Package formula 实现通达信公式语言的常用子集，对整段行情做向量化求值
+//
+// 支持的语法：
+//
+//	NAME:expr;   输出线
+//	NAME:=expr;  中间变量
+//	{注释} 与 // 注释，输出后的 ,COLORRED 等绘图属性会被忽略
+//
+// 行情变量为 O/OPEN、H/HIGH、L/LOW、C/CLOSE、V/VOL/VOLUME，
+// 逻辑运算为 AND/OR/NOT，比较结果为1或0。
+package formula
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"stock/common/types"
+	"strings"
+)
+
+// Program 编译后的公式
+type Program struct {
+	source     string
+	statements []statement
+	params     map[string]float64
+}
+
+// Result 公式求值结果，Names保持公式中的输出顺序
+type Result struct {
+	Names  []string
+	Series map[string][]float64
+}
+
+// Get 按输出名读取序列
+func (r *Result) Get(name string) ([]float64, bool) {
+	series, ok := r.Series[name]
+	return series, ok
+}
+
+// Compile 解析公式并检查函数名与参数个数
+func Compile(src string) (*Program, error) {
+	stmts, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, stmt := range stmts {
+		if seen[stmt.name] {
+			return nil, fmt.Errorf("重复定义: %s", stmt.name)
+		}
+		if isSeries(stmt.name) {
+			return nil, fmt.Errorf("不能重定义行情变量: %s", stmt.name)
+		}
+		seen[stmt.name] = true
+		if err := checkCalls(stmt.expr); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Program{source: src, statements: stmts, params: make(map[string]float64)}, nil
+}
+
+// MustCompile 与Compile相同，出错时panic，用于固定公式
+func MustCompile(src string) *Program {
+	p, err := Compile(src)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// SetParam 设置公式参数，例如 N、M
+func (p *Program) SetParam(name string, value float64) {
+	p.params[strings.ToUpper(name)] = value
+}
+
+// Source 返回公式源码
+func (p *Program) Source() string {
+	return p.source
+}
+
+// Outputs 返回输出线名称
+func (p *Program) Outputs() []string {
+	var names []string
+	for _, stmt := range p.statements {
+		if stmt.output {
+			names = append(names, stmt.name)
+		}
+	}
+	return names
+}
+
+// Evaluate 在整段行情上求值，序列长度与data相同，数据不足处为NaN
+func (p *Program) Evaluate(data []*types.DataPoint) (*Result, error) {
+	env := &env{
+		n:         len(data),
+		data:      data,
+		params:    p.params,
+		variables: make(map[string][]float64),
+	}
+
+	result := &Result{Series: make(map[string][]float64)}
+	for _, stmt := range p.statements {
+		values, err := env.eval(stmt.expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", stmt.name, err)
+		}
+		env.variables[stmt.name] = values
+		if stmt.output {
+			result.Names = append(result.Names, stmt.name)
+			result.Series[stmt.name] = values
+		}
+	}
+	return result, nil
+}
+
+// env 单次求值的上下文
+type env struct {
+	n         int
+	data      []*types.DataPoint
+	params    map[string]float64
+	variables map[string][]float64
+}
+
+func (e *env) eval(n node) ([]float64, error) {
+	switch n := n.(type) {
+	case *numberNode:
+		return e.constant(n.value), nil
+	case *identNode:
+		return e.ident(n)
+	case *unaryNode:
+		operand, err := e.eval(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]float64, e.n)
+		for i, v := range operand {
+			switch n.op {
+			case "-":
+				out[i] = -v
+			case "+":
+				out[i] = v
+			case "NOT":
+				out[i] = boolValue(!truth(v))
+			}
+		}
+		return out, nil
+	case *binaryNode:
+		left, err := e.eval(n.left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.eval(n.right)
+		if err != nil {
+			return nil, err
+		}
+		return binary(n.op, left, right), nil
+	case *callNode:
+		fn := functions[n.name]
+		args := make([][]float64, len(n.args))
+		for i, arg := range n.args {
+			values, err := e.eval(arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = values
+		}
+		return fn.apply(e.n, args), nil
+	default:
+		return nil, fmt.Errorf("未知节点 %T", n)
+	}
+}
+
+func (e *env) constant(value float64) []float64 {
+	out := make([]float64, e.n)
+	for i := range out {
+		out[i] = value
+	}
+	return out
+}
+
+func (e *env) ident(n *identNode) ([]float64, error) {
+	if values, ok := e.variables[n.name]; ok {
+		return values, nil
+	}
+	if value, ok := e.params[n.name]; ok {
+		return e.constant(value), nil
+	}
+	if field, ok := seriesFields[n.name]; ok {
+		out := make([]float64, e.n)
+		for i, dp := range e.data {
+			out[i] = field(dp)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("位置%d: 未定义的变量 %s", n.pos, n.name)
+}
+
+// seriesFields 行情变量
+var seriesFields = map[string]func(*types.DataPoint) float64{
+	"O":      func(dp *types.DataPoint) float64 { return dp.Open },
+	"OPEN":   func(dp *types.DataPoint) float64 { return dp.Open },
+	"H":      func(dp *types.DataPoint) float64 { return dp.High },
+	"HIGH":   func(dp *types.DataPoint) float64 { return dp.High },
+	"L":      func(dp *types.DataPoint) float64 { return dp.Low },
+	"LOW":    func(dp *types.DataPoint) float64 { return dp.Low },
+	"C":      func(dp *types.DataPoint) float64 { return dp.Close },
+	"CLOSE":  func(dp *types.DataPoint) float64 { return dp.Close },
+	"V":      func(dp *types.DataPoint) float64 { return dp.Volume },
+	"VOL":    func(dp *types.DataPoint) float64 { return dp.Volume },
+	"VOLUME": func(dp *types.DataPoint) float64 { return dp.Volume },
+}
+
+func isSeries(name string) bool {
+	_, ok := seriesFields[name]
+	return ok
+}
+
+// checkCalls 检查函数是否存在以及参数个数
+func checkCalls(n node) error {
+	switch n := n.(type) {
+	case *unaryNode:
+		return checkCalls(n.operand)
+	case *binaryNode:
+		if err := checkCalls(n.left); err != nil {
+			return err
+		}
+		return checkCalls(n.right)
+	case *callNode:
+		fn, ok := functions[n.name]
+		if !ok {
+			return fmt.Errorf("位置%d: 不支持的函数 %s", n.pos, n.name)
+		}
+		if len(n.args) != fn.arity {
+			return fmt.Errorf("位置%d: %s需要%d个参数，实际为%d", n.pos, n.name, fn.arity, len(n.args))
+		}
+		for _, arg := range n.args {
+			if err := checkCalls(arg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Functions 返回支持的函数名
+func Functions() []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func binary(op string, left, right []float64) []float64 {
+	out := make([]float64, len(left))
+	for i := range out {
+		a, b := left[i], right[i]
+		switch op {
+		case "+":
+			out[i] = a + b
+		case "-":
+			out[i] = a - b
+		case "*":
+			out[i] = a * b
+		case "/":
+			if b == 0 {
+				out[i] = math.NaN()
+			} else {
+				out[i] = a / b
+			}
+		case ">":
+			out[i] = boolValue(a > b)
+		case "<":
+			out[i] = boolValue(a < b)
+		case ">=":
+			out[i] = boolValue(a >= b)
+		case "<=":
+			out[i] = boolValue(a <= b)
+		case "=":
+			out[i] = boolValue(a == b)
+		case "<>":
+			out[i] = boolValue(!math.IsNaN(a) && !math.IsNaN(b) && a != b)
+		case "AND":
+			out[i] = boolValue(truth(a) && truth(b))
+		case "OR":
+			out[i] = boolValue(truth(a) || truth(b))
+		}
+	}
+	return out
+}
+
+// truth 非零且非NaN视为真
+func truth(v float64) bool {
+	return v != 0 && !math.IsNaN(v)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
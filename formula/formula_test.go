@@ -0,0 +1,158 @@
+package formula
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"stock/common/types"
+)
+
+var nan = math.NaN()
+
+// testBars 收盘价1 3 2 5 4，最高价为收盘价+1，最低价为收盘价-1
+func testBars() []*types.DataPoint {
+	closes := []float64{1, 3, 2, 5, 4}
+	bars := make([]*types.DataPoint, len(closes))
+	for i, c := range closes {
+		bars[i] = &types.DataPoint{Open: c, High: c + 1, Low: c - 1, Close: c, Volume: float64(i+1) * 100}
+	}
+	return bars
+}
+
+func sameSeries(got, want []float64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if math.IsNaN(want[i]) != math.IsNaN(got[i]) {
+			return false
+		}
+		if !math.IsNaN(want[i]) && math.Abs(got[i]-want[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []float64
+	}{
+		{"MA(C,3)", []float64{nan, nan, 2, 10.0 / 3, 11.0 / 3}},
+		{"MA(MA(C,2),2)", []float64{nan, nan, 2.25, 3, 4}},
+		{"MA(REF(C,1),3)", []float64{nan, nan, nan, 2, 10.0 / 3}},
+		{"EMA(C,3)", []float64{1, 2, 2, 3.5, 3.75}},
+		{"SMA(C,3,1)", []float64{1, 5.0 / 3, 16.0 / 9, 77.0 / 27, 262.0 / 81}},
+		{"STD(C,3)", []float64{nan, nan, 1, math.Sqrt(7.0 / 3), math.Sqrt(7.0 / 3)}},
+		{"REF(C,2)", []float64{nan, nan, 1, 3, 2}},
+		{"HHV(C,3)", []float64{nan, nan, 3, 5, 5}},
+		{"HHV(H,0)", []float64{2, 4, 4, 6, 6}},
+		{"HHV(REF(C,1),2)", []float64{nan, nan, 3, 3, 5}},
+		{"LLV(C,2)", []float64{nan, 1, 2, 2, 4}},
+		{"LLV(L,0)", []float64{0, 0, 0, 0, 0}},
+		{"SUM(C,2)", []float64{nan, 4, 5, 7, 9}},
+		{"SUM(C,0)", []float64{1, 4, 6, 11, 15}},
+		{"SUM(REF(C,1),0)", []float64{nan, 1, 4, 6, 11}},
+		{"COUNT(C>2,2)", []float64{nan, 1, 1, 1, 2}},
+		{"COUNT(C>2,0)", []float64{0, 1, 1, 2, 3}},
+		{"EVERY(C>0,3)", []float64{0, 0, 1, 1, 1}},
+		{"EVERY(C>1,0)", []float64{0, 0, 0, 0, 0}},
+		{"EXIST(C>4,2)", []float64{0, 0, 0, 1, 1}},
+		{"BARSLAST(C>2)", []float64{nan, 0, 1, 0, 0}},
+		{"CROSS(C,3)", []float64{0, 0, 0, 1, 0}},
+		{"IF(C>2,C,-C)", []float64{-1, 3, -2, 5, 4}},
+		{"ABS(C-3)", []float64{2, 0, 1, 2, 1}},
+		{"SQRT(C-1)", []float64{0, math.Sqrt2, 1, 2, math.Sqrt(3)}},
+		{"NOT(C>2)", []float64{1, 0, 1, 0, 0}},
+		{"MAX(C,3)", []float64{3, 3, 3, 5, 4}},
+		{"MIN(C,3)", []float64{1, 3, 2, 3, 3}},
+		{"C/(C-3)", []float64{-0.5, nan, -2, 2.5, 4}},
+		{"C>2 AND C<5 OR C=1", []float64{1, 1, 0, 0, 1}},
+		{"C<>3", []float64{1, 0, 1, 1, 1}},
+		{"REF(C,1)<>3", []float64{0, 1, 0, 1, 1}},
+		{"-C+V/100", []float64{0, -1, 1, -1, 1}},
+	}
+	bars := testBars()
+	for _, tt := range tests {
+		program, err := Compile("X:" + tt.expr + ";")
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		result, err := program.Evaluate(bars)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := result.Series["X"]; !sameSeries(got, tt.want) {
+			t.Errorf("%s = %v，期望%v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestWindowedVariablePeriod(t *testing.T) {
+	// 周期逐根变化：HHV(C,BARSLAST(C>2)+1) 为上次C>2以来的最高价
+	program := MustCompile("X:HHV(C,BARSLAST(C>2)+1);")
+	result, err := program.Evaluate(testBars())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{nan, 3, 3, 5, 4}; !sameSeries(result.Series["X"], want) {
+		t.Errorf("X = %v，期望%v", result.Series["X"], want)
+	}
+}
+
+func TestProgram(t *testing.T) {
+	program := MustCompile(`
+		{均线交叉} N:=2;
+		FAST:MA(C,N), COLORRED;
+		SLOW:MA(C,M);
+		// 中间变量不输出
+		D:=FAST-SLOW;
+		D>0;`)
+	program.SetParam("m", 3)
+	result, err := program.Evaluate(testBars())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(result.Names, ","); got != "FAST,SLOW,OUT1" {
+		t.Errorf("输出%s，期望FAST,SLOW,OUT1", got)
+	}
+	if want := []float64{0, 0, 1, 1, 1}; !sameSeries(result.Series["OUT1"], want) {
+		t.Errorf("OUT1 = %v，期望%v", result.Series["OUT1"], want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", "公式为空"},
+		{"MA(C,3", "期望','或')'"},
+		{"X:C+;", "意外的符号"},
+		{"X:C+", "公式意外结束"},
+		{"X:C @ 1;", "无法识别的字符"},
+		{"{未闭合 X:C;", "注释未闭合"},
+		{"X:C 1;", "期望';'"},
+		{"X:C,;", "期望绘图属性"},
+		{"X:C;X:O;", "重复定义"},
+		{"C:O;", "不能重定义行情变量"},
+		{"X:FOO(C);", "不支持的函数"},
+		{"X:MA(C);", "MA需要2个参数"},
+		{"X:1.2.3;", "数字格式错误"},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q 错误为%v，期望包含%q", tt.src, err, tt.want)
+		}
+	}
+
+	// 未定义的变量在求值时报错
+	if _, err := MustCompile("X:Y+1;").Evaluate(testBars()); err == nil || !strings.Contains(err.Error(), "未定义的变量 Y") {
+		t.Errorf("未定义变量的错误为%v", err)
+	}
+}
@@ -0,0 +1,332 @@
+package formula
+
+import (
+	"math"
+	"math/bits"
+)
+
+// function 内置函数，参数与返回值都是与行情等长的序列
+type function struct {
+	arity int
+	apply func(n int, args [][]float64) []float64
+}
+
+var functions = map[string]function{
+	"MA":       {2, fnMA},
+	"EMA":      {2, fnEMA},
+	"SMA":      {3, fnSMA},
+	"STD":      {2, fnSTD},
+	"REF":      {2, fnREF},
+	"HHV":      {2, windowed(rangeTable(math.Max))},
+	"LLV":      {2, windowed(rangeTable(math.Min))},
+	"SUM":      {2, windowed(prefixSum(func(v float64) float64 { return v }))},
+	"COUNT":    {2, windowed(prefixSum(countTrue))},
+	"EVERY":    {2, fnEVERY},
+	"EXIST":    {2, fnEXIST},
+	"BARSLAST": {1, fnBARSLAST},
+	"CROSS":    {2, fnCROSS},
+	"IF":       {3, fnIF},
+	"IFF":      {3, fnIF},
+	"ABS":      {1, elementwise(math.Abs)},
+	"SQRT":     {1, elementwise(math.Sqrt)},
+	"NOT":      {1, elementwise(func(v float64) float64 { return boolValue(!truth(v)) })},
+	"MAX":      {2, pairwise(math.Max)},
+	"MIN":      {2, pairwise(math.Min)},
+}
+
+func nanSeries(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
+
+// period 取周期参数的最后一个有效值，用于MA、EMA等需要固定周期的函数
+func period(values []float64) int {
+	for i := len(values) - 1; i >= 0; i-- {
+		if !math.IsNaN(values[i]) {
+			return int(values[i])
+		}
+	}
+	return 0
+}
+
+// barPeriod 取第i根K线的周期参数，NaN或负数视为无效
+func barPeriod(values []float64, i int) (int, bool) {
+	v := values[i]
+	if math.IsNaN(v) || v < 0 {
+		return 0, false
+	}
+	return int(v), true
+}
+
+func elementwise(f func(float64) float64) func(int, [][]float64) []float64 {
+	return func(n int, args [][]float64) []float64 {
+		out := make([]float64, n)
+		for i, v := range args[0] {
+			out[i] = f(v)
+		}
+		return out
+	}
+}
+
+func pairwise(f func(a, b float64) float64) func(int, [][]float64) []float64 {
+	return func(n int, args [][]float64) []float64 {
+		out := make([]float64, n)
+		for i := range out {
+			out[i] = f(args[0][i], args[1][i])
+		}
+		return out
+	}
+}
+
+// rangeQuery 返回第start到第i根K线（含）的累积值
+type rangeQuery func(start, i int) float64
+
+// windowed 对最近N根K线做累积，N为0时从第一个有效值起累积；N可以逐根变化
+func windowed(build func(x []float64) rangeQuery) func(int, [][]float64) []float64 {
+	return func(n int, args [][]float64) []float64 {
+		x, periods := args[0], args[1]
+		query := build(x)
+		first := 0
+		for first < n && math.IsNaN(x[first]) {
+			first++
+		}
+		out := nanSeries(n)
+		for i := 0; i < n; i++ {
+			p, ok := barPeriod(periods, i)
+			if !ok {
+				continue
+			}
+			start := first
+			if p == 0 && i < first {
+				continue
+			}
+			if p > 0 {
+				if i+1 < p {
+					continue
+				}
+				start = i + 1 - p
+			}
+			out[i] = query(start, i)
+		}
+		return out
+	}
+}
+
+// prefixSum 按前缀和求区间和，区间内有NaN时结果为NaN
+func prefixSum(value func(float64) float64) func(x []float64) rangeQuery {
+	return func(x []float64) rangeQuery {
+		sums := make([]float64, len(x)+1)
+		nans := make([]int, len(x)+1)
+		for i, v := range x {
+			v = value(v)
+			sums[i+1], nans[i+1] = sums[i], nans[i]
+			if math.IsNaN(v) {
+				nans[i+1]++
+			} else {
+				sums[i+1] += v
+			}
+		}
+		return func(start, i int) float64 {
+			if nans[i+1] > nans[start] {
+				return math.NaN()
+			}
+			return sums[i+1] - sums[start]
+		}
+	}
+}
+
+// countTrue COUNT的计数值，NaN视为条件不成立
+func countTrue(v float64) float64 {
+	return boolValue(truth(v))
+}
+
+// rangeTable 稀疏表求区间最值，预处理O(n log n)，每次查询O(1)；f遇到NaN返回NaN
+func rangeTable(f func(a, b float64) float64) func(x []float64) rangeQuery {
+	return func(x []float64) rangeQuery {
+		levels := [][]float64{x}
+		for k := 1; 1<<k <= len(x); k++ {
+			prev, half := levels[k-1], 1<<(k-1)
+			level := make([]float64, len(x)-1<<k+1)
+			for i := range level {
+				level[i] = f(prev[i], prev[i+half])
+			}
+			levels = append(levels, level)
+		}
+		return func(start, i int) float64 {
+			k := bits.Len(uint(i-start+1)) - 1
+			return f(levels[k][start], levels[k][i+1-1<<k])
+		}
+	}
+}
+
+// fnMA 简单移动平均，窗口内有NaN时为NaN，NaN移出窗口后恢复
+func fnMA(n int, args [][]float64) []float64 {
+	x, p := args[0], period(args[1])
+	out := nanSeries(n)
+	if p <= 0 {
+		return out
+	}
+	sum, nans := 0.0, 0
+	for i := 0; i < n; i++ {
+		if math.IsNaN(x[i]) {
+			nans++
+		} else {
+			sum += x[i]
+		}
+		if i >= p {
+			if math.IsNaN(x[i-p]) {
+				nans--
+			} else {
+				sum -= x[i-p]
+			}
+		}
+		if i >= p-1 && nans == 0 {
+			out[i] = sum / float64(p)
+		}
+	}
+	return out
+}
+
+// recursive 递归平滑 Y=(weight*X+(1-weight)*Y')，以第一个有效值为初值
+func recursive(n int, x []float64, weight float64) []float64 {
+	out := nanSeries(n)
+	started := false
+	prev := 0.0
+	for i := 0; i < n; i++ {
+		if math.IsNaN(x[i]) {
+			if started {
+				out[i] = prev
+			}
+			continue
+		}
+		if !started {
+			prev = x[i]
+			started = true
+		} else {
+			prev = weight*x[i] + (1-weight)*prev
+		}
+		out[i] = prev
+	}
+	return out
+}
+
+// fnEMA 指数移动平均 Y=(2*X+(N-1)*Y')/(N+1)
+func fnEMA(n int, args [][]float64) []float64 {
+	p := period(args[1])
+	if p <= 0 {
+		return nanSeries(n)
+	}
+	return recursive(n, args[0], 2/float64(p+1))
+}
+
+// fnSMA 通达信SMA Y=(M*X+(N-M)*Y')/N
+func fnSMA(n int, args [][]float64) []float64 {
+	p, m := period(args[1]), period(args[2])
+	if p <= 0 || m <= 0 || m > p {
+		return nanSeries(n)
+	}
+	return recursive(n, args[0], float64(m)/float64(p))
+}
+
+// fnSTD 样本标准差
+func fnSTD(n int, args [][]float64) []float64 {
+	x, p := args[0], period(args[1])
+	out := nanSeries(n)
+	if p <= 1 {
+		return out
+	}
+	for i := p - 1; i < n; i++ {
+		mean := 0.0
+		for j := i + 1 - p; j <= i; j++ {
+			mean += x[j]
+		}
+		mean /= float64(p)
+		variance := 0.0
+		for j := i + 1 - p; j <= i; j++ {
+			variance += (x[j] - mean) * (x[j] - mean)
+		}
+		out[i] = math.Sqrt(variance / float64(p-1))
+	}
+	return out
+}
+
+// fnREF 引用N根K线之前的值
+func fnREF(n int, args [][]float64) []float64 {
+	x, periods := args[0], args[1]
+	out := nanSeries(n)
+	for i := 0; i < n; i++ {
+		p, ok := barPeriod(periods, i)
+		if ok && i-p >= 0 {
+			out[i] = x[i-p]
+		}
+	}
+	return out
+}
+
+// fnEVERY 最近N根K线是否全部满足条件
+func fnEVERY(n int, args [][]float64) []float64 {
+	count := windowed(prefixSum(countTrue))(n, args)
+	out := make([]float64, n)
+	for i, c := range count {
+		p, ok := barPeriod(args[1], i)
+		if !ok || math.IsNaN(c) {
+			continue
+		}
+		if p == 0 {
+			p = i + 1
+		}
+		out[i] = boolValue(int(c) == p)
+	}
+	return out
+}
+
+// fnEXIST 最近N根K线是否存在满足条件的K线
+func fnEXIST(n int, args [][]float64) []float64 {
+	count := windowed(prefixSum(countTrue))(n, args)
+	out := make([]float64, n)
+	for i, c := range count {
+		out[i] = boolValue(c > 0)
+	}
+	return out
+}
+
+// fnBARSLAST 上一次条件成立到当前的K线数，从未成立时为NaN
+func fnBARSLAST(n int, args [][]float64) []float64 {
+	out := nanSeries(n)
+	last := -1
+	for i, v := range args[0] {
+		if truth(v) {
+			last = i
+		}
+		if last >= 0 {
+			out[i] = float64(i - last)
+		}
+	}
+	return out
+}
+
+// fnCROSS A上穿B
+func fnCROSS(n int, args [][]float64) []float64 {
+	a, b := args[0], args[1]
+	out := make([]float64, n)
+	for i := 1; i < n; i++ {
+		out[i] = boolValue(a[i] > b[i] && a[i-1] <= b[i-1])
+	}
+	return out
+}
+
+// fnIF 条件成立取A，否则取B
+func fnIF(n int, args [][]float64) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		if truth(args[0][i]) {
+			out[i] = args[1][i]
+		} else {
+			out[i] = args[2][i]
+		}
+	}
+	return out
+}
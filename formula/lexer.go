@@ -0,0 +1,127 @@
+package formula
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tokenKind 词法单元类型
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOperator // + - * / > < >= <= = <> != && ||
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenColon   // :
+	tokenAssign  // :=
+	tokenSemicol // ;
+)
+
+// token 词法单元
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+	pos   int
+}
+
+// lex 将公式源码切分为词法单元，支持{}与//注释
+func lex(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	i := 0
+
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '{':
+			end := i
+			for end < len(runes) && runes[end] != '}' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("位置%d: 注释未闭合", i)
+			}
+			i = end + 1
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("位置%d: 数字格式错误 %s", start, text)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, value: value, pos: start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			text := strings.ToUpper(string(runes[start:i]))
+			kind := tokenIdent
+			if text == "AND" || text == "OR" {
+				kind = tokenOperator
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: start})
+		default:
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch two {
+			case ":=":
+				tokens = append(tokens, token{kind: tokenAssign, text: two, pos: i})
+				i += 2
+				continue
+			case ">=", "<=", "<>", "!=", "&&", "||":
+				text := two
+				switch two {
+				case "!=":
+					text = "<>"
+				case "&&":
+					text = "AND"
+				case "||":
+					text = "OR"
+				}
+				tokens = append(tokens, token{kind: tokenOperator, text: text, pos: i})
+				i += 2
+				continue
+			}
+
+			switch r {
+			case '+', '-', '*', '/', '>', '<', '=':
+				tokens = append(tokens, token{kind: tokenOperator, text: string(r), pos: i})
+			case '(':
+				tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			case ')':
+				tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			case ',':
+				tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			case ':':
+				tokens = append(tokens, token{kind: tokenColon, text: ":", pos: i})
+			case ';':
+				tokens = append(tokens, token{kind: tokenSemicol, text: ";", pos: i})
+			default:
+				return nil, fmt.Errorf("位置%d: 无法识别的字符 %q", i, r)
+			}
+			i++
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
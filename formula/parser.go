@@ -0,0 +1,221 @@
+package formula
+
+import "fmt"
+
+// node 语法树节点
+type node interface{}
+
+type numberNode struct {
+	value float64
+}
+
+type identNode struct {
+	name string
+	pos  int
+}
+
+type callNode struct {
+	name string
+	args []node
+	pos  int
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+// statement 公式语句，NAME:expr为输出，NAME:=expr为中间变量
+type statement struct {
+	name   string
+	output bool
+	expr   node
+}
+
+// 运算符优先级，数值越大结合越紧
+var precedence = map[string]int{
+	"OR":  1,
+	"AND": 2,
+	"=":   3, "<>": 3, ">": 3, "<": 3, ">=": 3, "<=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5,
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, fmt.Errorf("位置%d: 期望%s，实际为%q", tok.pos, what, tok.text)
+	}
+	return tok, nil
+}
+
+// parse 解析整段公式，未命名的输出依次命名为OUT1、OUT2...
+func parse(src string) ([]statement, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+
+	var stmts []statement
+	unnamed := 0
+	for p.peek().kind != tokenEOF {
+		if p.peek().kind == tokenSemicol {
+			p.next()
+			continue
+		}
+
+		stmt := statement{output: true}
+		if p.peek().kind == tokenIdent && p.pos+1 < len(p.tokens) {
+			switch p.tokens[p.pos+1].kind {
+			case tokenColon:
+				stmt.name = p.next().text
+				p.next()
+			case tokenAssign:
+				stmt.name = p.next().text
+				stmt.output = false
+				p.next()
+			}
+		}
+
+		expr, err := p.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		stmt.expr = expr
+
+		// 忽略绘图属性，例如 ,COLORRED ,LINETHICK2 ,NODRAW
+		for p.peek().kind == tokenComma {
+			p.next()
+			if _, err := p.expect(tokenIdent, "绘图属性"); err != nil {
+				return nil, err
+			}
+		}
+
+		if tok := p.peek(); tok.kind != tokenSemicol && tok.kind != tokenEOF {
+			return nil, fmt.Errorf("位置%d: 期望';'，实际为%q", tok.pos, tok.text)
+		}
+
+		if stmt.name == "" {
+			unnamed++
+			stmt.name = fmt.Sprintf("OUT%d", unnamed)
+		}
+		stmts = append(stmts, stmt)
+	}
+
+	if len(stmts) == 0 {
+		return nil, fmt.Errorf("公式为空")
+	}
+	return stmts, nil
+}
+
+// parseExpr 按优先级爬升解析二元表达式
+func (p *parser) parseExpr(minPrec int) (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		if tok.kind != tokenOperator {
+			return left, nil
+		}
+		prec := precedence[tok.text]
+		if prec <= minPrec {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseExpr(prec)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	tok := p.peek()
+	if tok.kind == tokenOperator && (tok.text == "-" || tok.text == "+") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: tok.text, operand: operand}, nil
+	}
+	if tok.kind == tokenIdent && tok.text == "NOT" && p.tokens[p.pos+1].kind != tokenLParen {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "NOT", operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		return &numberNode{value: tok.value}, nil
+	case tokenLParen:
+		expr, err := p.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case tokenIdent:
+		if p.peek().kind != tokenLParen {
+			return &identNode{name: tok.text, pos: tok.pos}, nil
+		}
+		p.next()
+		call := &callNode{name: tok.text, pos: tok.pos}
+		if p.peek().kind == tokenRParen {
+			p.next()
+			return call, nil
+		}
+		for {
+			arg, err := p.parseExpr(0)
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			sep := p.next()
+			if sep.kind == tokenRParen {
+				return call, nil
+			}
+			if sep.kind != tokenComma {
+				return nil, fmt.Errorf("位置%d: 期望','或')'，实际为%q", sep.pos, sep.text)
+			}
+		}
+	case tokenEOF:
+		return nil, fmt.Errorf("位置%d: 公式意外结束", tok.pos)
+	default:
+		return nil, fmt.Errorf("位置%d: 意外的符号%q", tok.pos, tok.text)
+	}
+}
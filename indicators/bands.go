@@ -0,0 +1,124 @@
+package indicators
+
+import (
+	"math"
+	"stock/common/types"
+)
+
+// BollingerIndicator 布林带（BOLL），输出Mid/Upper/Lower
+type BollingerIndicator struct {
+	std *rollingStd
+	k   float64
+}
+
+// NewBollinger 创建布林带指标，period为均线周期，k为标准差倍数
+func NewBollinger(period int, k float64) *BollingerIndicator {
+	return &BollingerIndicator{std: newRollingStd(period), k: k}
+}
+
+// NewBOLL 通达信BOLL指标，与布林带相同
+func NewBOLL(period int, k float64) *BollingerIndicator {
+	return NewBollinger(period, k)
+}
+
+func (b *BollingerIndicator) Update(bar types.Bar) { b.std.update(bar.Close) }
+func (b *BollingerIndicator) Value() float64       { return b.std.mean.value() }
+func (b *BollingerIndicator) Ready() bool          { return b.std.ready() }
+func (b *BollingerIndicator) Outputs() []string    { return []string{"Mid", "Upper", "Lower"} }
+
+func (b *BollingerIndicator) Output(name string) float64 {
+	mid := b.std.mean.value()
+	switch name {
+	case "Mid":
+		return mid
+	case "Upper":
+		return mid + b.k*b.std.value()
+	case "Lower":
+		return mid - b.k*b.std.value()
+	default:
+		return math.NaN()
+	}
+}
+
+// Bollinger 计算布林带
+func Bollinger(bars []types.Bar, period int, k float64) map[string][]float64 {
+	return BatchOutputs(NewBollinger(period, k), bars)
+}
+
+// DonchianIndicator 唐奇安通道，输出Upper/Lower/Mid
+type DonchianIndicator struct {
+	high *rollingExtreme
+	low  *rollingExtreme
+}
+
+func NewDonchian(period int) *DonchianIndicator {
+	return &DonchianIndicator{high: newRollingMax(period), low: newRollingMin(period)}
+}
+
+func (d *DonchianIndicator) Update(bar types.Bar) {
+	d.high.update(bar.High)
+	d.low.update(bar.Low)
+}
+
+func (d *DonchianIndicator) Value() float64    { return d.Output("Mid") }
+func (d *DonchianIndicator) Ready() bool       { return d.high.ready() }
+func (d *DonchianIndicator) Outputs() []string { return []string{"Upper", "Lower", "Mid"} }
+
+func (d *DonchianIndicator) Output(name string) float64 {
+	switch name {
+	case "Upper":
+		return d.high.value()
+	case "Lower":
+		return d.low.value()
+	case "Mid":
+		return (d.high.value() + d.low.value()) / 2
+	default:
+		return math.NaN()
+	}
+}
+
+// Donchian 计算唐奇安通道
+func Donchian(bars []types.Bar, period int) map[string][]float64 {
+	return BatchOutputs(NewDonchian(period), bars)
+}
+
+// KeltnerIndicator 肯特纳通道，中轨为收盘价EMA，上下轨为中轨加减ATR倍数
+type KeltnerIndicator struct {
+	ema  *expMean
+	atr  *ATRIndicator
+	mult float64
+}
+
+func NewKeltner(emaPeriod, atrPeriod int, mult float64) *KeltnerIndicator {
+	return &KeltnerIndicator{ema: newExpMean(emaPeriod), atr: NewATR(atrPeriod), mult: mult}
+}
+
+func (k *KeltnerIndicator) Update(bar types.Bar) {
+	k.ema.update(bar.Close)
+	k.atr.Update(bar)
+}
+
+func (k *KeltnerIndicator) Value() float64    { return k.ema.value() }
+func (k *KeltnerIndicator) Ready() bool       { return k.ema.ready() && k.atr.Ready() }
+func (k *KeltnerIndicator) Outputs() []string { return []string{"Mid", "Upper", "Lower"} }
+
+func (k *KeltnerIndicator) Output(name string) float64 {
+	if !k.Ready() {
+		return math.NaN()
+	}
+	switch name {
+	case "Mid":
+		return k.ema.value()
+	case "Upper":
+		return k.ema.value() + k.mult*k.atr.Value()
+	case "Lower":
+		return k.ema.value() - k.mult*k.atr.Value()
+	default:
+		return math.NaN()
+	}
+}
+
+// Keltner 计算肯特纳通道
+func Keltner(bars []types.Bar, emaPeriod, atrPeriod int, mult float64) map[string][]float64 {
+	return BatchOutputs(NewKeltner(emaPeriod, atrPeriod, mult), bars)
+}
@@ -0,0 +1,295 @@
+package indicators
+
+import (
+	"math"
+	"stock/common/types"
+)
+
+// Indicator 流式指标接口，每根K线调用一次Update，单次更新为O(1)
+// 例外：CCI的平均绝对偏差无法增量维护，Value需遍历窗口，为O(period)
+// 预热期内Ready返回false，Value返回NaN
+type Indicator interface {
+	Update(bar types.Bar)
+	Value() float64
+	Ready() bool
+}
+
+// Batch 使用流式指标计算整段序列，结果与输入等长，预热期为NaN
+func Batch(ind Indicator, bars []types.Bar) []float64 {
+	values := make([]float64, len(bars))
+	for i, bar := range bars {
+		ind.Update(bar)
+		values[i] = ind.Value()
+	}
+	return values
+}
+
+// MultiIndicator 多输出指标，Value返回主输出
+type MultiIndicator interface {
+	Indicator
+	Outputs() []string
+	Output(name string) float64
+}
+
+// BatchOutputs 使用多输出指标计算整段序列，返回各输出名对应的序列
+func BatchOutputs(ind MultiIndicator, bars []types.Bar) map[string][]float64 {
+	result := make(map[string][]float64)
+	for _, name := range ind.Outputs() {
+		result[name] = make([]float64, len(bars))
+	}
+	for i, bar := range bars {
+		ind.Update(bar)
+		for name, values := range result {
+			values[i] = ind.Output(name)
+		}
+	}
+	return result
+}
+
+// rollingWindow 固定长度的滑动窗口
+type rollingWindow struct {
+	values []float64
+	next   int
+	count  int
+}
+
+func newRollingWindow(size int) *rollingWindow {
+	if size < 1 {
+		size = 1
+	}
+	return &rollingWindow{values: make([]float64, size)}
+}
+
+// push 加入新值，窗口已满时返回被移出的值
+func (w *rollingWindow) push(v float64) (float64, bool) {
+	old := w.values[w.next]
+	full := w.count == len(w.values)
+	w.values[w.next] = v
+	w.next = (w.next + 1) % len(w.values)
+	if !full {
+		w.count++
+	}
+	return old, full
+}
+
+func (w *rollingWindow) full() bool {
+	return w.count == len(w.values)
+}
+
+// at 返回窗口内第i个值，0为最早的值
+func (w *rollingWindow) at(i int) float64 {
+	start := 0
+	if w.full() {
+		start = w.next
+	}
+	return w.values[(start+i)%len(w.values)]
+}
+
+// rollingMean 滑动平均
+type rollingMean struct {
+	window *rollingWindow
+	sum    float64
+}
+
+func newRollingMean(period int) *rollingMean {
+	return &rollingMean{window: newRollingWindow(period)}
+}
+
+func (m *rollingMean) update(v float64) {
+	if old, full := m.window.push(v); full {
+		m.sum -= old
+	}
+	m.sum += v
+}
+
+func (m *rollingMean) ready() bool {
+	return m.window.full()
+}
+
+func (m *rollingMean) value() float64 {
+	if !m.ready() {
+		return math.NaN()
+	}
+	return m.sum / float64(m.window.count)
+}
+
+// rollingStd 滑动总体标准差
+type rollingStd struct {
+	mean  *rollingMean
+	sumSq float64
+}
+
+func newRollingStd(period int) *rollingStd {
+	return &rollingStd{mean: newRollingMean(period)}
+}
+
+func (s *rollingStd) update(v float64) {
+	if old, full := s.mean.window.push(v); full {
+		s.mean.sum -= old
+		s.sumSq -= old * old
+	}
+	s.mean.sum += v
+	s.sumSq += v * v
+}
+
+func (s *rollingStd) ready() bool {
+	return s.mean.ready()
+}
+
+func (s *rollingStd) value() float64 {
+	if !s.ready() {
+		return math.NaN()
+	}
+	mean := s.mean.value()
+	variance := s.sumSq/float64(s.mean.window.count) - mean*mean
+	return math.Sqrt(math.Max(variance, 0))
+}
+
+// rollingSum 滑动求和
+type rollingSum struct {
+	window *rollingWindow
+	sum    float64
+}
+
+func newRollingSum(period int) *rollingSum {
+	return &rollingSum{window: newRollingWindow(period)}
+}
+
+func (s *rollingSum) update(v float64) {
+	if old, full := s.window.push(v); full {
+		s.sum -= old
+	}
+	s.sum += v
+}
+
+func (s *rollingSum) ready() bool {
+	return s.window.full()
+}
+
+// rollingExtreme 单调队列维护的滑动最大/最小值，均摊O(1)
+type rollingExtreme struct {
+	period int
+	max    bool
+	index  int
+	deque  []extremeItem
+}
+
+type extremeItem struct {
+	index int
+	value float64
+}
+
+func newRollingMax(period int) *rollingExtreme {
+	if period < 1 {
+		period = 1
+	}
+	return &rollingExtreme{period: period, max: true}
+}
+
+func newRollingMin(period int) *rollingExtreme {
+	if period < 1 {
+		period = 1
+	}
+	return &rollingExtreme{period: period}
+}
+
+func (e *rollingExtreme) update(v float64) {
+	for len(e.deque) > 0 {
+		last := e.deque[len(e.deque)-1].value
+		if (e.max && last > v) || (!e.max && last < v) {
+			break
+		}
+		e.deque = e.deque[:len(e.deque)-1]
+	}
+	e.deque = append(e.deque, extremeItem{index: e.index, value: v})
+	if e.deque[0].index <= e.index-e.period {
+		e.deque = e.deque[1:]
+	}
+	e.index++
+}
+
+func (e *rollingExtreme) ready() bool {
+	return e.index >= e.period
+}
+
+func (e *rollingExtreme) value() float64 {
+	if !e.ready() {
+		return math.NaN()
+	}
+	return e.deque[0].value
+}
+
+// expMean 指数平均，前period个值的简单平均作为初值
+type expMean struct {
+	period int
+	alpha  float64
+	count  int
+	sum    float64
+	ema    float64
+}
+
+func newExpMean(period int) *expMean {
+	return newExpMeanAlpha(period, 2.0/float64(period+1))
+}
+
+// newWilderMean Wilder平滑，alpha为1/period
+func newWilderMean(period int) *expMean {
+	return newExpMeanAlpha(period, 1.0/float64(period))
+}
+
+func newExpMeanAlpha(period int, alpha float64) *expMean {
+	if period < 1 {
+		period = 1
+	}
+	return &expMean{period: period, alpha: alpha}
+}
+
+func (e *expMean) update(v float64) {
+	e.count++
+	if e.count < e.period {
+		e.sum += v
+		return
+	}
+	if e.count == e.period {
+		e.ema = (e.sum + v) / float64(e.period)
+		return
+	}
+	e.ema = v*e.alpha + e.ema*(1-e.alpha)
+}
+
+func (e *expMean) ready() bool {
+	return e.count >= e.period
+}
+
+func (e *expMean) value() float64 {
+	if !e.ready() {
+		return math.NaN()
+	}
+	return e.ema
+}
+
+// SMAIndicator 简单移动平均
+type SMAIndicator struct {
+	mean *rollingMean
+}
+
+func NewSMA(period int) *SMAIndicator {
+	return &SMAIndicator{mean: newRollingMean(period)}
+}
+
+func (s *SMAIndicator) Update(bar types.Bar) { s.mean.update(bar.Close) }
+func (s *SMAIndicator) Value() float64       { return s.mean.value() }
+func (s *SMAIndicator) Ready() bool          { return s.mean.ready() }
+
+// EMAIndicator 指数移动平均
+type EMAIndicator struct {
+	mean *expMean
+}
+
+func NewEMA(period int) *EMAIndicator {
+	return &EMAIndicator{mean: newExpMean(period)}
+}
+
+func (e *EMAIndicator) Update(bar types.Bar) { e.mean.update(bar.Close) }
+func (e *EMAIndicator) Value() float64       { return e.mean.value() }
+func (e *EMAIndicator) Ready() bool          { return e.mean.ready() }
@@ -0,0 +1,244 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+
+	"stock/common/types"
+)
+
+// testBars 生成确定性的测试K线：带趋势和周期波动，包含涨跌
+func testBars(n int) []types.Bar {
+	bars := make([]types.Bar, n)
+	price := 10.0
+	for i := range bars {
+		change := 0.3*math.Sin(float64(i)/3) + 0.05*math.Cos(float64(i)*1.7)
+		open := price
+		price += change
+		bars[i] = types.Bar{
+			Time:   int64(i) * 86400,
+			Open:   open,
+			High:   math.Max(open, price) + 0.1,
+			Low:    math.Min(open, price) - 0.1,
+			Close:  price,
+			Volume: 1000 + float64(i%7)*100,
+		}
+	}
+	return bars
+}
+
+func sameFloat(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(b))
+}
+
+// leadingNaN 序列开头连续NaN的个数
+func leadingNaN(values []float64) int {
+	for i, v := range values {
+		if !math.IsNaN(v) {
+			return i
+		}
+	}
+	return len(values)
+}
+
+func TestBatchMatchesStreaming(t *testing.T) {
+	bars := testBars(120)
+	tests := []struct {
+		name   string
+		new    func() Indicator
+		warmup int // 预热期NaN个数
+	}{
+		{"SMA(1)", func() Indicator { return NewSMA(1) }, 0},
+		{"SMA(5)", func() Indicator { return NewSMA(5) }, 4},
+		{"SMA(20)", func() Indicator { return NewSMA(20) }, 19},
+		{"EMA(1)", func() Indicator { return NewEMA(1) }, 0},
+		{"EMA(12)", func() Indicator { return NewEMA(12) }, 11},
+		{"EMA(26)", func() Indicator { return NewEMA(26) }, 25},
+		{"MACD(12,26,9)", func() Indicator { return NewMACD(12, 26, 9) }, 25},
+		{"MACD(5,10,3)", func() Indicator { return NewMACD(5, 10, 3) }, 9},
+		{"RSI(6)", func() Indicator { return NewRSI(6) }, 6},
+		{"RSI(14)", func() Indicator { return NewRSI(14) }, 14},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := Batch(tt.new(), bars)
+			if len(batch) != len(bars) {
+				t.Fatalf("批量结果长度%d，期望%d", len(batch), len(bars))
+			}
+			if got := leadingNaN(batch); got != tt.warmup {
+				t.Fatalf("预热期NaN个数%d，期望%d", got, tt.warmup)
+			}
+			stream := tt.new()
+			for i, bar := range bars {
+				stream.Update(bar)
+				value := stream.Value()
+				if !sameFloat(value, batch[i]) {
+					t.Fatalf("第%d根K线流式结果%v与批量结果%v不一致", i, value, batch[i])
+				}
+				if ready := i >= tt.warmup; stream.Ready() != ready {
+					t.Fatalf("第%d根K线Ready()=%v，期望%v", i, stream.Ready(), ready)
+				}
+				if stream.Ready() == math.IsNaN(value) {
+					t.Fatalf("第%d根K线Ready()=%v与Value()=%v不一致", i, stream.Ready(), value)
+				}
+			}
+		})
+	}
+}
+
+func TestSMAReference(t *testing.T) {
+	bars := testBars(60)
+	for _, period := range []int{1, 3, 10} {
+		values := SMA(bars, period)
+		for i := period - 1; i < len(bars); i++ {
+			sum := 0.0
+			for _, bar := range bars[i-period+1 : i+1] {
+				sum += bar.Close
+			}
+			if want := sum / float64(period); !sameFloat(values[i], want) {
+				t.Fatalf("SMA(%d)第%d根K线为%v，期望%v", period, i, values[i], want)
+			}
+		}
+	}
+}
+
+func TestEMAReference(t *testing.T) {
+	bars := testBars(60)
+	period := 10
+	values := EMA(bars, period)
+	// 初值为前period个收盘价的简单平均
+	ema := 0.0
+	for _, bar := range bars[:period] {
+		ema += bar.Close
+	}
+	ema /= float64(period)
+	alpha := 2.0 / float64(period+1)
+	for i := period - 1; i < len(bars); i++ {
+		if i >= period {
+			ema = alpha*bars[i].Close + (1-alpha)*ema
+		}
+		if !sameFloat(values[i], ema) {
+			t.Fatalf("EMA(%d)第%d根K线为%v，期望%v", period, i, values[i], ema)
+		}
+	}
+}
+
+func TestMACDOutputs(t *testing.T) {
+	bars := testBars(100)
+	fast, slow, signal := 12, 26, 9
+	values, err := MACD(bars, fast, slow, signal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := BatchOutputs(NewMACD(fast, slow, signal), bars)
+	fastEMA, slowEMA := EMA(bars, fast), EMA(bars, slow)
+	macdLine := make([]float64, len(bars))
+	for i := range bars {
+		macdLine[i] = fastEMA[i] - slowEMA[i]
+	}
+	signalLine := EMAForMACD(macdLine[slow-1:], signal)
+
+	signalWarmup := slow + signal - 2
+	if got := leadingNaN(outputs["Signal"]); got != signalWarmup {
+		t.Fatalf("信号线预热期NaN个数%d，期望%d", got, signalWarmup)
+	}
+	if got := leadingNaN(outputs["Histogram"]); got != signalWarmup {
+		t.Fatalf("柱状线预热期NaN个数%d，期望%d", got, signalWarmup)
+	}
+	for i := range bars {
+		if !sameFloat(values[i].MACD, macdLine[i]) || !sameFloat(outputs["MACD"][i], macdLine[i]) {
+			t.Fatalf("第%d根K线MACD为%v/%v，期望%v", i, values[i].MACD, outputs["MACD"][i], macdLine[i])
+		}
+		wantSignal := math.NaN()
+		if i >= slow-1 {
+			wantSignal = signalLine[i-slow+1]
+		}
+		if !sameFloat(values[i].Signal, wantSignal) || !sameFloat(outputs["Signal"][i], wantSignal) {
+			t.Fatalf("第%d根K线信号线为%v/%v，期望%v", i, values[i].Signal, outputs["Signal"][i], wantSignal)
+		}
+		if !sameFloat(values[i].Histogram, macdLine[i]-wantSignal) {
+			t.Fatalf("第%d根K线柱状线为%v，期望%v", i, values[i].Histogram, macdLine[i]-wantSignal)
+		}
+	}
+
+	macd := NewMACD(fast, slow, signal)
+	for i, bar := range bars {
+		macd.Update(bar)
+		if macd.SignalReady() != (i >= signalWarmup) {
+			t.Fatalf("第%d根K线SignalReady()=%v", i, macd.SignalReady())
+		}
+	}
+}
+
+func TestRSIReference(t *testing.T) {
+	bars := testBars(80)
+	period := 14
+	values, err := RSI(bars, period)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wilder平滑：前period个涨跌幅的简单平均为初值
+	var avgGain, avgLoss float64
+	for i := 1; i < len(bars); i++ {
+		change := bars[i].Close - bars[i-1].Close
+		gain, loss := math.Max(change, 0), math.Max(-change, 0)
+		switch {
+		case i < period:
+			avgGain += gain
+			avgLoss += loss
+			continue
+		case i == period:
+			avgGain = (avgGain + gain) / float64(period)
+			avgLoss = (avgLoss + loss) / float64(period)
+		default:
+			avgGain = (avgGain*float64(period-1) + gain) / float64(period)
+			avgLoss = (avgLoss*float64(period-1) + loss) / float64(period)
+		}
+		want := 100.0
+		if avgLoss != 0 {
+			want = 100 - 100/(1+avgGain/avgLoss)
+		}
+		if !sameFloat(values[i], want) {
+			t.Fatalf("RSI(%d)第%d根K线为%v，期望%v", period, i, values[i], want)
+		}
+	}
+}
+
+func TestRSIFlat(t *testing.T) {
+	bars := make([]types.Bar, 10)
+	for i := range bars {
+		bars[i] = types.Bar{Close: 10}
+	}
+	// 先上涨后持平，平均涨幅衰减但不为0，RSI仍为100
+	bars = append(bars, types.Bar{Close: 11}, types.Bar{Close: 11})
+	values := Batch(NewRSI(5), bars)
+	for i, v := range values[5:10] {
+		if v != 50 {
+			t.Fatalf("持平时第%d根K线RSI为%v，期望50", i+5, v)
+		}
+	}
+	for _, v := range values[10:] {
+		if v != 100 {
+			t.Fatalf("上涨后持平RSI为%v，期望100", v)
+		}
+	}
+}
+
+func TestRSIAllGains(t *testing.T) {
+	bars := make([]types.Bar, 10)
+	for i := range bars {
+		bars[i] = types.Bar{Close: float64(10 + i)}
+	}
+	values := Batch(NewRSI(5), bars)
+	if got := leadingNaN(values); got != 5 {
+		t.Fatalf("预热期NaN个数%d，期望5", got)
+	}
+	for _, v := range values[5:] {
+		if v != 100 {
+			t.Fatalf("单边上涨RSI为%v，期望100", v)
+		}
+	}
+}
@@ -13,54 +13,98 @@ type MACDValue struct {
 	Histogram float64
 }
 
-// MACD calculates Moving Average Convergence Divergence
-func MACD(bars []types.Bar, fastPeriod, slowPeriod, signalPeriod int) ([]types.MACDValue, error) {
-	if len(bars) < slowPeriod {
-		return nil, errors.New("not enough data points")
-	}
+// MACDIndicator streaming MACD, Value returns the MACD line
+type MACDIndicator struct {
+	fast   *expMean
+	slow   *expMean
+	signal *expMean
+}
 
-	// Calculate fast and slow EMAs
-	fastEMA := EMA(bars, fastPeriod)
-	slowEMA := EMA(bars, slowPeriod)
+// NewMACD creates a streaming MACD indicator
+func NewMACD(fastPeriod, slowPeriod, signalPeriod int) *MACDIndicator {
+	return &MACDIndicator{
+		fast:   newExpMean(fastPeriod),
+		slow:   newExpMean(slowPeriod),
+		signal: newExpMean(signalPeriod),
+	}
+}
 
-	if fastEMA == nil || slowEMA == nil {
-		return nil, errors.New("failed to calculate EMA")
+func (m *MACDIndicator) Update(bar types.Bar) {
+	m.fast.update(bar.Close)
+	m.slow.update(bar.Close)
+	if m.fast.ready() && m.slow.ready() {
+		m.signal.update(m.fast.value() - m.slow.value())
 	}
+}
 
-	// Calculate MACD line
-	macdLine := make([]float64, len(bars))
-	for i := slowPeriod - 1; i < len(bars); i++ {
-		macdLine[i] = fastEMA[i] - slowEMA[i]
+// Value returns the MACD line, NaN until the slow EMA is ready
+func (m *MACDIndicator) Value() float64 {
+	if !m.slow.ready() || !m.fast.ready() {
+		return math.NaN()
 	}
+	return m.fast.value() - m.slow.value()
+}
+
+// Signal returns the signal line
+func (m *MACDIndicator) Signal() float64 {
+	return m.signal.value()
+}
+
+// Histogram returns MACD minus signal
+func (m *MACDIndicator) Histogram() float64 {
+	return m.Value() - m.Signal()
+}
 
-	// Calculate Signal line (EMA of MACD line)
-	signalLine := EMAForMACD(macdLine[slowPeriod-1:], signalPeriod)
-	if signalLine == nil {
-		return nil, errors.New("failed to calculate signal line")
+// Ready reports whether the MACD line (Value) is available, consistent with Value
+func (m *MACDIndicator) Ready() bool {
+	return m.fast.ready() && m.slow.ready()
+}
+
+// SignalReady reports whether the signal line and histogram are available
+func (m *MACDIndicator) SignalReady() bool {
+	return m.signal.ready()
+}
+
+// Outputs returns the output names
+func (m *MACDIndicator) Outputs() []string {
+	return []string{"MACD", "Signal", "Histogram"}
+}
+
+// Output returns an output value by name
+func (m *MACDIndicator) Output(name string) float64 {
+	switch name {
+	case "MACD":
+		return m.Value()
+	case "Signal":
+		return m.Signal()
+	case "Histogram":
+		return m.Histogram()
+	default:
+		return math.NaN()
 	}
+}
 
-	// Pad signalLine with NaN values to match original length
-	fullSignalLine := make([]float64, len(bars))
-	for i := 0; i < slowPeriod-1; i++ {
-		fullSignalLine[i] = math.NaN()
+// MACDValue returns all three MACD outputs
+func (m *MACDIndicator) MACDValue() types.MACDValue {
+	return types.MACDValue{
+		MACD:      m.Value(),
+		Signal:    m.Signal(),
+		Histogram: m.Histogram(),
 	}
-	copy(fullSignalLine[slowPeriod-1:], signalLine)
-	signalLine = fullSignalLine
+}
 
-	// Calculate MACD histogram
-	histogram := make([]float64, len(bars))
-	for i := range histogram {
-		histogram[i] = macdLine[i] - signalLine[i]
+// MACD calculates Moving Average Convergence Divergence
+// Values are NaN until the corresponding line has warmed up
+func MACD(bars []types.Bar, fastPeriod, slowPeriod, signalPeriod int) ([]types.MACDValue, error) {
+	if len(bars) < slowPeriod {
+		return nil, errors.New("not enough data points")
 	}
 
-	// Prepare result
+	macd := NewMACD(fastPeriod, slowPeriod, signalPeriod)
 	result := make([]types.MACDValue, len(bars))
-	for i := range result {
-		result[i] = types.MACDValue{
-			MACD:      macdLine[i],
-			Signal:    signalLine[i],
-			Histogram: histogram[i],
-		}
+	for i, bar := range bars {
+		macd.Update(bar)
+		result[i] = macd.MACDValue()
 	}
 
 	return result, nil
@@ -86,23 +130,7 @@ func EMA(bars []types.Bar, period int) []float64 {
 	if len(bars) < period {
 		return nil
 	}
-
-	ema := make([]float64, len(bars))
-	k := 2.0 / float64(period+1)
-
-	// Calculate first EMA as SMA
-	var sum float64
-	for i := 0; i < period; i++ {
-		sum += bars[i].Close
-	}
-	ema[period-1] = sum / float64(period)
-
-	// Calculate remaining EMAs
-	for i := period; i < len(bars); i++ {
-		ema[i] = bars[i].Close*k + ema[i-1]*(1-k)
-	}
-
-	return ema
+	return Batch(NewEMA(period), bars)
 }
 
 // SMA calculates Simple Moving Average
@@ -110,18 +138,7 @@ func SMA(bars []types.Bar, period int) []float64 {
 	if len(bars) < period {
 		return nil
 	}
-
-	sma := make([]float64, len(bars))
-
-	for i := period - 1; i < len(bars); i++ {
-		var sum float64
-		for j := 0; j < period; j++ {
-			sum += bars[i-j].Close
-		}
-		sma[i] = sum / float64(period)
-	}
-
-	return sma
+	return Batch(NewSMA(period), bars)
 }
 
 // EMAForMACD calculates EMA for MACD signal line
@@ -130,20 +147,11 @@ func EMAForMACD(values []float64, period int) []float64 {
 		return nil
 	}
 
+	mean := newExpMean(period)
 	ema := make([]float64, len(values))
-	k := 2.0 / float64(period+1)
-
-	// Calculate first EMA as SMA
-	var sum float64
-	for i := 0; i < period; i++ {
-		sum += values[i]
+	for i, v := range values {
+		mean.update(v)
+		ema[i] = mean.value()
 	}
-	ema[period-1] = sum / float64(period)
-
-	// Calculate remaining EMAs
-	for i := period; i < len(values); i++ {
-		ema[i] = values[i]*k + ema[i-1]*(1-k)
-	}
-
 	return ema
 }
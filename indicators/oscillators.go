@@ -0,0 +1,295 @@
+package indicators
+
+import (
+	"math"
+	"stock/common/types"
+)
+
+// KDJIndicator 随机指标，输出K/D/J，K和D初值为50
+type KDJIndicator struct {
+	high  *rollingExtreme
+	low   *rollingExtreme
+	m1    float64
+	m2    float64
+	k     float64
+	d     float64
+	ready bool
+}
+
+// NewKDJ 创建KDJ指标，常用参数为9、3、3
+func NewKDJ(n, m1, m2 int) *KDJIndicator {
+	return &KDJIndicator{
+		high: newRollingMax(n),
+		low:  newRollingMin(n),
+		m1:   float64(m1),
+		m2:   float64(m2),
+		k:    50,
+		d:    50,
+	}
+}
+
+func (k *KDJIndicator) Update(bar types.Bar) {
+	k.high.update(bar.High)
+	k.low.update(bar.Low)
+	if !k.high.ready() {
+		return
+	}
+
+	rsv := 50.0
+	if spread := k.high.value() - k.low.value(); spread > 0 {
+		rsv = (bar.Close - k.low.value()) / spread * 100
+	}
+	// 通达信SMA(X,N,1)平滑
+	k.k = (rsv + (k.m1-1)*k.k) / k.m1
+	k.d = (k.k + (k.m2-1)*k.d) / k.m2
+	k.ready = true
+}
+
+func (k *KDJIndicator) Value() float64    { return k.Output("K") }
+func (k *KDJIndicator) Ready() bool       { return k.ready }
+func (k *KDJIndicator) Outputs() []string { return []string{"K", "D", "J"} }
+
+func (k *KDJIndicator) Output(name string) float64 {
+	if !k.ready {
+		return math.NaN()
+	}
+	switch name {
+	case "K":
+		return k.k
+	case "D":
+		return k.d
+	case "J":
+		return 3*k.k - 2*k.d
+	default:
+		return math.NaN()
+	}
+}
+
+// KDJ 计算随机指标
+func KDJ(bars []types.Bar, n, m1, m2 int) map[string][]float64 {
+	return BatchOutputs(NewKDJ(n, m1, m2), bars)
+}
+
+// WilliamsRIndicator 威廉指标，取值-100到0
+type WilliamsRIndicator struct {
+	high  *rollingExtreme
+	low   *rollingExtreme
+	close float64
+}
+
+func NewWilliamsR(period int) *WilliamsRIndicator {
+	return &WilliamsRIndicator{high: newRollingMax(period), low: newRollingMin(period)}
+}
+
+func (w *WilliamsRIndicator) Update(bar types.Bar) {
+	w.high.update(bar.High)
+	w.low.update(bar.Low)
+	w.close = bar.Close
+}
+
+func (w *WilliamsRIndicator) Value() float64 {
+	if !w.Ready() {
+		return math.NaN()
+	}
+	spread := w.high.value() - w.low.value()
+	if spread == 0 {
+		return -50
+	}
+	return -100 * (w.high.value() - w.close) / spread
+}
+
+func (w *WilliamsRIndicator) Ready() bool { return w.high.ready() }
+
+// WilliamsR 计算威廉指标
+func WilliamsR(bars []types.Bar, period int) []float64 {
+	return Batch(NewWilliamsR(period), bars)
+}
+
+// CCIIndicator 顺势指标
+//
+// 平均绝对偏差依赖当前均值，窗口内每个值的偏差都会随均值变化，无法像方差那样增量维护，
+// 因此Value遍历窗口计算，为O(period)，是流式指标O(1)约定的唯一例外
+type CCIIndicator struct {
+	window *rollingWindow
+	mean   *rollingMean
+	tp     float64
+}
+
+func NewCCI(period int) *CCIIndicator {
+	return &CCIIndicator{window: newRollingWindow(period), mean: newRollingMean(period)}
+}
+
+func (c *CCIIndicator) Update(bar types.Bar) {
+	c.tp = (bar.High + bar.Low + bar.Close) / 3
+	c.window.push(c.tp)
+	c.mean.update(c.tp)
+}
+
+func (c *CCIIndicator) Value() float64 {
+	if !c.Ready() {
+		return math.NaN()
+	}
+	mean := c.mean.value()
+	deviation := 0.0
+	for i := 0; i < c.window.count; i++ {
+		deviation += math.Abs(c.window.at(i) - mean)
+	}
+	deviation /= float64(c.window.count)
+	if deviation == 0 {
+		return 0
+	}
+	return (c.tp - mean) / (0.015 * deviation)
+}
+
+func (c *CCIIndicator) Ready() bool { return c.mean.ready() }
+
+// CCI 计算顺势指标
+func CCI(bars []types.Bar, period int) []float64 {
+	return Batch(NewCCI(period), bars)
+}
+
+// StochRSIIndicator 随机相对强弱指数，输出K/D，取值0到100
+type StochRSIIndicator struct {
+	rsi  *RSIIndicator
+	high *rollingExtreme
+	low  *rollingExtreme
+	k    *rollingMean
+	d    *rollingMean
+}
+
+// NewStochRSI 创建随机RSI，常用参数为14、14、3、3
+func NewStochRSI(rsiPeriod, stochPeriod, kPeriod, dPeriod int) *StochRSIIndicator {
+	return &StochRSIIndicator{
+		rsi:  NewRSI(rsiPeriod),
+		high: newRollingMax(stochPeriod),
+		low:  newRollingMin(stochPeriod),
+		k:    newRollingMean(kPeriod),
+		d:    newRollingMean(dPeriod),
+	}
+}
+
+func (s *StochRSIIndicator) Update(bar types.Bar) {
+	s.rsi.Update(bar)
+	if !s.rsi.Ready() {
+		return
+	}
+	rsi := s.rsi.Value()
+	s.high.update(rsi)
+	s.low.update(rsi)
+	if !s.high.ready() {
+		return
+	}
+
+	stoch := 0.0
+	if spread := s.high.value() - s.low.value(); spread > 0 {
+		stoch = (rsi - s.low.value()) / spread * 100
+	}
+	s.k.update(stoch)
+	if s.k.ready() {
+		s.d.update(s.k.value())
+	}
+}
+
+func (s *StochRSIIndicator) Value() float64    { return s.k.value() }
+func (s *StochRSIIndicator) Ready() bool       { return s.d.ready() }
+func (s *StochRSIIndicator) Outputs() []string { return []string{"K", "D"} }
+
+func (s *StochRSIIndicator) Output(name string) float64 {
+	switch name {
+	case "K":
+		return s.k.value()
+	case "D":
+		return s.d.value()
+	default:
+		return math.NaN()
+	}
+}
+
+// StochRSI 计算随机相对强弱指数
+func StochRSI(bars []types.Bar, rsiPeriod, stochPeriod, kPeriod, dPeriod int) map[string][]float64 {
+	return BatchOutputs(NewStochRSI(rsiPeriod, stochPeriod, kPeriod, dPeriod), bars)
+}
+
+// BIASIndicator 乖离率，收盘价偏离均线的百分比
+type BIASIndicator struct {
+	mean  *rollingMean
+	close float64
+}
+
+func NewBIAS(period int) *BIASIndicator {
+	return &BIASIndicator{mean: newRollingMean(period)}
+}
+
+func (b *BIASIndicator) Update(bar types.Bar) {
+	b.mean.update(bar.Close)
+	b.close = bar.Close
+}
+
+func (b *BIASIndicator) Value() float64 {
+	ma := b.mean.value()
+	return (b.close - ma) / ma * 100
+}
+
+func (b *BIASIndicator) Ready() bool { return b.mean.ready() }
+
+// BIAS 计算乖离率
+func BIAS(bars []types.Bar, period int) []float64 {
+	return Batch(NewBIAS(period), bars)
+}
+
+// PSYIndicator 心理线，输出PSY/PSYMA
+type PSYIndicator struct {
+	ups       *rollingSum
+	ma        *rollingMean
+	prevClose float64
+	started   bool
+}
+
+// NewPSY 创建心理线，常用参数为12、6
+func NewPSY(period, maPeriod int) *PSYIndicator {
+	return &PSYIndicator{ups: newRollingSum(period), ma: newRollingMean(maPeriod)}
+}
+
+func (p *PSYIndicator) Update(bar types.Bar) {
+	if !p.started {
+		p.prevClose = bar.Close
+		p.started = true
+		return
+	}
+	up := 0.0
+	if bar.Close > p.prevClose {
+		up = 1
+	}
+	p.prevClose = bar.Close
+	p.ups.update(up)
+	if p.ups.ready() {
+		p.ma.update(p.psy())
+	}
+}
+
+func (p *PSYIndicator) psy() float64 {
+	if !p.ups.ready() {
+		return math.NaN()
+	}
+	return p.ups.sum / float64(p.ups.window.count) * 100
+}
+
+func (p *PSYIndicator) Value() float64    { return p.psy() }
+func (p *PSYIndicator) Ready() bool       { return p.ups.ready() }
+func (p *PSYIndicator) Outputs() []string { return []string{"PSY", "PSYMA"} }
+
+func (p *PSYIndicator) Output(name string) float64 {
+	switch name {
+	case "PSY":
+		return p.psy()
+	case "PSYMA":
+		return p.ma.value()
+	default:
+		return math.NaN()
+	}
+}
+
+// PSY 计算心理线
+func PSY(bars []types.Bar, period, maPeriod int) map[string][]float64 {
+	return BatchOutputs(NewPSY(period, maPeriod), bars)
+}
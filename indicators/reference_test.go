@@ -0,0 +1,126 @@
+package indicators
+
+import (
+	"testing"
+
+	"stock/common/types"
+)
+
+// referenceBars 60根日K线夹具，参考值按通达信/TA-Lib公式逐窗口直接计算得到：
+// BOLL为总体标准差（同TA-Lib BBANDS），KDJ、ATR、DMI为通达信SMA/Wilder平滑，
+// 平滑类指标以前period个值的简单平均为初值，预热期与本包约定一致
+var referenceBars = []types.Bar{
+	{Open: 11.95, High: 12.11, Low: 11.61, Close: 11.63, Volume: 157100},
+	{Open: 11.59, High: 11.72, Low: 11.17, Close: 11.18, Volume: 435200},
+	{Open: 11.16, High: 11.3, Low: 10.93, Close: 10.94, Volume: 181400},
+	{Open: 11.07, High: 11.37, Low: 11.05, Close: 11.22, Volume: 404900},
+	{Open: 11.08, High: 11.22, Low: 10.82, Close: 10.85, Volume: 423300},
+	{Open: 10.74, High: 10.82, Low: 10.22, Close: 10.42, Volume: 228000},
+	{Open: 10.3, High: 10.44, Low: 10.28, Close: 10.39, Volume: 131400},
+	{Open: 10.41, High: 10.66, Low: 10.28, Close: 10.54, Volume: 337300},
+	{Open: 10.53, High: 11.03, Low: 10.47, Close: 10.94, Volume: 227200},
+	{Open: 11.0, High: 11.14, Low: 10.66, Close: 10.79, Volume: 361300},
+	{Open: 10.86, High: 11.11, Low: 10.66, Close: 10.69, Volume: 422500},
+	{Open: 10.59, High: 10.82, Low: 10.36, Close: 10.47, Volume: 143500},
+	{Open: 10.55, High: 10.86, Low: 10.47, Close: 10.64, Volume: 366800},
+	{Open: 10.67, High: 10.88, Low: 10.46, Close: 10.77, Volume: 301100},
+	{Open: 10.76, High: 10.96, Low: 10.58, Close: 10.94, Volume: 445000},
+	{Open: 10.88, High: 11.05, Low: 10.79, Close: 10.8, Volume: 458200},
+	{Open: 10.76, High: 11.01, Low: 10.71, Close: 10.89, Volume: 315400},
+	{Open: 10.78, High: 10.88, Low: 10.36, Close: 10.58, Volume: 146000},
+	{Open: 10.48, High: 10.55, Low: 10.39, Close: 10.42, Volume: 432600},
+	{Open: 10.53, High: 10.63, Low: 10.27, Close: 10.36, Volume: 391600},
+	{Open: 10.5, High: 10.54, Low: 10.15, Close: 10.21, Volume: 271100},
+	{Open: 10.06, High: 10.42, Low: 9.99, Close: 10.37, Volume: 199300},
+	{Open: 10.35, High: 10.49, Low: 10.02, Close: 10.26, Volume: 124200},
+	{Open: 10.25, High: 10.84, Low: 10.08, Close: 10.6, Volume: 401400},
+	{Open: 10.57, High: 10.69, Low: 10.4, Close: 10.5, Volume: 236100},
+	{Open: 10.37, High: 10.41, Low: 10.05, Close: 10.14, Volume: 123000},
+	{Open: 10.02, High: 10.23, Low: 9.78, Close: 10.1, Volume: 100800},
+	{Open: 9.97, High: 10.06, Low: 9.59, Close: 9.75, Volume: 364500},
+	{Open: 9.78, High: 9.81, Low: 9.66, Close: 9.78, Volume: 461700},
+	{Open: 9.77, High: 9.81, Low: 9.45, Close: 9.64, Volume: 296800},
+	{Open: 9.63, High: 9.94, Low: 9.58, Close: 9.81, Volume: 376300},
+	{Open: 9.7, High: 9.77, Low: 9.57, Close: 9.76, Volume: 154500},
+	{Open: 9.82, High: 9.91, Low: 9.61, Close: 9.65, Volume: 262500},
+	{Open: 9.66, High: 9.99, Low: 9.6, Close: 9.91, Volume: 239800},
+	{Open: 10.0, High: 10.48, Low: 9.94, Close: 10.3, Volume: 483600},
+	{Open: 10.26, High: 10.27, Low: 9.8, Close: 9.87, Volume: 292300},
+	{Open: 9.78, High: 9.98, Low: 9.58, Close: 9.89, Volume: 366300},
+	{Open: 10.03, High: 10.09, Low: 9.88, Close: 9.94, Volume: 241100},
+	{Open: 9.89, High: 10.15, Low: 9.74, Close: 9.9, Volume: 81500},
+	{Open: 9.89, High: 10.24, Low: 9.87, Close: 10.04, Volume: 178200},
+	{Open: 10.16, High: 10.62, Low: 10.04, Close: 10.43, Volume: 226200},
+	{Open: 10.41, High: 10.58, Low: 10.17, Close: 10.56, Volume: 404200},
+	{Open: 10.55, High: 10.81, Low: 10.51, Close: 10.79, Volume: 184000},
+	{Open: 10.65, High: 10.88, Low: 10.49, Close: 10.76, Volume: 468500},
+	{Open: 10.81, High: 10.95, Low: 10.67, Close: 10.7, Volume: 91600},
+	{Open: 10.79, High: 11.05, Low: 10.6, Close: 11.02, Volume: 194000},
+	{Open: 11.0, High: 11.59, Low: 10.95, Close: 11.38, Volume: 286300},
+	{Open: 11.29, High: 11.51, Low: 11.21, Close: 11.32, Volume: 423200},
+	{Open: 11.42, High: 11.6, Low: 10.8, Close: 11.02, Volume: 424500},
+	{Open: 11.12, High: 11.54, Low: 11.08, Close: 11.51, Volume: 498200},
+	{Open: 11.37, High: 11.42, Low: 11.34, Close: 11.34, Volume: 202700},
+	{Open: 11.24, High: 11.42, Low: 11.1, Close: 11.24, Volume: 347000},
+	{Open: 11.29, High: 11.47, Low: 11.1, Close: 11.35, Volume: 126500},
+	{Open: 11.27, High: 11.46, Low: 10.95, Close: 11.08, Volume: 102800},
+	{Open: 11.16, High: 11.69, Low: 11.01, Close: 11.58, Volume: 494100},
+	{Open: 11.61, High: 11.68, Low: 11.21, Close: 11.34, Volume: 471600},
+	{Open: 11.34, High: 11.47, Low: 10.91, Close: 11.13, Volume: 292600},
+	{Open: 11.26, High: 11.71, Low: 11.15, Close: 11.66, Volume: 421300},
+	{Open: 11.55, High: 11.57, Low: 11.46, Close: 11.52, Volume: 139900},
+	{Open: 11.43, High: 11.46, Low: 11.08, Close: 11.27, Volume: 379900},
+}
+
+func TestReferenceValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		warmup int             // 预热期NaN个数
+		want   map[int]float64 // K线序号到参考值
+	}{
+		{"BOLL(20,2).Mid", Bollinger(referenceBars, 20, 2)["Mid"], 19, map[int]float64{19: 10.773000000000001, 39: 10.021, 59: 11.150000000000002}},
+		{"BOLL(20,2).Upper", Bollinger(referenceBars, 20, 2)["Upper"], 19, map[int]float64{19: 11.40206597428251, 39: 10.564760976900697, 59: 11.830852406913571}},
+		{"BOLL(20,2).Lower", Bollinger(referenceBars, 20, 2)["Lower"], 19, map[int]float64{19: 10.143934025717494, 39: 9.477239023099305, 59: 10.469147593086433}},
+		{"KDJ(9,3,3).K", KDJ(referenceBars, 9, 3, 3)["K"], 8, map[int]float64{8: 46.031746031746025, 33: 29.231573993156246, 59: 63.200618159356544}},
+		{"KDJ(9,3,3).D", KDJ(referenceBars, 9, 3, 3)["D"], 8, map[int]float64{8: 48.67724867724868, 33: 24.458466689203362, 59: 67.72190025979405}},
+		{"KDJ(9,3,3).J", KDJ(referenceBars, 9, 3, 3)["J"], 8, map[int]float64{8: 40.74074074074072, 33: 38.777788601062014, 59: 54.15805395848153}},
+		{"WR(10)", WilliamsR(referenceBars, 10), 9, map[int]float64{9: -69.8412698412699, 34: -17.47572815533976, 59: -55.000000000000114}},
+		{"CCI(14)", CCI(referenceBars, 14), 13, map[int]float64{13: -37.287608382907536, 36: -41.137937488302114, 59: -38.80597014925412}},
+		{"StochRSI(14,14,3,3).K", StochRSI(referenceBars, 14, 14, 3, 3)["K"], 29, map[int]float64{29: 2.2791456276516433, 44: 96.43898196268486, 59: 28.9832140588238}},
+		{"StochRSI(14,14,3,3).D", StochRSI(referenceBars, 14, 14, 3, 3)["D"], 31, map[int]float64{31: 13.365911246681456, 45: 97.33463021898939, 59: 27.10014081614474}},
+		{"BIAS(6)", BIAS(referenceBars, 6), 5, map[int]float64{5: -5.615942028985501, 32: -0.8391847919164274, 59: -1.284671532846714}},
+		{"PSY(12,6).PSY", PSY(referenceBars, 12, 6)["PSY"], 12, map[int]float64{12: 33.33333333333333, 35: 33.33333333333333, 59: 33.33333333333333}},
+		{"PSY(12,6).PSYMA", PSY(referenceBars, 12, 6)["PSYMA"], 17, map[int]float64{17: 44.44444444444445, 38: 41.666666666666664, 59: 38.888888888888886}},
+		{"ATR(14)", ATR(referenceBars, 14), 13, map[int]float64{13: 0.4414285714285714, 36: 0.4071176299348934, 59: 0.43769341159708175}},
+		{"DMI(14).PDI", DMI(referenceBars, 14)["PDI"], 14, map[int]float64{14: 15.01650165016502, 36: 18.0997736656098, 59: 17.448521549663987}},
+		{"DMI(14).MDI", DMI(referenceBars, 14)["MDI"], 14, map[int]float64{14: 29.86798679867987, 36: 25.39730661216884, 59: 21.59326187424308}},
+		{"DMI(14).ADX", DMI(referenceBars, 14)["ADX"], 27, map[int]float64{27: 37.540347348312125, 43: 24.139039726550514, 59: 15.808562689648067}},
+		{"SAR(0.02,0.2)", SAR(referenceBars, 0.02, 0.2), 1, map[int]float64{1: 12.11, 30: 10.370309983999999, 59: 10.926}},
+		{"Ichimoku(3,5,8,4).Tenkan", Ichimoku(referenceBars, 3, 5, 8, 4)["Tenkan"], 2, map[int]float64{2: 11.52, 30: 9.695, 59: 11.395}},
+		{"Ichimoku(3,5,8,4).Kijun", Ichimoku(referenceBars, 3, 5, 8, 4)["Kijun"], 4, map[int]float64{4: 11.465, 31: 9.754999999999999, 59: 11.31}},
+		{"Ichimoku(3,5,8,4).SenkouA", Ichimoku(referenceBars, 3, 5, 8, 4)["SenkouA"], 8, map[int]float64{8: 11.28, 33: 9.8425, 59: 11.32}},
+		{"Ichimoku(3,5,8,4).SenkouB", Ichimoku(referenceBars, 3, 5, 8, 4)["SenkouB"], 11, map[int]float64{11: 11.165, 35: 10.07, 59: 11.245000000000001}},
+		{"OBV", OBV(referenceBars), 0, map[int]float64{0: 0.0, 29: -1811400.0, 59: -1226400.0}},
+		{"MFI(14)", MFI(referenceBars, 14), 14, map[int]float64{14: 55.20819079714979, 36: 48.83507993662928, 59: 52.12960823067477}},
+		{"VWAP(0)", VWAP(referenceBars, 0), 0, map[int]float64{0: 11.783333333333333, 29: 10.605413877544898, 59: 10.692850068662093}},
+		{"VWAP(10)", VWAP(referenceBars, 10), 9, map[int]float64{9: 10.964293235426553, 34: 9.861786741167569, 59: 11.35590966066792}},
+		{"BRAR(26).AR", BRAR(referenceBars, 26)["AR"], 25, map[int]float64{25: 92.80575539568358, 42: 97.07602339181292, 59: 124.13793103448299}},
+		{"BRAR(26).BR", BRAR(referenceBars, 26)["BR"], 26, map[int]float64{26: 75.04000000000008, 42: 85.02673796791449, 59: 126.3366336633665}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.values) != len(referenceBars) {
+				t.Fatalf("结果长度%d，期望%d", len(tt.values), len(referenceBars))
+			}
+			if got := leadingNaN(tt.values); got != tt.warmup {
+				t.Fatalf("预热期NaN个数%d，期望%d", got, tt.warmup)
+			}
+			for i, want := range tt.want {
+				if !sameFloat(tt.values[i], want) {
+					t.Errorf("第%d根K线为%v，参考值%v", i, tt.values[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package indicators
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Definition 指标定义
+type Definition struct {
+	Name         string
+	Params       []float64 // 默认参数
+	PriceOverlay bool      // 是否与价格同一量纲，可叠加在K线主图上
+	build        func(p []float64) Indicator
+}
+
+var registry = map[string]Definition{}
+
+func register(def Definition, aliases ...string) {
+	registry[def.Name] = def
+	for _, alias := range aliases {
+		registry[alias] = def
+	}
+}
+
+func init() {
+	register(Definition{Name: "SMA", Params: []float64{5}, PriceOverlay: true,
+		build: func(p []float64) Indicator { return NewSMA(int(p[0])) }}, "MA")
+	register(Definition{Name: "EMA", Params: []float64{12}, PriceOverlay: true,
+		build: func(p []float64) Indicator { return NewEMA(int(p[0])) }})
+	register(Definition{Name: "MACD", Params: []float64{12, 26, 9},
+		build: func(p []float64) Indicator { return NewMACD(int(p[0]), int(p[1]), int(p[2])) }})
+	register(Definition{Name: "RSI", Params: []float64{14},
+		build: func(p []float64) Indicator { return NewRSI(int(p[0])) }})
+	register(Definition{Name: "BOLL", Params: []float64{20, 2}, PriceOverlay: true,
+		build: func(p []float64) Indicator { return NewBollinger(int(p[0]), p[1]) }}, "BOLLINGER")
+	register(Definition{Name: "DONCHIAN", Params: []float64{20}, PriceOverlay: true,
+		build: func(p []float64) Indicator { return NewDonchian(int(p[0])) }})
+	register(Definition{Name: "KELTNER", Params: []float64{20, 10, 2}, PriceOverlay: true,
+		build: func(p []float64) Indicator { return NewKeltner(int(p[0]), int(p[1]), p[2]) }})
+	register(Definition{Name: "SAR", Params: []float64{0.02, 0.2}, PriceOverlay: true,
+		build: func(p []float64) Indicator { return NewSAR(p[0], p[1]) }})
+	register(Definition{Name: "ICHIMOKU", Params: []float64{9, 26, 52, 26}, PriceOverlay: true,
+		build: func(p []float64) Indicator { return NewIchimoku(int(p[0]), int(p[1]), int(p[2]), int(p[3])) }})
+	register(Definition{Name: "VWAP", Params: []float64{0}, PriceOverlay: true,
+		build: func(p []float64) Indicator { return NewVWAP(int(p[0])) }})
+	register(Definition{Name: "ATR", Params: []float64{14},
+		build: func(p []float64) Indicator { return NewATR(int(p[0])) }})
+	register(Definition{Name: "DMI", Params: []float64{14},
+		build: func(p []float64) Indicator { return NewDMI(int(p[0])) }}, "ADX")
+	register(Definition{Name: "KDJ", Params: []float64{9, 3, 3},
+		build: func(p []float64) Indicator { return NewKDJ(int(p[0]), int(p[1]), int(p[2])) }})
+	register(Definition{Name: "WR", Params: []float64{14},
+		build: func(p []float64) Indicator { return NewWilliamsR(int(p[0])) }})
+	register(Definition{Name: "CCI", Params: []float64{20},
+		build: func(p []float64) Indicator { return NewCCI(int(p[0])) }})
+	register(Definition{Name: "STOCHRSI", Params: []float64{14, 14, 3, 3},
+		build: func(p []float64) Indicator { return NewStochRSI(int(p[0]), int(p[1]), int(p[2]), int(p[3])) }})
+	register(Definition{Name: "BIAS", Params: []float64{6},
+		build: func(p []float64) Indicator { return NewBIAS(int(p[0])) }})
+	register(Definition{Name: "PSY", Params: []float64{12, 6},
+		build: func(p []float64) Indicator { return NewPSY(int(p[0]), int(p[1])) }})
+	register(Definition{Name: "OBV",
+		build: func(p []float64) Indicator { return NewOBV() }})
+	register(Definition{Name: "MFI", Params: []float64{14},
+		build: func(p []float64) Indicator { return NewMFI(int(p[0])) }})
+	register(Definition{Name: "BRAR", Params: []float64{26},
+		build: func(p []float64) Indicator { return NewBRAR(int(p[0])) }})
+}
+
+// Lookup 按名称查找指标定义，名称不区分大小写
+func Lookup(name string) (Definition, bool) {
+	def, ok := registry[strings.ToUpper(name)]
+	return def, ok
+}
+
+// Names 返回所有已注册的指标名
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New 按名称和参数创建流式指标，未给出的参数使用默认值
+func New(name string, params ...float64) (Indicator, error) {
+	def, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown indicator: %s", name)
+	}
+	if len(params) > len(def.Params) {
+		return nil, fmt.Errorf("indicator %s takes at most %d params, got %d", def.Name, len(def.Params), len(params))
+	}
+
+	full := make([]float64, len(def.Params))
+	copy(full, def.Params)
+	copy(full, params)
+	for _, p := range full {
+		if p < 0 {
+			return nil, fmt.Errorf("indicator %s: invalid param %v", def.Name, p)
+		}
+	}
+	return def.build(full), nil
+}
+
+// Outputs 返回指标的所有输出名，单输出指标返回指标名本身
+func Outputs(name string, ind Indicator) []string {
+	if multi, ok := ind.(MultiIndicator); ok {
+		return multi.Outputs()
+	}
+	return []string{strings.ToUpper(name)}
+}
+
+// Output 按输出名读取指标当前值，单输出指标忽略输出名
+func Output(ind Indicator, output string) float64 {
+	if multi, ok := ind.(MultiIndicator); ok && output != "" {
+		return multi.Output(output)
+	}
+	return ind.Value()
+}
@@ -2,57 +2,61 @@ package indicators
 
 import (
 	"errors"
+	"math"
 	"stock/common/types"
 )
 
-// RSI 计算相对强弱指数
-func RSI(data []types.Bar, period int) ([]float64, error) {
-	if len(data) < period+1 {
-		return nil, errors.New("not enough data points to calculate RSI")
+// RSIIndicator 流式相对强弱指数，采用Wilder平滑
+type RSIIndicator struct {
+	gain      *expMean
+	loss      *expMean
+	prevClose float64
+	started   bool
+}
+
+func NewRSI(period int) *RSIIndicator {
+	return &RSIIndicator{
+		gain: newWilderMean(period),
+		loss: newWilderMean(period),
 	}
+}
 
-	gains := make([]float64, len(data))
-	losses := make([]float64, len(data))
-
-	// 计算每日价格变化
-	for i := 1; i < len(data); i++ {
-		change := data[i].Close - data[i-1].Close
-		if change > 0 {
-			gains[i] = change
-		} else {
-			losses[i] = -change
-		}
+func (r *RSIIndicator) Update(bar types.Bar) {
+	if !r.started {
+		r.prevClose = bar.Close
+		r.started = true
+		return
 	}
 
-	// 计算平均收益和平均损失
-	avgGain := make([]float64, len(data))
-	avgLoss := make([]float64, len(data))
+	change := bar.Close - r.prevClose
+	r.prevClose = bar.Close
+	r.gain.update(math.Max(change, 0))
+	r.loss.update(math.Max(-change, 0))
+}
 
-	// 计算第一个平均收益/损失
-	var sumGain, sumLoss float64
-	for i := 1; i <= period; i++ {
-		sumGain += gains[i]
-		sumLoss += losses[i]
+func (r *RSIIndicator) Value() float64 {
+	if !r.Ready() {
+		return math.NaN()
 	}
-	avgGain[period] = sumGain / float64(period)
-	avgLoss[period] = sumLoss / float64(period)
-
-	// 计算后续的平滑平均收益/损失
-	for i := period + 1; i < len(data); i++ {
-		avgGain[i] = (avgGain[i-1]*(float64(period)-1) + gains[i]) / float64(period)
-		avgLoss[i] = (avgLoss[i-1]*(float64(period)-1) + losses[i]) / float64(period)
+	avgGain, avgLoss := r.gain.value(), r.loss.value()
+	switch {
+	case avgGain == 0 && avgLoss == 0:
+		// 价格持平（如长期停牌）时既不超买也不超卖，取中性值50
+		return 50
+	case avgLoss == 0:
+		return 100
 	}
+	return 100 - 100/(1+avgGain/avgLoss)
+}
 
-	// 计算RSI
-	rsi := make([]float64, len(data))
-	for i := period; i < len(data); i++ {
-		if avgLoss[i] == 0 {
-			rsi[i] = 100
-		} else {
-			rs := avgGain[i] / avgLoss[i]
-			rsi[i] = 100 - (100 / (1 + rs))
-		}
-	}
+func (r *RSIIndicator) Ready() bool {
+	return r.gain.ready()
+}
 
-	return rsi[period:], nil
+// RSI 计算相对强弱指数，结果与输入等长，前period个值为NaN；价格持平时为50
+func RSI(data []types.Bar, period int) ([]float64, error) {
+	if len(data) < period+1 {
+		return nil, errors.New("not enough data points to calculate RSI")
+	}
+	return Batch(NewRSI(period), data), nil
 }
@@ -0,0 +1,290 @@
+package indicators
+
+import (
+	"math"
+	"stock/common/types"
+)
+
+// ATRIndicator 平均真实波幅，采用Wilder平滑
+type ATRIndicator struct {
+	mean      *expMean
+	prevClose float64
+	started   bool
+}
+
+func NewATR(period int) *ATRIndicator {
+	return &ATRIndicator{mean: newWilderMean(period)}
+}
+
+func (a *ATRIndicator) Update(bar types.Bar) {
+	a.mean.update(trueRange(bar, a.prevClose, a.started))
+	a.prevClose = bar.Close
+	a.started = true
+}
+
+func (a *ATRIndicator) Value() float64 { return a.mean.value() }
+func (a *ATRIndicator) Ready() bool    { return a.mean.ready() }
+
+// ATR 计算平均真实波幅
+func ATR(bars []types.Bar, period int) []float64 {
+	return Batch(NewATR(period), bars)
+}
+
+// trueRange 真实波幅，第一根K线取最高价减最低价
+func trueRange(bar types.Bar, prevClose float64, hasPrev bool) float64 {
+	tr := bar.High - bar.Low
+	if hasPrev {
+		tr = math.Max(tr, math.Max(math.Abs(bar.High-prevClose), math.Abs(bar.Low-prevClose)))
+	}
+	return tr
+}
+
+// DMIIndicator 趋向指标，输出PDI/MDI/ADX，Value返回ADX
+type DMIIndicator struct {
+	tr      *expMean
+	plusDM  *expMean
+	minusDM *expMean
+	adx     *expMean
+	prev    types.Bar
+	started bool
+}
+
+func NewDMI(period int) *DMIIndicator {
+	return &DMIIndicator{
+		tr:      newWilderMean(period),
+		plusDM:  newWilderMean(period),
+		minusDM: newWilderMean(period),
+		adx:     newWilderMean(period),
+	}
+}
+
+// NewADX 平均趋向指数，与DMI相同
+func NewADX(period int) *DMIIndicator {
+	return NewDMI(period)
+}
+
+func (d *DMIIndicator) Update(bar types.Bar) {
+	if !d.started {
+		d.prev = bar
+		d.started = true
+		return
+	}
+
+	up := bar.High - d.prev.High
+	down := d.prev.Low - bar.Low
+	plus, minus := 0.0, 0.0
+	if up > down && up > 0 {
+		plus = up
+	}
+	if down > up && down > 0 {
+		minus = down
+	}
+
+	d.tr.update(trueRange(bar, d.prev.Close, true))
+	d.plusDM.update(plus)
+	d.minusDM.update(minus)
+	d.prev = bar
+
+	if d.tr.ready() {
+		pdi, mdi := d.di()
+		dx := 0.0
+		if pdi+mdi > 0 {
+			dx = 100 * math.Abs(pdi-mdi) / (pdi + mdi)
+		}
+		d.adx.update(dx)
+	}
+}
+
+func (d *DMIIndicator) di() (float64, float64) {
+	tr := d.tr.value()
+	if tr == 0 {
+		return 0, 0
+	}
+	return 100 * d.plusDM.value() / tr, 100 * d.minusDM.value() / tr
+}
+
+func (d *DMIIndicator) Value() float64    { return d.adx.value() }
+func (d *DMIIndicator) Ready() bool       { return d.adx.ready() }
+func (d *DMIIndicator) Outputs() []string { return []string{"PDI", "MDI", "ADX"} }
+
+func (d *DMIIndicator) Output(name string) float64 {
+	if !d.tr.ready() {
+		return math.NaN()
+	}
+	pdi, mdi := d.di()
+	switch name {
+	case "PDI":
+		return pdi
+	case "MDI":
+		return mdi
+	case "ADX":
+		return d.adx.value()
+	default:
+		return math.NaN()
+	}
+}
+
+// DMI 计算趋向指标
+func DMI(bars []types.Bar, period int) map[string][]float64 {
+	return BatchOutputs(NewDMI(period), bars)
+}
+
+// SARIndicator 抛物线转向指标
+type SARIndicator struct {
+	step    float64
+	maxStep float64
+	af      float64
+	ep      float64
+	sar     float64
+	long    bool
+	count   int
+	prev    types.Bar
+	prev2   types.Bar
+}
+
+// NewSAR 创建抛物线转向指标，常用参数为0.02和0.2
+func NewSAR(step, maxStep float64) *SARIndicator {
+	return &SARIndicator{step: step, maxStep: maxStep}
+}
+
+func (s *SARIndicator) Update(bar types.Bar) {
+	s.count++
+	switch s.count {
+	case 1:
+		s.prev = bar
+		return
+	case 2:
+		// 以前两根K线的收盘价方向确定初始趋势
+		s.long = bar.Close >= s.prev.Close
+		s.af = s.step
+		if s.long {
+			s.sar = math.Min(s.prev.Low, bar.Low)
+			s.ep = math.Max(s.prev.High, bar.High)
+		} else {
+			s.sar = math.Max(s.prev.High, bar.High)
+			s.ep = math.Min(s.prev.Low, bar.Low)
+		}
+		s.prev2, s.prev = s.prev, bar
+		return
+	}
+
+	sar := s.sar + s.af*(s.ep-s.sar)
+	if s.long {
+		sar = math.Min(sar, math.Min(s.prev.Low, s.prev2.Low))
+		if bar.Low < sar {
+			s.long = false
+			sar = s.ep
+			s.ep = bar.Low
+			s.af = s.step
+		} else if bar.High > s.ep {
+			s.ep = bar.High
+			s.af = math.Min(s.af+s.step, s.maxStep)
+		}
+	} else {
+		sar = math.Max(sar, math.Max(s.prev.High, s.prev2.High))
+		if bar.High > sar {
+			s.long = true
+			sar = s.ep
+			s.ep = bar.High
+			s.af = s.step
+		} else if bar.Low < s.ep {
+			s.ep = bar.Low
+			s.af = math.Min(s.af+s.step, s.maxStep)
+		}
+	}
+	s.sar = sar
+	s.prev2, s.prev = s.prev, bar
+}
+
+func (s *SARIndicator) Value() float64 {
+	if !s.Ready() {
+		return math.NaN()
+	}
+	return s.sar
+}
+
+func (s *SARIndicator) Ready() bool { return s.count >= 2 }
+
+// Long 当前是否处于上升趋势
+func (s *SARIndicator) Long() bool { return s.long }
+
+// SAR 计算抛物线转向指标
+func SAR(bars []types.Bar, step, maxStep float64) []float64 {
+	return Batch(NewSAR(step, maxStep), bars)
+}
+
+// IchimokuIndicator 一目均衡表，输出Tenkan/Kijun/SenkouA/SenkouB
+// 先行带为displacement根K线之前计算的值，对应当前K线位置；迟行线需要未来数据，不提供
+type IchimokuIndicator struct {
+	tenkanHigh, tenkanLow *rollingExtreme
+	kijunHigh, kijunLow   *rollingExtreme
+	spanBHigh, spanBLow   *rollingExtreme
+	spanA, spanB          *rollingWindow
+}
+
+// NewIchimoku 创建一目均衡表，常用参数为9、26、52、26
+func NewIchimoku(tenkan, kijun, spanB, displacement int) *IchimokuIndicator {
+	return &IchimokuIndicator{
+		tenkanHigh: newRollingMax(tenkan),
+		tenkanLow:  newRollingMin(tenkan),
+		kijunHigh:  newRollingMax(kijun),
+		kijunLow:   newRollingMin(kijun),
+		spanBHigh:  newRollingMax(spanB),
+		spanBLow:   newRollingMin(spanB),
+		spanA:      newRollingWindow(displacement + 1),
+		spanB:      newRollingWindow(displacement + 1),
+	}
+}
+
+func (ic *IchimokuIndicator) Update(bar types.Bar) {
+	for _, e := range []*rollingExtreme{ic.tenkanHigh, ic.kijunHigh, ic.spanBHigh} {
+		e.update(bar.High)
+	}
+	for _, e := range []*rollingExtreme{ic.tenkanLow, ic.kijunLow, ic.spanBLow} {
+		e.update(bar.Low)
+	}
+	ic.spanA.push((ic.tenkan() + ic.kijun()) / 2)
+	ic.spanB.push((ic.spanBHigh.value() + ic.spanBLow.value()) / 2)
+}
+
+func (ic *IchimokuIndicator) tenkan() float64 {
+	return (ic.tenkanHigh.value() + ic.tenkanLow.value()) / 2
+}
+
+func (ic *IchimokuIndicator) kijun() float64 {
+	return (ic.kijunHigh.value() + ic.kijunLow.value()) / 2
+}
+
+func (ic *IchimokuIndicator) Value() float64 { return ic.tenkan() }
+func (ic *IchimokuIndicator) Ready() bool {
+	return ic.spanB.full() && !math.IsNaN(ic.spanB.at(0))
+}
+func (ic *IchimokuIndicator) Outputs() []string {
+	return []string{"Tenkan", "Kijun", "SenkouA", "SenkouB"}
+}
+
+func (ic *IchimokuIndicator) Output(name string) float64 {
+	switch name {
+	case "Tenkan":
+		return ic.tenkan()
+	case "Kijun":
+		return ic.kijun()
+	case "SenkouA":
+		if !ic.spanA.full() {
+			return math.NaN()
+		}
+		return ic.spanA.at(0)
+	case "SenkouB":
+		if !ic.spanB.full() {
+			return math.NaN()
+		}
+		return ic.spanB.at(0)
+	default:
+		return math.NaN()
+	}
+}
+
+// Ichimoku 计算一目均衡表
+func Ichimoku(bars []types.Bar, tenkan, kijun, spanB, displacement int) map[string][]float64 {
+	return BatchOutputs(NewIchimoku(tenkan, kijun, spanB, displacement), bars)
+}
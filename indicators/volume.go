@@ -0,0 +1,202 @@
+package indicators
+
+import (
+	"math"
+	"stock/common/types"
+)
+
+// OBVIndicator 能量潮
+type OBVIndicator struct {
+	obv       float64
+	prevClose float64
+	started   bool
+}
+
+func NewOBV() *OBVIndicator {
+	return &OBVIndicator{}
+}
+
+func (o *OBVIndicator) Update(bar types.Bar) {
+	if o.started {
+		switch {
+		case bar.Close > o.prevClose:
+			o.obv += bar.Volume
+		case bar.Close < o.prevClose:
+			o.obv -= bar.Volume
+		}
+	}
+	o.prevClose = bar.Close
+	o.started = true
+}
+
+func (o *OBVIndicator) Value() float64 {
+	if !o.started {
+		return math.NaN()
+	}
+	return o.obv
+}
+
+func (o *OBVIndicator) Ready() bool { return o.started }
+
+// OBV 计算能量潮
+func OBV(bars []types.Bar) []float64 {
+	return Batch(NewOBV(), bars)
+}
+
+// MFIIndicator 资金流量指标
+type MFIIndicator struct {
+	positive *rollingSum
+	negative *rollingSum
+	prevTP   float64
+	started  bool
+}
+
+func NewMFI(period int) *MFIIndicator {
+	return &MFIIndicator{positive: newRollingSum(period), negative: newRollingSum(period)}
+}
+
+func (m *MFIIndicator) Update(bar types.Bar) {
+	tp := (bar.High + bar.Low + bar.Close) / 3
+	if m.started {
+		flow := tp * bar.Volume
+		pos, neg := 0.0, 0.0
+		if tp > m.prevTP {
+			pos = flow
+		} else if tp < m.prevTP {
+			neg = flow
+		}
+		m.positive.update(pos)
+		m.negative.update(neg)
+	}
+	m.prevTP = tp
+	m.started = true
+}
+
+func (m *MFIIndicator) Value() float64 {
+	if !m.Ready() {
+		return math.NaN()
+	}
+	if m.negative.sum == 0 {
+		return 100
+	}
+	return 100 - 100/(1+m.positive.sum/m.negative.sum)
+}
+
+func (m *MFIIndicator) Ready() bool { return m.positive.ready() }
+
+// MFI 计算资金流量指标
+func MFI(bars []types.Bar, period int) []float64 {
+	return Batch(NewMFI(period), bars)
+}
+
+// VWAPIndicator 成交量加权平均价，period为0时从第一根K线起累计
+type VWAPIndicator struct {
+	period    int
+	pv        *rollingSum
+	volume    *rollingSum
+	cumPV     float64
+	cumVolume float64
+	count     int
+}
+
+func NewVWAP(period int) *VWAPIndicator {
+	v := &VWAPIndicator{period: period}
+	if period > 0 {
+		v.pv = newRollingSum(period)
+		v.volume = newRollingSum(period)
+	}
+	return v
+}
+
+func (v *VWAPIndicator) Update(bar types.Bar) {
+	tp := (bar.High + bar.Low + bar.Close) / 3
+	v.count++
+	if v.period > 0 {
+		v.pv.update(tp * bar.Volume)
+		v.volume.update(bar.Volume)
+		return
+	}
+	v.cumPV += tp * bar.Volume
+	v.cumVolume += bar.Volume
+}
+
+func (v *VWAPIndicator) Value() float64 {
+	if !v.Ready() {
+		return math.NaN()
+	}
+	pv, volume := v.cumPV, v.cumVolume
+	if v.period > 0 {
+		pv, volume = v.pv.sum, v.volume.sum
+	}
+	if volume == 0 {
+		return math.NaN()
+	}
+	return pv / volume
+}
+
+func (v *VWAPIndicator) Ready() bool {
+	if v.period > 0 {
+		return v.pv.ready()
+	}
+	return v.count > 0
+}
+
+// VWAP 计算成交量加权平均价
+func VWAP(bars []types.Bar, period int) []float64 {
+	return Batch(NewVWAP(period), bars)
+}
+
+// BRARIndicator 人气意愿指标，输出BR/AR
+type BRARIndicator struct {
+	arUp, arDown *rollingSum
+	brUp, brDown *rollingSum
+	prevClose    float64
+	started      bool
+}
+
+// NewBRAR 创建人气意愿指标，常用参数为26
+func NewBRAR(period int) *BRARIndicator {
+	return &BRARIndicator{
+		arUp:   newRollingSum(period),
+		arDown: newRollingSum(period),
+		brUp:   newRollingSum(period),
+		brDown: newRollingSum(period),
+	}
+}
+
+func (b *BRARIndicator) Update(bar types.Bar) {
+	b.arUp.update(bar.High - bar.Open)
+	b.arDown.update(bar.Open - bar.Low)
+	if b.started {
+		b.brUp.update(math.Max(0, bar.High-b.prevClose))
+		b.brDown.update(math.Max(0, b.prevClose-bar.Low))
+	}
+	b.prevClose = bar.Close
+	b.started = true
+}
+
+func (b *BRARIndicator) Value() float64    { return b.Output("AR") }
+func (b *BRARIndicator) Ready() bool       { return b.brUp.ready() }
+func (b *BRARIndicator) Outputs() []string { return []string{"BR", "AR"} }
+
+func (b *BRARIndicator) Output(name string) float64 {
+	ratio := func(up, down *rollingSum) float64 {
+		if !up.ready() || down.sum == 0 {
+			return math.NaN()
+		}
+		return up.sum / down.sum * 100
+	}
+	switch name {
+	case "AR":
+		return ratio(b.arUp, b.arDown)
+	case "BR":
+		return ratio(b.brUp, b.brDown)
+	default:
+		return math.NaN()
+	}
+}
+
+// BRAR 计算人气意愿指标
+func BRAR(bars []types.Bar, period int) map[string][]float64 {
+	return BatchOutputs(NewBRAR(period), bars)
+}
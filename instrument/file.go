@@ -0,0 +1,137 @@
+package instrument
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadFile 从品种文件加载品种信息，见Load
+func (r *Registry) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开品种文件失败: %v", err)
+	}
+	defer f.Close()
+	return r.Load(f)
+}
+
+// Load 从CSV加载品种信息，第一行为表头，symbol列必填，其余列可省略或留空，留空时使用该资产类别的默认值：
+//
+//	symbol,class,exchange,multiplier,tick,lot,margin,limit,t0,commission,min_fee,stamp_duty,transfer_fee
+//	159920.SZ,etf,,,,,,,true,,,,
+//	T2303.CFE,future,CFE,10000,0.005,1,0.02,0.02,true,,,,
+//
+// class可选stock、etf、convertible、future，省略时按代码识别
+func (r *Registry) Load(reader io.Reader) error {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("读取品种文件失败: %v", err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["symbol"]; !ok {
+		return fmt.Errorf("品种文件缺少symbol列")
+	}
+
+	for line, record := range records[1:] {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		symbol := field("symbol")
+		if symbol == "" {
+			continue
+		}
+		inst, err := r.defaults(symbol, AssetClass(strings.ToLower(field("class"))))
+		if err != nil {
+			return fmt.Errorf("品种文件第%d行: %v", line+2, err)
+		}
+		if exchange := field("exchange"); exchange != "" {
+			inst.Exchange = strings.ToUpper(exchange)
+		}
+		numbers := []struct {
+			name   string
+			target *float64
+		}{
+			{"multiplier", &inst.Multiplier},
+			{"tick", &inst.TickSize},
+			{"lot", &inst.LotSize},
+			{"margin", &inst.MarginRate},
+			{"limit", &inst.LimitRate},
+			{"commission", &inst.Fees.Commission},
+			{"min_fee", &inst.Fees.MinFee},
+			{"stamp_duty", &inst.Fees.StampDuty},
+			{"transfer_fee", &inst.Fees.TransferFee},
+		}
+		for _, n := range numbers {
+			value := field(n.name)
+			if value == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("品种文件第%d行%s列格式错误: %v", line+2, n.name, err)
+			}
+			*n.target = v
+		}
+		if value := field("t0"); value != "" {
+			t0, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("品种文件第%d行t0列格式错误: %v", line+2, err)
+			}
+			inst.T0 = t0
+		}
+		r.Register(inst)
+	}
+	return nil
+}
+
+// defaults 返回资产类别的默认品种信息，class为空时按代码识别
+func (r *Registry) defaults(symbol string, class AssetClass) (*Instrument, error) {
+	var inst *Instrument
+	switch class {
+	case "":
+		inst = r.Lookup(symbol)
+	case ClassStock:
+		inst = Stock(symbol)
+	case ClassETF:
+		if inst = ETF(symbol); inst == nil {
+			inst = newETF(symbol, exchangeOf(symbol), false)
+		}
+	case ClassConvertible:
+		if inst = Convertible(symbol); inst == nil {
+			inst = newConvertible(symbol, exchangeOf(symbol))
+		}
+	case ClassFuture:
+		if inst = IndexFuture(symbol); inst == nil {
+			inst = &Instrument{
+				Symbol:     symbol,
+				Class:      ClassFuture,
+				Exchange:   exchangeOf(symbol),
+				Multiplier: 1,
+				TickSize:   0.01,
+				LotSize:    1,
+				MarginRate: 0.1,
+				T0:         true,
+			}
+		}
+	default:
+		return nil, fmt.Errorf("未知的资产类别: %s", class)
+	}
+	copied := *inst
+	copied.Symbol = symbol
+	return &copied, nil
+}
@@ -0,0 +1,319 @@
+// Package instrument 交易品种元数据：资产类别、合约乘数、最小变动价位、交易单位、保证金、涨跌停和交收规则
+package instrument
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AssetClass 资产类别
+type AssetClass string
+
+const (
+	ClassStock       AssetClass = "stock"
+	ClassETF         AssetClass = "etf"
+	ClassConvertible AssetClass = "convertible"
+	ClassFuture      AssetClass = "future"
+)
+
+// Instrument 交易品种
+type Instrument struct {
+	Symbol     string
+	Class      AssetClass
+	Exchange   string
+	Multiplier float64 // 合约乘数，股票为1
+	TickSize   float64 // 最小变动价位
+	LotSize    float64 // 最小交易单位
+	MarginRate float64 // 保证金比例，0表示全额现金交易
+	LimitRate  float64 // 涨跌停幅度（相对上一交易日收盘价），0表示不限制
+	T0         bool    // 当日买入当日可以卖出
+	Fees       FeeSchedule
+}
+
+// FeeSchedule 费率，均按成交金额（含合约乘数）计
+type FeeSchedule struct {
+	Commission  float64 // 佣金，买卖双向
+	MinFee      float64 // 单笔最低佣金
+	StampDuty   float64 // 印花税，仅卖出
+	TransferFee float64 // 过户费，买卖双向
+}
+
+// IsFuture 是否为保证金交易的期货
+func (i *Instrument) IsFuture() bool {
+	return i.MarginRate > 0
+}
+
+// Notional 合约价值
+func (i *Instrument) Notional(price, quantity float64) float64 {
+	return price * quantity * i.Multiplier
+}
+
+// Margin 持仓占用的保证金
+func (i *Instrument) Margin(price, quantity float64) float64 {
+	return math.Abs(i.Notional(price, quantity)) * i.MarginRate
+}
+
+// RoundPrice 按最小变动价位取整
+func (i *Instrument) RoundPrice(price float64) float64 {
+	if i.TickSize <= 0 {
+		return price
+	}
+	// 再按1e-8取整，去掉浮点乘法的尾差
+	return math.Round(math.Round(price/i.TickSize)*i.TickSize*1e8) / 1e8
+}
+
+// RoundLot 按交易单位向零取整
+func (i *Instrument) RoundLot(quantity float64) float64 {
+	if i.LotSize <= 0 {
+		return quantity
+	}
+	return math.Trunc(quantity/i.LotSize) * i.LotSize
+}
+
+// LimitPrices 按上一交易日收盘价计算涨停价和跌停价，不限制时返回+Inf和0
+func (i *Instrument) LimitPrices(prevClose float64) (up, down float64) {
+	if i.LimitRate <= 0 || prevClose <= 0 {
+		return math.Inf(1), 0
+	}
+	return i.RoundPrice(prevClose * (1 + i.LimitRate)), i.RoundPrice(prevClose * (1 - i.LimitRate))
+}
+
+// Detector 按代码识别品种，无法识别时返回nil
+type Detector func(symbol string) *Instrument
+
+// Registry 品种注册表，先查显式注册的品种，再依次尝试识别规则，最后按A股股票处理
+type Registry struct {
+	mu          sync.RWMutex
+	instruments map[string]*Instrument
+	detectors   []Detector
+}
+
+// NewRegistry 创建包含股指期货、ETF和可转债识别规则的注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		instruments: make(map[string]*Instrument),
+		detectors:   []Detector{detectIndexFuture, ETF, Convertible},
+	}
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default 返回默认注册表
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Register 注册品种，覆盖识别规则
+func (r *Registry) Register(inst *Instrument) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.instruments[inst.Symbol] = inst
+}
+
+// AddDetector 添加识别规则，后添加的规则优先
+func (r *Registry) AddDetector(detector Detector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.detectors = append([]Detector{detector}, r.detectors...)
+}
+
+// Lookup 返回品种信息，总是返回非nil
+func (r *Registry) Lookup(symbol string) *Instrument {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if inst, ok := r.instruments[symbol]; ok {
+		return inst
+	}
+	for _, detect := range r.detectors {
+		if inst := detect(symbol); inst != nil {
+			return inst
+		}
+	}
+	return Stock(symbol)
+}
+
+// Stock A股股票：每手100股，最小变动0.01元，主板涨跌停10%，创业板和科创板20%，T+1交收
+func Stock(symbol string) *Instrument {
+	limit := 0.10
+	code := strings.SplitN(symbol, ".", 2)[0]
+	if strings.HasPrefix(code, "300") || strings.HasPrefix(code, "301") || strings.HasPrefix(code, "688") {
+		limit = 0.20
+	}
+	return &Instrument{
+		Symbol:     symbol,
+		Class:      ClassStock,
+		Exchange:   exchangeOf(symbol),
+		Multiplier: 1,
+		TickSize:   0.01,
+		LotSize:    100,
+		LimitRate:  limit,
+		Fees: FeeSchedule{
+			Commission:  0.0003,
+			MinFee:      5,
+			StampDuty:   0.001,
+			TransferFee: 0.00002,
+		},
+	}
+}
+
+func exchangeOf(symbol string) string {
+	if idx := strings.LastIndex(symbol, "."); idx >= 0 {
+		return strings.ToUpper(symbol[idx+1:])
+	}
+	return ""
+}
+
+// splitCode 拆分证券代码和交易所，没有后缀时交易所为空
+func splitCode(symbol string) (code, exchange string) {
+	parts := strings.SplitN(strings.ToUpper(symbol), ".", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
+// matchCode 六位代码以prefixes之一开头，且交易所后缀为空或等于exchange
+func matchCode(symbol, exchange string, prefixes ...string) bool {
+	code, ex := splitCode(symbol)
+	if len(code) != 6 || (ex != "" && ex != exchange) {
+		return false
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(code, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ETF 场内ETF：上交所51、56、58开头，深交所159开头，每手100份，最小变动0.001元，涨跌停10%，免印花税和过户费。
+// 债券、货币、跨境和黄金ETF（上交所511、513、518开头）T+0交易，其余T+1；其他T+0品种可通过品种文件指定
+func ETF(symbol string) *Instrument {
+	var exchange string
+	switch {
+	case matchCode(symbol, "SH", "51", "56", "58"):
+		exchange = "SH"
+	case matchCode(symbol, "SZ", "159"):
+		exchange = "SZ"
+	default:
+		return nil
+	}
+	return newETF(symbol, exchange, matchCode(symbol, "SH", "511", "513", "518"))
+}
+
+func newETF(symbol, exchange string, t0 bool) *Instrument {
+	return &Instrument{
+		Symbol:     symbol,
+		Class:      ClassETF,
+		Exchange:   exchange,
+		Multiplier: 1,
+		TickSize:   0.001,
+		LotSize:    100,
+		LimitRate:  0.10,
+		T0:         t0,
+		Fees:       FeeSchedule{Commission: 0.0003, MinFee: 5},
+	}
+}
+
+// Convertible 可转债：上交所110、111、113、118开头，深交所123、127、128开头，
+// 每手10张，最小变动0.001元，涨跌停20%，T+0交易，免印花税和过户费
+func Convertible(symbol string) *Instrument {
+	var exchange string
+	switch {
+	case matchCode(symbol, "SH", "110", "111", "113", "118"):
+		exchange = "SH"
+	case matchCode(symbol, "SZ", "123", "127", "128"):
+		exchange = "SZ"
+	default:
+		return nil
+	}
+	return newConvertible(symbol, exchange)
+}
+
+func newConvertible(symbol, exchange string) *Instrument {
+	return &Instrument{
+		Symbol:     symbol,
+		Class:      ClassConvertible,
+		Exchange:   exchange,
+		Multiplier: 1,
+		TickSize:   0.001,
+		LotSize:    10,
+		LimitRate:  0.20,
+		T0:         true,
+		Fees:       FeeSchedule{Commission: 0.0001, MinFee: 1},
+	}
+}
+
+// indexFutures 中金所股指期货：合约乘数与交易所最低保证金
+var indexFutures = map[string]struct {
+	multiplier float64
+	margin     float64
+}{
+	"IF": {300, 0.12},
+	"IH": {300, 0.12},
+	"IC": {200, 0.14},
+	"IM": {200, 0.15},
+}
+
+// futurePattern 匹配 IF2303、IF2303.CFE 以及连续合约 IF888、IF.CFE
+var futurePattern = regexp.MustCompile(`^(IF|IH|IC|IM)(\d{4}|888|889)?(\.(CFE|CFFEX))?$`)
+
+// FutureRoot 返回期货代码的品种代码（如IF），不是股指期货时返回空字符串
+func FutureRoot(symbol string) string {
+	m := futurePattern.FindStringSubmatch(strings.ToUpper(symbol))
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// IndexFuture 中金所股指期货，最小变动0.2点，涨跌停10%，T+0交易
+func IndexFuture(symbol string) *Instrument {
+	spec, ok := indexFutures[FutureRoot(symbol)]
+	if !ok {
+		return nil
+	}
+	return &Instrument{
+		Symbol:     symbol,
+		Class:      ClassFuture,
+		Exchange:   "CFE",
+		Multiplier: spec.multiplier,
+		TickSize:   0.2,
+		LotSize:    1,
+		MarginRate: spec.margin,
+		LimitRate:  0.10,
+		T0:         true,
+		Fees:       FeeSchedule{Commission: 0.000023},
+	}
+}
+
+func detectIndexFuture(symbol string) *Instrument {
+	return IndexFuture(symbol)
+}
+
+// contractPattern 匹配具体月份合约，如 IF2303、IF2303.CFE
+var contractPattern = regexp.MustCompile(`^(IF|IH|IC|IM)(\d{2})(\d{2})(\.(CFE|CFFEX))?$`)
+
+// ContractMonth 返回股指期货具体合约的交割年月，连续合约或非期货代码返回ok=false
+func ContractMonth(symbol string) (year int, month time.Month, ok bool) {
+	m := contractPattern.FindStringSubmatch(strings.ToUpper(symbol))
+	if m == nil {
+		return 0, 0, false
+	}
+	yy, _ := strconv.Atoi(m[2])
+	mm, _ := strconv.Atoi(m[3])
+	if mm < 1 || mm > 12 {
+		return 0, 0, false
+	}
+	return 2000 + yy, time.Month(mm), true
+}
+
+// IsContinuous 是否为连续合约代码，如 IF888、IF889、IF.CFE
+func IsContinuous(symbol string) bool {
+	_, _, ok := ContractMonth(symbol)
+	return FutureRoot(symbol) != "" && !ok
+}
@@ -1,25 +1,41 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
 	"time"
 
 	"stock/analyzer"
 	"stock/backtest"
 	"stock/broker"
+	"stock/calendar"
 	"stock/common"
 	"stock/common/types"
 	"stock/datasource"
+	"stock/indicators"
+	"stock/risk"
+	"stock/schedule"
+	"stock/sizing"
 	"stock/strategy"
 	"stock/visualization"
 )
 
 func main() {
+	// 子命令模式，如: stock data check -file data/cmb.csv
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// 测试通达信数据源
-	tdxDs := datasource.NewTDXDataSource("data/sh600036.day")
+	// 通达信数据源，解析结果缓存到本地，源文件更新后自动失效
+	tdxDs := datasource.NewCachedDataSource(datasource.NewTDXDataSource("data/sh600036.day"), ".cache", datasource.AdjustNone)
 	startDate := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
 	endDate := time.Date(2022, 12, 31, 23, 59, 59, 0, time.UTC)
 	tdxData, err := tdxDs.GetData("600036.SH", datasource.PeriodTypeDay, startDate, endDate)
@@ -37,10 +53,27 @@ func main() {
 		log.Fatalf("获取数据失败: %v", err)
 	}
 
-	// 初始化多个策略
-	strategies := []strategy.Strategy{
-		strategy.NewMACDStrategy(12, 26, 9, []int{5, 10, 20}),
+	// 初始化多个策略，MACD按权益比例开仓
+	macdStrategy := strategy.NewMACDStrategy(12, 26, 9, []int{5, 10, 20})
+	macdStrategy.SetSizer(sizing.NewPercentEquity(0.5))
+	strategies := []strategy.Strategy{macdStrategy}
+
+	// 通达信公式策略：均线金叉买入、死叉卖出
+	formulaStrategy, err := strategy.NewFormulaStrategy(`
+		MA5:MA(C,5);
+		MA20:MA(C,20);
+		BUY:CROSS(MA5,MA20) AND C>REF(HHV(H,10),1);
+		SELL:CROSS(MA20,MA5) OR BARSLAST(CROSS(MA5,MA20))>=20;`)
+	if err != nil {
+		log.Fatalf("编译公式失败: %v", err)
 	}
+	// 每笔风险1%权益，止损距离2倍ATR
+	formulaStrategy.SetSizer(sizing.NewATRRisk(0.01, 14, 2))
+	strategies = append(strategies, formulaStrategy)
+
+	// 每月最后一个交易日再平衡到50%仓位
+	strategies = append(strategies, strategy.NewRebalanceStrategy(
+		schedule.LastOfMonth(nil), map[string]float64{"600036.SH": 0.5}))
 
 	// 初始化费用配置
 	feeConfig := backtest.DefaultFeeConfig
@@ -62,8 +95,40 @@ func main() {
 		bt.AddStrategy(strategy)
 	}
 
-	// 运行回测
-	results, _ := bt.Run()
+	// 通达信数据为不复权价格，需要计入分红送转
+	actions, err := datasource.LoadCorporateActions("data/cmb_actions.csv")
+	if err != nil {
+		log.Fatalf("加载公司行为数据失败: %v", err)
+	}
+	bt.SetCorporateActions(actions)
+
+	// 组合风控：单股不超过60%，回撤超过20%清仓停止
+	sectors, err := risk.LoadSectors("data/sectors.csv")
+	if err != nil {
+		log.Fatalf("加载行业分类失败: %v", err)
+	}
+	bt.SetRiskConfig(risk.Config{
+		MaxPositionWeight: 0.6,
+		MaxGrossExposure:  1.0,
+		MaxHoldings:       10,
+		MaxSectorWeight:   0.8,
+		Sectors:           sectors,
+		DailyLossLimit:    0.05,
+		MaxDrawdown:       0.2,
+	})
+
+	// 运行回测，stderr显示进度条，Ctrl+C中止
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	bar := common.NewProgressBar(os.Stderr, 40)
+	bt.SetProgressHandler(func(p backtest.Progress) {
+		bar.Update(p.Fraction(), fmt.Sprintf("%s %s 权益: %.2f", p.Name, p.Time.Format("2006-01-02"), p.Equity))
+	})
+	results, err := bt.RunContext(ctx)
+	bar.Finish()
+	if err != nil {
+		log.Fatalf("回测失败: %v", err)
+	}
 
 	// 获取回测结果
 	if len(results.Results) == 0 {
@@ -79,11 +144,11 @@ func main() {
 
 		// 初始化analyzer
 		analyzer := analyzer.NewAnalyzer(result.Trades, initialCash)
+		analyzer.SetCalendar(calendar.Default())
 		// 计算关键指标
 		finalValue := result.FinalValue
-		duration := endDate.Sub(startDate)
 		totalReturn := analyzer.TotalReturn(finalValue)
-		annualizedReturn := analyzer.AnnualizedReturn(finalValue, duration)
+		annualizedReturn := analyzer.AnnualizedReturn(finalValue, startDate, endDate)
 		maxDrawdown := result.MaxDrawdown
 		winRate := analyzer.WinRate()
 		avgProfit, avgLoss := analyzer.AverageProfitLoss()
@@ -105,16 +170,24 @@ func main() {
 		returns := result.Returns
 		annualVolatility := analyzer.AnnualVolatility(returns)
 		sortinoRatio := analyzer.SortinoRatio(returns, 0)
-		calmarRatio := analyzer.CalmarRatio(finalValue, maxDrawdown, duration)
-		drawdownDuration := analyzer.DrawdownDuration(result.Values)
+		calmarRatio := analyzer.CalmarRatio(finalValue, maxDrawdown, startDate, endDate)
+		ddStart, ddEnd, ddDays := analyzer.DrawdownPeriod(result.Values, result.Timestamps)
 		var95 := analyzer.ValueAtRisk(returns, 0.95)
 
 		// 显示新增指标
 		fmt.Printf("年波动率: %.2f%%\n", annualVolatility*100)
 		fmt.Printf("索提诺比率: %.2f\n", sortinoRatio)
 		fmt.Printf("卡尔玛比率: %.2f\n", calmarRatio)
-		fmt.Printf("最大回撤持续时间: %s\n", drawdownDuration)
+		fmt.Printf("最大回撤持续时间: %d个交易日 (%s ~ %s)\n", ddDays,
+			ddStart.Format("2006-01-02"), ddEnd.Format("2006-01-02"))
 		fmt.Printf("95%%置信度VaR: %.2f%%\n", var95*100)
+		fmt.Printf("风控事件: %d\n", len(result.RiskEvents))
+		if result.Halted {
+			fmt.Println("策略已因最大回撤熔断停止")
+		}
+		if s, ok := strategies[i].(interface{ Errors() []error }); ok {
+			printOrderErrors("调仓", s.Errors())
+		}
 
 		// 将DataPoint转换为Candle
 		candles := make([]types.Candle, len(data))
@@ -140,6 +213,23 @@ func main() {
 			}
 		}
 
+		// 指标叠加：布林带叠加在主图，KDJ单独绘制
+		bars := make([]types.Bar, len(data))
+		for i, dp := range data {
+			bars[i] = types.Bar{
+				Time:   dp.Timestamp.Unix(),
+				Open:   dp.Open,
+				High:   dp.High,
+				Low:    dp.Low,
+				Close:  dp.Close,
+				Volume: dp.Volume,
+			}
+		}
+		overlays := []visualization.Overlay{
+			{Name: "BOLL", Series: indicators.Bollinger(bars, 20, 2), PriceOverlay: true},
+			{Name: "KDJ", Series: indicators.KDJ(bars, 9, 3, 3)},
+		}
+
 		// 按策略名称分组交易数据
 		tradesMap := map[string][]types.Trade{
 			strategyName: result.Trades,
@@ -147,6 +237,12 @@ func main() {
 
 		// 可视化结果
 		chart := visualization.NewChart(fmt.Sprintf("招商银行 %s 策略回测", strategyName))
+		if fs, ok := strategies[i].(*strategy.FormulaStrategy); ok {
+			overlays = append(overlays, visualization.Overlay{Name: "FORMULA", Series: fs.Calculate(candles), PriceOverlay: true})
+		}
+		for _, overlay := range overlays {
+			chart.AddOverlay(overlay)
+		}
 		chartFile := fmt.Sprintf("cmb_%s_candlestick.html", strategyName)
 		err = chart.PlotCandlestick(candles, tradesMap, chartFile)
 		if err != nil {
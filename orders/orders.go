@@ -4,6 +4,7 @@ import (
 	"errors"
 	"time"
 
+	"stock/clock"
 	"stock/common/types"
 )
 
@@ -15,6 +16,9 @@ var (
 type OrderManager struct {
 	orders map[string]*types.Order
 	broker types.Broker
+	clock  clock.Clock
+	ids    *clock.Sequence
+	notify func(order *types.Order)
 }
 
 // NewOrderManager 创建新的订单管理器
@@ -22,9 +26,26 @@ func NewOrderManager(broker types.Broker) *OrderManager {
 	return &OrderManager{
 		orders: make(map[string]*types.Order),
 		broker: broker,
+		clock:  clock.WallClock{},
+		ids:    clock.NewSequence(clock.OrderIDPrefix),
 	}
 }
 
+// SetClock 设置时钟，回测时注入模拟时钟使订单时间戳取自K线时间
+func (om *OrderManager) SetClock(c clock.Clock) {
+	om.clock = c
+}
+
+// SetIDSequence 设置订单编号生成器，同一回测的多个订单管理器共用一个生成器，订单编号不会重复
+func (om *OrderManager) SetIDSequence(ids *clock.Sequence) {
+	om.ids = ids
+}
+
+// SetEventHandler 设置订单事件回调，订单创建和状态变化时调用
+func (om *OrderManager) SetEventHandler(handler func(order *types.Order)) {
+	om.notify = handler
+}
+
 // CreateOrder 创建新订单
 func (om *OrderManager) CreateOrder(strategyID, symbol string, quantity float64, orderType types.OrderType) (*types.Order, error) {
 	if quantity <= 0 {
@@ -32,16 +53,17 @@ func (om *OrderManager) CreateOrder(strategyID, symbol string, quantity float64,
 	}
 
 	order := &types.Order{
-		ID:         generateOrderID(),
+		ID:         om.ids.Next(),
 		StrategyID: strategyID,
 		Symbol:     symbol,
 		Quantity:   quantity,
 		Type:       orderType,
 		Status:     types.OrderStatusNew,
-		CreatedAt:  time.Now(),
+		CreatedAt:  om.clock.Now(),
 	}
 
 	om.orders[order.ID] = order
+	om.changed(order)
 	return order, nil
 }
 
@@ -59,13 +81,13 @@ func (om *OrderManager) ExecuteOrder(orderID string) error {
 
 	// 调用broker执行订单
 	if err := om.broker.ExecuteOrder(order); err != nil {
-		if err := SetOrderStatus(order, types.OrderStatusRejected); err != nil {
+		if err := om.setStatus(order, types.OrderStatusRejected); err != nil {
 			return err
 		}
 		return err
 	}
 
-	return SetOrderStatus(order, types.OrderStatusFilled)
+	return om.setStatus(order, types.OrderStatusFilled)
 }
 
 // CancelOrder 取消订单
@@ -80,7 +102,22 @@ func (om *OrderManager) CancelOrder(orderID string) error {
 		return types.ErrOrderCannotBeCanceled
 	}
 
-	return SetOrderStatus(order, types.OrderStatusCanceled)
+	return om.setStatus(order, types.OrderStatusCanceled)
+}
+
+// setStatus 按时钟时间设置订单状态并通知事件回调
+func (om *OrderManager) setStatus(order *types.Order, status types.OrderStatus) error {
+	if err := SetOrderStatus(order, status, om.clock.Now()); err != nil {
+		return err
+	}
+	om.changed(order)
+	return nil
+}
+
+func (om *OrderManager) changed(order *types.Order) {
+	if om.notify != nil {
+		om.notify(order)
+	}
 }
 
 // GetOrder 获取订单详情
@@ -97,11 +134,6 @@ func (om *OrderManager) validateOrder(orderID string) (*types.Order, error) {
 	return order, nil
 }
 
-// generateOrderID 生成唯一订单ID
-func generateOrderID() string {
-	return "order_" + time.Now().Format("20060102150405")
-}
-
 // CanExecute 判断订单是否可以执行
 func CanExecute(o *types.Order) bool {
 	return o.Status == types.OrderStatusNew || o.Status == types.OrderStatusFilled
@@ -112,8 +144,8 @@ func CanCancel(o *types.Order) bool {
 	return o.Status == types.OrderStatusNew
 }
 
-// SetOrderStatus 设置订单状态
-func SetOrderStatus(o *types.Order, status types.OrderStatus) error {
+// SetOrderStatus 设置订单状态，timestamp为状态变更时间
+func SetOrderStatus(o *types.Order, status types.OrderStatus, timestamp time.Time) error {
 	// 验证状态转换
 	switch status {
 	case types.OrderStatusFilled:
@@ -131,6 +163,6 @@ func SetOrderStatus(o *types.Order, status types.OrderStatus) error {
 	}
 
 	o.Status = status
-	o.UpdatedAt = time.Now()
+	o.UpdatedAt = timestamp
 	return nil
 }
@@ -0,0 +1,226 @@
+package pairs
+
+import (
+	"fmt"
+	"math"
+)
+
+// ADFResult 增广迪基-富勒单位根检验结果
+type ADFResult struct {
+	Stat  float64 // 滞后一期水平项系数的t统计量
+	Gamma float64 // 滞后一期水平项系数，负值越大均值回复越快
+	Lags  int
+	N     int // 回归样本数
+}
+
+// ADF 对序列做带常数项的ADF检验：Δs(t) = c + γ·s(t-1) + Σφi·Δs(t-i) + e
+// lags小于0时按 12*(n/100)^(1/4) 自动确定滞后阶数
+func ADF(series []float64, lags int) (*ADFResult, error) {
+	n := len(series)
+	if lags < 0 {
+		lags = int(12 * math.Pow(float64(n)/100, 0.25))
+	}
+	rows := n - 1 - lags
+	cols := 2 + lags
+	if rows <= cols+1 {
+		return nil, fmt.Errorf("样本数%d不足以做%d阶滞后的ADF检验", n, lags)
+	}
+
+	diff := make([]float64, n-1)
+	for i := 1; i < n; i++ {
+		diff[i-1] = series[i] - series[i-1]
+	}
+
+	x := make([][]float64, rows)
+	y := make([]float64, rows)
+	for r := 0; r < rows; r++ {
+		t := r + lags + 1 // series中的位置
+		row := make([]float64, cols)
+		row[0] = 1
+		row[1] = series[t-1]
+		for i := 1; i <= lags; i++ {
+			row[1+i] = diff[t-1-i]
+		}
+		x[r] = row
+		y[r] = diff[t-1]
+	}
+
+	coef, se, err := regress(x, y)
+	if err != nil {
+		return nil, err
+	}
+	return &ADFResult{Stat: coef[1] / se[1], Gamma: coef[1], Lags: lags, N: rows}, nil
+}
+
+// CointResult Engle-Granger两步法协整检验结果
+type CointResult struct {
+	Alpha    float64
+	Beta     float64
+	ADF      *ADFResult
+	Critical map[float64]float64 // 显著性水平 -> 临界值
+	// Level 能够拒绝"不协整"原假设的最小显著性水平（0.01/0.05/0.1），不能拒绝时为1
+	Level    float64
+	HalfLife float64 // 价差均值回复半衰期（K线数），不回复时为+Inf
+}
+
+// Cointegrated 在5%显著性水平下是否协整
+func (r *CointResult) Cointegrated() bool {
+	return r.Level <= 0.05
+}
+
+// egCritical MacKinnon(2010)两变量带常数项协整检验临界值的响应面系数
+var egCritical = map[float64][3]float64{
+	0.01: {-3.89644, -10.9519, -22.527},
+	0.05: {-3.33613, -6.1101, -6.823},
+	0.10: {-3.04445, -4.2412, -2.720},
+}
+
+// CriticalValues 返回样本数为n时Engle-Granger检验的临界值
+func CriticalValues(n int) map[float64]float64 {
+	t := float64(n)
+	values := make(map[float64]float64, len(egCritical))
+	for level, b := range egCritical {
+		values[level] = b[0] + b[1]/t + b[2]/(t*t)
+	}
+	return values
+}
+
+// EngleGranger 对两条价格序列做Engle-Granger协整检验：先回归 y = α + β·x，再对残差做ADF检验
+func EngleGranger(ys, xs []float64, lags int) (*CointResult, error) {
+	if len(ys) != len(xs) {
+		return nil, fmt.Errorf("两条序列长度不一致: %d != %d", len(ys), len(xs))
+	}
+	alpha, beta := OLS(ys, xs)
+	if math.IsNaN(beta) {
+		return nil, fmt.Errorf("无法估计对冲比例")
+	}
+	residuals := make([]float64, len(ys))
+	for i := range ys {
+		residuals[i] = Spread(ys[i], xs[i], alpha, beta)
+	}
+	adf, err := ADF(residuals, lags)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CointResult{
+		Alpha:    alpha,
+		Beta:     beta,
+		ADF:      adf,
+		Critical: CriticalValues(adf.N),
+		Level:    1,
+		HalfLife: HalfLife(residuals),
+	}
+	for _, level := range []float64{0.01, 0.05, 0.10} {
+		if adf.Stat < result.Critical[level] {
+			result.Level = level
+			break
+		}
+	}
+	return result, nil
+}
+
+// HalfLife 由 Δs(t) = c + λ·s(t-1) 估计均值回复半衰期 -ln2/λ
+func HalfLife(spread []float64) float64 {
+	if len(spread) < 3 {
+		return math.NaN()
+	}
+	lagged := spread[:len(spread)-1]
+	diff := make([]float64, len(spread)-1)
+	for i := 1; i < len(spread); i++ {
+		diff[i-1] = spread[i] - spread[i-1]
+	}
+	_, lambda := OLS(diff, lagged)
+	if math.IsNaN(lambda) {
+		return math.NaN()
+	}
+	if lambda >= 0 {
+		return math.Inf(1)
+	}
+	return -math.Ln2 / lambda
+}
+
+// regress 多元最小二乘，返回系数及其标准误
+func regress(x [][]float64, y []float64) (coef, se []float64, err error) {
+	rows, cols := len(x), len(x[0])
+	xtx := make([][]float64, cols)
+	xty := make([]float64, cols)
+	for i := range xtx {
+		xtx[i] = make([]float64, cols)
+	}
+	for r := 0; r < rows; r++ {
+		for i := 0; i < cols; i++ {
+			xty[i] += x[r][i] * y[r]
+			for j := 0; j < cols; j++ {
+				xtx[i][j] += x[r][i] * x[r][j]
+			}
+		}
+	}
+
+	inv, ok := invert(xtx)
+	if !ok {
+		return nil, nil, fmt.Errorf("回归矩阵奇异")
+	}
+	coef = make([]float64, cols)
+	for i := 0; i < cols; i++ {
+		for j := 0; j < cols; j++ {
+			coef[i] += inv[i][j] * xty[j]
+		}
+	}
+
+	sse := 0.0
+	for r := 0; r < rows; r++ {
+		fitted := 0.0
+		for i := 0; i < cols; i++ {
+			fitted += x[r][i] * coef[i]
+		}
+		sse += (y[r] - fitted) * (y[r] - fitted)
+	}
+	sigma2 := sse / float64(rows-cols)
+	se = make([]float64, cols)
+	for i := range se {
+		se[i] = math.Sqrt(sigma2 * inv[i][i])
+	}
+	return coef, se, nil
+}
+
+// invert 高斯-约当消元求逆矩阵
+func invert(m [][]float64) ([][]float64, bool) {
+	n := len(m)
+	a := make([][]float64, n)
+	for i := range m {
+		a[i] = make([]float64, 2*n)
+		copy(a[i], m[i])
+		a[i][n+i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		p := a[col][col]
+		for j := range a[col] {
+			a[col][j] /= p
+		}
+		for r := 0; r < n; r++ {
+			if r == col || a[r][col] == 0 {
+				continue
+			}
+			f := a[r][col]
+			for j := range a[r] {
+				a[r][j] -= f * a[col][j]
+			}
+		}
+	}
+	inv := make([][]float64, n)
+	for i := range a {
+		inv[i] = a[i][n:]
+	}
+	return inv, true
+}
@@ -0,0 +1,171 @@
+// Package pairs 配对交易与统计套利工具：滚动对冲比例、价差Z值和协整检验
+package pairs
+
+import (
+	"math"
+	"stock/stats"
+)
+
+// HedgeEstimator 对冲比例估计器，按时间顺序输入两条腿的价格，估计 y = Alpha + Beta*x
+type HedgeEstimator interface {
+	Update(y, x float64)
+	// Ready 数据是否足够给出估计值
+	Ready() bool
+	Beta() float64
+	Alpha() float64
+	Name() string
+}
+
+// RollingOLS 最近window个样本的最小二乘对冲比例
+type RollingOLS struct {
+	window int
+	ys, xs []float64
+	alpha  float64
+	beta   float64
+}
+
+func NewRollingOLS(window int) *RollingOLS {
+	if window < 3 {
+		window = 3
+	}
+	return &RollingOLS{window: window, alpha: math.NaN(), beta: math.NaN()}
+}
+
+func (h *RollingOLS) Name() string {
+	return "OLS"
+}
+
+func (h *RollingOLS) Update(y, x float64) {
+	h.ys = append(h.ys, y)
+	h.xs = append(h.xs, x)
+	if len(h.ys) > h.window {
+		h.ys = h.ys[1:]
+		h.xs = h.xs[1:]
+	}
+	if len(h.ys) == h.window {
+		h.alpha, h.beta = OLS(h.ys, h.xs)
+	}
+}
+
+func (h *RollingOLS) Ready() bool {
+	return len(h.ys) == h.window && !math.IsNaN(h.beta)
+}
+
+func (h *RollingOLS) Alpha() float64 {
+	return h.alpha
+}
+
+func (h *RollingOLS) Beta() float64 {
+	return h.beta
+}
+
+// Kalman 卡尔曼滤波动态对冲比例，状态为[Beta, Alpha]的随机游走
+//
+// delta控制状态变化速度，越大对冲比例调整越快；obsVar为观测噪声方差。
+type Kalman struct {
+	delta  float64
+	obsVar float64
+	state  [2]float64
+	cov    [2][2]float64
+	count  int
+	warmup int
+}
+
+// NewKalman 创建卡尔曼滤波估计器，常用参数 delta=1e-4, obsVar=1e-3，前warmup个样本视为预热
+func NewKalman(delta, obsVar float64, warmup int) *Kalman {
+	return &Kalman{delta: delta, obsVar: obsVar, warmup: warmup}
+}
+
+func (h *Kalman) Name() string {
+	return "Kalman"
+}
+
+func (h *Kalman) Update(y, x float64) {
+	// 预测：协方差加上状态噪声
+	q := h.delta / (1 - h.delta)
+	r := h.cov
+	r[0][0] += q
+	r[1][1] += q
+
+	// 观测向量为[x, 1]
+	obs := [2]float64{x, 1}
+	forecast := obs[0]*h.state[0] + obs[1]*h.state[1]
+	rObs := [2]float64{r[0][0]*obs[0] + r[0][1]*obs[1], r[1][0]*obs[0] + r[1][1]*obs[1]}
+	variance := obs[0]*rObs[0] + obs[1]*rObs[1] + h.obsVar
+	gain := [2]float64{rObs[0] / variance, rObs[1] / variance}
+
+	err := y - forecast
+	h.state[0] += gain[0] * err
+	h.state[1] += gain[1] * err
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 2; j++ {
+			h.cov[i][j] = r[i][j] - gain[i]*rObs[j]
+		}
+	}
+	h.count++
+}
+
+func (h *Kalman) Ready() bool {
+	return h.count > h.warmup
+}
+
+func (h *Kalman) Alpha() float64 {
+	return h.state[1]
+}
+
+func (h *Kalman) Beta() float64 {
+	return h.state[0]
+}
+
+// ZScore 滚动Z值：(v - 均值) / 标准差
+type ZScore struct {
+	window int
+	values []float64
+}
+
+func NewZScore(window int) *ZScore {
+	if window < 2 {
+		window = 2
+	}
+	return &ZScore{window: window}
+}
+
+// Update 加入新值并返回其Z值，样本不足或标准差为0时返回NaN
+func (z *ZScore) Update(v float64) float64 {
+	z.values = append(z.values, v)
+	if len(z.values) > z.window {
+		z.values = z.values[1:]
+	}
+	if len(z.values) < z.window {
+		return math.NaN()
+	}
+	m := stats.Mean(z.values)
+	s := stats.StdDev(z.values)
+	if s == 0 || math.IsNaN(s) {
+		return math.NaN()
+	}
+	return (v - m) / s
+}
+
+// Spread 价差 y - Alpha - Beta*x
+func Spread(y, x, alpha, beta float64) float64 {
+	return y - alpha - beta*x
+}
+
+// OLS 一元最小二乘回归 y = alpha + beta*x
+func OLS(ys, xs []float64) (alpha, beta float64) {
+	if len(ys) != len(xs) || len(ys) < 2 {
+		return math.NaN(), math.NaN()
+	}
+	mx, my := stats.Mean(xs), stats.Mean(ys)
+	cov, varX := 0.0, 0.0
+	for i := range xs {
+		cov += (xs[i] - mx) * (ys[i] - my)
+		varX += (xs[i] - mx) * (xs[i] - mx)
+	}
+	if varX == 0 {
+		return math.NaN(), math.NaN()
+	}
+	beta = cov / varX
+	return my - beta*mx, beta
+}
@@ -0,0 +1,210 @@
+package pairs
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func closeTo(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+func TestOLS(t *testing.T) {
+	// 教科书例子：x=1..5，y=2,4,5,4,5，β=0.6，α=2.2
+	alpha, beta := OLS([]float64{2, 4, 5, 4, 5}, []float64{1, 2, 3, 4, 5})
+	if !closeTo(alpha, 2.2, 1e-12) || !closeTo(beta, 0.6, 1e-12) {
+		t.Errorf("α=%v β=%v，期望2.2 0.6", alpha, beta)
+	}
+	if _, beta := OLS([]float64{1, 2}, []float64{3, 3}); !math.IsNaN(beta) {
+		t.Errorf("x方差为0时β=%v，期望NaN", beta)
+	}
+	if _, beta := OLS([]float64{1}, []float64{1}); !math.IsNaN(beta) {
+		t.Errorf("样本不足时β=%v，期望NaN", beta)
+	}
+
+	h := NewRollingOLS(3)
+	for i, x := range []float64{1, 2, 3, 4} {
+		if i == 2 && h.Ready() {
+			t.Fatal("样本不足窗口时不应就绪")
+		}
+		// 前两个样本偏离 y=1+2x，滚出窗口后不再影响估计
+		y := 1 + 2*x
+		if i == 0 {
+			y = 100
+		}
+		h.Update(y, x)
+	}
+	if !h.Ready() || !closeTo(h.Alpha(), 1, 1e-12) || !closeTo(h.Beta(), 2, 1e-12) {
+		t.Errorf("滚动OLS α=%v β=%v，期望1 2", h.Alpha(), h.Beta())
+	}
+}
+
+func TestKalman(t *testing.T) {
+	// delta=0.5时状态噪声q=1，从零状态开始第一步：
+	// 观测方差 q·x²+q+obsVar=3，增益[1/3,1/3]，状态为增益乘以y
+	k := NewKalman(0.5, 1, 1)
+	k.Update(3, 1)
+	if !closeTo(k.Beta(), 1, 1e-12) || !closeTo(k.Alpha(), 1, 1e-12) {
+		t.Errorf("第一步β=%v α=%v，期望1 1", k.Beta(), k.Alpha())
+	}
+	if k.Ready() {
+		t.Error("预热期内不应就绪")
+	}
+
+	// 无噪声的 y=1+2x 上收敛到真实参数
+	k = NewKalman(1e-3, 1e-3, 10)
+	for i := 0; i < 500; i++ {
+		x := 5 + 4*math.Sin(float64(i)/5)
+		k.Update(1+2*x, x)
+	}
+	if !k.Ready() || !closeTo(k.Beta(), 2, 1e-3) || !closeTo(k.Alpha(), 1, 1e-2) {
+		t.Errorf("收敛后β=%v α=%v，期望2 1", k.Beta(), k.Alpha())
+	}
+}
+
+func TestZScore(t *testing.T) {
+	z := NewZScore(3)
+	if v := z.Update(1); !math.IsNaN(v) {
+		t.Errorf("样本不足时Z值%v", v)
+	}
+	z.Update(2)
+	// 1,2,3均值2、样本标准差1
+	if v := z.Update(3); !closeTo(v, 1, 1e-12) {
+		t.Errorf("Z值%v，期望1", v)
+	}
+	flat := NewZScore(2)
+	flat.Update(5)
+	if v := flat.Update(5); !math.IsNaN(v) {
+		t.Errorf("标准差为0时Z值%v", v)
+	}
+}
+
+// ar1 生成 s(t) = phi·s(t-1) + e(t) 的序列
+func ar1(rng *rand.Rand, n int, phi float64) []float64 {
+	s := make([]float64, n)
+	for i := 1; i < n; i++ {
+		s[i] = phi*s[i-1] + rng.NormFloat64()
+	}
+	return s
+}
+
+func TestADF(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	series := ar1(rng, 200, 0.5)
+
+	// 0阶滞后时回归退化为一元回归 Δs = c + γ·s(t-1)，与OLS及其标准误一致
+	r, err := ADF(series, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lagged := series[:len(series)-1]
+	diff := make([]float64, len(lagged))
+	for i := range diff {
+		diff[i] = series[i+1] - series[i]
+	}
+	c, gamma := OLS(diff, lagged)
+	sse, sxx, mx := 0.0, 0.0, 0.0
+	for _, x := range lagged {
+		mx += x / float64(len(lagged))
+	}
+	for i, x := range lagged {
+		e := diff[i] - c - gamma*x
+		sse += e * e
+		sxx += (x - mx) * (x - mx)
+	}
+	stat := gamma / math.Sqrt(sse/float64(len(diff)-2)/sxx)
+	if r.N != 199 || r.Lags != 0 || !closeTo(r.Gamma, gamma, 1e-9) || !closeTo(r.Stat, stat, 1e-9) {
+		t.Errorf("ADF %+v，期望γ=%v t=%v", r, gamma, stat)
+	}
+	// phi=0.5时γ约为-0.5，远低于5%临界值约-2.88
+	if r.Gamma > -0.3 || r.Stat > -5 {
+		t.Errorf("平稳序列γ=%v t=%v", r.Gamma, r.Stat)
+	}
+
+	// 随机游走不能拒绝单位根
+	walk, err := ADF(ar1(rng, 200, 1), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if walk.Stat < -2.88 {
+		t.Errorf("随机游走t=%v，不应拒绝单位根", walk.Stat)
+	}
+
+	// 自动滞后阶数 12*(100/100)^(1/4) = 12
+	if r, err := ADF(ar1(rng, 100, 0.5), -1); err != nil || r.Lags != 12 {
+		t.Errorf("自动滞后阶数%v: %v", r, err)
+	}
+	if _, err := ADF([]float64{1, 2, 3, 4}, 1); err == nil {
+		t.Error("样本不足时应返回错误")
+	}
+}
+
+func TestHalfLife(t *testing.T) {
+	// s(t) = 0.5·s(t-1)：λ=-0.5，半衰期ln2/0.5
+	spread := []float64{16, 8, 4, 2, 1, 0.5}
+	if got := HalfLife(spread); !closeTo(got, math.Ln2/0.5, 1e-9) {
+		t.Errorf("半衰期%v，期望%v", got, math.Ln2/0.5)
+	}
+	if got := HalfLife([]float64{1, 2, 4, 8}); !math.IsInf(got, 1) {
+		t.Errorf("发散序列半衰期%v，期望+Inf", got)
+	}
+}
+
+func TestCriticalValues(t *testing.T) {
+	// MacKinnon(2010)表：两变量带常数项，样本数100时
+	want := map[float64]float64{
+		0.01: -3.89644 - 10.9519/100 - 22.527/1e4,
+		0.05: -3.33613 - 6.1101/100 - 6.823/1e4,
+		0.10: -3.04445 - 4.2412/100 - 2.720/1e4,
+	}
+	got := CriticalValues(100)
+	for level, v := range want {
+		if !closeTo(got[level], v, 1e-12) {
+			t.Errorf("%v临界值%v，期望%v", level, got[level], v)
+		}
+	}
+	if !closeTo(got[0.05], -3.398, 1e-3) {
+		t.Errorf("5%%临界值%v，期望约-3.398", got[0.05])
+	}
+}
+
+func TestEngleGranger(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	n := 500
+	xs := make([]float64, n)
+	for i := range xs {
+		xs[i] = 50
+		if i > 0 {
+			xs[i] = xs[i-1] + rng.NormFloat64()
+		}
+	}
+	noise := ar1(rng, n, 0.3)
+	ys := make([]float64, n)
+	for i := range ys {
+		ys[i] = 1 + 2*xs[i] + noise[i]
+	}
+
+	r, err := EngleGranger(ys, xs, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Cointegrated() || r.Level != 0.01 || !closeTo(r.Beta, 2, 0.05) {
+		t.Errorf("协整序列检验结果%+v", r)
+	}
+	if r.HalfLife <= 0 || r.HalfLife > 2 {
+		t.Errorf("phi=0.3的价差半衰期%v，期望约1", r.HalfLife)
+	}
+
+	// 两条独立随机游走不协整
+	walk := make([]float64, n)
+	for i := 1; i < n; i++ {
+		walk[i] = walk[i-1] + rng.NormFloat64()
+	}
+	if r, err := EngleGranger(walk, xs, 1); err != nil || r.Cointegrated() {
+		t.Errorf("独立随机游走检验结果%+v: %v", r, err)
+	}
+	if _, err := EngleGranger(ys, xs[1:], 1); err == nil {
+		t.Error("长度不一致时应返回错误")
+	}
+}
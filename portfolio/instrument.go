@@ -0,0 +1,250 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"stock/common/types"
+	"stock/instrument"
+	"stock/risk"
+	"time"
+)
+
+// instrumentBroker 提供品种注册表的经纪商
+type instrumentBroker interface {
+	Instruments() *instrument.Registry
+}
+
+// symbolCostBroker 按品种计算交易成本的经纪商
+type symbolCostBroker interface {
+	CalculateSymbolTradeCost(symbol string, action types.Action, price float64, quantity float64) float64
+}
+
+// Instrument 返回品种信息，经纪商没有品种注册表时使用默认注册表
+func (p *Portfolio) Instrument(symbol string) *instrument.Instrument {
+	if b, ok := p.broker.(instrumentBroker); ok {
+		if registry := b.Instruments(); registry != nil {
+			return registry.Lookup(symbol)
+		}
+	}
+	return instrument.Default().Lookup(symbol)
+}
+
+// SymbolTradeCost 按品种计算交易费用，期货按合约价值计费
+func (p *Portfolio) SymbolTradeCost(symbol string, action types.Action, price float64, quantity float64) float64 {
+	if b, ok := p.broker.(symbolCostBroker); ok {
+		return b.CalculateSymbolTradeCost(symbol, action, price, quantity)
+	}
+	return p.TradeCost(action, price, quantity)
+}
+
+// checkPriceLimit 以上一交易日收盘价计算涨跌停，涨停价不能买入，跌停价不能卖出
+func (p *Portfolio) checkPriceLimit(symbol string, action types.Action, price float64) error {
+	prev, ok := p.prevPrices[symbol]
+	if !ok || p.liquidating {
+		return nil
+	}
+	inst := p.Instrument(symbol)
+	up, down := inst.LimitPrices(prev)
+	tolerance := inst.TickSize / 2
+	switch {
+	case action == types.ActionBuy && price >= up-tolerance:
+		return fmt.Errorf("%s涨停%.2f无法买入: %w", symbol, up, types.ErrPriceLimit)
+	case action == types.ActionSell && price <= down+tolerance:
+		return fmt.Errorf("%s跌停%.2f无法卖出: %w", symbol, down, types.ErrPriceLimit)
+	}
+	return nil
+}
+
+// checkSellable T+1品种当日买入的数量当日不能卖出，卖出后的持仓不能低于当日买入数量
+func (p *Portfolio) checkSellable(symbol string, timestamp time.Time, quantity float64) error {
+	if p.Instrument(symbol).T0 || !sameDay(p.boughtOn[symbol], timestamp) {
+		return nil
+	}
+	locked := math.Min(p.boughtToday[symbol], math.Max(p.positions[symbol], 0))
+	if locked > 0 && p.positions[symbol]-quantity < locked {
+		return fmt.Errorf("%s当日买入的%.0f需T+1卖出: %w", symbol, locked, types.ErrNotSellable)
+	}
+	return nil
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// FuturesMargin 期货持仓按最新价占用的保证金
+func (p *Portfolio) FuturesMargin() float64 {
+	total := 0.0
+	for _, symbol := range p.heldSymbols() {
+		if inst := p.Instrument(symbol); inst.IsFuture() {
+			total += inst.Margin(p.MarketPrice(symbol), p.positions[symbol])
+		}
+	}
+	return total
+}
+
+// freeCash 扣除期货保证金后可用于买入股票的现金
+func (p *Portfolio) freeCash() float64 {
+	return p.cash - p.FuturesMargin()
+}
+
+// futureOutcome 期货成交后的持仓、结算基准价和平仓盈亏
+func futureOutcome(inst *instrument.Instrument, position, basis, signed, price float64) (after, newBasis, realized float64) {
+	after = position + signed
+	switch {
+	case after == 0:
+		newBasis = 0
+	case position == 0 || position*after < 0:
+		newBasis = price
+	case position*signed > 0:
+		newBasis = (basis*math.Abs(position) + price*math.Abs(signed)) / math.Abs(after)
+	default:
+		newBasis = basis
+	}
+	if position*signed < 0 {
+		closed := math.Min(math.Abs(position), math.Abs(signed))
+		if position < 0 {
+			closed = -closed
+		}
+		realized = (price - basis) * closed * inst.Multiplier
+	}
+	return after, newBasis, realized
+}
+
+// canTradeFuture 期货成交后现金足以覆盖全部期货保证金，减仓总是允许
+func (p *Portfolio) canTradeFuture(symbol string, action types.Action, price, quantity float64) bool {
+	inst := p.Instrument(symbol)
+	signed := quantity
+	if action == types.ActionSell {
+		signed = -quantity
+	}
+	position := p.positions[symbol]
+	after, _, realized := futureOutcome(inst, position, p.futureBasis[symbol], signed, price)
+	if math.Abs(after) <= math.Abs(position) {
+		return true
+	}
+	cash := p.cash + realized - p.SymbolTradeCost(symbol, action, price, quantity)
+	required := p.FuturesMargin() - inst.Margin(p.MarketPrice(symbol), position) + inst.Margin(price, after)
+	return cash >= required
+}
+
+// tradeFuture 期货成交：按结算基准价计算平仓盈亏，现金只用于缴纳保证金和费用
+func (p *Portfolio) tradeFuture(inst *instrument.Instrument, symbol string, timestamp time.Time, action types.Action, price, quantity float64) error {
+	if !p.liquidating && !p.canTradeFuture(symbol, action, price, quantity) {
+		return types.ErrInsufficientFunds
+	}
+	orderType, signed := types.OrderTypeBuy, quantity
+	if action == types.ActionSell {
+		orderType, signed = types.OrderTypeSell, -quantity
+	}
+	order, err := p.submit(symbol, quantity, orderType)
+	if err != nil || order.Status != types.OrderStatusFilled {
+		return err
+	}
+
+	position := p.positions[symbol]
+	after, basis, realized := futureOutcome(inst, position, p.futureBasis[symbol], signed, price)
+	fee := p.SymbolTradeCost(symbol, action, price, quantity)
+	p.cash += realized - fee
+	p.positions[symbol] = after
+	p.positionSizes[symbol] += signed
+	switch {
+	case after == 0:
+		delete(p.futureBasis, symbol)
+	case position == 0 || position*after < 0:
+		p.futureBasis[symbol] = basis
+		p.positionPrices[symbol] = price
+	default:
+		p.futureBasis[symbol] = basis
+		if position*signed > 0 {
+			p.positionPrices[symbol] = (p.positionPrices[symbol]*math.Abs(position) + price*quantity) / math.Abs(after)
+		}
+	}
+	p.record(types.Trade{
+		Timestamp: timestamp,
+		Symbol:    symbol,
+		Price:     price,
+		Quantity:  quantity,
+		Type:      action,
+		Fee:       fee,
+		Strategy:  "manual",
+		OrderID:   order.ID,
+	})
+	return nil
+}
+
+// Settle 每个交易日收盘后结算：期货按收盘价逐日盯市，现金不足以覆盖期货保证金时强制平仓，
+// 然后结算融资融券账户
+func (p *Portfolio) Settle(timestamp time.Time) []risk.Event {
+	var events []risk.Event
+	for _, symbol := range p.heldSymbols() {
+		inst := p.Instrument(symbol)
+		if !inst.IsFuture() {
+			continue
+		}
+		qty := p.positions[symbol]
+		price := p.MarketPrice(symbol)
+		p.cash += (price - p.futureBasis[symbol]) * qty * inst.Multiplier
+		p.futureBasis[symbol] = price
+	}
+	if required := p.FuturesMargin(); p.cash < required {
+		events = append(events, risk.Event{
+			Timestamp: timestamp,
+			Type:      risk.EventMarginCall,
+			Rule:      "futures_margin",
+			Detail:    fmt.Sprintf("现金%.2f不足期货保证金%.2f", p.cash, required),
+		})
+		events = append(events, p.liquidateFutures(timestamp)...)
+	}
+	return append(events, p.SettleMargin(timestamp)...)
+}
+
+// liquidateFutures 按合约价值从大到小平掉期货持仓，直到现金足以覆盖剩余保证金
+func (p *Portfolio) liquidateFutures(timestamp time.Time) []risk.Event {
+	var symbols []string
+	for _, symbol := range p.heldSymbols() {
+		if p.Instrument(symbol).IsFuture() {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		vi := math.Abs(p.GetSymbolValue(symbols[i]))
+		vj := math.Abs(p.GetSymbolValue(symbols[j]))
+		if vi != vj {
+			return vi > vj
+		}
+		return symbols[i] < symbols[j]
+	})
+
+	p.liquidating = true
+	defer func() { p.liquidating = false }()
+
+	var events []risk.Event
+	for _, symbol := range symbols {
+		if p.cash >= p.FuturesMargin() {
+			break
+		}
+		qty := p.positions[symbol]
+		price := p.MarketPrice(symbol)
+		var err error
+		if qty > 0 {
+			err = p.sell(symbol, timestamp, price, qty)
+		} else {
+			err = p.buy(symbol, timestamp, price, -qty)
+		}
+		detail := fmt.Sprintf("强制平仓%.0f手 @ %.2f", math.Abs(qty), price)
+		if err != nil {
+			detail += ", 失败: " + err.Error()
+		}
+		events = append(events, risk.Event{
+			Timestamp: timestamp,
+			Type:      risk.EventLiquidation,
+			Symbol:    symbol,
+			Rule:      "futures_margin",
+			Detail:    detail,
+		})
+	}
+	return events
+}
@@ -0,0 +1,263 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"stock/broker"
+	"stock/common/types"
+	"stock/risk"
+	"time"
+)
+
+// marginBroker 支持融资融券的经纪商
+type marginBroker interface {
+	Margin() *broker.MarginConfig
+	UpdateMargin(liabilities float64)
+}
+
+// Margin 返回融资融券参数，普通账户返回nil
+func (p *Portfolio) Margin() *broker.MarginConfig {
+	if b, ok := p.broker.(marginBroker); ok {
+		return b.Margin()
+	}
+	return nil
+}
+
+// Debt 融资负债
+func (p *Portfolio) Debt() float64 {
+	return p.debt
+}
+
+// AccruedInterest 已计提未支付的融资利息和融券费用
+func (p *Portfolio) AccruedInterest() float64 {
+	return p.interest
+}
+
+// InterestPaid 累计支付的融资利息和融券费用
+func (p *Portfolio) InterestPaid() float64 {
+	return p.interestPaid
+}
+
+// exposure 股票多头市值和空头市值（正数），不含期货
+func (p *Portfolio) exposure() (long, short float64) {
+	for _, symbol := range p.heldSymbols() {
+		if p.Instrument(symbol).IsFuture() {
+			continue
+		}
+		qty := p.positions[symbol]
+		value := qty * p.MarketPrice(symbol)
+		if qty > 0 {
+			long += value
+		} else {
+			short -= value
+		}
+	}
+	return long, short
+}
+
+// Liabilities 融资融券负债：融资负债、融券市值和未付利息
+func (p *Portfolio) Liabilities() float64 {
+	_, short := p.exposure()
+	return p.debt + p.interest + short
+}
+
+// MaintenanceRatio 维持担保比例 = (现金 − 期货保证金 + 多头市值) / 负债，没有负债时为+Inf
+func (p *Portfolio) MaintenanceRatio() float64 {
+	liabilities := p.Liabilities()
+	if liabilities <= 0 {
+		return math.Inf(1)
+	}
+	long, _ := p.exposure()
+	return (p.freeCash() + long) / liabilities
+}
+
+// Leverage 杠杆率 = (多头市值 + 空头市值 + 期货合约价值) / 权益
+func (p *Portfolio) Leverage() float64 {
+	long, short := p.exposure()
+	for _, symbol := range p.heldSymbols() {
+		if inst := p.Instrument(symbol); inst.IsFuture() {
+			long += math.Abs(inst.Notional(p.MarketPrice(symbol), p.positions[symbol]))
+		}
+	}
+	equity := p.GetValue()
+	if equity <= 0 {
+		return math.Inf(1)
+	}
+	return (long + short) / equity
+}
+
+// AvailableMargin 可用保证金，普通账户为扣除期货保证金后的现金
+func (p *Portfolio) AvailableMargin() float64 {
+	return p.availableMargin(p.freeCash(), p.debt, "", 0)
+}
+
+// availableMargin 按假设的可用现金、融资负债和symbol持仓计算可用保证金：
+// 现金 + Σ多头市值×折算率 − 融资负债×(1+融资保证金比例) − 融券市值×(1+融券保证金比例) − 未付利息
+func (p *Portfolio) availableMargin(cash, debt float64, symbol string, quantity float64) float64 {
+	m := p.Margin()
+	if m == nil {
+		return cash
+	}
+	available := cash - debt*(1+m.FinancingMargin) - p.interest
+	value := func(s string, qty float64) {
+		v := qty * p.MarketPrice(s)
+		if qty > 0 {
+			available += v * m.Haircut(s)
+		} else {
+			available += v * (1 + m.ShortMargin)
+		}
+	}
+	for _, s := range p.heldSymbols() {
+		if s != symbol && !p.Instrument(s).IsFuture() {
+			value(s, p.positions[s])
+		}
+	}
+	if symbol != "" {
+		value(symbol, quantity)
+	}
+	return available
+}
+
+// canBuy 现金足够或融资后可用保证金不为负时可以买入，期货检查保证金
+func (p *Portfolio) canBuy(symbol string, price, quantity float64) bool {
+	if p.Instrument(symbol).IsFuture() {
+		return p.canTradeFuture(symbol, types.ActionBuy, price, quantity)
+	}
+	cash := p.freeCash()
+	cost := price*quantity + p.SymbolTradeCost(symbol, types.ActionBuy, price, quantity)
+	if cost <= cash {
+		return true
+	}
+	if p.Margin() == nil {
+		return false
+	}
+	debt := p.debt + cost - math.Max(cash, 0)
+	return p.availableMargin(math.Min(cash, 0), debt, symbol, p.positions[symbol]+quantity) >= 0
+}
+
+// canShort 融券卖出后可用保证金不为负
+func (p *Portfolio) canShort(symbol string, price, quantity float64) bool {
+	cash := p.freeCash() + price*quantity - p.SymbolTradeCost(symbol, types.ActionSell, price, quantity)
+	return p.availableMargin(cash, p.debt, symbol, p.positions[symbol]-quantity) >= 0
+}
+
+// affordable 返回不超过quantity、按lot取整后可以买入的最大数量
+func (p *Portfolio) affordable(symbol string, price, quantity, lot float64) float64 {
+	lo, hi := 0.0, math.Floor(quantity/lot)
+	if hi <= 0 || p.canBuy(symbol, price, hi*lot) {
+		return hi * lot
+	}
+	for hi-lo > 1 {
+		mid := math.Floor((lo + hi) / 2)
+		if p.canBuy(symbol, price, mid*lot) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo * lot
+}
+
+// repay 现金优先偿还利息，再偿还融资负债
+func (p *Portfolio) repay() {
+	if p.cash <= 0 {
+		return
+	}
+	paid := math.Min(p.cash, p.interest)
+	p.cash -= paid
+	p.interest -= paid
+	p.interestPaid += paid
+
+	repaid := math.Min(p.cash, p.debt)
+	p.cash -= repaid
+	p.debt -= repaid
+}
+
+// SettleMargin 每个交易日收盘后结算融资融券账户：按自然日计提利息，
+// 检查维持担保比例，低于下限时发出追保通知，追保期满仍不足则强制平仓
+func (p *Portfolio) SettleMargin(timestamp time.Time) []risk.Event {
+	m := p.Margin()
+	if m == nil {
+		return nil
+	}
+
+	// 按上次结算时的负债计提期间利息
+	if !p.lastSettle.IsZero() {
+		if days := timestamp.Sub(p.lastSettle).Hours() / 24; days > 0 && m.DaysPerYear > 0 {
+			p.interest += (p.settledDebt*m.FinancingRate + p.settledShort*m.LendingRate) * days / m.DaysPerYear
+		}
+	}
+	p.repay()
+
+	var events []risk.Event
+	ratio := p.MaintenanceRatio()
+	if ratio >= m.MaintenanceRatio {
+		p.callDays = 0
+	} else {
+		if p.callDays == 0 {
+			events = append(events, risk.Event{
+				Timestamp: timestamp,
+				Type:      risk.EventMarginCall,
+				Rule:      "maintenance_ratio",
+				Detail:    fmt.Sprintf("维持担保比例%.2f%%低于%.2f%%", ratio*100, m.MaintenanceRatio*100),
+			})
+		}
+		p.callDays++
+		if p.callDays > m.GraceDays {
+			events = append(events, p.liquidate(timestamp, m)...)
+			p.callDays = 0
+		}
+	}
+
+	p.lastSettle = timestamp
+	_, p.settledShort = p.exposure()
+	p.settledDebt = p.debt
+	if b, ok := p.broker.(marginBroker); ok {
+		b.UpdateMargin(p.Liabilities())
+	}
+	return events
+}
+
+// liquidate 按市值从大到小逐个平仓，直到维持担保比例恢复到RestoreRatio
+func (p *Portfolio) liquidate(timestamp time.Time, m *broker.MarginConfig) []risk.Event {
+	symbols := p.heldSymbols()
+	sort.Slice(symbols, func(i, j int) bool {
+		vi := math.Abs(p.GetSymbolValue(symbols[i]))
+		vj := math.Abs(p.GetSymbolValue(symbols[j]))
+		if vi != vj {
+			return vi > vj
+		}
+		return symbols[i] < symbols[j]
+	})
+
+	p.liquidating = true
+	defer func() { p.liquidating = false }()
+
+	var events []risk.Event
+	for _, symbol := range symbols {
+		if p.MaintenanceRatio() >= m.RestoreRatio {
+			break
+		}
+		qty := p.positions[symbol]
+		price := p.MarketPrice(symbol)
+		var err error
+		if qty > 0 {
+			err = p.sell(symbol, timestamp, price, qty)
+		} else {
+			err = p.buy(symbol, timestamp, price, -qty)
+		}
+		detail := fmt.Sprintf("强制平仓%.0f股 @ %.2f", math.Abs(qty), price)
+		if err != nil {
+			detail += ", 失败: " + err.Error()
+		}
+		events = append(events, risk.Event{
+			Timestamp: timestamp,
+			Type:      risk.EventLiquidation,
+			Symbol:    symbol,
+			Rule:      "maintenance_ratio",
+			Detail:    detail,
+		})
+	}
+	return events
+}
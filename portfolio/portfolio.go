@@ -1,9 +1,16 @@
 package portfolio
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
 	"stock/broker"
+	"stock/clock"
 	"stock/common/types"
+	"stock/instrument"
 	"stock/orders"
+	"stock/risk"
 	"time"
 )
 
@@ -12,10 +19,35 @@ type Portfolio struct {
 	initialCash    float64
 	positions      map[string]float64 // 各股票持仓数量
 	positionPrices map[string]float64 // 各股票持仓成本价
+	marketPrices   map[string]float64 // 各股票最新市价，用于按市值估值
 	trades         []types.Trade
 	positionSizes  map[string]float64
+	lots           map[string][]holdingLot // 股票多头持仓按买入时间先进先出，用于计算红利税
 	broker         broker.Broker
 	orderManager   *orders.OrderManager
+	riskChecker    RiskChecker
+	tradeIDs       *clock.Sequence // 成交编号按记录顺序递增
+
+	// 融资融券
+	debt         float64 // 融资负债
+	interest     float64 // 已计提未支付的利息和融券费用
+	interestPaid float64
+	lastSettle   time.Time
+	settledDebt  float64 // 上次结算时的融资负债，用于计提利息
+	settledShort float64 // 上次结算时的融券市值
+	callDays     int     // 维持担保比例持续低于下限的交易日数
+	liquidating  bool    // 强制平仓中，不做保证金检查
+
+	prevPrices  map[string]float64 // 上一交易日收盘价，用于计算涨跌停
+	futureBasis map[string]float64 // 期货结算基准价，建仓时为成交价，每日结算后为结算价
+	boughtOn    map[string]time.Time
+	boughtToday map[string]float64 // T+1品种boughtOn当日买入的数量，当日不能卖出
+	reversing   bool               // 撤回组合订单中，成交记为rollback策略
+}
+
+// RiskChecker 下单前的风控检查，返回错误时订单不会提交
+type RiskChecker interface {
+	CheckOrder(account risk.Account, order risk.Order) error
 }
 
 func NewPortfolio(initialCash float64, broker broker.Broker, orderManager *orders.OrderManager) *Portfolio {
@@ -24,13 +56,62 @@ func NewPortfolio(initialCash float64, broker broker.Broker, orderManager *order
 		initialCash:    initialCash,
 		positions:      make(map[string]float64),
 		positionPrices: make(map[string]float64),
+		marketPrices:   make(map[string]float64),
 		trades:         make([]types.Trade, 0),
 		positionSizes:  make(map[string]float64),
+		lots:           make(map[string][]holdingLot),
+		prevPrices:     make(map[string]float64),
+		futureBasis:    make(map[string]float64),
+		boughtOn:       make(map[string]time.Time),
+		boughtToday:    make(map[string]float64),
 		broker:         broker,
 		orderManager:   orderManager,
+		tradeIDs:       clock.NewSequence(clock.TradeIDPrefix),
 	}
 }
 
+// SetTradeIDSequence 设置成交编号生成器，同一回测的多个组合共用一个生成器，成交编号不会重复
+func (p *Portfolio) SetTradeIDSequence(ids *clock.Sequence) {
+	p.tradeIDs = ids
+}
+
+// SetRiskChecker 设置下单前的风控检查
+func (p *Portfolio) SetRiskChecker(checker RiskChecker) {
+	p.riskChecker = checker
+}
+
+// shortSeller 支持卖空的经纪商
+type shortSeller interface {
+	AllowShort() bool
+}
+
+// AllowShort 经纪商是否允许卖空
+func (p *Portfolio) AllowShort() bool {
+	if s, ok := p.broker.(shortSeller); ok {
+		return s.AllowShort()
+	}
+	return false
+}
+
+// canGoShort 期货总是可以开空仓，股票需要经纪商允许卖空
+func (p *Portfolio) canGoShort(symbol string) bool {
+	return p.AllowShort() || p.Instrument(symbol).IsFuture()
+}
+
+// checkRisk 执行风控检查
+func (p *Portfolio) checkRisk(symbol string, timestamp time.Time, action types.Action, price, quantity float64) error {
+	if p.riskChecker == nil {
+		return nil
+	}
+	return p.riskChecker.CheckOrder(p, risk.Order{
+		Timestamp: timestamp,
+		Symbol:    symbol,
+		Action:    action,
+		Price:     price,
+		Quantity:  quantity,
+	})
+}
+
 func (p *Portfolio) Balance() float64 {
 	return p.cash
 }
@@ -56,33 +137,77 @@ func (p *Portfolio) Transactions() []types.Trade {
 }
 
 func (p *Portfolio) Buy(symbol string, timestamp time.Time, price float64, quantity float64) error {
-	// 通过OrderManager创建订单
-	order, err := p.orderManager.CreateOrder("manual", symbol, quantity, types.OrderTypeBuy)
-	if err != nil {
+	if err := p.checkRisk(symbol, timestamp, types.ActionBuy, price, quantity); err != nil {
 		return err
 	}
+	return p.buy(symbol, timestamp, price, quantity)
+}
 
-	// 执行订单
-	err = p.orderManager.ExecuteOrder(order.ID)
+// submit 通过OrderManager创建并执行订单，返回执行后的订单状态
+func (p *Portfolio) submit(symbol string, quantity float64, orderType types.OrderType) (*types.Order, error) {
+	order, err := p.orderManager.CreateOrder("manual", symbol, quantity, orderType)
 	if err != nil {
+		return nil, err
+	}
+	if err := p.orderManager.ExecuteOrder(order.ID); err != nil {
+		return nil, err
+	}
+	return p.orderManager.GetOrder(order.ID)
+}
+
+// record 记录成交、分配成交编号并写入交易日志，T+1品种记录当日买入数量
+func (p *Portfolio) record(trade types.Trade) {
+	if trade.Type == types.ActionBuy && !p.Instrument(trade.Symbol).T0 {
+		if !sameDay(p.boughtOn[trade.Symbol], trade.Timestamp) {
+			p.boughtOn[trade.Symbol] = trade.Timestamp
+			p.boughtToday[trade.Symbol] = 0
+		}
+		p.boughtToday[trade.Symbol] += trade.Quantity
+	}
+	if p.reversing {
+		trade.Strategy = "rollback"
+	}
+	trade.ID = p.tradeIDs.Next()
+	p.trades = append(p.trades, trade)
+	if p.broker.Logger() != nil {
+		p.broker.Logger().LogTrade(trade)
+	}
+}
+
+// buy 不经风控检查直接买入，资金不足时不成交
+func (p *Portfolio) buy(symbol string, timestamp time.Time, price float64, quantity float64) error {
+	if err := p.checkPriceLimit(symbol, types.ActionBuy, price); err != nil {
 		return err
 	}
+	if inst := p.Instrument(symbol); inst.IsFuture() {
+		return p.tradeFuture(inst, symbol, timestamp, types.ActionBuy, price, quantity)
+	}
 
-	// 获取更新后的订单状态
-	order, err = p.orderManager.GetOrder(order.ID)
+	order, err := p.submit(symbol, quantity, types.OrderTypeBuy)
 	if err != nil {
 		return err
 	}
 
 	if order.Status == types.OrderStatusFilled {
 		cost := price * quantity
-		fee := p.broker.CalculateTradeCost(types.ActionBuy, price, quantity)
+		fee := p.SymbolTradeCost(symbol, types.ActionBuy, price, quantity)
 		totalCost := cost + fee
 
-		if p.cash >= totalCost {
+		if p.freeCash() >= totalCost || (p.Margin() != nil && (p.liquidating || p.canBuy(symbol, price, quantity))) {
+			if after := p.positions[symbol] + quantity; after > 0 {
+				// 买回空头的部分不形成多头持仓
+				p.addLot(symbol, timestamp, math.Min(quantity, after))
+			}
 			p.cash -= totalCost
+			if p.cash < 0 {
+				// 现金不足部分为融资买入
+				p.debt -= p.cash
+				p.cash = 0
+			}
 			p.positions[symbol] += quantity
-			p.positionPrices[symbol] = price
+			if p.positions[symbol] > 0 {
+				p.positionPrices[symbol] = price
+			}
 			p.positionSizes[symbol] += quantity
 			trade := types.Trade{
 				Timestamp: timestamp,
@@ -94,48 +219,62 @@ func (p *Portfolio) Buy(symbol string, timestamp time.Time, price float64, quant
 				Strategy:  "manual",
 				OrderID:   order.ID,
 			}
-			p.trades = append(p.trades, trade)
-
-			// Log trade if logger is configured
-			if p.broker.Logger() != nil {
-				p.broker.Logger().LogTrade(trade)
-			}
+			p.record(trade)
 		}
 	}
 	return nil
 }
 
+// Sell 卖出，经纪商允许卖空时卖出数量可以超过持仓，超出部分为空头仓位
+//
+// 开通融资融券时融券卖出检查保证金；只用SetAllowShort允许卖空而未开通融资融券时不检查保证金，
+// 空头规模只受风控限制
 func (p *Portfolio) Sell(symbol string, timestamp time.Time, price float64, quantity float64) error {
-	if p.positions[symbol] < quantity {
+	if p.positions[symbol] < quantity && !p.canGoShort(symbol) {
 		return types.ErrInsufficientPosition
 	}
-
-	// 通过OrderManager创建订单
-	order, err := p.orderManager.CreateOrder("manual", symbol, quantity, types.OrderTypeSell)
-	if err != nil {
+	if err := p.checkSellable(symbol, timestamp, quantity); err != nil {
 		return err
 	}
+	if err := p.checkRisk(symbol, timestamp, types.ActionSell, price, quantity); err != nil {
+		return err
+	}
+	return p.sell(symbol, timestamp, price, quantity)
+}
 
-	// 执行订单
-	err = p.orderManager.ExecuteOrder(order.ID)
-	if err != nil {
+// sell 不经持仓和风控检查直接卖出，融券卖出时检查保证金
+func (p *Portfolio) sell(symbol string, timestamp time.Time, price float64, quantity float64) error {
+	if err := p.checkPriceLimit(symbol, types.ActionSell, price); err != nil {
 		return err
 	}
+	if inst := p.Instrument(symbol); inst.IsFuture() {
+		return p.tradeFuture(inst, symbol, timestamp, types.ActionSell, price, quantity)
+	}
+	if p.Margin() != nil && !p.liquidating && p.positions[symbol] < quantity && !p.canShort(symbol, price, quantity) {
+		return types.ErrInsufficientFunds
+	}
 
-	// 获取更新后的订单状态
-	order, err = p.orderManager.GetOrder(order.ID)
+	order, err := p.submit(symbol, quantity, types.OrderTypeSell)
 	if err != nil {
 		return err
 	}
 
 	if order.Status == types.OrderStatusFilled {
 		proceeds := price * quantity
-		fee := p.broker.CalculateTradeCost(types.ActionSell, price, quantity)
+		fee := p.SymbolTradeCost(symbol, types.ActionSell, price, quantity)
 		totalProceeds := proceeds - fee
 
+		before := p.positions[symbol]
 		p.cash += totalProceeds
+		p.repay()
 		p.positions[symbol] -= quantity
 		p.positionSizes[symbol] -= quantity
+		if before > 0 {
+			p.removeLots(symbol, math.Min(quantity, before))
+		}
+		if before >= 0 && p.positions[symbol] < 0 {
+			p.positionPrices[symbol] = price
+		}
 		trade := types.Trade{
 			Timestamp: timestamp,
 			Symbol:    symbol,
@@ -146,12 +285,391 @@ func (p *Portfolio) Sell(symbol string, timestamp time.Time, price float64, quan
 			Strategy:  "manual",
 			OrderID:   order.ID,
 		}
-		p.trades = append(p.trades, trade)
+		p.record(trade)
+	}
+	return nil
+}
+
+// CloseAll 按最新市价卖出全部多头持仓并买入平掉全部空头持仓
+//
+// 涨跌停或T+1无法成交的持仓保留且不返回错误，调用方应检查剩余持仓并在之后重试
+func (p *Portfolio) CloseAll(timestamp time.Time) error {
+	for _, symbol := range p.heldSymbols() {
+		var err error
+		if qty := p.positions[symbol]; qty > 0 {
+			err = p.Sell(symbol, timestamp, p.MarketPrice(symbol), qty)
+		} else {
+			err = p.Buy(symbol, timestamp, p.MarketPrice(symbol), -qty)
+		}
+		if err != nil && !errors.Is(err, types.ErrPriceLimit) && !errors.Is(err, types.ErrNotSellable) {
+			return err
+		}
+	}
+	return nil
+}
 
-		// Log trade if logger is configured
-		if p.broker.Logger() != nil {
-			p.broker.Logger().LogTrade(trade)
+// Delist 退市清算：按最后收盘价了结symbol的全部持仓，不受涨跌停、T+1和风控限制，不收交易费用
+func (p *Portfolio) Delist(symbol string, timestamp time.Time) error {
+	qty := p.positions[symbol]
+	price := p.MarketPrice(symbol)
+	if qty != 0 {
+		action, orderType := types.ActionSell, types.OrderTypeSell
+		if qty < 0 {
+			action, orderType = types.ActionBuy, types.OrderTypeBuy
+		}
+		if inst := p.Instrument(symbol); inst.IsFuture() {
+			p.liquidating = true
+			err := p.tradeFuture(inst, symbol, timestamp, action, price, math.Abs(qty))
+			p.liquidating = false
+			return err
 		}
+		order, err := p.submit(symbol, math.Abs(qty), orderType)
+		if err != nil {
+			return err
+		}
+		// 空头按最后收盘价买回，现金不足部分计入融资负债
+		p.cash += qty * price
+		if p.cash < 0 {
+			p.debt -= p.cash
+			p.cash = 0
+		}
+		p.repay()
+		p.record(types.Trade{
+			Timestamp: timestamp,
+			Symbol:    symbol,
+			Price:     price,
+			Quantity:  math.Abs(qty),
+			Type:      action,
+			Strategy:  "delisting",
+			OrderID:   order.ID,
+		})
+	}
+	delete(p.positions, symbol)
+	delete(p.positionSizes, symbol)
+	delete(p.positionPrices, symbol)
+	delete(p.lots, symbol)
+	delete(p.boughtOn, symbol)
+	delete(p.boughtToday, symbol)
+	return nil
+}
+
+// OrderTargetWeights 调整持仓到目标权重，未列出的持仓视为目标权重0，允许卖空时负权重为空头
+// 目标股数按lot向零取整，先卖后买以释放资金，买入时扣除费用后资金（融资融券账户为保证金）不足则减少手数
+func (p *Portfolio) OrderTargetWeights(timestamp time.Time, weights map[string]float64, lot float64) error {
+	if lot <= 0 {
+		lot = 1
+	}
+	equity := p.GetValue()
+
+	symbols := make(map[string]bool)
+	for symbol := range weights {
+		symbols[symbol] = true
+	}
+	for symbol, qty := range p.positions {
+		if qty != 0 {
+			symbols[symbol] = true
+		}
+	}
+	ordered := make([]string, 0, len(symbols))
+	for symbol := range symbols {
+		ordered = append(ordered, symbol)
+	}
+	sort.Strings(ordered)
+
+	// ETF、可转债和期货按品种的交易单位取整，期货按合约价值计算目标手数；
+	// 还没有价格的股票（如尚未上市）跳过并返回错误，不影响其余股票调仓
+	var errs []error
+	priced := ordered[:0]
+	lots := make(map[string]float64)
+	targets := make(map[string]float64)
+	for _, symbol := range ordered {
+		price := p.MarketPrice(symbol)
+		if price <= 0 {
+			errs = append(errs, fmt.Errorf("%s没有最新价格，无法计算目标仓位", symbol))
+			continue
+		}
+		priced = append(priced, symbol)
+		weight := weights[symbol]
+		if weight < 0 && !p.canGoShort(symbol) {
+			weight = 0
+		}
+		lots[symbol] = lot
+		if inst := p.Instrument(symbol); inst.Class != instrument.ClassStock {
+			lots[symbol] = inst.LotSize
+			price *= inst.Multiplier
+		}
+		targets[symbol] = math.Trunc(equity*weight/price/lots[symbol]) * lots[symbol]
+	}
+
+	for _, symbol := range priced {
+		if diff := p.positions[symbol] - targets[symbol]; diff > 0 {
+			if err := p.Sell(symbol, timestamp, p.MarketPrice(symbol), diff); err != nil {
+				errs = append(errs, fmt.Errorf("卖出%s失败: %w", symbol, err))
+			}
+		}
+	}
+	for _, symbol := range priced {
+		diff := targets[symbol] - p.positions[symbol]
+		if diff <= 0 {
+			continue
+		}
+		price := p.MarketPrice(symbol)
+		diff = p.affordable(symbol, price, diff, lots[symbol])
+		if diff <= 0 {
+			continue
+		}
+		if err := p.Buy(symbol, timestamp, price, diff); err != nil {
+			errs = append(errs, fmt.Errorf("买入%s失败: %w", symbol, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Leg 组合订单中的一条腿
+type Leg struct {
+	Symbol   string
+	Action   types.Action
+	Price    float64
+	Quantity float64
+}
+
+// ExecuteLegs 原子地执行一组订单，全部成交或全部不成交
+//
+// 下单前检查每条腿的涨跌停、持仓、T+1可卖数量、风控和资金（先卖后买），能预先判断的失败都不会产生成交。
+// 执行中途仍失败时（如保证金不足）按原价反向撤回已成交的腿：撤回是真实成交，
+// 以"rollback"策略名记入交易日志，原成交和撤回都收取费用。撤回失败时返回该错误，此时组合处于部分成交状态
+func (p *Portfolio) ExecuteLegs(timestamp time.Time, legs []Leg) error {
+	cash := p.freeCash()
+	futures := false
+	for _, leg := range legs {
+		if p.Instrument(leg.Symbol).IsFuture() {
+			futures = true
+		}
+		if leg.Quantity <= 0 || leg.Price <= 0 {
+			return fmt.Errorf("%s订单数量或价格无效", leg.Symbol)
+		}
+		if err := p.checkPriceLimit(leg.Symbol, leg.Action, leg.Price); err != nil {
+			return err
+		}
+		switch leg.Action {
+		case types.ActionBuy:
+		case types.ActionSell:
+			if p.positions[leg.Symbol] < leg.Quantity && !p.canGoShort(leg.Symbol) {
+				return fmt.Errorf("卖出%s失败: %w", leg.Symbol, types.ErrInsufficientPosition)
+			}
+			if err := p.checkSellable(leg.Symbol, timestamp, leg.Quantity); err != nil {
+				return err
+			}
+			cash += leg.Price*leg.Quantity - p.SymbolTradeCost(leg.Symbol, types.ActionSell, leg.Price, leg.Quantity)
+		default:
+			return fmt.Errorf("%s不支持的交易动作: %v", leg.Symbol, leg.Action)
+		}
+		if err := p.checkRisk(leg.Symbol, timestamp, leg.Action, leg.Price, leg.Quantity); err != nil {
+			return err
+		}
+	}
+	for _, leg := range legs {
+		if leg.Action == types.ActionBuy {
+			cash -= leg.Price*leg.Quantity + p.SymbolTradeCost(leg.Symbol, types.ActionBuy, leg.Price, leg.Quantity)
+		}
+	}
+	// 融资融券账户和期货的保证金在逐条腿成交时检查
+	if cash < 0 && p.Margin() == nil && !futures {
+		return types.ErrInsufficientFunds
+	}
+
+	ordered := make([]Leg, 0, len(legs))
+	for _, leg := range legs {
+		if leg.Action == types.ActionSell {
+			ordered = append(ordered, leg)
+		}
+	}
+	for _, leg := range legs {
+		if leg.Action == types.ActionBuy {
+			ordered = append(ordered, leg)
+		}
+	}
+
+	for i, leg := range ordered {
+		filled := len(p.trades)
+		var err error
+		if leg.Action == types.ActionBuy {
+			err = p.buy(leg.Symbol, timestamp, leg.Price, leg.Quantity)
+		} else {
+			err = p.sell(leg.Symbol, timestamp, leg.Price, leg.Quantity)
+		}
+		if err == nil && len(p.trades) == filled {
+			err = fmt.Errorf("%s未成交", leg.Symbol)
+		}
+		if err != nil {
+			if rerr := p.reverseLegs(timestamp, ordered[:i]); rerr != nil {
+				return fmt.Errorf("组合订单执行失败(%v)，撤回已成交的腿失败: %w", err, rerr)
+			}
+			return fmt.Errorf("组合订单执行失败，已撤回: %w", err)
+		}
+	}
+	return nil
+}
+
+// reverseLegs 按相反顺序反向成交已成交的腿，撤回成交记为"rollback"策略
+func (p *Portfolio) reverseLegs(timestamp time.Time, legs []Leg) error {
+	p.reversing = true
+	defer func() { p.reversing = false }()
+	for j := len(legs) - 1; j >= 0; j-- {
+		leg := legs[j]
+		filled := len(p.trades)
+		var err error
+		if leg.Action == types.ActionBuy {
+			err = p.sell(leg.Symbol, timestamp, leg.Price, leg.Quantity)
+		} else {
+			err = p.buy(leg.Symbol, timestamp, leg.Price, leg.Quantity)
+		}
+		if err == nil && len(p.trades) == filled {
+			err = fmt.Errorf("%s未成交", leg.Symbol)
+		}
+		if err != nil {
+			return fmt.Errorf("撤回%s: %w", leg.Symbol, err)
+		}
+	}
+	return nil
+}
+
+// DividendTaxRate 按持股期限计算差别化红利税率
+// 持股1个月以内20%，1个月至1年10%，超过1年免征
+func DividendTaxRate(held time.Duration) float64 {
+	days := held.Hours() / 24
+	switch {
+	case days <= 30:
+		return 0.2
+	case days <= 365:
+		return 0.1
+	default:
+		return 0
+	}
+}
+
+// holdingLot 同一时间买入的一笔多头持仓
+type holdingLot struct {
+	quantity float64
+	openedAt time.Time
+}
+
+// addLot 记录买入形成的多头持仓
+func (p *Portfolio) addLot(symbol string, timestamp time.Time, quantity float64) {
+	lots := p.lots[symbol]
+	if n := len(lots); n > 0 && lots[n-1].openedAt.Equal(timestamp) {
+		lots[n-1].quantity += quantity
+		return
+	}
+	p.lots[symbol] = append(lots, holdingLot{quantity: quantity, openedAt: timestamp})
+}
+
+// removeLots 卖出时按先进先出扣减多头持仓
+func (p *Portfolio) removeLots(symbol string, quantity float64) {
+	lots := p.lots[symbol]
+	for len(lots) > 0 && quantity > 0 {
+		if lots[0].quantity > quantity {
+			lots[0].quantity -= quantity
+			break
+		}
+		quantity -= lots[0].quantity
+		lots = lots[1:]
+	}
+	if len(lots) == 0 {
+		delete(p.lots, symbol)
+		return
+	}
+	p.lots[symbol] = lots
+}
+
+// ApplyCorporateAction 在除权除息日处理分红和送转股，并记入交易流水
+// 多头持仓中除权日当天及之后买入的股份不参与分红送转，红利税按每笔持仓的持股期限分别计算，送转股沿用原持仓的买入时间；
+// 空头仓位需向出借方补偿全部分红，送转股时空头数量同比例增加
+func (p *Portfolio) ApplyCorporateAction(action types.CorporateAction) error {
+	quantity := p.positions[action.Symbol]
+	if quantity == 0 {
+		return nil
+	}
+
+	// entitled 参与分红送转的股数，taxable 按各笔持仓的红利税率加权的股数
+	entitled, taxable := quantity, 0.0
+	var lots []int
+	if quantity > 0 {
+		entitled = 0
+		for i, lot := range p.lots[action.Symbol] {
+			if sameDay(lot.openedAt, action.ExDate) || lot.openedAt.After(action.ExDate) {
+				continue
+			}
+			entitled += lot.quantity
+			taxable += lot.quantity * DividendTaxRate(action.ExDate.Sub(lot.openedAt))
+			lots = append(lots, i)
+		}
+		if entitled == 0 {
+			return nil
+		}
+	}
+
+	var netCash, newShares float64
+	var entries []types.Trade
+
+	if action.CashDividend > 0 {
+		gross := entitled * action.CashDividend
+		tax := taxable * action.CashDividend
+		netCash = gross - tax
+		entries = append(entries, types.Trade{
+			Timestamp: action.ExDate,
+			Symbol:    action.Symbol,
+			Price:     action.CashDividend,
+			Quantity:  entitled,
+			Type:      types.ActionDividend,
+			Fee:       tax,
+			Strategy:  "corporate_action",
+		})
+	}
+
+	if multiplier := action.ShareMultiplier(); multiplier != 1 {
+		// 送转股不足一股的部分不予派发
+		newShares = math.Floor(math.Abs(entitled)*multiplier) - math.Abs(entitled)
+		if entitled < 0 {
+			newShares = -newShares
+		}
+		if newShares != 0 {
+			entries = append(entries, types.Trade{
+				Timestamp: action.ExDate,
+				Symbol:    action.Symbol,
+				Quantity:  newShares,
+				Type:      types.ActionBonus,
+				Strategy:  "corporate_action",
+			})
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if err := p.broker.ApplyCorporateAction(action.Symbol, netCash, newShares); err != nil {
+		return err
+	}
+
+	p.cash += netCash
+	if newShares != 0 {
+		// 除权后持仓市值不变，摊薄每股价格
+		p.positionPrices[action.Symbol] = p.positionPrices[action.Symbol] * quantity / (quantity + newShares)
+		if price, ok := p.marketPrices[action.Symbol]; ok {
+			// 市价按参与送转的股份除权，除权日买入的股份不影响除权价
+			p.marketPrices[action.Symbol] = price * entitled / (entitled + newShares)
+		}
+		p.positions[action.Symbol] += newShares
+		p.positionSizes[action.Symbol] += newShares
+		for _, i := range lots {
+			lot := &p.lots[action.Symbol][i]
+			lot.quantity += newShares * lot.quantity / entitled
+		}
+	}
+
+	for _, trade := range entries {
+		p.record(trade)
 	}
 	return nil
 }
@@ -172,23 +690,63 @@ func (p *Portfolio) GetTrades() []types.Trade {
 	return p.trades
 }
 
+// UpdatePrice 更新股票最新市价，回测引擎在每根K线调用，原市价作为上一交易日收盘价
+func (p *Portfolio) UpdatePrice(symbol string, price float64) {
+	if price > 0 {
+		if prev, ok := p.marketPrices[symbol]; ok {
+			p.prevPrices[symbol] = prev
+		}
+		p.marketPrices[symbol] = price
+	}
+}
+
+// MarketPrice 返回股票最新市价，未更新过时使用持仓成本价
+func (p *Portfolio) MarketPrice(symbol string) float64 {
+	if price, ok := p.marketPrices[symbol]; ok {
+		return price
+	}
+	return p.positionPrices[symbol]
+}
+
+// heldSymbols 按代码排序的非零持仓，汇总浮点数时按固定顺序累加使结果可复现
+func (p *Portfolio) heldSymbols() []string {
+	symbols := make([]string, 0, len(p.positions))
+	for symbol, qty := range p.positions {
+		if qty != 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// GetValue 账户权益，持仓按最新市价估值，期货只计未结算的浮动盈亏，扣除融资负债和未付利息
 func (p *Portfolio) GetValue() float64 {
 	positionValue := 0.0
-	for symbol, qty := range p.positions {
-		// 使用持仓成本价计算价值
-		positionValue += qty * p.positionPrices[symbol]
+	for _, symbol := range p.heldSymbols() {
+		qty := p.positions[symbol]
+		if inst := p.Instrument(symbol); inst.IsFuture() {
+			positionValue += (p.MarketPrice(symbol) - p.futureBasis[symbol]) * qty * inst.Multiplier
+			continue
+		}
+		positionValue += qty * p.MarketPrice(symbol)
 	}
-	return p.cash + positionValue
+	return p.cash + positionValue - p.debt - p.interest
 }
 
-// GetSymbolValue 获取指定股票持仓价值
+// GetSymbolValue 获取指定股票持仓市值，期货为合约价值
 func (p *Portfolio) GetSymbolValue(symbol string) float64 {
 	if qty, ok := p.positions[symbol]; ok {
-		return qty * p.positionPrices[symbol]
+		return p.Instrument(symbol).Notional(p.MarketPrice(symbol), qty)
 	}
 	return 0
 }
 
+// TradeCost 计算交易费用
+func (p *Portfolio) TradeCost(action types.Action, price float64, quantity float64) float64 {
+	return p.broker.CalculateTradeCost(action, price, quantity)
+}
+
 // GetSymbolPosition 获取指定股票持仓信息
 func (p *Portfolio) GetSymbolPosition(symbol string) (float64, float64) {
 	qty := p.positions[symbol]
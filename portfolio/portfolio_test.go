@@ -0,0 +1,245 @@
+package portfolio
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"stock/broker"
+	"stock/common/types"
+	"stock/orders"
+)
+
+func newTestPortfolio(cash float64, margin bool) *Portfolio {
+	b := broker.NewSimulatedBroker(broker.NewFixedFeeCalculator(0.001), nil, cash)
+	if margin {
+		b.SetMargin(broker.DefaultMarginConfig())
+	}
+	return NewPortfolio(cash, b, orders.NewOrderManager(b))
+}
+
+func TestExecuteLegsPriceLimitPreflight(t *testing.T) {
+	p := newTestPortfolio(100000, false)
+	day1 := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	p.UpdatePrice("600000.SH", 10)
+	if err := p.Buy("600000.SH", day1, 10, 1000); err != nil {
+		t.Fatal(err)
+	}
+	p.UpdatePrice("600000.SH", 10.5)
+	p.UpdatePrice("600036.SH", 30)
+	p.UpdatePrice("600036.SH", 33) // 涨停
+
+	err := p.ExecuteLegs(day2, []Leg{
+		{Symbol: "600000.SH", Action: types.ActionSell, Price: 10.5, Quantity: 1000},
+		{Symbol: "600036.SH", Action: types.ActionBuy, Price: 33, Quantity: 100},
+	})
+	if !errors.Is(err, types.ErrPriceLimit) {
+		t.Fatalf("涨停腿应在下单前被拒绝，得到%v", err)
+	}
+	if n := len(p.Trades()); n != 1 {
+		t.Fatalf("预检失败后成交%d笔，期望不产生新成交", n)
+	}
+	if qty := p.PositionSize("600000.SH"); qty != 1000 {
+		t.Errorf("卖出腿不应成交，持仓%v", qty)
+	}
+}
+
+func TestExecuteLegsRollback(t *testing.T) {
+	p := newTestPortfolio(100000, true)
+	day1 := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	p.UpdatePrice("600000.SH", 10)
+	if err := p.Buy("600000.SH", day1, 10, 1000); err != nil {
+		t.Fatal(err)
+	}
+	p.UpdatePrice("600036.SH", 30)
+
+	// 融资融券账户的资金在逐条腿成交时检查，买入腿超出保证金不能成交，已成交的卖出腿被撤回
+	err := p.ExecuteLegs(day2, []Leg{
+		{Symbol: "600000.SH", Action: types.ActionSell, Price: 10, Quantity: 1000},
+		{Symbol: "600036.SH", Action: types.ActionBuy, Price: 30, Quantity: 100000},
+	})
+	if err == nil {
+		t.Fatal("买入腿不能成交时应返回错误")
+	}
+	if qty := p.PositionSize("600000.SH"); qty != 1000 {
+		t.Errorf("撤回后持仓%v，期望1000", qty)
+	}
+	if qty := p.PositionSize("600036.SH"); qty != 0 {
+		t.Errorf("买入腿不应成交，持仓%v", qty)
+	}
+	trades := p.Trades()
+	if len(trades) != 3 {
+		t.Fatalf("成交%d笔，期望建仓、卖出腿和撤回共3笔", len(trades))
+	}
+	sell, back := trades[1], trades[2]
+	if sell.Type != types.ActionSell || sell.Strategy != "manual" {
+		t.Errorf("卖出腿成交记录错误: %+v", sell)
+	}
+	if back.Type != types.ActionBuy || back.Quantity != 1000 || back.Strategy != "rollback" {
+		t.Errorf("撤回成交记录错误: %+v", back)
+	}
+	// 卖出腿和撤回都收取费用
+	if want := 100000 - 3*10*1000*0.001; !closeTo(p.GetValue(), want) {
+		t.Errorf("撤回后权益%.2f，期望%.2f", p.GetValue(), want)
+	}
+}
+
+func TestOrderTargetWeightsSkipsUnpriced(t *testing.T) {
+	p := newTestPortfolio(100000, false)
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	p.UpdatePrice("600000.SH", 10)
+
+	// 688001.SH还没有价格（尚未上市），只跳过该股票，其余股票照常调仓
+	err := p.OrderTargetWeights(day, map[string]float64{"600000.SH": 0.5, "688001.SH": 0.5}, 100)
+	if err == nil || !strings.Contains(err.Error(), "688001.SH") {
+		t.Fatalf("没有价格的股票应返回错误，得到%v", err)
+	}
+	if qty := p.PositionSize("600000.SH"); qty != 5000 {
+		t.Errorf("600000.SH持仓%v，期望按一半权重买入5000股", qty)
+	}
+	if qty := p.PositionSize("688001.SH"); qty != 0 {
+		t.Errorf("没有价格的股票不应成交，持仓%v", qty)
+	}
+}
+
+// dividendPortfolio 第0天以10元买入1000股的组合
+func dividendPortfolio(t *testing.T) (*Portfolio, time.Time) {
+	t.Helper()
+	p := newTestPortfolio(100000, false)
+	day0 := time.Date(2022, 1, 4, 0, 0, 0, 0, time.UTC)
+	p.UpdatePrice("600000.SH", 10)
+	if err := p.Buy("600000.SH", day0, 10, 1000); err != nil {
+		t.Fatal(err)
+	}
+	return p, day0
+}
+
+// applyDividend 处理每股分红dividend，返回实收现金和红利税
+func applyDividend(t *testing.T, p *Portfolio, exDate time.Time, dividend float64) (net, tax float64) {
+	t.Helper()
+	cash, trades := p.AvailableCash(), len(p.Trades())
+	if err := p.ApplyCorporateAction(types.CorporateAction{Symbol: "600000.SH", ExDate: exDate, CashDividend: dividend}); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Trades()) > trades {
+		tax = p.Trades()[len(p.Trades())-1].Fee
+	}
+	return p.AvailableCash() - cash, tax
+}
+
+func TestCorporateActionDividendTax(t *testing.T) {
+	tests := []struct {
+		days int
+		tax  float64
+	}{
+		{10, 100}, // 1个月以内20%
+		{100, 50}, // 1个月至1年10%
+		{400, 0},  // 超过1年免征
+		{30, 100}, // 满30天仍按20%
+		{365, 50}, // 满1年仍按10%
+		{366, 0},
+	}
+	for _, tt := range tests {
+		p, day0 := dividendPortfolio(t)
+		net, tax := applyDividend(t, p, day0.AddDate(0, 0, tt.days), 0.5)
+		if !closeTo(tax, tt.tax) || !closeTo(net, 500-tt.tax) {
+			t.Errorf("持有%d天: 红利税%.2f 实收%.2f，期望%.2f和%.2f", tt.days, tax, net, tt.tax, 500-tt.tax)
+		}
+	}
+}
+
+func TestCorporateActionBonusShares(t *testing.T) {
+	p := newTestPortfolio(100000, false)
+	day0 := time.Date(2022, 1, 4, 0, 0, 0, 0, time.UTC)
+	p.UpdatePrice("600000.SH", 10)
+	if err := p.Buy("600000.SH", day0, 10, 1010); err != nil {
+		t.Fatal(err)
+	}
+	cash := p.AvailableCash()
+	// 10送1.5并派0.2元：1010股送151.5股，不足一股的部分不派发
+	err := p.ApplyCorporateAction(types.CorporateAction{
+		Symbol: "600000.SH", ExDate: day0.AddDate(0, 0, 400), CashDividend: 0.2, BonusRatio: 0.15,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qty := p.PositionSize("600000.SH"); qty != 1161 {
+		t.Errorf("送股后持仓%v，期望1161", qty)
+	}
+	if got := p.AvailableCash() - cash; !closeTo(got, 202) {
+		t.Errorf("分红%.2f，期望按送股前1010股派发202", got)
+	}
+	if got, want := p.MarketPrice("600000.SH"), 10*1010.0/1161; !closeTo(got, want) {
+		t.Errorf("除权后市价%.4f，期望%.4f", got, want)
+	}
+	trades := p.Trades()
+	bonus := trades[len(trades)-1]
+	if bonus.Type != types.ActionBonus || bonus.Quantity != 151 {
+		t.Errorf("送股记录%+v", bonus)
+	}
+}
+
+func TestCorporateActionMultiLot(t *testing.T) {
+	p, day0 := dividendPortfolio(t)
+	if err := p.Buy("600000.SH", day0.AddDate(0, 0, 380), 10, 500); err != nil {
+		t.Fatal(err)
+	}
+	// 1000股持有超过1年免税，500股持有20天按20%
+	if net, tax := applyDividend(t, p, day0.AddDate(0, 0, 400), 1); !closeTo(tax, 100) || !closeTo(net, 1400) {
+		t.Errorf("红利税%.2f 实收%.2f，期望100和1400", tax, net)
+	}
+
+	// 先进先出卖出1200股，剩余300股为第二笔买入，持有40天按10%
+	if err := p.Sell("600000.SH", day0.AddDate(0, 0, 401), 10, 1200); err != nil {
+		t.Fatal(err)
+	}
+	if _, tax := applyDividend(t, p, day0.AddDate(0, 0, 420), 1); !closeTo(tax, 30) {
+		t.Errorf("卖出后红利税%.2f，期望30", tax)
+	}
+}
+
+func TestCorporateActionBonusKeepsHoldingPeriod(t *testing.T) {
+	p, day0 := dividendPortfolio(t)
+	if err := p.Buy("600000.SH", day0.AddDate(0, 0, 380), 10, 500); err != nil {
+		t.Fatal(err)
+	}
+	// 10送10后两笔持仓分别为2000股和1000股，送股沿用原持仓的买入时间
+	if err := p.ApplyCorporateAction(types.CorporateAction{Symbol: "600000.SH", ExDate: day0.AddDate(0, 0, 400), BonusRatio: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, tax := applyDividend(t, p, day0.AddDate(0, 0, 420), 1); !closeTo(tax, 100) {
+		t.Errorf("红利税%.2f，期望只对第二笔1000股按10%%征收100", tax)
+	}
+}
+
+func TestCorporateActionBoughtOnExDate(t *testing.T) {
+	p, day0 := dividendPortfolio(t)
+	exDate := day0.AddDate(0, 0, 400)
+	// 除权日买入的股份不参与分红
+	if err := p.Buy("600000.SH", exDate, 10, 500); err != nil {
+		t.Fatal(err)
+	}
+	if net, tax := applyDividend(t, p, exDate, 1); !closeTo(net, 1000) || tax != 0 {
+		t.Errorf("实收%.2f 红利税%.2f，期望只对除权日前的1000股派发1000", net, tax)
+	}
+	if trades := p.Trades(); trades[len(trades)-1].Quantity != 1000 {
+		t.Errorf("分红记录持股%v，期望1000", trades[len(trades)-1].Quantity)
+	}
+
+	// 只在除权日买入的持仓没有分红
+	q := newTestPortfolio(100000, false)
+	if err := q.Buy("600000.SH", exDate, 10, 500); err != nil {
+		t.Fatal(err)
+	}
+	if net, _ := applyDividend(t, q, exDate, 1); net != 0 || len(q.Trades()) != 1 {
+		t.Errorf("除权日买入的持仓收到分红%.2f", net)
+	}
+}
+
+func closeTo(a, b float64) bool {
+	d := a - b
+	return d < 1e-6 && d > -1e-6
+}
@@ -0,0 +1,310 @@
+// Package risk 组合层面的风控：下单前检查与熔断
+package risk
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"stock/common/types"
+	"stock/instrument"
+	"strings"
+	"time"
+)
+
+// Config 风控参数，取值为0表示不启用对应检查
+type Config struct {
+	MaxPositionWeight float64           // 单只股票持仓市值占权益的上限
+	MaxGrossExposure  float64           // 总持仓市值占权益的上限
+	MaxHoldings       int               // 最多同时持有的股票数
+	MaxSectorWeight   float64           // 单个行业持仓市值占权益的上限
+	Sectors           map[string]string // 股票代码 -> 行业，未列出的股票不做行业检查
+	DailyLossLimit    float64           // 当日亏损占上一交易日权益的比例，超过后当日禁止开仓
+	MaxDrawdown       float64           // 权益自高点回撤超过该比例时清仓并停止策略
+}
+
+// EventType 风控事件类型
+type EventType string
+
+const (
+	EventRejected  EventType = "rejected"   // 订单被拒绝
+	EventDailyHalt EventType = "daily_halt" // 触发当日亏损限制
+	EventKill      EventType = "kill"       // 触发最大回撤，清仓并停止
+
+	EventMarginCall  EventType = "margin_call" // 维持担保比例低于下限，需追加担保物
+	EventLiquidation EventType = "liquidation" // 追保期满仍不足，强制平仓
+)
+
+// Event 风控事件
+type Event struct {
+	Timestamp time.Time
+	Type      EventType
+	Symbol    string
+	Rule      string
+	Detail    string
+}
+
+func (e Event) String() string {
+	symbol := e.Symbol
+	if symbol == "" {
+		symbol = "-"
+	}
+	return fmt.Sprintf("%s %s %s %s: %s", e.Timestamp.Format("2006-01-02"), e.Type, symbol, e.Rule, e.Detail)
+}
+
+// Rejection 订单被风控拒绝时返回的错误
+type Rejection struct {
+	Event Event
+}
+
+func (r *Rejection) Error() string {
+	return "风控拒绝: " + r.Event.Rule + " " + r.Event.Detail
+}
+
+// Account 风控检查所需的账户信息，*portfolio.Portfolio实现了该接口
+type Account interface {
+	GetValue() float64
+	GetPositions() map[string]float64
+	MarketPrice(symbol string) float64
+	Instrument(symbol string) *instrument.Instrument
+}
+
+// Order 待检查的订单
+type Order struct {
+	Timestamp time.Time
+	Symbol    string
+	Action    types.Action
+	Price     float64
+	Quantity  float64
+}
+
+// Manager 风控管理器，每个组合使用一个实例
+type Manager struct {
+	config      Config
+	events      []Event
+	onEvent     func(Event)
+	peak        float64
+	day         time.Time
+	dayStart    float64 // 当日开始时的权益，即上一交易日收盘权益
+	lastEquity  float64
+	dailyHalted bool
+	killed      bool
+}
+
+func NewManager(config Config) *Manager {
+	return &Manager{config: config}
+}
+
+// SetEventHandler 设置事件回调，用于输出日志
+func (m *Manager) SetEventHandler(handler func(Event)) {
+	m.onEvent = handler
+}
+
+// Events 返回全部风控事件
+func (m *Manager) Events() []Event {
+	return m.events
+}
+
+// Killed 是否已触发最大回撤熔断
+func (m *Manager) Killed() bool {
+	return m.killed
+}
+
+func (m *Manager) record(event Event) {
+	m.events = append(m.events, event)
+	if m.onEvent != nil {
+		m.onEvent(event)
+	}
+}
+
+func (m *Manager) reject(order Order, rule, detail string) error {
+	event := Event{Timestamp: order.Timestamp, Type: EventRejected, Symbol: order.Symbol, Rule: rule, Detail: detail}
+	m.record(event)
+	return &Rejection{Event: event}
+}
+
+// startDay 进入新交易日时以上一交易日收盘权益作为当日基准
+func (m *Manager) startDay(timestamp time.Time, equity float64) {
+	day := time.Date(timestamp.Year(), timestamp.Month(), timestamp.Day(), 0, 0, 0, 0, timestamp.Location())
+	if day.Equal(m.day) {
+		return
+	}
+	m.day = day
+	m.dailyHalted = false
+	m.dayStart = m.lastEquity
+	if m.dayStart <= 0 {
+		m.dayStart = equity
+	}
+}
+
+// CheckOrder 下单前检查，减仓（卖出多头、买入平空）总是放行，熔断后禁止一切开仓
+func (m *Manager) CheckOrder(account Account, order Order) error {
+	positions := account.GetPositions()
+	current := positions[order.Symbol]
+	signed := order.Quantity
+	switch order.Action {
+	case types.ActionBuy:
+	case types.ActionSell:
+		signed = -order.Quantity
+	default:
+		return nil
+	}
+	// 减仓（卖出多头、买入平空）不受限制
+	if after := current + signed; math.Abs(after) <= math.Abs(current) && current*after >= 0 {
+		return nil
+	}
+	equity := account.GetValue()
+	m.startDay(order.Timestamp, equity)
+
+	if m.killed {
+		return m.reject(order, "max_drawdown", "已触发最大回撤熔断，策略停止")
+	}
+	if m.checkDailyLoss(order.Timestamp, equity) {
+		return m.reject(order, "daily_loss", fmt.Sprintf("当日亏损超过%.2f%%，暂停开仓", m.config.DailyLossLimit*100))
+	}
+	if equity <= 0 {
+		return m.reject(order, "equity", "账户权益不足")
+	}
+
+	// 持仓和订单按合约价值计算，期货计入合约乘数
+	value := func(symbol string) float64 {
+		return account.Instrument(symbol).Notional(account.MarketPrice(symbol), positions[symbol])
+	}
+	orderValue := account.Instrument(order.Symbol).Notional(order.Price, signed)
+	// 下单后该股票的持仓市值，空头为负
+	after := value(order.Symbol) + orderValue
+
+	if limit := m.config.MaxPositionWeight; limit > 0 {
+		if weight := math.Abs(after) / equity; weight > limit+1e-9 {
+			return m.reject(order, "max_position_weight",
+				fmt.Sprintf("持仓占比%.2f%%超过上限%.2f%%", weight*100, limit*100))
+		}
+	}
+
+	if limit := m.config.MaxGrossExposure; limit > 0 {
+		gross := math.Abs(after)
+		for _, symbol := range held(positions) {
+			if symbol != order.Symbol {
+				gross += math.Abs(value(symbol))
+			}
+		}
+		if exposure := gross / equity; exposure > limit+1e-9 {
+			return m.reject(order, "max_gross_exposure",
+				fmt.Sprintf("总敞口%.2f%%超过上限%.2f%%", exposure*100, limit*100))
+		}
+	}
+
+	if limit := m.config.MaxHoldings; limit > 0 && positions[order.Symbol] == 0 {
+		holdings := 0
+		for _, qty := range positions {
+			if qty != 0 {
+				holdings++
+			}
+		}
+		if holdings >= limit {
+			return m.reject(order, "max_holdings", fmt.Sprintf("持股数已达上限%d只", limit))
+		}
+	}
+
+	if limit := m.config.MaxSectorWeight; limit > 0 {
+		if sector, ok := m.config.Sectors[order.Symbol]; ok {
+			sectorValue := after
+			for _, symbol := range held(positions) {
+				if symbol != order.Symbol && m.config.Sectors[symbol] == sector {
+					sectorValue += value(symbol)
+				}
+			}
+			if weight := math.Abs(sectorValue) / equity; weight > limit+1e-9 {
+				return m.reject(order, "max_sector_weight",
+					fmt.Sprintf("行业%s占比%.2f%%超过上限%.2f%%", sector, weight*100, limit*100))
+			}
+		}
+	}
+	return nil
+}
+
+// held 按代码排序的非零持仓，使敞口按固定顺序累加
+func held(positions map[string]float64) []string {
+	symbols := make([]string, 0, len(positions))
+	for symbol, qty := range positions {
+		if qty != 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// checkDailyLoss 检查当日亏损，首次触发时记录事件
+func (m *Manager) checkDailyLoss(timestamp time.Time, equity float64) bool {
+	if m.config.DailyLossLimit <= 0 || m.dayStart <= 0 {
+		return false
+	}
+	if !m.dailyHalted && (m.dayStart-equity)/m.dayStart > m.config.DailyLossLimit {
+		m.dailyHalted = true
+		m.record(Event{
+			Timestamp: timestamp,
+			Type:      EventDailyHalt,
+			Rule:      "daily_loss",
+			Detail:    fmt.Sprintf("当日权益从%.2f降至%.2f", m.dayStart, equity),
+		})
+	}
+	return m.dailyHalted
+}
+
+// OnBar 每根K线结束时调用，更新权益高点并检查熔断；返回true表示需要清仓并停止策略
+func (m *Manager) OnBar(timestamp time.Time, account Account) bool {
+	equity := account.GetValue()
+	m.startDay(timestamp, equity)
+	m.checkDailyLoss(timestamp, equity)
+	m.lastEquity = equity
+	if equity > m.peak {
+		m.peak = equity
+	}
+
+	if m.killed || m.config.MaxDrawdown <= 0 || m.peak <= 0 {
+		return false
+	}
+	if drawdown := (m.peak - equity) / m.peak; drawdown > m.config.MaxDrawdown {
+		m.killed = true
+		m.record(Event{
+			Timestamp: timestamp,
+			Type:      EventKill,
+			Rule:      "max_drawdown",
+			Detail:    fmt.Sprintf("回撤%.2f%%超过上限%.2f%%，清仓并停止策略", drawdown*100, m.config.MaxDrawdown*100),
+		})
+		return true
+	}
+	return false
+}
+
+// LoadSectors 读取行业分类CSV，表头为 Symbol,Sector
+func LoadSectors(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开行业分类文件失败: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	if _, err := reader.Read(); err != nil {
+		return nil, fmt.Errorf("读取行业分类表头失败: %v", err)
+	}
+	sectors := make(map[string]string)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取行业分类失败: %v", err)
+		}
+		if len(record) < 2 {
+			continue
+		}
+		sectors[strings.TrimSpace(record[0])] = strings.TrimSpace(record[1])
+	}
+	return sectors, nil
+}
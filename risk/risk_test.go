@@ -0,0 +1,45 @@
+package risk
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"stock/common/types"
+	"stock/instrument"
+)
+
+// account 固定权益、持仓和价格的账户
+type account struct {
+	value     float64
+	positions map[string]float64
+	prices    map[string]float64
+}
+
+func (a *account) GetValue() float64                 { return a.value }
+func (a *account) GetPositions() map[string]float64  { return a.positions }
+func (a *account) MarketPrice(symbol string) float64 { return a.prices[symbol] }
+func (a *account) Instrument(symbol string) *instrument.Instrument {
+	return instrument.Default().Lookup(symbol)
+}
+
+func TestPositionWeightUsesNotional(t *testing.T) {
+	a := &account{
+		value:     1000000,
+		positions: map[string]float64{},
+		prices:    map[string]float64{"IF2403.CFE": 3500, "600036.SH": 35},
+	}
+	m := NewManager(Config{MaxPositionWeight: 0.6})
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// 1手股指期货合约价值3500×300=105万，超过权益的60%
+	err := m.CheckOrder(a, Order{Timestamp: day, Symbol: "IF2403.CFE", Action: types.ActionBuy, Price: 3500, Quantity: 1})
+	var rejection *Rejection
+	if !errors.As(err, &rejection) || rejection.Event.Rule != "max_position_weight" {
+		t.Fatalf("期货按合约价值应超过持仓上限，得到%v", err)
+	}
+	// 同样数量的股票市值只有35元
+	if err := m.CheckOrder(a, Order{Timestamp: day, Symbol: "600036.SH", Action: types.ActionBuy, Price: 35, Quantity: 1}); err != nil {
+		t.Fatalf("股票订单不应被拒绝: %v", err)
+	}
+}
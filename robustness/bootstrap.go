@@ -0,0 +1,58 @@
+package robustness
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// StationaryBootstrap 平稳块自助法（Politis-Romano）：从随机位置开始截取收益率，
+// 每步以1/BlockSize的概率跳到新的随机位置，超出序列末尾时回到开头，保留收益率的短期相关性
+func StationaryBootstrap(returns []float64, rng *rand.Rand, blockSize float64) []float64 {
+	n := len(returns)
+	sample := make([]float64, n)
+	if n == 0 {
+		return sample
+	}
+	p := 1.0
+	if blockSize > 1 {
+		p = 1 / blockSize
+	}
+	i := rng.Intn(n)
+	for t := range sample {
+		if t > 0 {
+			if rng.Float64() < p {
+				i = rng.Intn(n)
+			} else {
+				i = (i + 1) % n
+			}
+		}
+		sample[t] = returns[i]
+	}
+	return sample
+}
+
+// Returns 对日收益率做平稳块自助法重抽样，权益路径由初始资金按重抽样的收益率复利得到
+func Returns(returns []float64, initialCash float64, config Config) (*Result, error) {
+	if len(returns) < 2 {
+		return nil, fmt.Errorf("收益率只有%d个，无法重抽样", len(returns))
+	}
+	rng := rand.New(rand.NewSource(config.Seed))
+	paths := make([][]float64, config.Simulations)
+	for s := range paths {
+		paths[s] = equityPath(initialCash, StationaryBootstrap(returns, rng, config.BlockSize))
+	}
+	return summarize("收益率块自助法", equityPath(initialCash, returns), paths, config.PeriodsPerYear, config), nil
+}
+
+// DailyReturns 由初始资金和每日净值计算日收益率，第一天相对初始资金
+func DailyReturns(initialCash float64, values []float64) []float64 {
+	returns := make([]float64, len(values))
+	prev := initialCash
+	for i, v := range values {
+		if prev != 0 {
+			returns[i] = v/prev - 1
+		}
+		prev = v
+	}
+	return returns
+}
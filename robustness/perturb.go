@@ -0,0 +1,115 @@
+package robustness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"stock/common/types"
+	"stock/datasource"
+	"stock/stats"
+	"time"
+)
+
+// PerturbedDataSource 对价格路径加噪声的数据源：每日对数收益率加上noise倍日波动率的高斯噪声，
+// 开高低收按收盘价的调整比例同比缩放，成交量不变。同一种子和股票代码生成的路径相同
+type PerturbedDataSource struct {
+	source datasource.DataSource
+	noise  float64
+	seed   int64
+}
+
+func NewPerturbedDataSource(source datasource.DataSource, noise float64, seed int64) *PerturbedDataSource {
+	return &PerturbedDataSource{source: source, noise: noise, seed: seed}
+}
+
+func (ds *PerturbedDataSource) GetData(symbol string, period datasource.PeriodType, start, end time.Time) ([]*types.DataPoint, error) {
+	data, err := ds.source.GetData(symbol, period, start, end)
+	if err != nil || len(data) < 2 {
+		return data, err
+	}
+
+	logReturns := make([]float64, 0, len(data)-1)
+	for i := 1; i < len(data); i++ {
+		if data[i-1].Close > 0 && data[i].Close > 0 {
+			logReturns = append(logReturns, math.Log(data[i].Close/data[i-1].Close))
+		}
+	}
+	sigma := stats.StdDev(logReturns)
+	if math.IsNaN(sigma) {
+		sigma = 0
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(symbol))
+	rng := rand.New(rand.NewSource(ds.seed ^ int64(h.Sum64())))
+
+	perturbed := make([]*types.DataPoint, len(data))
+	perturbed[0] = data[0]
+	prev := data[0].Close
+	for i := 1; i < len(data); i++ {
+		dp := *data[i]
+		if data[i-1].Close > 0 && dp.Close > 0 {
+			close := prev * math.Exp(math.Log(dp.Close/data[i-1].Close)+ds.noise*sigma*rng.NormFloat64())
+			scale := close / dp.Close
+			dp.Open *= scale
+			dp.High *= scale
+			dp.Low *= scale
+			dp.Close = close
+		}
+		prev = dp.Close
+		perturbed[i] = &dp
+	}
+	return perturbed, nil
+}
+
+func (ds *PerturbedDataSource) GetSupportedPeriods() []datasource.PeriodType {
+	return ds.source.GetSupportedPeriods()
+}
+
+func (ds *PerturbedDataSource) ConvertPeriod(data []*types.DataPoint, targetPeriod datasource.PeriodType) ([]*types.DataPoint, error) {
+	return ds.source.ConvertPeriod(data, targetPeriod)
+}
+
+// RunFunc 在给定数据源上运行一次回测，返回每日净值序列，ctx超时后应尽快返回
+type RunFunc func(ctx context.Context, ds datasource.DataSource) ([]float64, error)
+
+// PricePaths 在加噪声的价格路径上重新运行回测，每次模拟使用不同的种子，
+// run每次调用都应创建新的策略和经纪商实例。设置了config.Timeout时超时的模拟被跳过，计入Aborted
+func PricePaths(source datasource.DataSource, noise, initialCash float64, run RunFunc, config Config) (*Result, error) {
+	observed, err := run(context.Background(), source)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([][]float64, 0, config.Simulations)
+	aborted := 0
+	for s := 0; s < config.Simulations; s++ {
+		values, err := runWithTimeout(run, NewPerturbedDataSource(source, noise, config.Seed+int64(s)), config.Timeout)
+		if errors.Is(err, context.DeadlineExceeded) {
+			aborted++
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("第%d次模拟失败: %v", s+1, err)
+		}
+		paths = append(paths, append([]float64{initialCash}, values...))
+	}
+	if len(paths) == 0 && config.Simulations > 0 {
+		return nil, fmt.Errorf("%d次模拟全部超过时间上限%v", aborted, config.Timeout)
+	}
+	result := summarize("价格路径扰动", append([]float64{initialCash}, observed...), paths, config.PeriodsPerYear, config)
+	result.Aborted = aborted
+	return result, nil
+}
+
+func runWithTimeout(run RunFunc, ds datasource.DataSource, timeout time.Duration) ([]float64, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return run(ctx, ds)
+}
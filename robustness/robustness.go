@@ -0,0 +1,232 @@
+// Package robustness 回测结果的稳健性分析：对交易盈亏、日收益率或价格路径重抽样，
+// 得到最终资产、最大回撤、夏普比率的分布、置信区间和破产概率
+package robustness
+
+import (
+	"math"
+	"sort"
+	"stock/calendar"
+	"stock/stats"
+	"time"
+)
+
+// Config 重抽样参数
+type Config struct {
+	Simulations    int     // 模拟次数
+	Seed           int64   // 随机种子，相同种子结果可重复
+	BlockSize      float64 // 平稳块自助法的平均块长度（交易日）
+	RuinThreshold  float64 // 权益跌破初始资金的该比例视为破产，如0.5表示亏损一半
+	PeriodsPerYear float64 // 日收益率的年化周期数
+	Percentiles    []float64
+	Timeout        time.Duration // 价格路径扰动每次回测的时间上限，超时的模拟被跳过，0不限
+}
+
+// DefaultConfig 1000次模拟，平均块长度20个交易日，亏损50%视为破产，扇形图分位数5%、25%、50%、75%、95%
+func DefaultConfig() Config {
+	return Config{
+		Simulations:    1000,
+		Seed:           1,
+		BlockSize:      20,
+		RuinThreshold:  0.5,
+		PeriodsPerYear: calendar.DefaultTradingDaysPerYear,
+		Percentiles:    []float64{0.05, 0.25, 0.5, 0.75, 0.95},
+	}
+}
+
+// Metrics 一条权益路径的统计量
+type Metrics struct {
+	FinalValue  float64
+	TotalReturn float64
+	MaxDrawdown float64
+	Sharpe      float64 // 年化夏普比率，无风险利率为0
+	Ruined      bool
+}
+
+// Evaluate 计算权益路径的统计量，values[0]为初始资金
+func Evaluate(values []float64, periodsPerYear, ruinThreshold float64) Metrics {
+	if len(values) == 0 {
+		return Metrics{}
+	}
+	initial := values[0]
+	floor := initial * (1 - ruinThreshold)
+	m := Metrics{FinalValue: values[len(values)-1]}
+	if initial != 0 {
+		m.TotalReturn = m.FinalValue/initial - 1
+	}
+
+	peak := initial
+	returns := make([]float64, 0, len(values)-1)
+	for i, v := range values {
+		if v > peak {
+			peak = v
+		}
+		if peak > 0 {
+			m.MaxDrawdown = math.Max(m.MaxDrawdown, (peak-v)/peak)
+		}
+		if ruinThreshold > 0 && v <= floor {
+			m.Ruined = true
+		}
+		if i > 0 && values[i-1] != 0 {
+			returns = append(returns, v/values[i-1]-1)
+		}
+	}
+	mean, std := stats.MeanStd(returns)
+	if std > 0 {
+		m.Sharpe = mean / std * math.Sqrt(periodsPerYear)
+	}
+	return m
+}
+
+// Distribution 模拟结果的经验分布
+type Distribution struct {
+	Values []float64 // 升序排列的样本
+	Mean   float64
+	Std    float64
+}
+
+// NewDistribution 由样本构造经验分布，NaN和Inf被忽略
+func NewDistribution(samples []float64) Distribution {
+	values := make([]float64, 0, len(samples))
+	for _, v := range samples {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			values = append(values, v)
+		}
+	}
+	sort.Float64s(values)
+	mean, std := stats.MeanStd(values)
+	return Distribution{Values: values, Mean: mean, Std: std}
+}
+
+// Percentile 返回p分位数（0≤p≤1），样本之间线性插值
+func (d Distribution) Percentile(p float64) float64 {
+	return percentile(d.Values, p)
+}
+
+// Interval 返回双侧置信区间，如confidence=0.95返回2.5%和97.5%分位数
+func (d Distribution) Interval(confidence float64) (lo, hi float64) {
+	tail := (1 - confidence) / 2
+	return d.Percentile(tail), d.Percentile(1 - tail)
+}
+
+// Histogram 将样本等宽分为bins组，返回各组左边界和样本数
+func (d Distribution) Histogram(bins int) (edges []float64, counts []int) {
+	if len(d.Values) == 0 || bins <= 0 {
+		return nil, nil
+	}
+	lo, hi := d.Values[0], d.Values[len(d.Values)-1]
+	width := (hi - lo) / float64(bins)
+	edges = make([]float64, bins)
+	counts = make([]int, bins)
+	for i := range edges {
+		edges[i] = lo + width*float64(i)
+	}
+	for _, v := range d.Values {
+		i := bins - 1
+		if width > 0 {
+			i = int((v - lo) / width)
+		}
+		if i >= bins {
+			i = bins - 1
+		}
+		counts[i]++
+	}
+	return edges, counts
+}
+
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := p * float64(len(sorted)-1)
+	i := int(math.Floor(pos))
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	if i < 0 {
+		return sorted[0]
+	}
+	frac := pos - float64(i)
+	return sorted[i] + (sorted[i+1]-sorted[i])*frac
+}
+
+// Fan 扇形图：各分位数在每一步的权益
+type Fan struct {
+	Percentiles []float64
+	Bands       [][]float64 // Bands[i][t]为第i个分位数在第t步的权益
+}
+
+// Result 一种重抽样方法的分析结果
+type Result struct {
+	Method      string
+	Observed    Metrics   // 原始结果
+	Path        []float64 // 原始权益路径
+	Samples     []Metrics // 每次模拟的统计量
+	FinalValue  Distribution
+	MaxDrawdown Distribution
+	Sharpe      Distribution
+	RiskOfRuin  float64 // 模拟中出现破产的比例
+	Fan         Fan
+	Aborted     int // 超时中止、未计入统计的模拟次数
+}
+
+// summarize 汇总模拟路径的统计量、分布和扇形图
+func summarize(method string, observed []float64, paths [][]float64, periodsPerYear float64, config Config) *Result {
+	result := &Result{
+		Method:   method,
+		Observed: Evaluate(observed, periodsPerYear, config.RuinThreshold),
+		Path:     observed,
+		Samples:  make([]Metrics, len(paths)),
+	}
+	finals := make([]float64, len(paths))
+	drawdowns := make([]float64, len(paths))
+	sharpes := make([]float64, len(paths))
+	ruined := 0
+	length := 0
+	for i, path := range paths {
+		m := Evaluate(path, periodsPerYear, config.RuinThreshold)
+		result.Samples[i] = m
+		finals[i], drawdowns[i], sharpes[i] = m.FinalValue, m.MaxDrawdown, m.Sharpe
+		if m.Ruined {
+			ruined++
+		}
+		if len(path) > length {
+			length = len(path)
+		}
+	}
+	result.FinalValue = NewDistribution(finals)
+	result.MaxDrawdown = NewDistribution(drawdowns)
+	result.Sharpe = NewDistribution(sharpes)
+	if len(paths) > 0 {
+		result.RiskOfRuin = float64(ruined) / float64(len(paths))
+	}
+
+	result.Fan.Percentiles = config.Percentiles
+	result.Fan.Bands = make([][]float64, len(config.Percentiles))
+	for i := range result.Fan.Bands {
+		result.Fan.Bands[i] = make([]float64, length)
+	}
+	column := make([]float64, 0, len(paths))
+	for t := 0; t < length; t++ {
+		column = column[:0]
+		for _, path := range paths {
+			if t < len(path) {
+				column = append(column, path[t])
+			}
+		}
+		sort.Float64s(column)
+		for i, p := range config.Percentiles {
+			result.Fan.Bands[i][t] = percentile(column, p)
+		}
+	}
+	return result
+}
+
+// equityPath 由初始资金和每步收益率生成权益路径
+func equityPath(initial float64, returns []float64) []float64 {
+	path := make([]float64, len(returns)+1)
+	path[0] = initial
+	for i, r := range returns {
+		path[i+1] = path[i] * (1 + r)
+	}
+	return path
+}
@@ -0,0 +1,192 @@
+package robustness
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"stock/analyzer"
+	"stock/common/types"
+	"stock/datasource"
+)
+
+func testConfig(simulations int, seed int64) Config {
+	config := DefaultConfig()
+	config.Simulations = simulations
+	config.Seed = seed
+	return config
+}
+
+func sampleReturns(n int) []float64 {
+	rng := rand.New(rand.NewSource(42))
+	returns := make([]float64, n)
+	for i := range returns {
+		returns[i] = 0.0005 + 0.01*rng.NormFloat64()
+	}
+	return returns
+}
+
+func TestReturnsSeedReproducible(t *testing.T) {
+	returns := sampleReturns(250)
+	a, err := Returns(returns, 100000, testConfig(50, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := Returns(returns, 100000, testConfig(50, 3))
+	if !reflect.DeepEqual(a.Samples, b.Samples) || !reflect.DeepEqual(a.Fan, b.Fan) {
+		t.Error("相同种子的模拟结果应完全一致")
+	}
+	c, _ := Returns(returns, 100000, testConfig(50, 4))
+	if reflect.DeepEqual(a.Samples, c.Samples) {
+		t.Error("不同种子的模拟结果不应相同")
+	}
+	if _, err := Returns(returns[:1], 100000, testConfig(50, 3)); err == nil {
+		t.Error("收益率不足时应返回错误")
+	}
+}
+
+func TestStationaryBootstrapBlockLength(t *testing.T) {
+	// 收益率取各自的下标，相邻样本下标连续（含回到开头）即属于同一块
+	n := 1000
+	returns := make([]float64, n)
+	for i := range returns {
+		returns[i] = float64(i)
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, blockSize := range []float64{1, 5, 20} {
+		blocks, length := 0, 0
+		for s := 0; s < 200; s++ {
+			sample := StationaryBootstrap(returns, rng, blockSize)
+			blocks++
+			for t := 1; t < len(sample); t++ {
+				if int(sample[t]) != (int(sample[t-1])+1)%n {
+					blocks++
+				}
+			}
+			length += len(sample)
+		}
+		// 块长度服从均值为blockSize的几何分布，随机跳到下一位置的概率1/n可忽略
+		mean := float64(length) / float64(blocks)
+		if math.Abs(mean-blockSize)/blockSize > 0.05 {
+			t.Errorf("平均块长度%v，期望%v", mean, blockSize)
+		}
+	}
+	if got := StationaryBootstrap(nil, rng, 20); len(got) != 0 {
+		t.Errorf("空序列重抽样得到%v", got)
+	}
+}
+
+func TestEvaluateRuin(t *testing.T) {
+	tests := []struct {
+		values    []float64
+		threshold float64
+		ruined    bool
+		drawdown  float64
+	}{
+		// 恰好亏损一半即视为破产，之后回升也算
+		{[]float64{100, 60, 50, 120}, 0.5, true, 0.5},
+		{[]float64{100, 51, 200}, 0.5, false, 0.49},
+		{[]float64{100, 200, 90}, 0.5, false, 0.55},
+		{[]float64{100, 10}, 0, false, 0.9},
+	}
+	for _, tt := range tests {
+		m := Evaluate(tt.values, 252, tt.threshold)
+		if m.Ruined != tt.ruined || math.Abs(m.MaxDrawdown-tt.drawdown) > 1e-12 {
+			t.Errorf("%v: 破产%v 最大回撤%v，期望%v %v", tt.values, m.Ruined, m.MaxDrawdown, tt.ruined, tt.drawdown)
+		}
+	}
+}
+
+func TestTradesRiskOfRuin(t *testing.T) {
+	// 先亏6万再赚6万会跌破5万的破产线，顺序相反则不会
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	trips := []analyzer.RoundTrip{
+		{Opened: day, Closed: day.AddDate(0, 0, 5), PnL: -60000},
+		{Opened: day.AddDate(0, 0, 10), Closed: day.AddDate(0, 0, 15), PnL: 60000},
+	}
+	result, err := Trades(trips, 100000, false, testConfig(1000, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Observed.Ruined {
+		t.Error("原始顺序先亏后赚，应视为破产")
+	}
+	ruined := 0
+	for _, m := range result.Samples {
+		if m.FinalValue != 100000 {
+			t.Fatalf("打乱顺序不应改变最终资产，得到%v", m.FinalValue)
+		}
+		if m.Ruined {
+			ruined++
+		}
+	}
+	if result.RiskOfRuin != float64(ruined)/1000 || math.Abs(result.RiskOfRuin-0.5) > 0.05 {
+		t.Errorf("破产概率%v，破产路径%d条", result.RiskOfRuin, ruined)
+	}
+
+	// 有放回抽样时只要第一笔抽到亏损就会破产，破产概率约为1/2
+	result, err = Trades(trips, 100000, true, testConfig(1000, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(result.RiskOfRuin-0.5) > 0.05 {
+		t.Errorf("自助法破产概率%v，期望约0.5", result.RiskOfRuin)
+	}
+}
+
+// fixedSource 返回固定K线的数据源
+type fixedSource []*types.DataPoint
+
+func (s fixedSource) GetData(symbol string, period datasource.PeriodType, start, end time.Time) ([]*types.DataPoint, error) {
+	return s, nil
+}
+
+func (s fixedSource) GetSupportedPeriods() []datasource.PeriodType {
+	return []datasource.PeriodType{datasource.PeriodTypeDay}
+}
+
+func (s fixedSource) ConvertPeriod(data []*types.DataPoint, targetPeriod datasource.PeriodType) ([]*types.DataPoint, error) {
+	return data, nil
+}
+
+func TestPerturbedSeedReproducible(t *testing.T) {
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	source := make(fixedSource, 50)
+	for i := range source {
+		c := 10 * (1 + 0.1*math.Sin(float64(i)/3))
+		source[i] = &types.DataPoint{Timestamp: start.AddDate(0, 0, i), Open: c, High: c * 1.02, Low: c * 0.98, Close: c, Volume: 1000}
+	}
+	closes := func(ds datasource.DataSource, symbol string) []float64 {
+		data, err := ds.GetData(symbol, datasource.PeriodTypeDay, start, start.AddDate(1, 0, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]float64, len(data))
+		for i, dp := range data {
+			out[i] = dp.Close
+			if math.Abs(dp.High/dp.Close-1.02) > 1e-12 {
+				t.Fatalf("第%d根K线最高价未按收盘价同比缩放", i)
+			}
+		}
+		return out
+	}
+
+	a := closes(NewPerturbedDataSource(source, 0.5, 7), "600000.SH")
+	if b := closes(NewPerturbedDataSource(source, 0.5, 7), "600000.SH"); !reflect.DeepEqual(a, b) {
+		t.Error("相同种子和代码的价格路径应相同")
+	}
+	if b := closes(NewPerturbedDataSource(source, 0.5, 8), "600000.SH"); reflect.DeepEqual(a, b) {
+		t.Error("不同种子的价格路径不应相同")
+	}
+	if b := closes(NewPerturbedDataSource(source, 0.5, 7), "600036.SH"); reflect.DeepEqual(a, b) {
+		t.Error("不同股票的价格路径不应相同")
+	}
+	if a[0] != source[0].Close {
+		t.Errorf("首根K线收盘价%v，不应扰动", a[0])
+	}
+	if b := closes(NewPerturbedDataSource(source, 0, 7), "600000.SH"); math.Abs(b[len(b)-1]-source[len(source)-1].Close) > 1e-9 {
+		t.Errorf("噪声为0时末根收盘价%v，期望%v", b[len(b)-1], source[len(source)-1].Close)
+	}
+}
@@ -0,0 +1,56 @@
+package robustness
+
+import (
+	"fmt"
+	"math/rand"
+	"stock/analyzer"
+	"stock/calendar"
+)
+
+// Trades 对开平仓交易的盈亏重抽样：replace为false时随机打乱顺序（最终资产不变，检验路径依赖），
+// 为true时有放回抽样（自助法）。权益路径为初始资金依次累加每笔盈亏，夏普比率按每年交易笔数年化
+func Trades(trips []analyzer.RoundTrip, initialCash float64, replace bool, config Config) (*Result, error) {
+	if len(trips) < 2 {
+		return nil, fmt.Errorf("开平仓交易只有%d笔，无法重抽样", len(trips))
+	}
+	pnls := make([]float64, len(trips))
+	for i, trip := range trips {
+		pnls[i] = trip.PnL
+	}
+	// 按首笔开仓到末笔平仓之间的交易日数折算年数
+	perYear := float64(len(trips))
+	days := calendar.Default().TradingDaysBetween(trips[0].Opened, trips[len(trips)-1].Closed)
+	if years := float64(days) / config.PeriodsPerYear; config.PeriodsPerYear > 0 && years > 0 {
+		perYear = float64(len(trips)) / years
+	}
+
+	path := func(order []float64) []float64 {
+		values := make([]float64, len(order)+1)
+		values[0] = initialCash
+		for i, pnl := range order {
+			values[i+1] = values[i] + pnl
+		}
+		return values
+	}
+
+	rng := rand.New(rand.NewSource(config.Seed))
+	paths := make([][]float64, config.Simulations)
+	sample := make([]float64, len(pnls))
+	for s := range paths {
+		if replace {
+			for i := range sample {
+				sample[i] = pnls[rng.Intn(len(pnls))]
+			}
+		} else {
+			copy(sample, pnls)
+			rng.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+		}
+		paths[s] = path(sample)
+	}
+
+	method := "交易重排"
+	if replace {
+		method = "交易自助法"
+	}
+	return summarize(method, path(pnls), paths, perYear, config), nil
+}
@@ -0,0 +1,224 @@
+// Package schedule 提供按交易日历触发的调仓时间表
+package schedule
+
+import (
+	"fmt"
+	"stock/calendar"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 调仓时间表，Due在每个交易日调用一次，返回当日是否触发
+type Schedule interface {
+	Due(t time.Time) bool
+}
+
+// EveryNDays 每N个交易日触发一次，第一个交易日触发
+type EveryNDays struct {
+	n     int
+	count int
+}
+
+func NewEveryNDays(n int) *EveryNDays {
+	if n < 1 {
+		n = 1
+	}
+	return &EveryNDays{n: n}
+}
+
+func (s *EveryNDays) Due(t time.Time) bool {
+	due := s.count%s.n == 0
+	s.count++
+	return due
+}
+
+// periodBoundary 按日历判断周/月首末交易日
+type periodBoundary struct {
+	cal   *calendar.Calendar
+	check func(c *calendar.Calendar, t time.Time) bool
+}
+
+func (s *periodBoundary) Due(t time.Time) bool {
+	return s.check(s.cal, t)
+}
+
+func calendarOrDefault(cal *calendar.Calendar) *calendar.Calendar {
+	if cal == nil {
+		return calendar.Default()
+	}
+	return cal
+}
+
+// FirstOfWeek 每周第一个交易日，cal为nil时使用默认日历
+func FirstOfWeek(cal *calendar.Calendar) Schedule {
+	return &periodBoundary{cal: calendarOrDefault(cal), check: (*calendar.Calendar).IsFirstOfWeek}
+}
+
+// LastOfWeek 每周最后一个交易日
+func LastOfWeek(cal *calendar.Calendar) Schedule {
+	return &periodBoundary{cal: calendarOrDefault(cal), check: (*calendar.Calendar).IsLastOfWeek}
+}
+
+// FirstOfMonth 每月第一个交易日
+func FirstOfMonth(cal *calendar.Calendar) Schedule {
+	return &periodBoundary{cal: calendarOrDefault(cal), check: (*calendar.Calendar).IsFirstOfMonth}
+}
+
+// LastOfMonth 每月最后一个交易日
+func LastOfMonth(cal *calendar.Calendar) Schedule {
+	return &periodBoundary{cal: calendarOrDefault(cal), check: (*calendar.Calendar).IsLastOfMonth}
+}
+
+// Cron 类cron规则，三个字段依次为 日 月 星期，每个字段的写法：
+//
+//	"*"      任意
+//	"1,15"   列表
+//	"1-5"    范围
+//	"*/2"    步长，也可写作 "1-12/3"
+//	"F"/"L"  仅用于日字段，表示当月第一个/最后一个交易日
+//
+// 例如 "L 3,6,9,12 *" 为每季度末最后一个交易日，"* * 1" 为每周一。
+type Cron struct {
+	cal      *calendar.Calendar
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+	firstDay bool
+	lastDay  bool
+	anyDay   bool
+	spec     string
+}
+
+// ParseCron 解析类cron规则，cal为nil时使用默认日历
+func ParseCron(spec string, cal *calendar.Calendar) (*Cron, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("调仓规则需要3个字段(日 月 星期): %q", spec)
+	}
+
+	c := &Cron{cal: calendarOrDefault(cal), spec: spec, days: make(map[int]bool)}
+	for _, part := range strings.Split(fields[0], ",") {
+		switch strings.ToUpper(part) {
+		case "F":
+			c.firstDay = true
+		case "L":
+			c.lastDay = true
+		case "*":
+			c.anyDay = true
+		default:
+			values, err := parseField(part, 1, 31)
+			if err != nil {
+				return nil, fmt.Errorf("日字段错误: %v", err)
+			}
+			for v := range values {
+				c.days[v] = true
+			}
+		}
+	}
+
+	var err error
+	if c.months, err = parseList(fields[1], 1, 12); err != nil {
+		return nil, fmt.Errorf("月字段错误: %v", err)
+	}
+	if c.weekdays, err = parseList(fields[2], 0, 7); err != nil {
+		return nil, fmt.Errorf("星期字段错误: %v", err)
+	}
+	// 与cron一致，0和7都表示周日
+	if c.weekdays[7] {
+		c.weekdays[0] = true
+	}
+	return c, nil
+}
+
+// MustParseCron 与ParseCron相同，出错时panic
+func MustParseCron(spec string, cal *calendar.Calendar) *Cron {
+	c, err := ParseCron(spec, cal)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (c *Cron) String() string {
+	return c.spec
+}
+
+func (c *Cron) Due(t time.Time) bool {
+	if !c.cal.IsTradingDay(t) {
+		return false
+	}
+	if !c.months[int(t.Month())] || !c.weekdays[int(t.Weekday())] {
+		return false
+	}
+	switch {
+	case c.anyDay:
+		return true
+	case c.days[t.Day()]:
+		return true
+	case c.firstDay && c.cal.IsFirstOfMonth(t):
+		return true
+	case c.lastDay && c.cal.IsLastOfMonth(t):
+		return true
+	}
+	return false
+}
+
+// parseList 解析逗号分隔的字段
+func parseList(field string, min, max int) (map[int]bool, error) {
+	result := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		values, err := parseField(part, min, max)
+		if err != nil {
+			return nil, err
+		}
+		for v := range values {
+			result[v] = true
+		}
+	}
+	return result, nil
+}
+
+// parseField 解析单个 * / a-b / a / 带步长的表达式
+func parseField(part string, min, max int) (map[int]bool, error) {
+	step, stepped := 1, false
+	if idx := strings.Index(part, "/"); idx >= 0 {
+		n, err := strconv.Atoi(part[idx+1:])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("步长错误: %q", part)
+		}
+		step, stepped = n, true
+		part = part[:idx]
+	}
+
+	lo, hi := min, max
+	switch {
+	case part == "*":
+	case strings.Contains(part, "-"):
+		bounds := strings.SplitN(part, "-", 2)
+		a, err1 := strconv.Atoi(bounds[0])
+		b, err2 := strconv.Atoi(bounds[1])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("范围错误: %q", part)
+		}
+		lo, hi = a, b
+	default:
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("无法解析: %q", part)
+		}
+		lo, hi = v, v
+		if stepped {
+			hi = max
+		}
+	}
+	if lo < min || hi > max || lo > hi {
+		return nil, fmt.Errorf("取值超出范围[%d,%d]: %q", min, max, part)
+	}
+
+	values := make(map[int]bool)
+	for v := lo; v <= hi; v += step {
+		values[v] = true
+	}
+	return values, nil
+}
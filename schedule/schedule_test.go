@@ -0,0 +1,124 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestEveryNDays(t *testing.T) {
+	s := NewEveryNDays(3)
+	var got []bool
+	for i := 0; i < 7; i++ {
+		got = append(got, s.Due(time.Time{}))
+	}
+	want := []bool{true, false, false, true, false, false, true}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("触发序列%v，期望%v", got, want)
+		}
+	}
+}
+
+func TestPeriodBoundaries(t *testing.T) {
+	tests := []struct {
+		name  string
+		sched Schedule
+		date  string
+		want  bool
+	}{
+		// 2024年春节休市2月9日至2月16日
+		{"LastOfWeek", LastOfWeek(nil), "2024-02-08", true},
+		{"FirstOfWeek", FirstOfWeek(nil), "2024-02-19", true},
+		{"FirstOfWeek", FirstOfWeek(nil), "2024-02-20", false},
+		{"FirstOfMonth", FirstOfMonth(nil), "2024-02-01", true},
+		{"LastOfMonth", LastOfMonth(nil), "2024-02-29", true},
+		{"LastOfMonth", LastOfMonth(nil), "2024-02-28", false},
+	}
+	for _, tt := range tests {
+		if got := tt.sched.Due(day(tt.date)); got != tt.want {
+			t.Errorf("%s(%s) = %v，期望%v", tt.name, tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestCronDue(t *testing.T) {
+	tests := []struct {
+		spec string
+		date string
+		want bool
+	}{
+		{"L 3,6,9,12 *", "2024-03-29", true},
+		{"L 3,6,9,12 *", "2024-03-28", false},
+		{"L 3,6,9,12 *", "2024-04-30", false},
+		{"F * *", "2024-02-01", true},
+		{"F * *", "2024-02-02", false},
+		// 5月1日至5日休市，5月6日为当月第一个交易日
+		{"F * *", "2024-05-06", true},
+		{"F,15 * *", "2024-05-15", true},
+		{"*/2 * *", "2024-01-03", true},
+		{"*/2 * *", "2024-01-04", false},
+		{"* 1-12/3 *", "2024-04-02", true},
+		{"* 1-12/3 *", "2024-05-07", false},
+		{"* * 1", "2024-01-08", true},
+		{"* * 1", "2024-01-09", false},
+		{"* * 1-5", "2024-01-12", true},
+		// 非交易日不触发
+		{"* * *", "2024-01-06", false},
+		{"1 * *", "2024-01-01", false},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.spec, nil)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.spec, err)
+		}
+		if got := c.Due(day(tt.date)); got != tt.want {
+			t.Errorf("%q在%s触发%v，期望%v", tt.spec, tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestCronSunday(t *testing.T) {
+	// 与cron一致，0和7都表示周日
+	for _, spec := range []string{"* * 0", "* * 7", "* * 6-7"} {
+		c := MustParseCron(spec, nil)
+		if !c.weekdays[0] {
+			t.Errorf("%q应包含周日", spec)
+		}
+	}
+	if c := MustParseCron("* * 0", nil); c.weekdays[7] || c.weekdays[1] {
+		t.Errorf("\"* * 0\"只应包含周日: %v", c.weekdays)
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"* *", "需要3个字段"},
+		{"* * * *", "需要3个字段"},
+		{"32 * *", "日字段错误"},
+		{"0 * *", "日字段错误"},
+		{"* 13 *", "月字段错误"},
+		{"* F *", "月字段错误"},
+		{"* * 8", "星期字段错误"},
+		{"*/0 * *", "步长错误"},
+		{"5-1 * *", "取值超出范围"},
+		{"a-b * *", "范围错误"},
+	}
+	for _, tt := range tests {
+		_, err := ParseCron(tt.spec, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q 错误为%v，期望包含%q", tt.spec, err, tt.want)
+		}
+	}
+}
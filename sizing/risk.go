@@ -0,0 +1,186 @@
+package sizing
+
+import (
+	"math"
+	"stock/analyzer"
+	"stock/calendar"
+	"stock/common/types"
+	"stock/indicators"
+	"stock/stats"
+)
+
+// ATRRisk 固定风险仓位：止损距离为ATR的Multiple倍，止损时亏损为权益的Risk比例
+type ATRRisk struct {
+	Risk     float64
+	Multiple float64
+	period   int
+	atr      map[string]*indicators.ATRIndicator
+}
+
+// NewATRRisk 创建ATR风险仓位管理器，例如每笔风险1%、止损2倍ATR(14)
+func NewATRRisk(risk float64, period int, multiple float64) *ATRRisk {
+	return &ATRRisk{Risk: risk, Multiple: multiple, period: period, atr: make(map[string]*indicators.ATRIndicator)}
+}
+
+func (s *ATRRisk) Update(symbol string, bar types.Bar) {
+	atr, ok := s.atr[symbol]
+	if !ok {
+		atr = indicators.NewATR(s.period)
+		s.atr[symbol] = atr
+	}
+	atr.Update(bar)
+}
+
+// StopDistance 当前止损距离，ATR未就绪时返回NaN
+func (s *ATRRisk) StopDistance(symbol string) float64 {
+	atr, ok := s.atr[symbol]
+	if !ok || !atr.Ready() {
+		return math.NaN()
+	}
+	return atr.Value() * s.Multiple
+}
+
+func (s *ATRRisk) Size(ctx Context) float64 {
+	distance := s.StopDistance(ctx.Symbol)
+	if math.IsNaN(distance) || distance <= 0 {
+		return 0
+	}
+	// 期货每张合约的止损亏损为止损距离乘以合约乘数
+	return Fit(ctx, ctx.Equity*s.Risk/(distance*ctx.multiplier()))
+}
+
+func (s *ATRRisk) Name() string { return "atr_risk" }
+
+// VolTarget 波动率目标仓位：持仓市值占权益的比例为 目标年化波动率/标的年化波动率，
+// 不超过MaxWeight
+type VolTarget struct {
+	Target    float64
+	MaxWeight float64
+	period    int
+	annualize float64
+	returns   map[string][]float64
+	prevClose map[string]float64
+}
+
+// NewVolTarget 创建波动率目标仓位管理器，period为估计波动率的收益率个数
+func NewVolTarget(target float64, period int) *VolTarget {
+	if period < 2 {
+		period = 2
+	}
+	return &VolTarget{
+		Target:    target,
+		MaxWeight: 1,
+		period:    period,
+		annualize: calendar.Default().TradingDaysPerYear(),
+		returns:   make(map[string][]float64),
+		prevClose: make(map[string]float64),
+	}
+}
+
+// SetCalendar 使用指定日历的年交易日数年化波动率
+func (s *VolTarget) SetCalendar(cal *calendar.Calendar) {
+	s.annualize = cal.TradingDaysPerYear()
+}
+
+func (s *VolTarget) Update(symbol string, bar types.Bar) {
+	if prev, ok := s.prevClose[symbol]; ok && prev > 0 && bar.Close > 0 {
+		returns := append(s.returns[symbol], math.Log(bar.Close/prev))
+		if len(returns) > s.period {
+			returns = returns[len(returns)-s.period:]
+		}
+		s.returns[symbol] = returns
+	}
+	s.prevClose[symbol] = bar.Close
+}
+
+// Volatility 标的年化波动率，样本不足时返回NaN
+func (s *VolTarget) Volatility(symbol string) float64 {
+	returns := s.returns[symbol]
+	if len(returns) < s.period {
+		return math.NaN()
+	}
+	return stats.StdDev(returns) * math.Sqrt(s.annualize)
+}
+
+func (s *VolTarget) Size(ctx Context) float64 {
+	vol := s.Volatility(ctx.Symbol)
+	if math.IsNaN(vol) || vol <= 0 || ctx.Price <= 0 {
+		return 0
+	}
+	weight := math.Min(s.Target/vol, s.MaxWeight)
+	return Fit(ctx, ctx.Equity*weight/ctx.UnitValue())
+}
+
+func (s *VolTarget) Name() string { return "vol_target" }
+
+// Kelly 分数凯利仓位：由最近Window笔已平仓交易的胜率和盈亏比估计凯利比例，
+// 乘以Fraction后作为仓位占权益的比例；交易数不足MinTrades时使用Fallback比例
+type Kelly struct {
+	Fraction  float64
+	Window    int
+	MinTrades int
+	Fallback  float64
+	MaxWeight float64
+}
+
+// NewKelly 创建分数凯利仓位管理器，常用半凯利 fraction=0.5
+func NewKelly(fraction float64, window int) *Kelly {
+	return &Kelly{Fraction: fraction, Window: window, MinTrades: 10, Fallback: 0.1, MaxWeight: 1}
+}
+
+func (s *Kelly) Update(symbol string, bar types.Bar) {}
+
+// Weight 根据历史成交计算仓位比例
+func (s *Kelly) Weight(trades []types.Trade) float64 {
+	returns := TradeReturns(trades)
+	if s.Window > 0 && len(returns) > s.Window {
+		returns = returns[len(returns)-s.Window:]
+	}
+	if len(returns) < s.MinTrades {
+		return s.Fallback
+	}
+
+	// 收益为0的交易不影响最优比例，既不算盈利也不算亏损
+	wins, losses, winSum, lossSum := 0, 0, 0.0, 0.0
+	for _, r := range returns {
+		switch {
+		case r > 0:
+			wins++
+			winSum += r
+		case r < 0:
+			losses++
+			lossSum -= r
+		}
+	}
+	if wins == 0 {
+		return 0
+	}
+
+	// 没有亏损时凯利比例为1，同样乘以Fraction
+	kelly := 1.0
+	if losses > 0 {
+		p := float64(wins) / float64(wins+losses)
+		ratio := (winSum / float64(wins)) / (lossSum / float64(losses))
+		kelly = p - (1-p)/ratio
+	}
+	return math.Max(0, math.Min(kelly*s.Fraction, s.MaxWeight))
+}
+
+func (s *Kelly) Size(ctx Context) float64 {
+	if ctx.Price <= 0 {
+		return 0
+	}
+	return Fit(ctx, ctx.Equity*s.Weight(ctx.Trades)/ctx.UnitValue())
+}
+
+func (s *Kelly) Name() string { return "kelly" }
+
+// TradeReturns 按先进先出配对开平仓成交（analyzer.RoundTrips），返回每笔已平仓交易的收益率（含费用）
+func TradeReturns(trades []types.Trade) []float64 {
+	trips := analyzer.RoundTrips(trades)
+	returns := make([]float64, 0, len(trips))
+	for _, trip := range trips {
+		returns = append(returns, trip.Return())
+	}
+	return returns
+}
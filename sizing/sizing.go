@@ -0,0 +1,119 @@
+// Package sizing 提供开仓数量计算，所有仓位管理器都按整手取整并受可用资金约束
+package sizing
+
+import (
+	"math"
+	"stock/common/types"
+)
+
+// DefaultLot A股每手股数
+const DefaultLot = 100
+
+// Context 下单时的账户与行情信息
+type Context struct {
+	Symbol     string
+	Price      float64                   // 预计成交价
+	Cash       float64                   // 可用资金
+	Equity     float64                   // 账户权益（现金加持仓市值）
+	Lot        float64                   // 每手股数，0表示DefaultLot
+	Multiplier float64                   // 合约乘数，0表示1
+	MarginRate float64                   // 保证金比例，0表示全额现金交易
+	Fee        func(qty float64) float64 // 买入qty股的交易费用，可为nil
+	Trades     []types.Trade             // 历史成交，用于凯利公式等统计
+}
+
+// UnitValue 每股（期货为每张合约）的价值，仓位比例按合约价值计算
+func (ctx Context) UnitValue() float64 {
+	return ctx.Price * ctx.multiplier()
+}
+
+func (ctx Context) multiplier() float64 {
+	if ctx.Multiplier > 0 {
+		return ctx.Multiplier
+	}
+	return 1
+}
+
+// unitOutlay 每股占用的资金，期货只占用保证金
+func (ctx Context) unitOutlay() float64 {
+	if ctx.MarginRate > 0 {
+		return ctx.UnitValue() * ctx.MarginRate
+	}
+	return ctx.UnitValue()
+}
+
+// Sizer 仓位管理器
+type Sizer interface {
+	// Update 每根K线调用一次，用于维护ATR、波动率等状态
+	Update(symbol string, bar types.Bar)
+	// Size 返回买入股数，已按整手取整且不超过可用资金
+	Size(ctx Context) float64
+	Name() string
+}
+
+// Fit 将目标股数向下取整到整手，并保证含费用的成交金额（期货为保证金）不超过可用资金
+func Fit(ctx Context, qty float64) float64 {
+	lot := ctx.Lot
+	if lot <= 0 {
+		lot = DefaultLot
+	}
+	if ctx.Price <= 0 || math.IsNaN(qty) || qty <= 0 {
+		return 0
+	}
+
+	qty = math.Floor(qty/lot) * lot
+	cost := func(q float64) float64 {
+		total := q * ctx.unitOutlay()
+		if ctx.Fee != nil {
+			total += ctx.Fee(q)
+		}
+		return total
+	}
+	if cost(qty) > ctx.Cash {
+		qty = math.Floor(ctx.Cash/ctx.unitOutlay()/lot) * lot
+		for qty > 0 && cost(qty) > ctx.Cash {
+			qty -= lot
+		}
+	}
+	return math.Max(qty, 0)
+}
+
+// FixedCash 每笔买入固定金额，期货按合约价值计算
+type FixedCash struct {
+	Amount float64
+}
+
+func NewFixedCash(amount float64) *FixedCash {
+	return &FixedCash{Amount: amount}
+}
+
+func (s *FixedCash) Update(symbol string, bar types.Bar) {}
+
+func (s *FixedCash) Size(ctx Context) float64 {
+	if ctx.Price <= 0 {
+		return 0
+	}
+	return Fit(ctx, s.Amount/ctx.UnitValue())
+}
+
+func (s *FixedCash) Name() string { return "fixed_cash" }
+
+// PercentEquity 每笔买入账户权益的固定比例，期货按合约价值计算
+type PercentEquity struct {
+	Percent float64
+}
+
+func NewPercentEquity(percent float64) *PercentEquity {
+	return &PercentEquity{Percent: percent}
+}
+
+func (s *PercentEquity) Update(symbol string, bar types.Bar) {}
+
+func (s *PercentEquity) Size(ctx Context) float64 {
+	if ctx.Price <= 0 {
+		return 0
+	}
+	return Fit(ctx, ctx.Equity*s.Percent/ctx.UnitValue())
+}
+
+func (s *PercentEquity) Name() string { return "percent_equity" }
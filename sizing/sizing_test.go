@@ -0,0 +1,163 @@
+package sizing
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"stock/common/types"
+)
+
+func TestFit(t *testing.T) {
+	fixedFee := func(float64) float64 { return 5 }
+	rateFee := func(q float64) float64 { return q * 10 * 0.001 }
+	tests := []struct {
+		name string
+		ctx  Context
+		qty  float64
+		want float64
+	}{
+		{"向下取整到整手", Context{Price: 10, Cash: 1e6, Lot: 100}, 1299, 1200},
+		{"默认每手100股", Context{Price: 10, Cash: 1e6}, 250, 200},
+		{"自定义交易单位", Context{Price: 10, Cash: 1e6, Lot: 10}, 255, 250},
+		{"不足一手", Context{Price: 10, Cash: 1e6}, 99, 0},
+		{"资金不足", Context{Price: 10, Cash: 5000}, 1000, 500},
+		{"固定费用计入成本", Context{Price: 10, Cash: 10000, Fee: fixedFee}, 1000, 900},
+		{"费用恰好用尽资金", Context{Price: 10, Cash: 10010, Fee: rateFee}, 1000, 1000},
+		{"差一分不足", Context{Price: 10, Cash: 10009.99, Fee: rateFee}, 1000, 900},
+		{"价格无效", Context{Price: 0, Cash: 1e6}, 1000, 0},
+		{"数量为NaN", Context{Price: 10, Cash: 1e6}, math.NaN(), 0},
+		{"数量为负", Context{Price: 10, Cash: 1e6}, -100, 0},
+	}
+	for _, tt := range tests {
+		if got := Fit(tt.ctx, tt.qty); got != tt.want {
+			t.Errorf("%s: Fit=%v，期望%v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFuturesSizing(t *testing.T) {
+	// IF合约乘数300，3500点时每张合约价值105万，保证金12%即12.6万
+	ctx := Context{Symbol: "IF2403.CFE", Price: 3500, Cash: 1e6, Equity: 1e6, Lot: 1, Multiplier: 300, MarginRate: 0.12}
+	tests := []struct {
+		name  string
+		sizer Sizer
+		cash  float64
+		want  float64
+	}{
+		{"半仓不足一张", NewPercentEquity(0.5), 1e6, 0},
+		{"3倍杠杆", NewPercentEquity(3), 1e6, 2},
+		{"保证金不足", NewPercentEquity(3), 200000, 1},
+		{"固定金额按合约价值", NewFixedCash(2.2e6), 1e6, 2},
+	}
+	for _, tt := range tests {
+		ctx.Cash = tt.cash
+		if got := tt.sizer.Size(ctx); got != tt.want {
+			t.Errorf("%s: %v张，期望%v张", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestATRRiskWarmup(t *testing.T) {
+	s := NewATRRisk(0.01, 3, 2)
+	ctx := Context{Symbol: "600000.SH", Price: 10, Cash: 1e6, Equity: 1e6}
+	for i := 0; i < 3; i++ {
+		if got := s.Size(ctx); got != 0 {
+			t.Fatalf("ATR就绪前第%d根K线开仓%v股", i, got)
+		}
+		// 真实波幅恒为1
+		s.Update("600000.SH", types.Bar{Open: 10, High: 10.5, Low: 9.5, Close: 10})
+	}
+	// 止损距离2倍ATR即2元，风险1%权益即1万元，开仓5000股
+	if got := s.Size(ctx); got != 5000 {
+		t.Errorf("开仓%v股，期望5000", got)
+	}
+	if got := s.Size(Context{Symbol: "600036.SH", Price: 10, Cash: 1e6, Equity: 1e6}); got != 0 {
+		t.Errorf("没有行情的股票开仓%v股", got)
+	}
+	// 期货每点亏损乘以合约乘数
+	futures := Context{Symbol: "600000.SH", Price: 10, Cash: 1e6, Equity: 1e6, Lot: 1, Multiplier: 10}
+	if got := s.Size(futures); got != 500 {
+		t.Errorf("合约乘数10时开仓%v张，期望500", got)
+	}
+}
+
+func TestVolTargetWarmup(t *testing.T) {
+	s := NewVolTarget(0.2, 5)
+	ctx := Context{Symbol: "600000.SH", Price: 10, Cash: 1e5, Equity: 1e5}
+	// 5个收益率需要6根K线
+	for i := 0; i < 6; i++ {
+		if got := s.Size(ctx); got != 0 {
+			t.Fatalf("波动率就绪前第%d根K线开仓%v股", i, got)
+		}
+		s.Update("600000.SH", types.Bar{Close: 10 + float64(i%2)*0.01})
+	}
+	if vol := s.Volatility("600000.SH"); math.IsNaN(vol) || vol <= 0 {
+		t.Fatalf("波动率%v", vol)
+	}
+	// 波动率远低于目标，仓位以MaxWeight即满仓为上限
+	if got := s.Size(ctx); got != 10000 {
+		t.Errorf("开仓%v股，期望满仓10000股", got)
+	}
+
+	// 年化波动率40%时仓位为目标20%的一半
+	s.MaxWeight = 1
+	returns := s.returns["600000.SH"]
+	scale := 0.4 / s.Volatility("600000.SH")
+	for i := range returns {
+		returns[i] *= scale
+	}
+	if got := s.Size(ctx); got != 5000 {
+		t.Errorf("开仓%v股，期望半仓5000股", got)
+	}
+}
+
+// roundTrips 按收益率依次生成开平仓成交，不计费用
+func roundTrips(returns ...float64) []types.Trade {
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	var trades []types.Trade
+	for i, r := range returns {
+		day := start.AddDate(0, 0, 2*i)
+		trades = append(trades,
+			types.Trade{Timestamp: day, Symbol: "600000.SH", Price: 10, Quantity: 100, Type: types.ActionBuy},
+			types.Trade{Timestamp: day.AddDate(0, 0, 1), Symbol: "600000.SH", Price: 10 * (1 + r), Quantity: 100, Type: types.ActionSell})
+	}
+	return trades
+}
+
+func repeat(r float64, n int) []float64 {
+	returns := make([]float64, n)
+	for i := range returns {
+		returns[i] = r
+	}
+	return returns
+}
+
+func TestKellyWeight(t *testing.T) {
+	// 6笔盈利10%、4笔亏损5%：胜率0.6，盈亏比2，凯利比例0.6−0.4/2=0.4，半凯利0.2
+	mixed := append(repeat(0.1, 6), repeat(-0.05, 4)...)
+	tests := []struct {
+		name    string
+		returns []float64
+		want    float64
+	}{
+		{"交易不足使用Fallback", repeat(0.1, 9), 0.1},
+		{"胜率与盈亏比", mixed, 0.2},
+		{"收益为0的交易不计入", append(append([]float64{}, mixed...), 0, 0), 0.2},
+		{"没有亏损仍乘以Fraction", repeat(0.1, 10), 0.5},
+		{"没有盈利", repeat(-0.05, 10), 0},
+		{"期望为负时不开仓", append(repeat(0.05, 3), repeat(-0.1, 7)...), 0},
+	}
+	for _, tt := range tests {
+		s := NewKelly(0.5, 0)
+		if got := s.Weight(roundTrips(tt.returns...)); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: 仓位比例%v，期望%v", tt.name, got, tt.want)
+		}
+	}
+
+	// 只统计最近Window笔交易
+	s := NewKelly(0.5, 10)
+	if got := s.Weight(roundTrips(append(repeat(-0.05, 20), mixed...)...)); math.Abs(got-0.2) > 1e-9 {
+		t.Errorf("窗口内仓位比例%v，期望0.2", got)
+	}
+}
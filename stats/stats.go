@@ -0,0 +1,35 @@
+// Package stats 样本统计量，样本不足时返回NaN，由调用方决定如何处理
+package stats
+
+import "math"
+
+// Mean 算术平均，空样本返回NaN
+func Mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// StdDev 样本标准差（除以n−1），样本少于2个时返回NaN
+func StdDev(xs []float64) float64 {
+	_, std := MeanStd(xs)
+	return std
+}
+
+// MeanStd 同时返回均值和样本标准差
+func MeanStd(xs []float64) (mean, std float64) {
+	mean = Mean(xs)
+	if len(xs) < 2 {
+		return mean, math.NaN()
+	}
+	sum := 0.0
+	for _, x := range xs {
+		sum += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(sum / float64(len(xs)-1))
+}
@@ -0,0 +1,19 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMeanStd(t *testing.T) {
+	mean, std := MeanStd([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	if mean != 5 || math.Abs(std-math.Sqrt(32.0/7)) > 1e-12 {
+		t.Errorf("均值%v标准差%v，期望5和%v", mean, std, math.Sqrt(32.0/7))
+	}
+	if !math.IsNaN(Mean(nil)) {
+		t.Error("空样本均值应为NaN")
+	}
+	if mean, std := MeanStd([]float64{3}); mean != 3 || !math.IsNaN(std) {
+		t.Errorf("单个样本均值%v标准差%v，期望3和NaN", mean, std)
+	}
+}
@@ -0,0 +1,182 @@
+package strategy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"stock/common/types"
+	"stock/formula"
+	"stock/portfolio"
+	"time"
+)
+
+// FormulaStrategy 通达信公式策略，公式中BUY、SELL两条输出作为买卖条件，其余输出用于绘图
+type FormulaStrategy struct {
+	sizerSupport
+	program  *formula.Program
+	buy      string
+	sell     string
+	lookback int
+	history  map[string][]*types.DataPoint // 按symbol保存历史数据
+	prepared map[string]*preparedSeries    // 回测开始前在完整行情上求值的结果
+}
+
+// preparedSeries 一只股票完整行情的求值结果
+type preparedSeries struct {
+	timestamps []time.Time
+	result     *formula.Result
+}
+
+// NewFormulaStrategy 编译公式并创建策略，公式必须包含BUY和SELL输出
+func NewFormulaStrategy(source string) (*FormulaStrategy, error) {
+	program, err := formula.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	s := &FormulaStrategy{
+		program:  program,
+		buy:      "BUY",
+		sell:     "SELL",
+		history:  make(map[string][]*types.DataPoint),
+		prepared: make(map[string]*preparedSeries),
+	}
+	if err := s.SetSignals("BUY", "SELL"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SetSignals 指定作为买卖条件的输出名
+func (s *FormulaStrategy) SetSignals(buy, sell string) error {
+	outputs := make(map[string]bool)
+	for _, name := range s.program.Outputs() {
+		outputs[name] = true
+	}
+	if !outputs[buy] || !outputs[sell] {
+		return fmt.Errorf("公式缺少买卖条件输出: %s/%s", buy, sell)
+	}
+	s.buy, s.sell = buy, sell
+	return nil
+}
+
+// SetLookback 设置未经Prepare时每次求值使用的最近K线数，默认0表示使用全部历史
+// 截断后BARSLAST、HHV(X,0)、COUNT(X,0)及EMA、SMA等递归平滑与全量计算不一致
+func (s *FormulaStrategy) SetLookback(lookback int) {
+	s.lookback = lookback
+}
+
+// SetParam 设置公式参数
+func (s *FormulaStrategy) SetParam(name string, value float64) {
+	s.program.SetParam(name, value)
+}
+
+func (s *FormulaStrategy) Name() string {
+	return "Formula Strategy"
+}
+
+func (s *FormulaStrategy) OnStart(portfolio *portfolio.Portfolio) error {
+	s.history = make(map[string][]*types.DataPoint)
+	s.prepared = make(map[string]*preparedSeries)
+	return nil
+}
+
+// Prepare 在完整行情上对公式求值一次，OnData按时间读取当根K线的值
+//
+// 内置函数都只引用当前及之前的K线，逐根读取与逐根求值结果相同；
+// MA、EMA、SMA、STD的周期参数取最后一个有效值，周期应为常数
+func (s *FormulaStrategy) Prepare(symbol string, data []*types.DataPoint) error {
+	result, err := s.program.Evaluate(data)
+	if err != nil {
+		return err
+	}
+	timestamps := make([]time.Time, len(data))
+	for i, dp := range data {
+		timestamps[i] = dp.Timestamp
+	}
+	s.prepared[symbol] = &preparedSeries{timestamps: timestamps, result: result}
+	return nil
+}
+
+func (s *FormulaStrategy) OnData(data []*types.DataPoint, portfolio *portfolio.Portfolio) error {
+	for _, dp := range data {
+		s.updateSizer(dp)
+		buy, sell, err := s.signals(dp)
+		if err != nil {
+			return err
+		}
+		if buy {
+			if quantity := s.buyQuantity(portfolio, dp); quantity > 0 {
+				portfolio.Buy(dp.Symbol, dp.Timestamp, dp.Close, quantity)
+			}
+		} else if sell {
+			if quantity := s.sellQuantity(portfolio, dp.Symbol); quantity > 0 {
+				portfolio.Sell(dp.Symbol, dp.Timestamp, dp.Close, quantity)
+			}
+		}
+	}
+	return nil
+}
+
+// signals 当根K线的买卖条件，优先读取Prepare的结果，否则在历史数据上求值
+func (s *FormulaStrategy) signals(dp *types.DataPoint) (buy, sell bool, err error) {
+	if p, ok := s.prepared[dp.Symbol]; ok {
+		i := sort.Search(len(p.timestamps), func(i int) bool {
+			return !p.timestamps[i].Before(dp.Timestamp)
+		})
+		if i < len(p.timestamps) && p.timestamps[i].Equal(dp.Timestamp) {
+			return isTrue(p.result.Series[s.buy], i), isTrue(p.result.Series[s.sell], i), nil
+		}
+	}
+
+	history := append(s.history[dp.Symbol], dp)
+	if s.lookback > 0 && len(history) > s.lookback {
+		history = history[len(history)-s.lookback:]
+	}
+	s.history[dp.Symbol] = history
+	result, err := s.program.Evaluate(history)
+	if err != nil {
+		return false, false, err
+	}
+	last := len(history) - 1
+	return isTrue(result.Series[s.buy], last), isTrue(result.Series[s.sell], last), nil
+}
+
+// isTrue 序列第i个值是否为真，非零且非NaN
+func isTrue(series []float64, i int) bool {
+	if i < 0 || i >= len(series) {
+		return false
+	}
+	v := series[i]
+	return v != 0 && !math.IsNaN(v)
+}
+
+func (s *FormulaStrategy) OnEnd(portfolio *portfolio.Portfolio, symbol string) error {
+	return nil
+}
+
+// Calculate 返回公式中除买卖条件外的输出线
+func (s *FormulaStrategy) Calculate(candles []types.Candle) map[string][]float64 {
+	data := make([]*types.DataPoint, len(candles))
+	for i, c := range candles {
+		data[i] = &types.DataPoint{
+			Timestamp: c.Timestamp,
+			Open:      c.Open,
+			High:      c.High,
+			Low:       c.Low,
+			Close:     c.Close,
+			Volume:    c.Volume,
+		}
+	}
+
+	result, err := s.program.Evaluate(data)
+	if err != nil {
+		return nil
+	}
+	lines := make(map[string][]float64)
+	for _, name := range result.Names {
+		if name != s.buy && name != s.sell {
+			lines[name] = result.Series[name]
+		}
+	}
+	return lines
+}
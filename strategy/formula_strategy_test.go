@@ -0,0 +1,74 @@
+package strategy
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"stock/broker"
+	"stock/common/types"
+	"stock/orders"
+	"stock/portfolio"
+)
+
+func formulaBars(n int) []*types.DataPoint {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := make([]*types.DataPoint, n)
+	for i := range bars {
+		c := 10 + float64(i)*0.01 + 2*math.Sin(float64(i)/15) + 0.3*math.Sin(float64(i)*1.7)
+		bars[i] = &types.DataPoint{
+			Symbol: "600000.SH", Timestamp: start.AddDate(0, 0, i),
+			Open: c, High: c * 1.01, Low: c * 0.99, Close: c, Volume: 1e6,
+		}
+	}
+	return bars
+}
+
+// runFormula 逐根K线运行公式策略，返回成交记录
+func runFormula(t *testing.T, s *FormulaStrategy, bars []*types.DataPoint, prepare bool) []types.Trade {
+	t.Helper()
+	b := broker.NewSimulatedBroker(broker.NewFixedFeeCalculator(0), nil, 1e6)
+	p := portfolio.NewPortfolio(1e6, b, orders.NewOrderManager(b))
+	if err := s.OnStart(p); err != nil {
+		t.Fatal(err)
+	}
+	if prepare {
+		if err := s.Prepare("600000.SH", bars); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dp := range bars {
+		p.UpdatePrice(dp.Symbol, dp.Close)
+		if err := s.OnData([]*types.DataPoint{dp}, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p.Trades()
+}
+
+func TestFormulaPrepareMatchesFullHistory(t *testing.T) {
+	// BARSLAST、HHV(X,0)和COUNT(X,0)依赖全部历史
+	s, err := NewFormulaStrategy(`
+		TOP:=BARSLAST(C>=HHV(C,0));
+		BUY:TOP=0 AND COUNT(C>REF(C,1),0)>10;
+		SELL:TOP>=15;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bars := formulaBars(700)
+
+	want := runFormula(t, s, bars, false)
+	got := runFormula(t, s, bars, true)
+	if len(want) == 0 {
+		t.Fatal("测试数据应产生成交")
+	}
+	if len(got) != len(want) {
+		t.Fatalf("预先求值成交%d笔，逐根求值成交%d笔", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Timestamp.Equal(want[i].Timestamp) || got[i].Type != want[i].Type {
+			t.Fatalf("第%d笔成交%s %s，期望%s %s", i, got[i].Timestamp.Format("2006-01-02"), got[i].Type,
+				want[i].Timestamp.Format("2006-01-02"), want[i].Type)
+		}
+	}
+}
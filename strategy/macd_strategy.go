@@ -6,18 +6,25 @@ import (
 	"stock/common/types"
 	"stock/indicators"
 	"stock/portfolio"
-	"time"
 )
 
 type MACDStrategy struct {
+	sizerSupport
 	Periods         []int
 	fastPeriod      int
 	slowPeriod      int
 	signalPeriod    int
 	prevMACD        float64
 	prevSignal      float64
-	dataBuffer      []types.Bar
-	multiPeriodData map[int][]types.Bar // 存储不同周期的数据
+	states          map[string]*macdState // 按symbol保存流式MACD状态
+	multiPeriodData map[int][]types.Bar   // 存储不同周期的数据
+}
+
+// macdState 单个股票的流式MACD状态
+type macdState struct {
+	macd    *indicators.MACDIndicator
+	prev    types.MACDValue
+	hasPrev bool
 }
 
 func NewMACDStrategy(fast, slow, signal int, periods []int) *MACDStrategy {
@@ -26,7 +33,7 @@ func NewMACDStrategy(fast, slow, signal int, periods []int) *MACDStrategy {
 		slowPeriod:   slow,
 		signalPeriod: signal,
 		Periods:      periods,
-		dataBuffer:   make([]types.Bar, 0),
+		states:       make(map[string]*macdState),
 		prevMACD:     math.NaN(),
 		prevSignal:   math.NaN(),
 	}
@@ -105,7 +112,7 @@ func (s *MACDStrategy) Run(data []types.Bar) []types.Signal {
 
 // OnStart initializes the strategy
 func (s *MACDStrategy) OnStart(portfolio *portfolio.Portfolio) error {
-	s.dataBuffer = make([]types.Bar, 0)
+	s.states = make(map[string]*macdState)
 	return nil
 }
 
@@ -113,8 +120,16 @@ func (s *MACDStrategy) OnStart(portfolio *portfolio.Portfolio) error {
 func (s *MACDStrategy) OnData(data []*types.DataPoint, portfolio *portfolio.Portfolio) error {
 	// Process each stock's data point
 	for _, dp := range data {
-		// Add new data point to buffer
-		s.dataBuffer = append(s.dataBuffer, types.Bar{
+		state, exists := s.states[dp.Symbol]
+		if !exists {
+			state = &macdState{macd: indicators.NewMACD(s.fastPeriod, s.slowPeriod, s.signalPeriod)}
+			s.states[dp.Symbol] = state
+		}
+
+		s.updateSizer(dp)
+
+		// Update MACD incrementally with the new bar
+		state.macd.Update(types.Bar{
 			Time:   dp.Timestamp.Unix(),
 			Open:   dp.Open,
 			High:   dp.High,
@@ -122,43 +137,26 @@ func (s *MACDStrategy) OnData(data []*types.DataPoint, portfolio *portfolio.Port
 			Close:  dp.Close,
 			Volume: dp.Volume,
 		})
-
-		// Need at least slowPeriod + signalPeriod bars to calculate MACD
-		if len(s.dataBuffer) < s.slowPeriod+s.signalPeriod {
-			continue
-		}
-
-		// Calculate MACD values
-		macdValues, err := indicators.MACD(s.dataBuffer, s.fastPeriod, s.slowPeriod, s.signalPeriod)
-		if err != nil {
-			return err
-		}
-		if len(macdValues) == 0 {
+		if !state.macd.SignalReady() {
 			continue
 		}
 
-		// Use the last two MACD values to detect crossovers
-		if len(macdValues) < 2 {
-			continue
-		}
-
-		prevMACD := macdValues[len(macdValues)-2].MACD
-		prevSignal := macdValues[len(macdValues)-2].Signal
-		currentMACD := macdValues[len(macdValues)-1].MACD
-		currentSignal := macdValues[len(macdValues)-1].Signal
-
-		// Generate buy/sell signals based on MACD crossover
-		if len(s.dataBuffer) == 0 {
-			continue
-		}
-		latestBar := s.dataBuffer[len(s.dataBuffer)-1]
-		if prevMACD < prevSignal && currentMACD > currentSignal {
-			quantity := 1.0 // default quantity
-			portfolio.Buy(dp.Symbol, time.Unix(latestBar.Time, 0), latestBar.Close, quantity)
-		} else if prevMACD > prevSignal && currentMACD < currentSignal {
-			quantity := 1.0 // 默认交易1单位
-			portfolio.Sell(dp.Symbol, time.Unix(latestBar.Time, 0), latestBar.Close, quantity)
+		// Use the previous and current MACD values to detect crossovers
+		current := state.macd.MACDValue()
+		if state.hasPrev {
+			prev := state.prev
+			if prev.MACD < prev.Signal && current.MACD > current.Signal {
+				if quantity := s.buyQuantity(portfolio, dp); quantity > 0 {
+					portfolio.Buy(dp.Symbol, dp.Timestamp, dp.Close, quantity)
+				}
+			} else if prev.MACD > prev.Signal && current.MACD < current.Signal {
+				if quantity := s.sellQuantity(portfolio, dp.Symbol); quantity > 0 {
+					portfolio.Sell(dp.Symbol, dp.Timestamp, dp.Close, quantity)
+				}
+			}
 		}
+		state.prev = current
+		state.hasPrev = true
 	}
 	return nil
 }
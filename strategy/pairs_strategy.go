@@ -0,0 +1,182 @@
+package strategy
+
+import (
+	"fmt"
+	"math"
+	"stock/common/types"
+	"stock/pairs"
+	"stock/portfolio"
+	"stock/sizing"
+	"time"
+)
+
+// PairsStrategy 配对交易策略：价差 y - α - β·x 的Z值偏离过大时做多/做空价差，回归后平仓
+//
+// 做多价差为买入y、卖空β倍的x，做空价差相反，两条腿原子成交。
+// 做空需要经纪商允许卖空（SimulatedBroker.SetAllowShort）。
+type PairsStrategy struct {
+	y, x      string
+	estimator pairs.HedgeEstimator
+	window    int
+	zscore    *pairs.ZScore
+	entry     float64
+	exit      float64
+	stop      float64
+	fraction  float64
+	lot       float64
+	state     int // 1 做多价差，-1 做空价差，0 空仓
+	last      float64
+	errs      []error
+}
+
+// NewPairsStrategy 创建配对交易策略，y、x为两条腿的代码，window为Z值的滚动窗口
+// 默认 |Z|>2 开仓、|Z|<0.5 平仓，两条腿合计使用一半权益
+func NewPairsStrategy(y, x string, estimator pairs.HedgeEstimator, window int) *PairsStrategy {
+	return &PairsStrategy{
+		y:         y,
+		x:         x,
+		estimator: estimator,
+		window:    window,
+		zscore:    pairs.NewZScore(window),
+		entry:     2,
+		exit:      0.5,
+		fraction:  0.5,
+		lot:       sizing.DefaultLot,
+		last:      math.NaN(),
+	}
+}
+
+// SetThresholds 设置开仓、平仓和止损的Z值阈值，stop为0表示不止损
+func (s *PairsStrategy) SetThresholds(entry, exit, stop float64) {
+	s.entry, s.exit, s.stop = entry, exit, stop
+}
+
+// SetFraction 设置两条腿合计占用的权益比例
+func (s *PairsStrategy) SetFraction(fraction float64) {
+	s.fraction = fraction
+}
+
+// SetLot 设置每手股数
+func (s *PairsStrategy) SetLot(lot float64) {
+	s.lot = lot
+}
+
+// ZScore 最近一根K线的价差Z值
+func (s *PairsStrategy) ZScore() float64 {
+	return s.last
+}
+
+// Errors 返回开平仓下单失败的错误，按日期排列
+func (s *PairsStrategy) Errors() []error {
+	return s.errs
+}
+
+func (s *PairsStrategy) Name() string {
+	return "Pairs Strategy"
+}
+
+func (s *PairsStrategy) OnStart(portfolio *portfolio.Portfolio) error {
+	s.zscore = pairs.NewZScore(s.window)
+	s.state = 0
+	s.last = math.NaN()
+	s.errs = nil
+	return nil
+}
+
+func (s *PairsStrategy) OnData(data []*types.DataPoint, portfolio *portfolio.Portfolio) error {
+	var y, x *types.DataPoint
+	for _, dp := range data {
+		switch dp.Symbol {
+		case s.y:
+			y = dp
+		case s.x:
+			x = dp
+		}
+	}
+	// 任意一条腿停牌时不更新也不交易
+	if y == nil || x == nil {
+		return nil
+	}
+
+	s.estimator.Update(y.Close, x.Close)
+	if !s.estimator.Ready() {
+		return nil
+	}
+	beta := s.estimator.Beta()
+	z := s.zscore.Update(pairs.Spread(y.Close, x.Close, s.estimator.Alpha(), beta))
+	s.last = z
+	if math.IsNaN(z) {
+		return nil
+	}
+
+	switch {
+	case s.state != 0 && s.stop > 0 && math.Abs(z) >= s.stop:
+		s.close(y.Timestamp, portfolio, y.Close, x.Close)
+	case s.state == 1 && z >= -s.exit, s.state == -1 && z <= s.exit:
+		s.close(y.Timestamp, portfolio, y.Close, x.Close)
+	case s.state == 0 && beta > 0 && math.Abs(z) >= s.entry && (s.stop <= 0 || math.Abs(z) < s.stop):
+		direction := 1
+		if z > 0 {
+			direction = -1
+		}
+		s.open(y.Timestamp, portfolio, direction, beta, y.Close, x.Close)
+	}
+	return nil
+}
+
+// open 按对冲比例计算两条腿的数量并同时下单
+func (s *PairsStrategy) open(timestamp time.Time, p *portfolio.Portfolio, direction int, beta, py, px float64) {
+	budget := p.GetValue() * s.fraction
+	qy := math.Floor(budget/(py+beta*px)/s.lot) * s.lot
+	qx := math.Round(beta*qy/s.lot) * s.lot
+	if qy <= 0 || qx <= 0 {
+		return
+	}
+
+	legs := []portfolio.Leg{
+		{Symbol: s.y, Action: types.ActionBuy, Price: py, Quantity: qy},
+		{Symbol: s.x, Action: types.ActionSell, Price: px, Quantity: qx},
+	}
+	if direction < 0 {
+		legs[0].Action, legs[1].Action = types.ActionSell, types.ActionBuy
+	}
+	if err := p.ExecuteLegs(timestamp, legs); err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s开仓: %w", timestamp.Format("2006-01-02"), err))
+		return
+	}
+	s.state = direction
+}
+
+// close 同时平掉两条腿的持仓
+func (s *PairsStrategy) close(timestamp time.Time, p *portfolio.Portfolio, py, px float64) {
+	var legs []portfolio.Leg
+	positions := p.GetPositions()
+	for _, leg := range []struct {
+		symbol string
+		price  float64
+	}{{s.y, py}, {s.x, px}} {
+		switch qty := positions[leg.symbol]; {
+		case qty > 0:
+			legs = append(legs, portfolio.Leg{Symbol: leg.symbol, Action: types.ActionSell, Price: leg.price, Quantity: qty})
+		case qty < 0:
+			legs = append(legs, portfolio.Leg{Symbol: leg.symbol, Action: types.ActionBuy, Price: leg.price, Quantity: -qty})
+		}
+	}
+	if len(legs) == 0 {
+		s.state = 0
+		return
+	}
+	if err := p.ExecuteLegs(timestamp, legs); err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s平仓: %w", timestamp.Format("2006-01-02"), err))
+		return
+	}
+	s.state = 0
+}
+
+func (s *PairsStrategy) OnEnd(portfolio *portfolio.Portfolio, symbol string) error {
+	return nil
+}
+
+func (s *PairsStrategy) Calculate(candles []types.Candle) map[string][]float64 {
+	return map[string][]float64{}
+}
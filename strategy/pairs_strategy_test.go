@@ -0,0 +1,83 @@
+package strategy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"stock/broker"
+	"stock/common/types"
+	"stock/orders"
+	"stock/portfolio"
+)
+
+// fixedHedge 固定对冲比例 y = x
+type fixedHedge struct{}
+
+func (fixedHedge) Update(y, x float64) {}
+func (fixedHedge) Ready() bool         { return true }
+func (fixedHedge) Beta() float64       { return 1 }
+func (fixedHedge) Alpha() float64      { return 0 }
+func (fixedHedge) Name() string        { return "fixed" }
+
+// runPairs 逐日输入两条腿的收盘价，x恒为10
+func runPairs(t *testing.T, s *PairsStrategy, ys []float64, allowShort bool) *portfolio.Portfolio {
+	t.Helper()
+	b := broker.NewSimulatedBroker(broker.NewFixedFeeCalculator(0.0003), nil, 100000)
+	b.SetAllowShort(allowShort)
+	p := portfolio.NewPortfolio(100000, b, orders.NewOrderManager(b))
+	if err := s.OnStart(p); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i, y := range ys {
+		day := start.AddDate(0, 0, i)
+		bars := []*types.DataPoint{
+			{Symbol: "600000.SH", Timestamp: day, Close: y},
+			{Symbol: "600036.SH", Timestamp: day, Close: 10},
+		}
+		for _, dp := range bars {
+			p.UpdatePrice(dp.Symbol, dp.Close)
+		}
+		if err := s.OnData(bars, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+func TestPairsOrderErrors(t *testing.T) {
+	// 价差在0附近窄幅波动后跳升到0.5，Z值超过2，做空价差需要卖空600000.SH
+	ys := []float64{10.01, 9.99, 10.01, 9.99, 10.01, 9.99, 10.01, 9.99, 10.01, 10.5}
+
+	s := NewPairsStrategy("600000.SH", "600036.SH", fixedHedge{}, 10)
+	p := runPairs(t, s, ys, false)
+	if s.ZScore() < 2 {
+		t.Fatalf("Z值%v，期望超过开仓阈值", s.ZScore())
+	}
+	if len(p.Trades()) != 0 {
+		t.Fatalf("不允许卖空时成交%d笔", len(p.Trades()))
+	}
+	errs := s.Errors()
+	if len(errs) != 1 || !errors.Is(errs[0], types.ErrInsufficientPosition) {
+		t.Fatalf("下单错误%v，期望记录卖空失败", errs)
+	}
+
+	// 允许卖空时两条腿同时成交，价差回归后平仓
+	s = NewPairsStrategy("600000.SH", "600036.SH", fixedHedge{}, 10)
+	p = runPairs(t, s, append(ys, 10), true)
+	if len(s.Errors()) != 0 {
+		t.Fatalf("下单错误%v", s.Errors())
+	}
+	if trades := p.Trades(); len(trades) != 4 {
+		t.Errorf("成交%d笔，期望开平仓各两笔", len(trades))
+	}
+	if positions := p.GetPositions(); positions["600000.SH"] != 0 || positions["600036.SH"] != 0 {
+		t.Errorf("平仓后仍有持仓%v", positions)
+	}
+
+	// 重新开始回测时清空错误
+	if err := s.OnStart(p); err != nil || s.Errors() != nil {
+		t.Errorf("OnStart后错误%v", s.Errors())
+	}
+}
@@ -0,0 +1,88 @@
+package strategy
+
+import (
+	"fmt"
+	"stock/common/types"
+	"stock/portfolio"
+	"stock/schedule"
+	"stock/sizing"
+	"time"
+)
+
+// WeightFunc 根据当日行情计算目标权重
+type WeightFunc func(timestamp time.Time, data []*types.DataPoint) map[string]float64
+
+// RebalanceStrategy 定期调仓策略，按时间表在触发日把组合调整到目标权重
+type RebalanceStrategy struct {
+	schedule schedule.Schedule
+	weights  WeightFunc
+	lot      float64
+	errs     []error
+}
+
+// NewRebalanceStrategy 创建固定目标权重的调仓策略
+func NewRebalanceStrategy(sched schedule.Schedule, weights map[string]float64) *RebalanceStrategy {
+	target := make(map[string]float64, len(weights))
+	for symbol, weight := range weights {
+		target[symbol] = weight
+	}
+	return NewDynamicRebalanceStrategy(sched, func(time.Time, []*types.DataPoint) map[string]float64 {
+		return target
+	})
+}
+
+// NewDynamicRebalanceStrategy 创建在每个调仓日调用weights计算目标权重的调仓策略
+func NewDynamicRebalanceStrategy(sched schedule.Schedule, weights WeightFunc) *RebalanceStrategy {
+	return &RebalanceStrategy{schedule: sched, weights: weights, lot: sizing.DefaultLot}
+}
+
+// EqualWeights 等权分配，总权重为gross
+func EqualWeights(symbols []string, gross float64) map[string]float64 {
+	weights := make(map[string]float64, len(symbols))
+	for _, symbol := range symbols {
+		weights[symbol] = gross / float64(len(symbols))
+	}
+	return weights
+}
+
+// SetLot 设置每手股数
+func (s *RebalanceStrategy) SetLot(lot float64) {
+	s.lot = lot
+}
+
+// Errors 返回调仓时个别股票下单失败的错误，按调仓日期排列
+func (s *RebalanceStrategy) Errors() []error {
+	return s.errs
+}
+
+func (s *RebalanceStrategy) Name() string {
+	return "Rebalance Strategy"
+}
+
+func (s *RebalanceStrategy) OnStart(portfolio *portfolio.Portfolio) error {
+	s.errs = nil
+	return nil
+}
+
+func (s *RebalanceStrategy) OnData(data []*types.DataPoint, portfolio *portfolio.Portfolio) error {
+	if len(data) == 0 {
+		return nil
+	}
+	timestamp := data[0].Timestamp
+	if !s.schedule.Due(timestamp) {
+		return nil
+	}
+	// 个别股票下单失败（停牌、风控拒绝等）不影响其余股票调仓，错误记录下来供回测结束后查看
+	if err := portfolio.OrderTargetWeights(timestamp, s.weights(timestamp, data), s.lot); err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s调仓: %w", timestamp.Format("2006-01-02"), err))
+	}
+	return nil
+}
+
+func (s *RebalanceStrategy) OnEnd(portfolio *portfolio.Portfolio, symbol string) error {
+	return nil
+}
+
+func (s *RebalanceStrategy) Calculate(candles []types.Candle) map[string][]float64 {
+	return map[string][]float64{}
+}
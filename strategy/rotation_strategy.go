@@ -0,0 +1,126 @@
+package strategy
+
+import (
+	"fmt"
+	"stock/common/types"
+	"stock/factor"
+	"stock/portfolio"
+	"stock/schedule"
+	"stock/sizing"
+)
+
+// RotationStrategy 因子轮动策略：调仓日按因子得分选股，等权持有
+type RotationStrategy struct {
+	schedule  schedule.Schedule
+	pipeline  *factor.Pipeline
+	topN      int
+	quantiles int // 大于0时按分位数分组选股
+	quantile  int
+	gross     float64
+	lot       float64
+	history   map[string][]*types.DataPoint
+	scores    []*factor.Scores
+	errs      []error
+}
+
+// NewRotationStrategy 创建因子轮动策略，每个调仓日持有得分最高的topN只股票
+func NewRotationStrategy(sched schedule.Schedule, pipeline *factor.Pipeline, topN int) *RotationStrategy {
+	return &RotationStrategy{
+		schedule: sched,
+		pipeline: pipeline,
+		topN:     topN,
+		gross:    1,
+		lot:      sizing.DefaultLot,
+		history:  make(map[string][]*types.DataPoint),
+	}
+}
+
+// SetQuantile 改为持有q分位中的第k组（1为得分最高），用于分层回测
+func (s *RotationStrategy) SetQuantile(q, k int) {
+	s.quantiles, s.quantile = q, k
+}
+
+// SetGross 设置总仓位，默认1即满仓
+func (s *RotationStrategy) SetGross(gross float64) {
+	s.gross = gross
+}
+
+// SetLot 设置每手股数
+func (s *RotationStrategy) SetLot(lot float64) {
+	s.lot = lot
+}
+
+// Scores 返回每个调仓日的截面得分
+func (s *RotationStrategy) Scores() []*factor.Scores {
+	return s.scores
+}
+
+// Errors 返回调仓时个别股票下单失败的错误，按调仓日期排列
+func (s *RotationStrategy) Errors() []error {
+	return s.errs
+}
+
+func (s *RotationStrategy) Name() string {
+	return "Rotation Strategy"
+}
+
+func (s *RotationStrategy) OnStart(portfolio *portfolio.Portfolio) error {
+	s.history = make(map[string][]*types.DataPoint)
+	s.scores = nil
+	s.errs = nil
+	return nil
+}
+
+func (s *RotationStrategy) OnData(data []*types.DataPoint, portfolio *portfolio.Portfolio) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	// 只保留因子计算所需的历史
+	keep := s.pipeline.Lookback()
+	for _, dp := range data {
+		history := append(s.history[dp.Symbol], dp)
+		if len(history) > keep {
+			history = history[len(history)-keep:]
+		}
+		s.history[dp.Symbol] = history
+	}
+
+	timestamp := data[0].Timestamp
+	if !s.schedule.Due(timestamp) {
+		return nil
+	}
+
+	// 当日停牌的股票不参与选股
+	histories := make(map[string][]*types.DataPoint, len(data))
+	for _, dp := range data {
+		histories[dp.Symbol] = s.history[dp.Symbol]
+	}
+	scores := s.pipeline.Run(timestamp, histories)
+	s.scores = append(s.scores, scores)
+
+	var selected []string
+	if s.quantiles > 0 {
+		selected = factor.Quantile(scores.Combined, s.quantiles, s.quantile)
+	} else {
+		selected = factor.TopN(scores.Combined, s.topN)
+	}
+
+	weights := map[string]float64{}
+	if len(selected) > 0 {
+		weights = EqualWeights(selected, s.gross)
+	}
+	// 个别股票下单失败不影响其余股票调仓，错误记录下来供回测结束后查看
+	if err := portfolio.OrderTargetWeights(timestamp, weights, s.lot); err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s调仓: %w", timestamp.Format("2006-01-02"), err))
+	}
+	return nil
+}
+
+func (s *RotationStrategy) OnEnd(portfolio *portfolio.Portfolio, symbol string) error {
+	return nil
+}
+
+func (s *RotationStrategy) Calculate(candles []types.Candle) map[string][]float64 {
+	return map[string][]float64{}
+}
@@ -4,15 +4,15 @@ import (
 	"stock/common/types"
 	"stock/indicators"
 	"stock/portfolio"
-	"time"
 )
 
 type RSIStrategy struct {
+	sizerSupport
 	period      int
 	overbought  float64
 	oversold    float64
-	dataBuffer  map[string][]types.Bar         // 按symbol存储数据
-	multiPeriod map[int]map[string][]types.Bar // 多周期数据
+	rsi         map[string]*indicators.RSIIndicator // 按symbol保存流式RSI
+	multiPeriod map[int]map[string][]types.Bar      // 多周期数据
 	logger      types.Logger
 }
 
@@ -21,7 +21,7 @@ func NewRSIStrategy(period int, overbought, oversold float64, logger types.Logge
 		period:      period,
 		overbought:  overbought,
 		oversold:    oversold,
-		dataBuffer:  make(map[string][]types.Bar),
+		rsi:         make(map[string]*indicators.RSIIndicator),
 		multiPeriod: make(map[int]map[string][]types.Bar),
 		logger:      logger,
 	}
@@ -71,20 +71,24 @@ func (s *RSIStrategy) Run(data []types.Bar) []types.Signal {
 }
 
 func (s *RSIStrategy) OnStart(portfolio *portfolio.Portfolio) error {
-	s.dataBuffer = make(map[string][]types.Bar)
+	s.rsi = make(map[string]*indicators.RSIIndicator)
 	return nil
 }
 
 func (s *RSIStrategy) OnData(data []*types.DataPoint, portfolio *portfolio.Portfolio) error {
 	// 处理每个股票的数据点
 	for _, dp := range data {
-		// 初始化symbol的数据缓冲区
-		if _, exists := s.dataBuffer[dp.Symbol]; !exists {
-			s.dataBuffer[dp.Symbol] = make([]types.Bar, 0)
+		// 初始化symbol的RSI指标
+		rsi, exists := s.rsi[dp.Symbol]
+		if !exists {
+			rsi = indicators.NewRSI(s.period)
+			s.rsi[dp.Symbol] = rsi
 		}
 
-		// 添加新数据点到缓冲区
-		s.dataBuffer[dp.Symbol] = append(s.dataBuffer[dp.Symbol], types.Bar{
+		s.updateSizer(dp)
+
+		// 增量更新RSI
+		rsi.Update(types.Bar{
 			Time:   dp.Timestamp.Unix(),
 			Open:   dp.Open,
 			High:   dp.High,
@@ -94,29 +98,20 @@ func (s *RSIStrategy) OnData(data []*types.DataPoint, portfolio *portfolio.Portf
 		})
 
 		// 需要至少period+1个bar来计算RSI
-		if len(s.dataBuffer[dp.Symbol]) < s.period+1 {
-			continue
-		}
-
-		// 计算RSI值
-		rsiValues, err := indicators.RSI(s.dataBuffer[dp.Symbol], s.period)
-		if err != nil {
-			return err
-		}
-		if len(rsiValues) == 0 {
+		if !rsi.Ready() {
 			continue
 		}
-
-		// 使用最新的RSI值
-		currentRSI := rsiValues[len(rsiValues)-1]
+		currentRSI := rsi.Value()
 
 		// 生成交易信号
 		if currentRSI < s.oversold {
-			quantity := 1.0
-			portfolio.Buy(dp.Symbol, time.Unix(s.dataBuffer[dp.Symbol][len(s.dataBuffer[dp.Symbol])-1].Time, 0), dp.Close, quantity)
+			if quantity := s.buyQuantity(portfolio, dp); quantity > 0 {
+				portfolio.Buy(dp.Symbol, dp.Timestamp, dp.Close, quantity)
+			}
 		} else if currentRSI > s.overbought {
-			quantity := 1.0
-			portfolio.Sell(dp.Symbol, time.Unix(s.dataBuffer[dp.Symbol][len(s.dataBuffer[dp.Symbol])-1].Time, 0), dp.Close, quantity)
+			if quantity := s.sellQuantity(portfolio, dp.Symbol); quantity > 0 {
+				portfolio.Sell(dp.Symbol, dp.Timestamp, dp.Close, quantity)
+			}
 		}
 	}
 	return nil
@@ -0,0 +1,508 @@
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"stock/common/types"
+	"stock/indicators"
+	"stock/portfolio"
+	"stock/sizing"
+	"strconv"
+	"strings"
+)
+
+// RuleConfig 规则策略配置
+//
+// 操作数写法：
+//
+//	close/open/high/low/volume  行情字段
+//	30                          常数
+//	RSI(14)                     单输出指标，参数省略时使用默认值
+//	MACD(12,26,9).Signal        多输出指标的某条输出
+//	SMA(20)*1.02                指标乘以系数，用于均线通道
+type RuleConfig struct {
+	Name   string       `json:"name"`
+	Entry  RuleSet      `json:"entry"`
+	Exit   RuleSet      `json:"exit"`
+	Sizing SizingConfig `json:"sizing"`
+	Stops  StopConfig   `json:"stops"`
+}
+
+// RuleSet 条件组，All全部满足且Any至少满足一条（Any为空时不检查）
+type RuleSet struct {
+	All []Rule `json:"all"`
+	Any []Rule `json:"any"`
+}
+
+// Rule 单条比较规则，Op为 > < >= <= cross_above cross_below
+type Rule struct {
+	Left  string `json:"left"`
+	Op    string `json:"op"`
+	Right string `json:"right"`
+}
+
+// SizingConfig 开仓数量，除fixed外均由sizing包计算
+//
+//	fixed       固定股数，Value为股数
+//	cash        固定金额，Value为金额
+//	percent     账户权益比例，Value为0到1之间的比例
+//	atr_risk    每笔风险占权益的比例Value，止损距离为Multiple倍ATR(Period)
+//	vol_target  目标年化波动率Value，以Period个收益率估计波动率
+//	kelly       分数凯利，Value为凯利比例的系数，统计最近Period笔交易
+type SizingConfig struct {
+	Mode     string  `json:"mode"`
+	Value    float64 `json:"value"`
+	Lot      float64 `json:"lot"` // 每手股数，默认sizing.DefaultLot
+	Period   int     `json:"period"`
+	Multiple float64 `json:"multiple"`
+}
+
+// newSizer 按配置创建仓位管理器，fixed返回nil
+func newSizer(config SizingConfig) (sizing.Sizer, error) {
+	period := func(def int) int {
+		if config.Period > 0 {
+			return config.Period
+		}
+		return def
+	}
+
+	switch config.Mode {
+	case "", "fixed":
+		return nil, nil
+	}
+	if config.Value <= 0 {
+		return nil, fmt.Errorf("开仓数量配置错误: %s %v", config.Mode, config.Value)
+	}
+	switch config.Mode {
+	case "cash":
+		return sizing.NewFixedCash(config.Value), nil
+	case "percent":
+		return sizing.NewPercentEquity(config.Value), nil
+	case "atr_risk":
+		multiple := config.Multiple
+		if multiple <= 0 {
+			multiple = 2
+		}
+		return sizing.NewATRRisk(config.Value, period(14), multiple), nil
+	case "vol_target":
+		return sizing.NewVolTarget(config.Value, period(20)), nil
+	case "kelly":
+		return sizing.NewKelly(config.Value, period(20)), nil
+	default:
+		return nil, fmt.Errorf("未知的开仓方式: %s", config.Mode)
+	}
+}
+
+// StopConfig 止损止盈，比例为0表示不启用
+type StopConfig struct {
+	StopLoss     float64 `json:"stop_loss"`     // 相对开仓价的最大亏损比例
+	TakeProfit   float64 `json:"take_profit"`   // 相对开仓价的止盈比例
+	TrailingStop float64 `json:"trailing_stop"` // 相对持仓期间最高价的回撤比例
+	MaxHoldDays  int     `json:"max_hold_days"` // 最长持有的K线数
+}
+
+// LoadRuleConfig 从JSON文件读取规则策略配置
+func LoadRuleConfig(path string) (*RuleConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取规则配置失败: %v", err)
+	}
+	var config RuleConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("解析规则配置失败: %v", err)
+	}
+	return &config, nil
+}
+
+// operand 规则操作数
+type operand struct {
+	field     string  // 行情字段
+	constant  float64 // 常数
+	indicator string  // 指标实例键，例如 MACD(12,26,9)
+	output    string  // 多输出指标的输出名
+	scale     float64
+}
+
+// indicatorSpec 指标名与参数
+type indicatorSpec struct {
+	name   string
+	params []float64
+}
+
+// compiledRule 解析后的规则
+type compiledRule struct {
+	id          int // 规则编号，用于保存穿越判断所需的上一根K线值
+	rule        Rule
+	left, right operand
+}
+
+// ruleState 单个股票的指标实例与持仓状态
+type ruleState struct {
+	indicators map[string]indicators.Indicator
+	prev       map[int][2]float64 // 规则序号 -> 上一根K线的左右值，用于判断穿越
+	entryPrice float64
+	highest    float64
+	held       int
+}
+
+// RuleStrategy 由配置描述的规则策略，入场、出场、仓位和止损均在配置中定义
+type RuleStrategy struct {
+	sizerSupport
+	config   RuleConfig
+	entryAll []compiledRule
+	entryAny []compiledRule
+	exitAll  []compiledRule
+	exitAny  []compiledRule
+	rules    int
+	specs    map[string]indicatorSpec
+	states   map[string]*ruleState
+}
+
+// NewRuleStrategy 校验配置中的指标引用并创建策略
+func NewRuleStrategy(config RuleConfig) (*RuleStrategy, error) {
+	s := &RuleStrategy{
+		config: config,
+		specs:  make(map[string]indicatorSpec),
+		states: make(map[string]*ruleState),
+	}
+	if len(config.Entry.All)+len(config.Entry.Any) == 0 {
+		return nil, fmt.Errorf("规则策略%s缺少入场条件", config.Name)
+	}
+
+	var err error
+	if s.entryAll, err = s.compile(config.Entry.All); err != nil {
+		return nil, err
+	}
+	if s.entryAny, err = s.compile(config.Entry.Any); err != nil {
+		return nil, err
+	}
+	if s.exitAll, err = s.compile(config.Exit.All); err != nil {
+		return nil, err
+	}
+	if s.exitAny, err = s.compile(config.Exit.Any); err != nil {
+		return nil, err
+	}
+
+	if s.sizer, err = newSizer(config.Sizing); err != nil {
+		return nil, err
+	}
+	if s.config.Sizing.Value <= 0 {
+		s.config.Sizing.Value = 1
+	}
+	s.lot = config.Sizing.Lot
+	return s, nil
+}
+
+func (s *RuleStrategy) compile(rules []Rule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		switch rule.Op {
+		case ">", "<", ">=", "<=", "cross_above", "cross_below":
+		default:
+			return nil, fmt.Errorf("未知的比较运算: %s", rule.Op)
+		}
+		left, err := s.parseOperand(rule.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := s.parseOperand(rule.Right)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, compiledRule{id: s.rules, rule: rule, left: left, right: right})
+		s.rules++
+	}
+	return compiled, nil
+}
+
+// parseOperand 解析操作数
+func (s *RuleStrategy) parseOperand(text string) (operand, error) {
+	op := operand{scale: 1}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return op, fmt.Errorf("操作数为空")
+	}
+
+	if idx := strings.LastIndex(text, "*"); idx >= 0 {
+		scale, err := strconv.ParseFloat(strings.TrimSpace(text[idx+1:]), 64)
+		if err != nil {
+			return op, fmt.Errorf("操作数系数错误: %s", text)
+		}
+		op.scale = scale
+		text = strings.TrimSpace(text[:idx])
+	}
+
+	if value, err := strconv.ParseFloat(text, 64); err == nil {
+		op.constant = value
+		return op, nil
+	}
+
+	switch lower := strings.ToLower(text); lower {
+	case "open", "high", "low", "close", "volume":
+		op.field = lower
+		return op, nil
+	}
+
+	name, output := text, ""
+	if idx := strings.LastIndex(text, "."); idx > strings.LastIndex(text, ")") {
+		name, output = text[:idx], text[idx+1:]
+	}
+
+	var params []float64
+	if open := strings.Index(name, "("); open >= 0 {
+		if !strings.HasSuffix(name, ")") {
+			return op, fmt.Errorf("指标引用格式错误: %s", text)
+		}
+		for _, p := range strings.Split(name[open+1:len(name)-1], ",") {
+			if p = strings.TrimSpace(p); p == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(p, 64)
+			if err != nil {
+				return op, fmt.Errorf("指标参数错误: %s", text)
+			}
+			params = append(params, value)
+		}
+		name = name[:open]
+	}
+	name = strings.ToUpper(strings.TrimSpace(name))
+
+	ind, err := indicators.New(name, params...)
+	if err != nil {
+		return op, err
+	}
+	if output != "" {
+		valid := false
+		for _, candidate := range indicators.Outputs(name, ind) {
+			if candidate == output {
+				valid = true
+			}
+		}
+		if !valid {
+			return op, fmt.Errorf("指标%s没有输出%s，可用输出: %s", name, output,
+				strings.Join(indicators.Outputs(name, ind), ","))
+		}
+	}
+
+	parts := make([]string, len(params))
+	for i, p := range params {
+		parts[i] = strconv.FormatFloat(p, 'f', -1, 64)
+	}
+	key := fmt.Sprintf("%s(%s)", name, strings.Join(parts, ","))
+	s.specs[key] = indicatorSpec{name: name, params: params}
+	op.indicator = key
+	op.output = output
+	return op, nil
+}
+
+func (s *RuleStrategy) Name() string {
+	if s.config.Name != "" {
+		return s.config.Name
+	}
+	return "Rule Strategy"
+}
+
+func (s *RuleStrategy) OnStart(portfolio *portfolio.Portfolio) error {
+	s.states = make(map[string]*ruleState)
+	return nil
+}
+
+func (s *RuleStrategy) state(symbol string) *ruleState {
+	st, ok := s.states[symbol]
+	if !ok {
+		st = &ruleState{
+			indicators: make(map[string]indicators.Indicator),
+			prev:       make(map[int][2]float64),
+		}
+		for key, spec := range s.specs {
+			st.indicators[key], _ = indicators.New(spec.name, spec.params...)
+		}
+		s.states[symbol] = st
+	}
+	return st
+}
+
+func (s *RuleStrategy) OnData(data []*types.DataPoint, portfolio *portfolio.Portfolio) error {
+	for _, dp := range data {
+		st := s.state(dp.Symbol)
+		bar := types.Bar{
+			Time:   dp.Timestamp.Unix(),
+			Open:   dp.Open,
+			High:   dp.High,
+			Low:    dp.Low,
+			Close:  dp.Close,
+			Volume: dp.Volume,
+		}
+		for _, ind := range st.indicators {
+			ind.Update(bar)
+		}
+		s.updateSizer(dp)
+
+		// 所有规则每根K线都求值，保证穿越判断使用相邻两根K线
+		entry := s.evaluate(st, dp, s.entryAll, s.entryAny)
+		exit := len(s.exitAll)+len(s.exitAny) > 0 && s.evaluate(st, dp, s.exitAll, s.exitAny)
+
+		qty, _ := portfolio.GetSymbolPosition(dp.Symbol)
+		if qty > 0 {
+			st.held++
+			st.highest = math.Max(st.highest, dp.High)
+			if exit || s.stopTriggered(st, dp) {
+				if err := portfolio.Sell(dp.Symbol, dp.Timestamp, dp.Close, qty); err == nil {
+					st.held = 0
+				}
+			}
+			continue
+		}
+
+		if entry {
+			quantity := s.quantity(portfolio, dp)
+			if quantity <= 0 {
+				continue
+			}
+			// 资金不足时Buy不成交也不返回错误，持仓建立后才记录开仓价
+			err := portfolio.Buy(dp.Symbol, dp.Timestamp, dp.Close, quantity)
+			if held, _ := portfolio.GetSymbolPosition(dp.Symbol); err == nil && held > 0 {
+				st.entryPrice = dp.Close
+				st.highest = dp.Close
+				st.held = 0
+			}
+		}
+	}
+	return nil
+}
+
+// evaluate 求值条件组，不短路以便每条规则都记录上一根K线的值
+func (s *RuleStrategy) evaluate(st *ruleState, dp *types.DataPoint, all, any []compiledRule) bool {
+	result := true
+	for _, rule := range all {
+		if !s.check(st, dp, rule) {
+			result = false
+		}
+	}
+	if len(any) > 0 {
+		matched := false
+		for _, rule := range any {
+			if s.check(st, dp, rule) {
+				matched = true
+			}
+		}
+		result = result && matched
+	}
+	return result
+}
+
+func (s *RuleStrategy) check(st *ruleState, dp *types.DataPoint, rule compiledRule) bool {
+	left := s.value(st, dp, rule.left)
+	right := s.value(st, dp, rule.right)
+	prev, hasPrev := st.prev[rule.id]
+	st.prev[rule.id] = [2]float64{left, right}
+
+	if math.IsNaN(left) || math.IsNaN(right) {
+		return false
+	}
+	switch rule.rule.Op {
+	case ">":
+		return left > right
+	case "<":
+		return left < right
+	case ">=":
+		return left >= right
+	case "<=":
+		return left <= right
+	case "cross_above":
+		return hasPrev && prev[0] <= prev[1] && left > right
+	case "cross_below":
+		return hasPrev && prev[0] >= prev[1] && left < right
+	}
+	return false
+}
+
+func (s *RuleStrategy) value(st *ruleState, dp *types.DataPoint, op operand) float64 {
+	var v float64
+	switch {
+	case op.indicator != "":
+		ind := st.indicators[op.indicator]
+		if !ind.Ready() {
+			return math.NaN()
+		}
+		v = indicators.Output(ind, op.output)
+	case op.field != "":
+		switch op.field {
+		case "open":
+			v = dp.Open
+		case "high":
+			v = dp.High
+		case "low":
+			v = dp.Low
+		case "close":
+			v = dp.Close
+		case "volume":
+			v = dp.Volume
+		}
+	default:
+		v = op.constant
+	}
+	return v * op.scale
+}
+
+// stopTriggered 检查止损、止盈、移动止损和最长持有期
+func (s *RuleStrategy) stopTriggered(st *ruleState, dp *types.DataPoint) bool {
+	stops := s.config.Stops
+	if st.entryPrice <= 0 {
+		return false
+	}
+	ret := (dp.Close - st.entryPrice) / st.entryPrice
+	switch {
+	case stops.StopLoss > 0 && ret <= -stops.StopLoss:
+		return true
+	case stops.TakeProfit > 0 && ret >= stops.TakeProfit:
+		return true
+	case stops.TrailingStop > 0 && dp.Close <= st.highest*(1-stops.TrailingStop):
+		return true
+	case stops.MaxHoldDays > 0 && st.held >= stops.MaxHoldDays:
+		return true
+	}
+	return false
+}
+
+// quantity 计算开仓股数，fixed方式直接使用配置的股数
+func (s *RuleStrategy) quantity(portfolio *portfolio.Portfolio, dp *types.DataPoint) float64 {
+	if s.sizer == nil {
+		return s.config.Sizing.Value
+	}
+	return s.buyQuantity(portfolio, dp)
+}
+
+func (s *RuleStrategy) OnEnd(portfolio *portfolio.Portfolio, symbol string) error {
+	return nil
+}
+
+// Calculate 按规则中引用的指标输出计算整段序列，用于绘图
+func (s *RuleStrategy) Calculate(candles []types.Candle) map[string][]float64 {
+	bars := make([]types.Bar, len(candles))
+	for i, c := range candles {
+		bars[i] = types.Bar{
+			Time:   c.Timestamp.Unix(),
+			Open:   c.Open,
+			High:   c.High,
+			Low:    c.Low,
+			Close:  c.Close,
+			Volume: c.Volume,
+		}
+	}
+
+	result := make(map[string][]float64)
+	for key, spec := range s.specs {
+		ind, _ := indicators.New(spec.name, spec.params...)
+		if multi, ok := ind.(indicators.MultiIndicator); ok {
+			for name, values := range indicators.BatchOutputs(multi, bars) {
+				result[key+"."+name] = values
+			}
+			continue
+		}
+		result[key] = indicators.Batch(ind, bars)
+	}
+	return result
+}
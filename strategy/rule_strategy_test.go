@@ -0,0 +1,153 @@
+package strategy
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"stock/broker"
+	"stock/common/types"
+	"stock/datasource"
+	"stock/orders"
+	"stock/portfolio"
+)
+
+func TestRuleConfigErrors(t *testing.T) {
+	entry := func(rule Rule) RuleConfig {
+		return RuleConfig{Entry: RuleSet{All: []Rule{rule}}}
+	}
+	tests := []struct {
+		name   string
+		config RuleConfig
+		want   string
+	}{
+		{"缺少入场条件", RuleConfig{Name: "空"}, "缺少入场条件"},
+		{"未知运算", entry(Rule{Left: "close", Op: "==", Right: "1"}), "未知的比较运算"},
+		{"操作数为空", entry(Rule{Left: "", Op: ">", Right: "1"}), "操作数为空"},
+		{"系数错误", entry(Rule{Left: "SMA(20)*x", Op: ">", Right: "1"}), "操作数系数错误"},
+		{"括号未闭合", entry(Rule{Left: "SMA(20", Op: ">", Right: "1"}), "指标引用格式错误"},
+		{"参数错误", entry(Rule{Left: "SMA(a)", Op: ">", Right: "1"}), "指标参数错误"},
+		{"未知指标", entry(Rule{Left: "FOO(3)", Op: ">", Right: "1"}), "FOO"},
+		{"未知输出", entry(Rule{Left: "MACD(12,26,9).Foo", Op: ">", Right: "0"}), "没有输出Foo"},
+		{"出场条件错误", RuleConfig{
+			Entry: RuleSet{All: []Rule{{Left: "close", Op: ">", Right: "1"}}},
+			Exit:  RuleSet{Any: []Rule{{Left: "close", Op: "above", Right: "1"}}},
+		}, "未知的比较运算"},
+		{"未知开仓方式", RuleConfig{
+			Entry:  RuleSet{All: []Rule{{Left: "close", Op: ">", Right: "1"}}},
+			Sizing: SizingConfig{Mode: "all_in", Value: 1},
+		}, "未知的开仓方式"},
+		{"开仓数量非正", RuleConfig{
+			Entry:  RuleSet{All: []Rule{{Left: "close", Op: ">", Right: "1"}}},
+			Sizing: SizingConfig{Mode: "percent"},
+		}, "开仓数量配置错误"},
+	}
+	for _, tt := range tests {
+		_, err := NewRuleStrategy(tt.config)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: 错误为%v，期望包含%q", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := LoadRuleConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("配置文件不存在时应返回错误")
+	}
+}
+
+// runRules 逐根K线运行规则策略，返回组合
+func runRules(t *testing.T, s *RuleStrategy, bars []*types.DataPoint, cash float64) *portfolio.Portfolio {
+	t.Helper()
+	b := broker.NewSimulatedBroker(broker.NewFixedFeeCalculator(0.0003), nil, cash)
+	p := portfolio.NewPortfolio(cash, b, orders.NewOrderManager(b))
+	if err := s.OnStart(p); err != nil {
+		t.Fatal(err)
+	}
+	for _, dp := range bars {
+		p.UpdatePrice(dp.Symbol, dp.Close)
+		if err := s.OnData([]*types.DataPoint{dp}, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+func TestRuleSamples(t *testing.T) {
+	bars, err := datasource.NewCSVDataSource("../data/cmb.csv").GetData("600036.SH", datasource.PeriodTypeDay,
+		time.Time{}, time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := make(map[time.Time]int, len(bars))
+	for i, dp := range bars {
+		index[dp.Timestamp] = i
+	}
+
+	paths, err := filepath.Glob("../data/rules/*.json")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("没有找到规则样例: %v", err)
+	}
+	for _, path := range paths {
+		config, err := LoadRuleConfig(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := NewRuleStrategy(*config)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		trades := runRules(t, s, bars, 100000).Trades()
+		if len(trades) < 2 {
+			t.Errorf("%s: 成交%d笔，样例应产生开平仓", config.Name, len(trades))
+			continue
+		}
+
+		// 开仓与平仓交替，每次平仓卖出全部持仓，数量为整手
+		for i, trade := range trades {
+			want := types.ActionBuy
+			if i%2 == 1 {
+				want = types.ActionSell
+			}
+			if trade.Type != want {
+				t.Fatalf("%s: 第%d笔成交为%s，期望%s", config.Name, i, trade.Type, want)
+			}
+			if i%2 == 1 && trade.Quantity != trades[i-1].Quantity {
+				t.Errorf("%s: 第%d笔卖出%v股，开仓%v股", config.Name, i, trade.Quantity, trades[i-1].Quantity)
+			}
+			if math.Mod(trade.Quantity, 100) != 0 {
+				t.Errorf("%s: 第%d笔成交%v股，不是整手", config.Name, i, trade.Quantity)
+			}
+		}
+
+		// 最长持有期：开仓后第max_hold_days根K线平仓
+		if days := config.Stops.MaxHoldDays; days > 0 {
+			for i := 1; i < len(trades); i += 2 {
+				if held := index[trades[i].Timestamp] - index[trades[i-1].Timestamp]; held > days {
+					t.Errorf("%s: %s开仓持有%d根K线，超过%d", config.Name,
+						trades[i-1].Timestamp.Format("2006-01-02"), held, days)
+				}
+			}
+		}
+	}
+}
+
+func TestRuleEntryRequiresFill(t *testing.T) {
+	// 固定开仓股数远超资金，Buy不成交时不应记录开仓价，否则止损按不存在的持仓计算
+	s, err := NewRuleStrategy(RuleConfig{
+		Entry:  RuleSet{All: []Rule{{Left: "close", Op: ">", Right: "0"}}},
+		Sizing: SizingConfig{Mode: "fixed", Value: 1e9},
+		Stops:  StopConfig{StopLoss: 0.05},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bars := formulaBars(5)
+	p := runRules(t, s, bars, 100000)
+	if len(p.Trades()) != 0 {
+		t.Fatalf("资金不足时成交%d笔", len(p.Trades()))
+	}
+	if st := s.states["600000.SH"]; st.entryPrice != 0 || st.highest != 0 {
+		t.Errorf("没有成交却记录了开仓价%v、最高价%v", st.entryPrice, st.highest)
+	}
+}
@@ -12,6 +12,7 @@ type SimpleStrategy struct {
 	macdSlow   int
 	macdSignal int
 	logger     types.Logger
+	lastTime   time.Time // 最近一根K线的时间，结束时按该时间平仓
 }
 
 func (s *SimpleStrategy) Name() string {
@@ -33,6 +34,7 @@ func (s *SimpleStrategy) OnData(data *types.DataPoint, portfolio types.Portfolio
 	if s.logger != nil {
 		s.logger.LogData(data)
 	}
+	s.lastTime = data.Timestamp
 
 	// 获取指标值
 	ma5, hasMA5 := data.Indicators["MA5"]
@@ -109,6 +111,6 @@ func (s *SimpleStrategy) OnEnd(portfolio types.Portfolio, symbol string) {
 
 	// Close any open positions
 	if s.bought {
-		portfolio.Sell(symbol, time.Now(), 0, 1)
+		portfolio.Sell(symbol, s.lastTime, 0, 1)
 	}
 }
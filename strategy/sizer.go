@@ -0,0 +1,78 @@
+package strategy
+
+import (
+	"stock/common/types"
+	"stock/instrument"
+	"stock/portfolio"
+	"stock/sizing"
+)
+
+// sizerSupport 策略的仓位管理部分，未设置仓位管理器时保持每次交易1股
+type sizerSupport struct {
+	sizer sizing.Sizer
+	lot   float64
+}
+
+// SetSizer 设置仓位管理器，设置后买入数量由仓位管理器决定，卖出时清仓
+func (s *sizerSupport) SetSizer(sizer sizing.Sizer) {
+	s.sizer = sizer
+}
+
+// SetLot 设置每手股数，默认sizing.DefaultLot
+func (s *sizerSupport) SetLot(lot float64) {
+	s.lot = lot
+}
+
+// updateSizer 每根K线更新仓位管理器的状态
+func (s *sizerSupport) updateSizer(dp *types.DataPoint) {
+	if s.sizer == nil {
+		return
+	}
+	s.sizer.Update(dp.Symbol, types.Bar{
+		Time:   dp.Timestamp.Unix(),
+		Open:   dp.Open,
+		High:   dp.High,
+		Low:    dp.Low,
+		Close:  dp.Close,
+		Volume: dp.Volume,
+	})
+}
+
+// buyQuantity 计算买入数量
+func (s *sizerSupport) buyQuantity(p *portfolio.Portfolio, dp *types.DataPoint) float64 {
+	if s.sizer == nil {
+		return 1
+	}
+	return s.sizer.Size(sizingContext(p, dp.Symbol, dp.Close, s.lot))
+}
+
+// sellQuantity 计算卖出数量
+func (s *sizerSupport) sellQuantity(p *portfolio.Portfolio, symbol string) float64 {
+	if s.sizer == nil {
+		return 1
+	}
+	qty, _ := p.GetSymbolPosition(symbol)
+	return qty
+}
+
+// sizingContext 根据账户状态构造仓位计算上下文，ETF、可转债等品种使用品种自身的交易单位，
+// 期货按合约乘数计算合约价值，可用资金扣除已占用的保证金
+func sizingContext(p *portfolio.Portfolio, symbol string, price, lot float64) sizing.Context {
+	inst := p.Instrument(symbol)
+	if inst.Class != instrument.ClassStock {
+		lot = inst.LotSize
+	}
+	return sizing.Context{
+		Symbol:     symbol,
+		Price:      price,
+		Cash:       p.AvailableCash() - p.FuturesMargin(),
+		Equity:     p.GetValue(),
+		Lot:        lot,
+		Multiplier: inst.Multiplier,
+		MarginRate: inst.MarginRate,
+		Fee: func(qty float64) float64 {
+			return p.SymbolTradeCost(symbol, types.ActionBuy, price, qty)
+		},
+		Trades: p.Transactions(),
+	}
+}
@@ -3,6 +3,7 @@ package strategy
 import (
 	"stock/common/types"
 	"stock/portfolio"
+	"stock/universe"
 )
 
 type Strategy interface {
@@ -12,3 +13,15 @@ type Strategy interface {
 	Calculate(candles []types.Candle) map[string][]float64
 	Name() string
 }
+
+// PanelStrategy 需要对齐截面数据的策略，回测时以OnPanel代替OnData，
+// 可以区分停牌（缺失）和不在股票池内的股票
+type PanelStrategy interface {
+	OnPanel(panel *universe.Panel, portfolio *portfolio.Portfolio) error
+}
+
+// SeriesStrategy 回测开始前接收每只股票回测区间内的完整行情，用于一次性计算指标序列，
+// 实现者在OnData中只能读取当前及之前K线的值，否则会引入未来数据
+type SeriesStrategy interface {
+	Prepare(symbol string, data []*types.DataPoint) error
+}
@@ -0,0 +1,146 @@
+package universe
+
+import (
+	"time"
+
+	"stock/common/types"
+)
+
+// BarStatus 面板中一只股票在某个交易日的数据状态
+type BarStatus int
+
+const (
+	BarNormal    BarStatus = iota // 当日有K线
+	BarFilled                     // 在股票池内但当日没有K线（停牌或数据缺失），已按前值填充
+	BarMissing                    // 在股票池内但当日没有K线，未填充
+	BarNotListed                  // 不在股票池内（尚未上市或已退市）
+)
+
+func (s BarStatus) String() string {
+	switch s {
+	case BarNormal:
+		return "正常"
+	case BarFilled:
+		return "填充"
+	case BarMissing:
+		return "缺失"
+	case BarNotListed:
+		return "未上市"
+	default:
+		return "未知"
+	}
+}
+
+// FillPolicy 缺失K线的填充方式
+type FillPolicy struct {
+	Forward bool // 沿用上一根K线的收盘价和指标值，成交量为0
+	Limit   int  // 最多连续填充的交易日数，0为不限
+}
+
+// ForwardFill 不限天数的前值填充
+func ForwardFill() FillPolicy {
+	return FillPolicy{Forward: true}
+}
+
+// Panel 按交易日对齐的多股票截面数据
+type Panel struct {
+	Time    time.Time
+	Symbols []string
+	Bars    []*types.DataPoint // 与Symbols一一对应，缺失未填充或不在股票池内时为nil
+	Status  []BarStatus
+	index   map[string]int // 股票代码在Symbols中的下标，Align生成的面板共用
+}
+
+// Bar 返回股票当日的数据和状态，不在面板中的股票视为不在股票池内
+func (p *Panel) Bar(symbol string) (*types.DataPoint, BarStatus) {
+	if p.index == nil {
+		p.index = symbolIndex(p.Symbols)
+	}
+	if i, ok := p.index[symbol]; ok {
+		return p.Bars[i], p.Status[i]
+	}
+	return nil, BarNotListed
+}
+
+// symbolIndex 股票代码到下标的映射，重复的代码取第一个
+func symbolIndex(symbols []string) map[string]int {
+	index := make(map[string]int, len(symbols))
+	for i, symbol := range symbols {
+		if _, exists := index[symbol]; !exists {
+			index[symbol] = i
+		}
+	}
+	return index
+}
+
+// Traded 返回当日有K线的数据，按Symbols顺序排列
+func (p *Panel) Traded() []*types.DataPoint {
+	var bars []*types.DataPoint
+	for i, status := range p.Status {
+		if status == BarNormal {
+			bars = append(bars, p.Bars[i])
+		}
+	}
+	return bars
+}
+
+// Align 将各股票的K线按交易日对齐为面板序列。
+// 股票池u为nil时所有股票始终在池内；不在池内的K线被忽略，在池内但缺失的K线按fill填充
+func Align(symbols []string, data map[string][]*types.DataPoint, timestamps []time.Time, u *Universe, fill FillPolicy) []*Panel {
+	bySymbol := make([]map[time.Time]*types.DataPoint, len(symbols))
+	for i, symbol := range symbols {
+		bySymbol[i] = make(map[time.Time]*types.DataPoint, len(data[symbol]))
+		for _, d := range data[symbol] {
+			if _, exists := bySymbol[i][d.Timestamp]; !exists {
+				bySymbol[i][d.Timestamp] = d
+			}
+		}
+	}
+
+	index := symbolIndex(symbols)
+	last := make([]*types.DataPoint, len(symbols))
+	gap := make([]int, len(symbols))
+	panels := make([]*Panel, len(timestamps))
+	for t, timestamp := range timestamps {
+		panel := &Panel{
+			Time:    timestamp,
+			Symbols: symbols,
+			Bars:    make([]*types.DataPoint, len(symbols)),
+			Status:  make([]BarStatus, len(symbols)),
+			index:   index,
+		}
+		for i, symbol := range symbols {
+			if !u.Contains(symbol, timestamp) {
+				panel.Status[i] = BarNotListed
+				continue
+			}
+			if bar, ok := bySymbol[i][timestamp]; ok {
+				panel.Bars[i] = bar
+				last[i], gap[i] = bar, 0
+				continue
+			}
+			gap[i]++
+			panel.Status[i] = BarMissing
+			if fill.Forward && last[i] != nil && (fill.Limit <= 0 || gap[i] <= fill.Limit) {
+				close := last[i].Close
+				// 复制指标，策略修改填充K线的指标不影响原始K线
+				indicators := make(map[string]float64, len(last[i].Indicators))
+				for name, value := range last[i].Indicators {
+					indicators[name] = value
+				}
+				panel.Bars[i] = &types.DataPoint{
+					Symbol:     symbol,
+					Timestamp:  timestamp,
+					Open:       close,
+					High:       close,
+					Low:        close,
+					Close:      close,
+					Indicators: indicators,
+				}
+				panel.Status[i] = BarFilled
+			}
+		}
+		panels[t] = panel
+	}
+	return panels
+}
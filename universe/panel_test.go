@@ -0,0 +1,56 @@
+package universe
+
+import (
+	"testing"
+	"time"
+
+	"stock/common/types"
+)
+
+func TestAlignForwardFill(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	data := map[string][]*types.DataPoint{
+		"600000.SH": {{Symbol: "600000.SH", Timestamp: day(2), Close: 10, Volume: 100, Indicators: map[string]float64{"MA5": 9.5}}},
+	}
+	timestamps := []time.Time{day(2), day(3), day(4)}
+	panels := Align([]string{"600000.SH"}, data, timestamps, nil, FillPolicy{Forward: true, Limit: 1})
+
+	bar, status := panels[1].Bar("600000.SH")
+	if status != BarFilled || bar.Close != 10 || bar.Volume != 0 {
+		t.Fatalf("停牌首日应按前值填充，得到%v %+v", status, bar)
+	}
+	if bar.Indicators["MA5"] != 9.5 {
+		t.Errorf("填充K线的指标%v，期望沿用上一根K线", bar.Indicators)
+	}
+	bar.Indicators["MA5"] = 0
+	if data["600000.SH"][0].Indicators["MA5"] != 9.5 {
+		t.Error("修改填充K线的指标不应影响原始K线")
+	}
+	if bar, status := panels[2].Bar("600000.SH"); status != BarMissing || bar != nil {
+		t.Errorf("超过填充天数应为缺失，得到%v %+v", status, bar)
+	}
+}
+
+func TestPanelBar(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	symbols := []string{"600000.SH", "600036.SH", "000001.SZ"}
+	data := make(map[string][]*types.DataPoint)
+	for i, symbol := range symbols {
+		data[symbol] = []*types.DataPoint{{Symbol: symbol, Timestamp: day, Close: float64(i + 1)}}
+	}
+	panels := Align(symbols, data, []time.Time{day}, nil, FillPolicy{})
+	for i, symbol := range symbols {
+		if bar, status := panels[0].Bar(symbol); status != BarNormal || bar.Close != float64(i+1) {
+			t.Errorf("%s: %v %+v", symbol, status, bar)
+		}
+	}
+	if bar, status := panels[0].Bar("688001.SH"); status != BarNotListed || bar != nil {
+		t.Errorf("不在面板中的股票应视为不在股票池内，得到%v %+v", status, bar)
+	}
+
+	// 直接构造的面板同样可以查找
+	panel := &Panel{Time: day, Symbols: symbols[:1], Bars: data[symbols[0]], Status: []BarStatus{BarNormal}}
+	if bar, _ := panel.Bar("600000.SH"); bar == nil || bar.Close != 1 {
+		t.Errorf("直接构造的面板查找结果%+v", bar)
+	}
+}
@@ -0,0 +1,140 @@
+package universe
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Listing 股票在股票池中的区间，Start为上市（纳入）日期，End为最后交易日，零值表示不限
+type Listing struct {
+	Symbol string
+	Start  time.Time
+	End    time.Time
+}
+
+// Contains 判断t是否在区间内
+func (l Listing) Contains(t time.Time) bool {
+	day := truncate(t)
+	if !l.Start.IsZero() && day.Before(truncate(l.Start)) {
+		return false
+	}
+	return l.End.IsZero() || !day.After(truncate(l.End))
+}
+
+// Delisted 判断t时是否已经退市
+func (l Listing) Delisted(t time.Time) bool {
+	return !l.End.IsZero() && truncate(t).After(truncate(l.End))
+}
+
+// Universe 随时间变化的股票池，未登记的股票视为始终在池内
+type Universe struct {
+	listings map[string]Listing
+}
+
+// NewUniverse 创建空股票池
+func NewUniverse() *Universe {
+	return &Universe{listings: make(map[string]Listing)}
+}
+
+// Add 登记股票的上市和退市日期，零值表示不限
+func (u *Universe) Add(symbol string, start, end time.Time) {
+	u.listings[symbol] = Listing{Symbol: symbol, Start: start, End: end}
+}
+
+// Listing 返回股票的上市区间
+func (u *Universe) Listing(symbol string) (Listing, bool) {
+	if u == nil {
+		return Listing{}, false
+	}
+	listing, ok := u.listings[symbol]
+	return listing, ok
+}
+
+// Contains 判断t时股票是否在股票池内
+func (u *Universe) Contains(symbol string, t time.Time) bool {
+	listing, ok := u.Listing(symbol)
+	return !ok || listing.Contains(t)
+}
+
+// Symbols 返回登记的全部股票代码
+func (u *Universe) Symbols() []string {
+	if u == nil {
+		return nil
+	}
+	symbols := make([]string, 0, len(u.listings))
+	for symbol := range u.listings {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Delistings 返回有退市日期的股票，按退市日期排序
+func (u *Universe) Delistings() []Listing {
+	var delistings []Listing
+	for _, symbol := range u.Symbols() {
+		if listing := u.listings[symbol]; !listing.End.IsZero() {
+			delistings = append(delistings, listing)
+		}
+	}
+	sort.SliceStable(delistings, func(i, j int) bool {
+		return delistings[i].End.Before(delistings[j].End)
+	})
+	return delistings
+}
+
+// LoadFile 从CSV文件读取股票池，表头为symbol,start,end，日期格式2006-01-02，留空表示不限
+func LoadFile(path string) (*Universe, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开股票池文件失败: %v", err)
+	}
+	defer file.Close()
+	return Load(file)
+}
+
+// Load 从CSV读取股票池
+func Load(r io.Reader) (*Universe, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	if _, err := reader.Read(); err != nil {
+		return nil, fmt.Errorf("读取股票池表头失败: %v", err)
+	}
+	u := NewUniverse()
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取股票池失败: %v", err)
+		}
+		if len(record) == 0 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		var dates [2]time.Time
+		for i := range dates {
+			if i+1 >= len(record) {
+				break
+			}
+			value := strings.TrimSpace(record[i+1])
+			if value == "" {
+				continue
+			}
+			if dates[i], err = time.Parse("2006-01-02", value); err != nil {
+				return nil, fmt.Errorf("第%d行日期格式错误: %s", line, value)
+			}
+		}
+		u.Add(strings.TrimSpace(record[0]), dates[0], dates[1])
+	}
+	return u, nil
+}
+
+func truncate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -1,7 +1,9 @@
 package visualization
 
 import (
-	"os"
+	"fmt"
+	"math"
+	"sort"
 	"stock/common/types"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
@@ -10,13 +12,59 @@ import (
 )
 
 type Chart struct {
-	title string
+	title    string
+	overlays []Overlay
+}
+
+// Overlay 指标叠加层
+type Overlay struct {
+	Name         string
+	Series       map[string][]float64 // 输出名到与K线等长的指标序列
+	PriceOverlay bool                 // true叠加在K线主图上，false单独绘制指标图
 }
 
 func NewChart(title string) *Chart {
 	return &Chart{title: title}
 }
 
+// AddOverlay 添加指标叠加层
+func (c *Chart) AddOverlay(overlay Overlay) {
+	c.overlays = append(c.overlays, overlay)
+}
+
+// lineData 将指标序列转换为折线数据，NaN显示为空缺
+func lineData(values []float64) []opts.LineData {
+	data := make([]opts.LineData, len(values))
+	for i, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			data[i] = opts.LineData{Value: "-"}
+		} else {
+			data[i] = opts.LineData{Value: float32(v)}
+		}
+	}
+	return data
+}
+
+// overlayLine 生成叠加层折线图，序列按名称排序
+func overlayLine(x []string, overlay Overlay) *charts.Line {
+	line := charts.NewLine()
+	names := make([]string, 0, len(overlay.Series))
+	for name := range overlay.Series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	line.SetXAxis(x)
+	for _, name := range names {
+		seriesName := overlay.Name
+		if len(names) > 1 {
+			seriesName = overlay.Name + "." + name
+		}
+		line.AddSeries(seriesName, lineData(overlay.Series[name]))
+	}
+	return line
+}
+
 func (c *Chart) PlotCandlestick(data []types.Candle, tradesMap map[string][]types.Trade, outputFile string) error {
 	// 创建页面
 	page := components.NewPage()
@@ -27,6 +75,7 @@ func (c *Chart) PlotCandlestick(data []types.Candle, tradesMap map[string][]type
 	// 创建交易量柱状图
 	volume := charts.NewBar()
 	volume.SetGlobalOptions(
+		chartID("volume"),
 		charts.WithTitleOpts(opts.Title{
 			Title: "交易量",
 			Left:  "center",
@@ -49,6 +98,7 @@ func (c *Chart) PlotCandlestick(data []types.Candle, tradesMap map[string][]type
 	// 创建MACD图表
 	macdChart := charts.NewLine()
 	macdChart.SetGlobalOptions(
+		chartID("macd"),
 		charts.WithTitleOpts(opts.Title{
 			Title: "MACD",
 			Left:  "center",
@@ -68,6 +118,7 @@ func (c *Chart) PlotCandlestick(data []types.Candle, tradesMap map[string][]type
 	// 创建RSI图表
 	rsiChart := charts.NewLine()
 	rsiChart.SetGlobalOptions(
+		chartID("rsi"),
 		charts.WithTitleOpts(opts.Title{
 			Title: "RSI",
 			Left:  "center",
@@ -165,7 +216,13 @@ func (c *Chart) PlotCandlestick(data []types.Candle, tradesMap map[string][]type
 	legendData := []string{"K线"}
 	scatterSeries := make([]*charts.Scatter, 0)
 
-	for strategyName, trades := range tradesMap {
+	strategyNames := make([]string, 0, len(tradesMap))
+	for strategyName := range tradesMap {
+		strategyNames = append(strategyNames, strategyName)
+	}
+	sort.Strings(strategyNames)
+	for _, strategyName := range strategyNames {
+		trades := tradesMap[strategyName]
 		//color := colors[len(scatterSeries)%len(colors)]
 		buyPoints := make([]opts.ScatterData, 0)
 		sellPoints := make([]opts.ScatterData, 0)
@@ -190,6 +247,7 @@ func (c *Chart) PlotCandlestick(data []types.Candle, tradesMap map[string][]type
 
 		// 创建散点图用于买卖点
 		scatter := charts.NewScatter()
+		scatter.SetGlobalOptions(chartID(fmt.Sprintf("trades%d", len(scatterSeries)+1)))
 		scatter.SetXAxis(x).
 			AddSeries(strategyName+" 买入", buyPoints).
 			AddSeries(strategyName+" 卖出", sellPoints)
@@ -239,9 +297,10 @@ func (c *Chart) PlotCandlestick(data []types.Candle, tradesMap map[string][]type
 			SplitNumber: 7,
 		}),
 		charts.WithInitializationOpts(opts.Initialization{
-			Width:  "100%",
-			Height: "800px",
-			Theme:  "light",
+			ChartID: "kline",
+			Width:   "100%",
+			Height:  "800px",
+			Theme:   "light",
 		}),
 	)
 
@@ -265,10 +324,36 @@ func (c *Chart) PlotCandlestick(data []types.Candle, tradesMap map[string][]type
 		}),
 	)
 
+	// 叠加指标
+	indicatorCharts := make([]components.Charter, 0)
+	for _, overlay := range c.overlays {
+		line := overlayLine(x, overlay)
+		if overlay.PriceOverlay {
+			kline.Overlap(line)
+			continue
+		}
+		line.SetGlobalOptions(
+			chartID(fmt.Sprintf("indicator%d", len(indicatorCharts)+1)),
+			charts.WithTitleOpts(opts.Title{
+				Title: overlay.Name,
+				Left:  "center",
+			}),
+			charts.WithXAxisOpts(opts.XAxis{
+				Name: "日期",
+				Type: "category",
+				AxisLabel: &opts.AxisLabel{
+					Rotate: 45,
+				},
+			}),
+		)
+		indicatorCharts = append(indicatorCharts, line)
+	}
+
 	volume.SetXAxis(x).AddSeries("交易量", volumeData)
 	// 创建MACD柱状图
 	macdBar := charts.NewBar()
 	macdBar.SetGlobalOptions(
+		chartID("macd_bar"),
 		charts.WithTitleOpts(opts.Title{
 			Title: "MACD柱状图",
 			Left:  "center",
@@ -293,18 +378,12 @@ func (c *Chart) PlotCandlestick(data []types.Candle, tradesMap map[string][]type
 
 	// 组合图表
 	chartsToAdd := []components.Charter{kline, volume, macdBar, macdChart, rsiChart}
+	chartsToAdd = append(chartsToAdd, indicatorCharts...)
 	for _, scatter := range scatterSeries {
 		chartsToAdd = append(chartsToAdd, scatter)
 	}
 	page.AddCharts(chartsToAdd...)
 
 	// 保存图表
-	f, err := os.Create(outputFile)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	// 使用HTML渲染器
-	return page.Render(f)
+	return savePage(page, outputFile)
 }
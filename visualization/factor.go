@@ -0,0 +1,103 @@
+package visualization
+
+import (
+	"fmt"
+	"math"
+	"stock/factor"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// barData 将序列转换为柱状图数据，NaN显示为空缺
+func barData(values []float64) []opts.BarData {
+	data := make([]opts.BarData, len(values))
+	for i, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			data[i] = opts.BarData{Value: "-"}
+		} else {
+			data[i] = opts.BarData{Value: float32(v)}
+		}
+	}
+	return data
+}
+
+// seriesOptions 因子分析图表的公共选项
+func seriesOptions(title string) []charts.GlobalOpts {
+	return []charts.GlobalOpts{
+		charts.WithTitleOpts(opts.Title{Title: title, Left: "center"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		charts.WithLegendOpts(opts.Legend{Show: true, Top: "30px"}),
+		charts.WithXAxisOpts(opts.XAxis{
+			Type:      "category",
+			AxisLabel: &opts.AxisLabel{Rotate: 45},
+		}),
+		charts.WithDataZoomOpts(opts.DataZoom{Type: "slider", Start: 0, End: 100}),
+	}
+}
+
+// PlotFactorReport 绘制因子分析报告：IC、累计IC、分位组收益、多空净值、自相关和换手率
+func PlotFactorReport(report *factor.Report, outputFile string) error {
+	page := components.NewPage()
+	page.PageTitle = report.Name + " 因子分析"
+
+	x := make([]string, len(report.Dates))
+	for i, date := range report.Dates {
+		x[i] = date.Format("2006-01-02")
+	}
+	groups := make([]string, report.Quantiles)
+	for q := range groups {
+		groups[q] = fmt.Sprintf("Q%d", q+1)
+	}
+
+	for _, h := range report.Horizons {
+		suffix := fmt.Sprintf("（%d日）", h.Horizon)
+
+		ic := charts.NewBar()
+		ic.SetGlobalOptions(append(seriesOptions(fmt.Sprintf("%s IC%s 均值%.4f IR%.2f",
+			report.Name, suffix, h.RankICStats.Mean, h.RankICStats.IR)),
+			chartID(fmt.Sprintf("ic%d", h.Horizon)))...)
+		ic.SetXAxis(x).
+			AddSeries("IC", barData(h.IC)).
+			AddSeries("Rank IC", barData(h.RankIC))
+		cumIC := charts.NewLine()
+		cumIC.SetXAxis(x).
+			AddSeries("累计IC", lineData(factor.CumulativeSum(h.IC))).
+			AddSeries("累计Rank IC", lineData(factor.CumulativeSum(h.RankIC)))
+		ic.Overlap(cumIC)
+
+		mean := charts.NewBar()
+		mean.SetGlobalOptions(
+			chartID(fmt.Sprintf("quantile_mean%d", h.Horizon)),
+			charts.WithTitleOpts(opts.Title{Title: "分位组平均收益" + suffix, Left: "center"}),
+			charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		)
+		mean.SetXAxis(groups).AddSeries("平均收益", barData(h.QuantileMean))
+
+		cumulative := charts.NewLine()
+		cumulative.SetGlobalOptions(append(seriesOptions("分位组累计净值"+suffix),
+			chartID(fmt.Sprintf("quantile_nav%d", h.Horizon)))...)
+		cumulative.SetXAxis(x)
+		for q, returns := range h.QuantileReturns {
+			cumulative.AddSeries(groups[q], lineData(factor.Cumulative(returns, h.Horizon)))
+		}
+		cumulative.AddSeries("多空", lineData(factor.Cumulative(h.Spread, h.Horizon)))
+
+		page.AddCharts(ic, mean, cumulative)
+	}
+
+	stability := charts.NewLine()
+	stability.SetGlobalOptions(append(seriesOptions("因子自相关与换手率"), chartID("stability"))...)
+	stability.SetXAxis(x).AddSeries("自相关", lineData(report.Autocorrelation))
+	if len(report.Turnover) > 0 {
+		last := len(report.Turnover) - 1
+		stability.AddSeries(groups[0]+"换手率", lineData(report.Turnover[0]))
+		if last > 0 {
+			stability.AddSeries(groups[last]+"换手率", lineData(report.Turnover[last]))
+		}
+	}
+	page.AddCharts(stability)
+
+	return savePage(page, outputFile)
+}
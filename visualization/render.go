@@ -0,0 +1,26 @@
+package visualization
+
+import (
+	"os"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// chartID 指定图表ID。go-echarts默认为每个图表生成随机ID，
+// 页面中的图表都使用固定ID，相同数据两次生成的文件完全相同
+func chartID(id string) charts.GlobalOpts {
+	return charts.WithInitializationOpts(opts.Initialization{ChartID: id})
+}
+
+// savePage 将页面写入HTML文件
+func savePage(page *components.Page, outputFile string) error {
+	page.ChartID = "page"
+	f, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return page.Render(f)
+}
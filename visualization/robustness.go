@@ -0,0 +1,63 @@
+package visualization
+
+import (
+	"fmt"
+	"stock/robustness"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/components"
+	"github.com/go-echarts/go-echarts/v2/opts"
+)
+
+// PlotRobustness 绘制稳健性分析：各重抽样方法的权益扇形图、最终资产和最大回撤分布
+func PlotRobustness(results []*robustness.Result, outputFile string) error {
+	page := components.NewPage()
+	page.PageTitle = "稳健性分析"
+
+	for n, result := range results {
+		x := make([]string, len(result.Path))
+		for i := range x {
+			x[i] = fmt.Sprint(i)
+		}
+		fan := charts.NewLine()
+		fan.SetGlobalOptions(append(seriesOptions(fmt.Sprintf("%s 权益扇形图（%d次模拟，破产概率%.2f%%）",
+			result.Method, len(result.Samples), result.RiskOfRuin*100)),
+			chartID(fmt.Sprintf("fan%d", n+1)))...)
+		fan.SetXAxis(x)
+		for i, p := range result.Fan.Percentiles {
+			fan.AddSeries(fmt.Sprintf("P%.0f", p*100), lineData(result.Fan.Bands[i]))
+		}
+		fan.AddSeries("原始", lineData(result.Path))
+
+		lo, hi := result.FinalValue.Interval(0.95)
+		final := histogram(fmt.Sprintf("final%d", n+1), fmt.Sprintf("%s 最终资产分布 95%%区间[%.0f, %.0f] 原始%.0f",
+			result.Method, lo, hi, result.Observed.FinalValue), result.FinalValue, "%.0f")
+		lo, hi = result.MaxDrawdown.Interval(0.95)
+		drawdown := histogram(fmt.Sprintf("drawdown%d", n+1), fmt.Sprintf("%s 最大回撤分布 95%%区间[%.2f%%, %.2f%%] 原始%.2f%%",
+			result.Method, lo*100, hi*100, result.Observed.MaxDrawdown*100), result.MaxDrawdown, "%.3f")
+
+		page.AddCharts(fan, final, drawdown)
+	}
+
+	return savePage(page, outputFile)
+}
+
+// histogram 将分布分为30组绘制柱状图
+func histogram(id, title string, d robustness.Distribution, format string) *charts.Bar {
+	edges, counts := d.Histogram(30)
+	x := make([]string, len(edges))
+	values := make([]float64, len(counts))
+	for i, edge := range edges {
+		x[i] = fmt.Sprintf(format, edge)
+		values[i] = float64(counts[i])
+	}
+	bar := charts.NewBar()
+	bar.SetGlobalOptions(
+		chartID(id),
+		charts.WithTitleOpts(opts.Title{Title: title, Left: "center"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		charts.WithXAxisOpts(opts.XAxis{Type: "category", AxisLabel: &opts.AxisLabel{Rotate: 45}}),
+	)
+	bar.SetXAxis(x).AddSeries("次数", barData(values))
+	return bar
+}